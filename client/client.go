@@ -0,0 +1,610 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package client implements a Go client of occamy. It logs in and
+// connects to an occamy server, sends user input and delivers the
+// decoded server instructions, or maintains an in-memory framebuffer
+// of the remote desktop, hence it can be used for monitoring probes,
+// scripted UI tests and load tests.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"github.com/gorilla/websocket"
+)
+
+// Errors of the client
+var (
+	ErrLogin  = errors.New("client: login failed")
+	ErrClosed = errors.New("client: connection closed")
+)
+
+// errDisconnected terminates a connection disconnected by the server
+var errDisconnected = errors.New("client: disconnected")
+
+// BlobSize is the maximum number of bytes sent per blob instruction
+const BlobSize = 6048
+
+// ServerError is an error reported by the server, either by the error
+// instruction or by a failed ack.
+type ServerError struct {
+	Status  Status
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("client: server error: %s (%v)", e.Message, e.Status)
+}
+
+// Credentials are the parameters of a remote desktop connection, they
+// are exchanged for a token by the login API.
+type Credentials struct {
+	Protocol string `json:"protocol"`
+	Host     string `json:"host"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Options are the options of a client connection, zero values are
+// replaced by the defaults of the server.
+type Options struct {
+	// Width, Height and DPI are the optimal display size and resolution.
+	Width, Height, DPI int
+	// Timezone is the timezone of the client, e.g. Europe/Berlin.
+	Timezone string
+	// Image are the supported image mimetypes, image/png and image/jpeg
+	// by default.
+	Image []string
+	// Version is the protocol version of the client, e.g. VERSION_1_1_0,
+	// which is required to receive the required instruction.
+	Version string
+	// Terminal requests the terminal mode of Go terminal protocols, e.g.
+	// "vt" to receive the raw output of the remote program by a pipe
+	// stream named STDOUT in place of the display. Width and Height are
+	// then given in columns and rows.
+	Terminal string
+	// Framebuffer enables the in-memory framebuffer of the default layer.
+	Framebuffer bool
+	// Discard drops the server instructions instead of delivering them
+	// to Messages, e.g. if only the framebuffer is of interest.
+	Discard bool
+	// Buffer is the capacity of the Messages channel, 64 by default.
+	Buffer int
+	// Dialer is the websocket dialer, websocket.DefaultDialer by default.
+	Dialer *websocket.Dialer
+}
+
+// Client is a connection to an occamy server. All methods are safe
+// for concurrent use.
+type Client struct {
+	received     uint64 // bytes received, accessed atomically
+	instructions uint64 // instructions received, accessed atomically
+	images       uint64 // bytes of images received, accessed atomically
+	copies       uint64 // copy instructions received, accessed atomically
+
+	imgStreams map[int]bool // open image streams, only used by serve
+
+	ws   *websocket.Conn
+	opts Options
+	fb   *Framebuffer
+	msgs chan Message
+
+	wmu     sync.Mutex // serializes writes to ws
+	smu     sync.Mutex // protects streams
+	streams map[int]chan *Ack
+
+	once    sync.Once
+	closing chan struct{} // closed by Close
+	done    chan struct{} // closed when the connection terminated
+	err     error         // valid after done is closed
+}
+
+// Login exchanges the given credentials for a token from the occamy
+// server at addr, e.g. http://0.0.0.0:5636. The server must enable
+// the login API.
+func Login(ctx context.Context, addr string, cred Credentials) (string, error) {
+	b, err := json.Marshal(cred)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrLogin, err)
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(addr, "/")+"/api/v1/login", bytes.NewReader(b))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrLogin, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrLogin, err)
+	}
+	defer resp.Body.Close()
+
+	d, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrLogin, err)
+	}
+	var out struct {
+		Token   string `json:"token"`
+		Message string `json:"message"`
+	}
+	err = json.Unmarshal(d, &out)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrLogin, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK || out.Token == "" {
+		return "", fmt.Errorf("%w: %s %s", ErrLogin, resp.Status, out.Message)
+	}
+	return out.Token, nil
+}
+
+// Connect logs in with the given credentials and connects to the
+// occamy server at addr.
+func Connect(ctx context.Context, addr string, cred Credentials, opts *Options) (*Client, error) {
+	token, err := Login(ctx, addr, cred)
+	if err != nil {
+		return nil, err
+	}
+	return Dial(ctx, addr, token, opts)
+}
+
+// Dial connects to the occamy server at addr using the given token.
+// It returns once the handshake is done and the first instruction of
+// the remote desktop is received.
+func Dial(ctx context.Context, addr, token string, opts *Options) (*Client, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	o := *opts
+	if o.Buffer <= 0 {
+		o.Buffer = 64
+	}
+	if o.Dialer == nil {
+		o.Dialer = websocket.DefaultDialer
+	}
+
+	u, err := connectURL(addr, token, &o)
+	if err != nil {
+		return nil, err
+	}
+	dialer := *o.Dialer
+	dialer.Subprotocols = []string{"guacamole"}
+	ws, _, err := dialer.DialContext(ctx, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("client: dial failed: %w", err)
+	}
+
+	c := &Client{
+		ws:         ws,
+		opts:       o,
+		msgs:       make(chan Message, o.Buffer),
+		streams:    make(map[int]chan *Ack),
+		imgStreams: make(map[int]bool),
+		closing:    make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if o.Framebuffer {
+		c.fb = newFramebuffer()
+	}
+
+	first := make(chan struct{})
+	go c.serve(first)
+	select {
+	case <-first:
+		return c, nil
+	case <-c.done:
+		err = c.Err()
+		if err == nil {
+			err = ErrClosed
+		}
+		return nil, err
+	case <-ctx.Done():
+		c.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// connectURL builds the websocket URL of the connect API, the user
+// information is given as query parameters.
+func connectURL(addr, token string, o *Options) (string, error) {
+	u, err := url.Parse(strings.TrimSuffix(addr, "/") + "/api/v1/connect")
+	if err != nil {
+		return "", fmt.Errorf("client: invalid address: %w", err)
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+
+	q := url.Values{}
+	q.Set("token", token)
+	if o.Version != "" {
+		q.Set("GUAC_VERSION", o.Version)
+	}
+	if o.Width > 0 && o.Height > 0 {
+		q.Set("GUAC_WIDTH", strconv.Itoa(o.Width))
+		q.Set("GUAC_HEIGHT", strconv.Itoa(o.Height))
+	}
+	if o.DPI > 0 {
+		q.Set("GUAC_DPI", strconv.Itoa(o.DPI))
+	}
+	if o.Timezone != "" {
+		q.Set("GUAC_TIMEZONE", o.Timezone)
+	}
+	image := o.Image
+	if len(image) == 0 {
+		image = []string{"image/png", "image/jpeg"}
+	}
+	for _, mimetype := range image {
+		q.Add("GUAC_IMAGE", mimetype)
+	}
+	if o.Terminal != "" {
+		q.Set("GUAC_TERMINAL", o.Terminal)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Messages returns the channel of decoded server instructions. The
+// channel must be drained unless Options.Discard is set, and it is
+// closed once the connection is terminated.
+func (c *Client) Messages() <-chan Message {
+	return c.msgs
+}
+
+// Framebuffer returns the framebuffer of the remote desktop, or nil if
+// Options.Framebuffer is not set.
+func (c *Client) Framebuffer() *Framebuffer {
+	return c.fb
+}
+
+// Done returns a channel that is closed once the connection is
+// terminated.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the reason of the terminated connection. It is nil if
+// the connection is alive, closed by Close or disconnected by the
+// server.
+func (c *Client) Err() error {
+	select {
+	case <-c.done:
+		if c.err == errDisconnected || c.err == ErrClosed {
+			return nil
+		}
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Received returns the number of bytes and instructions received from
+// the server.
+func (c *Client) Received() (bytes, instructions uint64) {
+	return atomic.LoadUint64(&c.received), atomic.LoadUint64(&c.instructions)
+}
+
+// Drawn returns the number of bytes of images, i.e. the img, png, blob
+// and end instructions of image streams, and the number of copy
+// instructions received from the server. Together with Received, it
+// tells how much of the bandwidth is spent on drawing.
+func (c *Client) Drawn() (imageBytes, copies uint64) {
+	return atomic.LoadUint64(&c.images), atomic.LoadUint64(&c.copies)
+}
+
+// Close disconnects from the server and releases the connection.
+func (c *Client) Close() error {
+	c.once.Do(func() {
+		c.write(protocol.NewInstruction([]string{"disconnect"}))
+		close(c.closing)
+		c.ws.Close()
+	})
+	<-c.done
+	return nil
+}
+
+// Send sends the given message to the server.
+func (c *Client) Send(m Message) error {
+	return c.write(m.Encode())
+}
+
+// SendKey sends a pressed or released X11 keysym.
+func (c *Client) SendKey(keysym int, pressed bool) error {
+	return c.Send(&Key{Keysym: keysym, Pressed: pressed})
+}
+
+// SendMouse sends the mouse position and the pressed buttons as a
+// combination of Mouse button masks.
+func (c *Client) SendMouse(x, y, mask int) error {
+	return c.Send(&Mouse{X: x, Y: y, Mask: mask})
+}
+
+// SendSize requests the given optimal display size.
+func (c *Client) SendSize(width, height int) error {
+	return c.Send(&Size{Width: width, Height: height})
+}
+
+// SendClipboard sets the clipboard of the remote desktop.
+func (c *Client) SendClipboard(mimetype string, data []byte) error {
+	index, _ := c.openStream()
+	defer c.closeStream(index)
+
+	err := c.Send(&Clipboard{Stream: index, Mimetype: mimetype})
+	if err != nil {
+		return err
+	}
+	for len(data) > 0 {
+		n := len(data)
+		if n > BlobSize {
+			n = BlobSize
+		}
+		err = c.Send(&Blob{Stream: index, Data: data[:n]})
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return c.Send(&End{Stream: index})
+}
+
+// SendInput sends raw terminal input, which is read by terminal
+// protocols from users in the vt mode, see Options.Terminal.
+func (c *Client) SendInput(data []byte) error {
+	index, _ := c.openStream()
+	defer c.closeStream(index)
+
+	err := c.Send(&Pipe{Stream: index, Mimetype: "application/octet-stream", Name: "STDIN"})
+	if err != nil {
+		return err
+	}
+	for len(data) > 0 {
+		n := len(data)
+		if n > BlobSize {
+			n = BlobSize
+		}
+		err = c.Send(&Blob{Stream: index, Data: data[:n]})
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return c.Send(&End{Stream: index})
+}
+
+// SendArgument updates the value of the named connection parameter,
+// e.g. in response to the required instruction.
+func (c *Client) SendArgument(name, value string) error {
+	index, _ := c.openStream()
+	defer c.closeStream(index)
+
+	err := c.Send(&Argv{Stream: index, Mimetype: "text/plain", Name: name})
+	if err != nil {
+		return err
+	}
+	err = c.Send(&Blob{Stream: index, Data: []byte(value)})
+	if err != nil {
+		return err
+	}
+	return c.Send(&End{Stream: index})
+}
+
+// UploadFile uploads a file to the remote desktop. Every chunk of the
+// file waits for the acknowledgement of the server.
+func (c *Client) UploadFile(ctx context.Context, mimetype, filename string, r io.Reader) error {
+	index, acks := c.openStream()
+	defer c.closeStream(index)
+
+	err := c.Send(&File{Stream: index, Mimetype: mimetype, Filename: filename})
+	if err != nil {
+		return err
+	}
+	err = c.waitAck(ctx, acks)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, BlobSize)
+	for {
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			err = c.Send(&Blob{Stream: index, Data: buf[:n]})
+			if err != nil {
+				return err
+			}
+			err = c.waitAck(ctx, acks)
+			if err != nil {
+				return err
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			c.Send(&End{Stream: index})
+			return rerr
+		}
+	}
+	return c.Send(&End{Stream: index})
+}
+
+func (c *Client) write(ins *Instruction) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	select {
+	case <-c.done:
+		return ErrClosed
+	default:
+	}
+	return c.ws.WriteMessage(websocket.TextMessage, []byte(ins.String()))
+}
+
+// openStream allocates the lowest unused index of an output stream.
+func (c *Client) openStream() (int, chan *Ack) {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	index := 0
+	for {
+		if _, ok := c.streams[index]; !ok {
+			break
+		}
+		index++
+	}
+	acks := make(chan *Ack, 1)
+	c.streams[index] = acks
+	return index, acks
+}
+
+func (c *Client) closeStream(index int) {
+	c.smu.Lock()
+	delete(c.streams, index)
+	c.smu.Unlock()
+}
+
+func (c *Client) waitAck(ctx context.Context, acks <-chan *Ack) error {
+	select {
+	case a := <-acks:
+		if a.Status != protocol.StatusSuccess {
+			return &ServerError{Status: a.Status, Message: a.Message}
+		}
+		return nil
+	case <-c.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// serve reads the server instructions until the connection is
+// terminated, first is closed once the first instruction is handled.
+func (c *Client) serve(first chan struct{}) {
+	defer close(c.msgs)
+
+	p := protocol.NewParser()
+	var err error
+	for err == nil {
+		var buf []byte
+		_, buf, err = c.ws.ReadMessage()
+		atomic.AddUint64(&c.received, uint64(len(buf)))
+		for err == nil && len(buf) > 0 {
+			var n int
+			n, err = p.Append(buf)
+			buf = buf[n:]
+			if err != nil || p.State() != protocol.ParserStateComplete {
+				break // an incomplete instruction continues with the next message
+			}
+			err = c.handle(p.Elements())
+			if first != nil {
+				close(first)
+				first = nil
+			}
+		}
+	}
+
+	select {
+	case <-c.closing:
+		err = ErrClosed
+	default:
+	}
+	c.wmu.Lock()
+	c.err = err
+	close(c.done)
+	c.wmu.Unlock()
+	c.ws.Close()
+}
+
+// handle handles a server instruction and delivers its message.
+func (c *Client) handle(elements [][]byte) error {
+	strs := make([]string, len(elements))
+	for i := range elements {
+		strs[i] = string(elements[i])
+	}
+	ins := protocol.NewInstruction(strs)
+	atomic.AddUint64(&c.instructions, 1)
+	if ins.Opcode() == "" {
+		return nil // internal instruction of the tunnel
+	}
+
+	m, err := protocol.Decode(ins)
+	if errors.Is(err, protocol.ErrMessageUnknown) {
+		m = &Raw{}
+		err = m.Decode(ins)
+	}
+	if err != nil {
+		return err
+	}
+
+	var term error
+	switch m := m.(type) {
+	case *Img:
+		c.imgStreams[m.Stream] = true
+		atomic.AddUint64(&c.images, instructionSize(elements))
+	case *PNG:
+		atomic.AddUint64(&c.images, instructionSize(elements))
+	case *Blob:
+		if c.imgStreams[m.Stream] {
+			atomic.AddUint64(&c.images, instructionSize(elements))
+		}
+	case *End:
+		if c.imgStreams[m.Stream] {
+			delete(c.imgStreams, m.Stream)
+			atomic.AddUint64(&c.images, instructionSize(elements))
+		}
+	case *Copy:
+		atomic.AddUint64(&c.copies, 1)
+	case *Sync:
+		err = c.Send(m) // the frame is handled
+		if err != nil {
+			return err
+		}
+	case *Ack:
+		c.smu.Lock()
+		if acks, ok := c.streams[m.Stream]; ok {
+			select {
+			case acks <- m:
+			default:
+			}
+		}
+		c.smu.Unlock()
+	case *Error:
+		term = &ServerError{Status: m.Status, Message: m.Message}
+	case *Disconnect:
+		term = errDisconnected
+	}
+	if c.fb != nil {
+		c.fb.apply(m)
+	}
+	if !c.opts.Discard {
+		select {
+		case c.msgs <- m:
+		case <-c.closing:
+			return ErrClosed
+		}
+	}
+	return term
+}
+
+// instructionSize returns the encoded size of an instruction of the
+// given elements.
+func instructionSize(elements [][]byte) uint64 {
+	size := 0
+	for _, e := range elements {
+		size += len(strconv.Itoa(len(e))) + len(e) + 2 // length, dot and separator
+	}
+	return uint64(size)
+}
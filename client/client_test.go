@@ -0,0 +1,188 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/client"
+	"changkun.de/x/occamy/internal/protocol"
+	"github.com/gorilla/websocket"
+)
+
+// fakeServer serves the login and connect APIs, the connect API sends
+// the given script and reports all received instructions.
+func fakeServer(t *testing.T, script []string, received chan<- string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/login", func(w http.ResponseWriter, r *http.Request) {
+		var cred client.Credentials
+		json.NewDecoder(r.Body).Decode(&cred)
+		if cred.Password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":401,"message":"incorrect Username or Password"}`))
+			return
+		}
+		w.Write([]byte(`{"code":200,"token":"token"}`))
+	})
+	mux.HandleFunc("/api/v1/connect", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") != "token" || r.URL.Query().Get("GUAC_WIDTH") != "8" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		up := websocket.Upgrader{Subprotocols: []string{"guacamole"}}
+		ws, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for _, s := range script {
+			ws.WriteMessage(websocket.TextMessage, []byte(s))
+		}
+		for {
+			_, data, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- string(data)
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func pngBase64(t *testing.T, c color.Color) string {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for x := 0; x < 2; x++ {
+		for y := 0; y < 2; y++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		t.Fatalf("encode png error: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func encode(m protocol.Message) string {
+	return m.Encode().String()
+}
+
+func TestClient(t *testing.T) {
+	red := color.RGBA{255, 0, 0, 255}
+	data := pngBase64(t, red)
+	script := []string{
+		"4.size,1.0,1.8,1.8;",
+		"3.img,1.3,2.12,1.0,9.image/png,1.1,1.1;",
+		// an instruction split into two websocket messages
+		"4.blob,1.3," + strconv.Itoa(len(data)) + "." + data[:5],
+		data[5:] + ";3.end,1.3;",
+		"4.copy,1.0,1.0,1.0,1.1,1.1,2.14,1.0,1.0,1.0;",
+		"3.nop;4.sync,3.123;",
+	}
+	received := make(chan string, 16)
+	srv := fakeServer(t, script, received)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := client.Connect(ctx, srv.URL, client.Credentials{Protocol: "vnc", Password: "bad"}, nil)
+	if !errors.Is(err, client.ErrLogin) {
+		t.Fatalf("login with bad credentials should fail, got: %v", err)
+	}
+	c, err := client.Connect(ctx, srv.URL, client.Credentials{Protocol: "vnc", Password: "secret"},
+		&client.Options{Width: 8, Height: 8, Framebuffer: true})
+	if err != nil {
+		t.Fatalf("connect error: %v", err)
+	}
+	defer c.Close()
+
+	var opcodes []string
+	for m := range c.Messages() {
+		opcodes = append(opcodes, m.Opcode())
+		if m.Opcode() == "sync" {
+			break
+		}
+	}
+	want := "size img blob end copy nop sync"
+	if got := strings.Join(opcodes, " "); got != want {
+		t.Fatalf("received messages, want: %s, got: %s", want, got)
+	}
+	images := len(script[1]) + len(script[2]) + len(script[3])
+	if imageBytes, copies := c.Drawn(); imageBytes != uint64(images) || copies != 1 {
+		t.Fatalf("drawn %d bytes of images and %d copies, want %d and 1", imageBytes, copies, images)
+	}
+	if got := <-received; got != "4.sync,3.123;" {
+		t.Fatalf("sync should be replied, got: %s", got)
+	}
+
+	fb := c.Framebuffer()
+	if w, h := fb.Size(); w != 8 || h != 8 || fb.Frames() != 1 {
+		t.Fatalf("framebuffer size %dx%d with %d frames", w, h, fb.Frames())
+	}
+	img := fb.Image()
+	if img.RGBAAt(1, 1) != red || img.RGBAAt(2, 2) != red || img.RGBAAt(0, 0) == red {
+		t.Fatalf("framebuffer is not drawn: %v", img.Pix)
+	}
+
+	c.SendKey(65, true)
+	c.SendMouse(1, 2, client.MouseLeft)
+	c.SendClipboard("text/plain", []byte("hi"))
+	c.SendInput([]byte("hi"))
+	for _, want := range []string{
+		"3.key,2.65,1.1;",
+		"5.mouse,1.1,1.2,1.1;",
+		"9.clipboard,1.0,10.text/plain;",
+		"4.blob,1.0,4.aGk=;",
+		"3.end,1.0;",
+		"4.pipe,1.0,24.application/octet-stream,5.STDIN;",
+		"4.blob,1.0,4.aGk=;",
+		"3.end,1.0;",
+	} {
+		if got := <-received; got != want {
+			t.Fatalf("sent instruction, want: %s, got: %s", want, got)
+		}
+	}
+
+	c.Close()
+	if got := <-received; got != "10.disconnect;" {
+		t.Fatalf("close should disconnect, got: %s", got)
+	}
+	if c.Err() != nil {
+		t.Fatalf("closed client should have no error, got: %v", c.Err())
+	}
+	if err := c.SendKey(65, false); !errors.Is(err, client.ErrClosed) {
+		t.Fatalf("send after close should fail, got: %v", err)
+	}
+}
+
+func TestClient_ServerError(t *testing.T) {
+	received := make(chan string, 16)
+	srv := fakeServer(t, []string{encode(&protocol.Error{Message: "denied", Status: protocol.StatusClientForbidden})}, received)
+	defer srv.Close()
+
+	c, err := client.Dial(context.Background(), srv.URL, "token", &client.Options{Width: 8, Height: 8, Discard: true})
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	<-c.Done()
+	var serr *client.ServerError
+	if !errors.As(c.Err(), &serr) || serr.Status != protocol.StatusClientForbidden {
+		t.Fatalf("error instruction should terminate the connection, got: %v", c.Err())
+	}
+}
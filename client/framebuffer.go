@@ -0,0 +1,138 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg" // decodes jpeg streams
+	_ "image/png"  // decodes png streams
+	"sync"
+
+	"changkun.de/x/occamy/internal/protocol"
+)
+
+// Framebuffer is an in-memory copy of the default layer of the remote
+// desktop, which is the visible display. Other layers, e.g. cursors
+// and off-screen buffers, are ignored.
+type Framebuffer struct {
+	mu      sync.Mutex
+	img     *image.RGBA
+	path    image.Rectangle // current path of rect instructions
+	streams map[int]*imgStream
+	frames  uint64
+}
+
+// imgStream is an img stream that draws to the default layer
+type imgStream struct {
+	mode CompositeMode
+	x, y int
+	data bytes.Buffer
+}
+
+func newFramebuffer() *Framebuffer {
+	return &Framebuffer{
+		img:     image.NewRGBA(image.Rect(0, 0, 0, 0)),
+		streams: make(map[int]*imgStream),
+	}
+}
+
+// Size returns the current size of the display.
+func (f *Framebuffer) Size() (width, height int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b := f.img.Bounds()
+	return b.Dx(), b.Dy()
+}
+
+// Frames returns the number of frames completed by sync instructions.
+func (f *Framebuffer) Frames() uint64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.frames
+}
+
+// Image returns a snapshot of the display.
+func (f *Framebuffer) Image() *image.RGBA {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	img := image.NewRGBA(f.img.Bounds())
+	copy(img.Pix, f.img.Pix)
+	return img
+}
+
+// apply applies the drawing instructions of the default layer.
+func (f *Framebuffer) apply(m Message) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch m := m.(type) {
+	case *LayerSize:
+		if m.Layer == 0 {
+			f.resize(m.Width, m.Height)
+		}
+	case *Img:
+		if m.Layer == 0 {
+			f.streams[m.Stream] = &imgStream{mode: m.Mode, x: m.X, y: m.Y}
+		}
+	case *Blob:
+		if s, ok := f.streams[m.Stream]; ok {
+			s.data.Write(m.Data)
+		}
+	case *End:
+		if s, ok := f.streams[m.Stream]; ok {
+			delete(f.streams, m.Stream)
+			f.drawImage(s.data.Bytes(), s.mode, s.x, s.y)
+		}
+	case *PNG:
+		if m.Layer == 0 {
+			f.drawImage(m.Data, m.Mode, m.X, m.Y)
+		}
+	case *Rect:
+		if m.Layer == 0 {
+			f.path = f.path.Union(image.Rect(m.X, m.Y, m.X+m.Width, m.Y+m.Height))
+		}
+	case *Cfill:
+		if m.Layer == 0 {
+			c := color.NRGBA{uint8(m.R), uint8(m.G), uint8(m.B), uint8(m.A)}
+			draw.Draw(f.img, f.path, image.NewUniform(c), image.Point{}, op(m.Mode))
+			f.path = image.Rectangle{}
+		}
+	case *Copy:
+		if m.SrcLayer == 0 && m.DstLayer == 0 {
+			r := image.Rect(m.DstX, m.DstY, m.DstX+m.SrcWidth, m.DstY+m.SrcHeight)
+			src := image.NewRGBA(image.Rect(m.SrcX, m.SrcY, m.SrcX+m.SrcWidth, m.SrcY+m.SrcHeight))
+			draw.Draw(src, src.Bounds(), f.img, src.Bounds().Min, draw.Src)
+			draw.Draw(f.img, r, src, src.Bounds().Min, op(m.Mode))
+		}
+	case *Sync:
+		f.frames++
+	}
+}
+
+func (f *Framebuffer) resize(width, height int) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), f.img, image.Point{}, draw.Src)
+	f.img = img
+}
+
+func (f *Framebuffer) drawImage(data []byte, mode CompositeMode, x, y int) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return // undecodable images, e.g. webp, are skipped
+	}
+	b := src.Bounds()
+	draw.Draw(f.img, image.Rect(x, y, x+b.Dx(), y+b.Dy()), src, b.Min, op(mode))
+}
+
+// op approximates a composite mode by the operators of image/draw.
+func op(mode CompositeMode) draw.Op {
+	if mode == protocol.CompositeSrc {
+		return draw.Src
+	}
+	return draw.Over
+}
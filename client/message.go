@@ -0,0 +1,77 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package client
+
+import "changkun.de/x/occamy/internal/protocol"
+
+// Message is a decoded server instruction, one of the message types
+// below. Instructions without a typed message are delivered as *Raw.
+type Message = protocol.Message
+
+// Instruction is a raw guacamole instruction
+type Instruction = protocol.Instruction
+
+// All typed messages exchanged with an occamy server
+type (
+	Mouse      = protocol.Mouse
+	Key        = protocol.Key
+	Size       = protocol.Size
+	LayerSize  = protocol.LayerSize
+	Clipboard  = protocol.Clipboard
+	File       = protocol.File
+	Pipe       = protocol.Pipe
+	Blob       = protocol.Blob
+	End        = protocol.End
+	Ack        = protocol.Ack
+	Img        = protocol.Img
+	PNG        = protocol.PNG
+	Rect       = protocol.Rect
+	Cfill      = protocol.Cfill
+	Copy       = protocol.Copy
+	Cursor     = protocol.Cursor
+	Sync       = protocol.Sync
+	Disconnect = protocol.Disconnect
+	Error      = protocol.Error
+	Required   = protocol.Required
+	Argv       = protocol.Argv
+	Msg        = protocol.Msg
+)
+
+// Status is a guacamole protocol status code
+type Status = protocol.Status
+
+// CompositeMode is the channel mask of draw instructions
+type CompositeMode = protocol.CompositeMode
+
+// Mouse button masks of SendMouse
+const (
+	MouseLeft       = protocol.MouseLeft
+	MouseMiddle     = protocol.MouseMiddle
+	MouseRight      = protocol.MouseRight
+	MouseScrollUp   = protocol.MouseScrollUp
+	MouseScrollDown = protocol.MouseScrollDown
+)
+
+// Raw is a server instruction without a typed message, e.g. nop or
+// name.
+type Raw struct {
+	Op   string
+	Args []string
+}
+
+// Opcode implements Message
+func (m *Raw) Opcode() string { return m.Op }
+
+// Encode implements Message
+func (m *Raw) Encode() *Instruction {
+	return protocol.NewInstruction(append([]string{m.Op}, m.Args...))
+}
+
+// Decode implements Message
+func (m *Raw) Decode(ins *Instruction) error {
+	m.Op = ins.Opcode()
+	m.Args = append([]string(nil), ins.Args()...)
+	return nil
+}
@@ -159,6 +159,24 @@ Occamy.ArrayBufferWriter = function(stream) {
  */
 Occamy.ArrayBufferWriter.DEFAULT_BLOB_LENGTH = 6048;
 
+/**
+ * Players of the audio streams of the Occamy client. The client does not
+ * play audio streams yet, the streams of unsupported types are not sent
+ * by the server.
+ */
+Occamy.AudioPlayer = {};
+
+/**
+ * Returns a list of all mimetypes of the audio streams which the client
+ * can play, which is sent to the server when connecting.
+ *
+ * @returns {String[]}
+ *     A list of all supported audio mimetypes.
+ */
+Occamy.AudioPlayer.getSupportedTypes = function getSupportedTypes() {
+    return [];
+};
+
 /**
  * A reader which automatically handles the given input stream, assembling all
  * received blobs into a single blob by appending them to each other in order.
@@ -857,6 +875,31 @@ Occamy.Client = function(tunnel) {
 
     };
 
+    /**
+     * Opens a new argument value stream for writing, having the given
+     * parameter name and mimetype, requesting that the connection parameter
+     * with the given name be updated to the value described by the contents
+     * of the stream. The instruction necessary to create this stream will
+     * automatically be sent.
+     *
+     * @param {String} mimetype
+     *     The mimetype of the data being sent.
+     *
+     * @param {String} name
+     *     The name of the connection parameter to attempt to update.
+     *
+     * @return {Occamy.OutputStream}
+     *     The created argument value stream.
+     */
+    this.createArgumentValueStream = function createArgumentValueStream(mimetype, name) {
+
+        // Allocate and associate stream with argument value metadata
+        var stream = guac_client.createOutputStream();
+        tunnel.sendMessage("argv", stream.index, mimetype, name);
+        return stream;
+
+    };
+
     /**
      * Creates a new output stream associated with the given object and having
      * the given mimetype and name. The legality of a mimetype and name is
@@ -1001,6 +1044,34 @@ Occamy.Client = function(tunnel) {
      */
     this.onclipboard = null;
 
+    /**
+     * Fired when the current value of a connection parameter is being exposed
+     * by the server.
+     *
+     * @event
+     * @param {Occamy.InputStream} stream
+     *     The stream that will receive connection parameter data from the
+     *     server.
+     *
+     * @param {String} mimetype
+     *     The mimetype of the data which will be received.
+     *
+     * @param {String} name
+     *     The name of the connection parameter whose value is being exposed.
+     */
+    this.onargv = null;
+
+    /**
+     * Fired when the remote desktop requires additional connection
+     * parameters, such as credentials, before it can continue. The values
+     * are provided through streams created by createArgumentValueStream.
+     *
+     * @event
+     * @param {String[]} parameters
+     *     The names of the connection parameters being requested.
+     */
+    this.onrequired = null;
+
     /**
      * Fired when a file stream is created. The stream provided to this event
      * handler will contain its own event handlers for received data.
@@ -1162,6 +1233,24 @@ Occamy.Client = function(tunnel) {
 
         },
 
+        "argv": function(parameters) {
+
+            var stream_index = parseInt(parameters[0]);
+            var mimetype = parameters[1];
+            var name = parameters[2];
+
+            // Create stream
+            if (guac_client.onargv) {
+                var stream = streams[stream_index] = new Occamy.InputStream(guac_client, stream_index);
+                guac_client.onargv(stream, mimetype, name);
+            }
+
+            // Otherwise, unsupported
+            else
+                guac_client.sendAck(stream_index, "Receiving argument values unsupported", 0x0100);
+
+        },
+
         "blob": function(parameters) {
 
             // Get stream 
@@ -1467,6 +1556,11 @@ Occamy.Client = function(tunnel) {
 
         },
  
+        "required": function(parameters) {
+            if (guac_client.onrequired)
+                guac_client.onrequired(parameters);
+        },
+
         "rect": function(parameters) {
 
             var layer = getLayer(parseInt(parameters[0]));
@@ -8964,6 +9058,24 @@ Occamy.Tunnel.State = {
 
 };
 
+/**
+ * Players of the video streams of the Occamy client. The client does not
+ * play video streams yet, the streams of unsupported types are not sent
+ * by the server.
+ */
+Occamy.VideoPlayer = {};
+
+/**
+ * Returns a list of all mimetypes of the video streams which the client
+ * can play, which is sent to the server when connecting.
+ *
+ * @returns {String[]}
+ *     A list of all supported video mimetypes.
+ */
+Occamy.VideoPlayer.getSupportedTypes = function getSupportedTypes() {
+    return [];
+};
+
 /**
  * Occamy Tunnel implemented over WebSocket via XMLHttpRequest.
  * 
@@ -0,0 +1,123 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Command occamy-bench is a load generator of occamy. It opens
+// concurrent sessions against an occamy server, replays recorded client
+// input, and writes a JSON report of handshake time, first-frame time,
+// sync latency, throughput and error rates.
+//
+// For instance, the following command opens 100 connections to two
+// hosts within 10 seconds, and replays input.rec twice as fast for one
+// minute per connection:
+//
+//	occamy-bench -sessions 100 -ramp 10s -duration 1m \
+//		-protocol vnc -host 172.16.239.11:5901,172.16.239.14:5901 \
+//		-password vncpassword -replay input.rec -speed 2 -out report.json
+//
+// Recordings of client input are written by server.RecordInput.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"changkun.de/x/occamy/client"
+	"changkun.de/x/occamy/internal/bench"
+)
+
+func main() {
+	log.SetPrefix("occamy-bench: ")
+	log.SetFlags(0)
+
+	var (
+		addr     = flag.String("addr", "http://0.0.0.0:5636", "address of the occamy server")
+		proto    = flag.String("protocol", "vnc", "protocol of the remote desktops: vnc/rdp/ssh")
+		hosts    = flag.String("host", "172.16.239.11:5901", "comma separated remote desktops, connections use them in turn")
+		username = flag.String("username", "", "username of the remote desktops")
+		password = flag.String("password", "", "password of the remote desktops")
+		sessions = flag.Int("sessions", 10, "number of concurrent connections")
+		ramp     = flag.Duration("ramp", 0, "duration to open all connections")
+		duration = flag.Duration("duration", 30*time.Second, "duration of each connection")
+		replay   = flag.String("replay", "", "recording of client input to replay")
+		speed    = flag.Float64("speed", 1, "replay speed, 1 is real time, 0 replays as fast as the server draws")
+		width    = flag.Int("width", 1024, "optimal display width")
+		height   = flag.Int("height", 768, "optimal display height")
+		out      = flag.String("out", "-", "path of the JSON report, - for stdout")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `a load generator of occamy.
+Usage:
+`)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	conf := bench.Config{
+		Addr:     *addr,
+		Sessions: *sessions,
+		Ramp:     *ramp,
+		Duration: *duration,
+		Speed:    *speed,
+		Options:  client.Options{Width: *width, Height: *height},
+	}
+	for _, host := range strings.Split(*hosts, ",") {
+		conf.Credentials = append(conf.Credentials, client.Credentials{
+			Protocol: *proto,
+			Host:     strings.TrimSpace(host),
+			Username: *username,
+			Password: *password,
+		})
+	}
+	if *replay != "" {
+		f, err := os.Open(*replay)
+		if err != nil {
+			log.Fatalf("cannot open recording: %v", err)
+		}
+		conf.Recording, err = bench.ReadRecording(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt)
+		<-quit
+		log.Println("interrupted, ending all connections...")
+		cancel()
+	}()
+	r, err := bench.Run(ctx, conf)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		w, err = os.Create(*out)
+		if err != nil {
+			log.Fatalf("cannot create report: %v", err)
+		}
+		defer w.Close()
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(r)
+	if err != nil {
+		log.Fatalf("write report failed: %v", err)
+	}
+	log.Printf("%d sessions, %d failed, first frame p50 %.1fms, sync latency p50 %.1fms",
+		r.Sessions, r.Failed, r.FirstFrame.P50, r.SyncLatency.P50)
+	log.Printf("%.0f bytes/s, %.0f image bytes/s, %.1f copies/s",
+		r.Throughput.Bytes, r.Throughput.Images, r.Throughput.Copies)
+}
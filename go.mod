@@ -3,10 +3,14 @@ module changkun.de/x/occamy
 go 1.15
 
 require (
-	gioui.org v0.0.0-20211202105001-872b4ba41be0
 	github.com/appleboy/gin-jwt/v2 v2.6.2
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/gin-gonic/gin v1.4.0
+	github.com/golang/protobuf v1.3.2 // indirect
 	github.com/gorilla/websocket v1.4.0
-	github.com/tfriedel6/canvas v0.9.1
+	github.com/json-iterator/go v1.1.8 // indirect
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
+	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
+	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
 	gopkg.in/yaml.v2 v2.2.4
 )
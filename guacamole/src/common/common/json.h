@@ -0,0 +1,177 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+#ifndef __GUAC_COMMON_JSON_H
+#define __GUAC_COMMON_JSON_H
+
+#include "config.h"
+
+#include <guacamole/stream.h>
+#include <guacamole/user.h>
+
+/**
+ * The size of the buffer of a JSON stream, in bytes. Data written to the
+ * stream is sent as a blob once the buffer is full or flushed.
+ */
+#define GUAC_COMMON_JSON_BUFFER_SIZE 4096
+
+/**
+ * The current state of a JSON object which is being written to a stream.
+ */
+typedef struct guac_common_json_state {
+
+    /**
+     * Buffer of data which has not yet been sent as a blob.
+     */
+    char buffer[GUAC_COMMON_JSON_BUFFER_SIZE];
+
+    /**
+     * The number of bytes currently used within the buffer.
+     */
+    int size;
+
+    /**
+     * The number of properties written to the current object.
+     */
+    int properties_written;
+
+} guac_common_json_state;
+
+/**
+ * Sends the contents of the JSON buffer as a blob, if any.
+ *
+ * @param user
+ *     The user receiving the stream.
+ *
+ * @param stream
+ *     The stream to send the blob over.
+ *
+ * @param json_state
+ *     The state of the JSON object being written.
+ */
+void guac_common_json_flush(guac_user* user, guac_stream* stream,
+        guac_common_json_state* json_state);
+
+/**
+ * Writes the given data to the JSON buffer, flushing the buffer as a blob if
+ * more room is needed.
+ *
+ * @param user
+ *     The user receiving the stream.
+ *
+ * @param stream
+ *     The stream to send blobs over.
+ *
+ * @param json_state
+ *     The state of the JSON object being written.
+ *
+ * @param buffer
+ *     The data to write.
+ *
+ * @param length
+ *     The number of bytes to write.
+ *
+ * @return
+ *     Non-zero if a blob was sent while writing, zero otherwise.
+ */
+int guac_common_json_write(guac_user* user, guac_stream* stream,
+        guac_common_json_state* json_state, const char* buffer, int length);
+
+/**
+ * Writes the given string as a quoted and escaped JSON string.
+ *
+ * @param user
+ *     The user receiving the stream.
+ *
+ * @param stream
+ *     The stream to send blobs over.
+ *
+ * @param json_state
+ *     The state of the JSON object being written.
+ *
+ * @param str
+ *     The null-terminated string to write.
+ *
+ * @return
+ *     Non-zero if a blob was sent while writing, zero otherwise.
+ */
+int guac_common_json_write_string(guac_user* user, guac_stream* stream,
+        guac_common_json_state* json_state, const char* str);
+
+/**
+ * Writes a property of the current JSON object having the given name and
+ * string value.
+ *
+ * @param user
+ *     The user receiving the stream.
+ *
+ * @param stream
+ *     The stream to send blobs over.
+ *
+ * @param json_state
+ *     The state of the JSON object being written.
+ *
+ * @param name
+ *     The name of the property.
+ *
+ * @param value
+ *     The value of the property.
+ *
+ * @return
+ *     Non-zero if a blob was sent while writing, zero otherwise.
+ */
+int guac_common_json_write_property(guac_user* user, guac_stream* stream,
+        guac_common_json_state* json_state, const char* name,
+        const char* value);
+
+/**
+ * Initializes the given JSON state and begins a new JSON object.
+ *
+ * @param user
+ *     The user receiving the stream.
+ *
+ * @param stream
+ *     The stream to send blobs over.
+ *
+ * @param json_state
+ *     The state to initialize.
+ */
+void guac_common_json_begin_object(guac_user* user, guac_stream* stream,
+        guac_common_json_state* json_state);
+
+/**
+ * Ends the current JSON object. The buffer must still be flushed with
+ * guac_common_json_flush() once the object is complete.
+ *
+ * @param user
+ *     The user receiving the stream.
+ *
+ * @param stream
+ *     The stream to send blobs over.
+ *
+ * @param json_state
+ *     The state of the JSON object being written.
+ *
+ * @return
+ *     Non-zero if a blob was sent while writing, zero otherwise.
+ */
+int guac_common_json_end_object(guac_user* user, guac_stream* stream,
+        guac_common_json_state* json_state);
+
+#endif
@@ -0,0 +1,162 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+#include "config.h"
+#include "common/json.h"
+
+#include <guacamole/protocol.h>
+#include <guacamole/socket.h>
+#include <guacamole/stream.h>
+#include <guacamole/user.h>
+
+#include <stdio.h>
+#include <string.h>
+
+void guac_common_json_flush(guac_user* user, guac_stream* stream,
+        guac_common_json_state* json_state) {
+
+    /* If JSON buffer is non-empty, send contents as a blob */
+    if (json_state->size > 0) {
+        guac_protocol_send_blob(user->socket, stream,
+                json_state->buffer, json_state->size);
+        json_state->size = 0;
+    }
+
+}
+
+int guac_common_json_write(guac_user* user, guac_stream* stream,
+        guac_common_json_state* json_state, const char* buffer, int length) {
+
+    int blob_written = 0;
+
+    /* Append to and flush the JSON buffer as necessary */
+    while (length > 0) {
+
+        /* Do not write more than fits within the buffer at once */
+        int blob_length = length;
+        if (blob_length > sizeof(json_state->buffer))
+            blob_length = sizeof(json_state->buffer);
+
+        /* Flush if more room is needed */
+        if (json_state->size + blob_length > sizeof(json_state->buffer)) {
+            guac_common_json_flush(user, stream, json_state);
+            blob_written = 1;
+        }
+
+        /* Append data to JSON buffer */
+        memcpy(json_state->buffer + json_state->size, buffer, blob_length);
+        json_state->size += blob_length;
+
+        length -= blob_length;
+        buffer += blob_length;
+
+    }
+
+    return blob_written;
+
+}
+
+int guac_common_json_write_string(guac_user* user, guac_stream* stream,
+        guac_common_json_state* json_state, const char* str) {
+
+    int blob_written = 0;
+
+    /* Write leading quote */
+    blob_written |= guac_common_json_write(user, stream, json_state, "\"", 1);
+
+    /* Write string content, escaping where required */
+    const char* current = str;
+    for (; *str != '\0'; str++) {
+
+        unsigned char c = (unsigned char) *str;
+        if (c != '"' && c != '\\' && c >= 0x20)
+            continue;
+
+        /* Write any string content up to current character */
+        if (str != current)
+            blob_written |= guac_common_json_write(user, stream, json_state,
+                    current, str - current);
+
+        /* Escape quotes, backslashes and control characters */
+        char escaped[8];
+        if (c == '"' || c == '\\')
+            snprintf(escaped, sizeof(escaped), "\\%c", c);
+        else
+            snprintf(escaped, sizeof(escaped), "\\u%04X", c);
+        blob_written |= guac_common_json_write(user, stream, json_state,
+                escaped, strlen(escaped));
+
+        current = str + 1;
+
+    }
+
+    /* Write any remaining string content */
+    if (str != current)
+        blob_written |= guac_common_json_write(user, stream, json_state,
+                current, str - current);
+
+    /* Write trailing quote */
+    blob_written |= guac_common_json_write(user, stream, json_state, "\"", 1);
+
+    return blob_written;
+
+}
+
+int guac_common_json_write_property(guac_user* user, guac_stream* stream,
+        guac_common_json_state* json_state, const char* name,
+        const char* value) {
+
+    int blob_written = 0;
+
+    /* Write leading comma if not first property */
+    if (json_state->properties_written != 0)
+        blob_written |= guac_common_json_write(user, stream, json_state,
+                ",", 1);
+
+    /* Write property */
+    blob_written |= guac_common_json_write_string(user, stream, json_state,
+            name);
+    blob_written |= guac_common_json_write(user, stream, json_state, ":", 1);
+    blob_written |= guac_common_json_write_string(user, stream, json_state,
+            value);
+
+    json_state->properties_written++;
+    return blob_written;
+
+}
+
+void guac_common_json_begin_object(guac_user* user, guac_stream* stream,
+        guac_common_json_state* json_state) {
+
+    /* Init JSON state */
+    json_state->size = 0;
+    json_state->properties_written = 0;
+
+    /* Write leading brace - no blob can possibly be written by this */
+    guac_common_json_write(user, stream, json_state, "{", 1);
+
+}
+
+int guac_common_json_end_object(guac_user* user, guac_stream* stream,
+        guac_common_json_state* json_state) {
+
+    /* Write final brace of JSON object */
+    return guac_common_json_write(user, stream, json_state, "}", 1);
+
+}
@@ -0,0 +1,58 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+#ifndef GUAC_ARGV_STATE_H
+#define GUAC_ARGV_STATE_H
+
+/**
+ * Internal allocation of the per-client state of received connection
+ * parameters. This is used only internally within libguac, and is not
+ * installed along with the library.
+ *
+ * @file argv-state.h
+ */
+
+#include "config.h"
+
+/**
+ * The registered connection parameters of a guac_client, and the values
+ * received for them.
+ */
+typedef struct guac_argv_state guac_argv_state;
+
+/**
+ * Allocates the state of registered connection parameters of a new
+ * guac_client.
+ *
+ * @return
+ *     The newly-allocated state, or NULL if allocation fails.
+ */
+guac_argv_state* guac_argv_state_alloc();
+
+/**
+ * Frees the given state of registered connection parameters, previously
+ * allocated with guac_argv_state_alloc().
+ *
+ * @param state
+ *     The state to free.
+ */
+void guac_argv_state_free(guac_argv_state* state);
+
+#endif
+
@@ -0,0 +1,389 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+#include "config.h"
+
+#include "argv.h"
+#include "argv-state.h"
+#include "client.h"
+#include "protocol.h"
+#include "socket.h"
+#include "stream.h"
+#include "user.h"
+
+#include <pthread.h>
+#include <stdlib.h>
+#include <string.h>
+
+/**
+ * A connection parameter registered with guac_argv_register().
+ */
+typedef struct guac_argv {
+
+    /**
+     * The name of the connection parameter.
+     */
+    char name[GUAC_ARGV_MAX_NAME_LENGTH];
+
+    /**
+     * Bitwise OR of all GUAC_ARGV_OPTION flags given at registration.
+     */
+    int options;
+
+    /**
+     * The callback to invoke for each received value, or NULL.
+     */
+    guac_argv_callback* callback;
+
+    /**
+     * Arbitrary data to pass to the callback.
+     */
+    void* data;
+
+    /**
+     * Non-zero if a value for this parameter has been received.
+     */
+    int received;
+
+} guac_argv;
+
+struct guac_argv_state {
+
+    /**
+     * Lock which is acquired while the registered parameters are accessed.
+     */
+    pthread_mutex_t lock;
+
+    /**
+     * Condition which is signalled whenever a value has been received or
+     * the state has been stopped.
+     */
+    pthread_cond_t changed;
+
+    /**
+     * Non-zero if all waiting threads should be released.
+     */
+    int stopped;
+
+    /**
+     * The number of registered parameters.
+     */
+    unsigned int size;
+
+    /**
+     * All registered parameters.
+     */
+    guac_argv registered[GUAC_ARGV_MAX_REGISTERED];
+
+};
+
+/**
+ * The value of a connection parameter being received along an argv stream.
+ */
+typedef struct guac_argv_stream {
+
+    /**
+     * The name of the connection parameter.
+     */
+    char name[GUAC_ARGV_MAX_NAME_LENGTH];
+
+    /**
+     * The mimetype of the value.
+     */
+    char mimetype[GUAC_ARGV_MAX_MIMETYPE_LENGTH];
+
+    /**
+     * The value received so far, null-terminated.
+     */
+    char buffer[GUAC_ARGV_MAX_LENGTH];
+
+    /**
+     * The number of bytes received so far, excluding the null terminator.
+     */
+    int length;
+
+} guac_argv_stream;
+
+guac_argv_state* guac_argv_state_alloc() {
+
+    guac_argv_state* state = calloc(1, sizeof(guac_argv_state));
+    if (state == NULL)
+        return NULL;
+
+    /* Callbacks may stop the client, which locks the state again */
+    pthread_mutexattr_t lock_attributes;
+    pthread_mutexattr_init(&lock_attributes);
+    pthread_mutexattr_settype(&lock_attributes, PTHREAD_MUTEX_RECURSIVE);
+
+    pthread_mutex_init(&state->lock, &lock_attributes);
+    pthread_cond_init(&state->changed, NULL);
+
+    pthread_mutexattr_destroy(&lock_attributes);
+    return state;
+
+}
+
+void guac_argv_state_free(guac_argv_state* state) {
+
+    if (state == NULL)
+        return;
+
+    pthread_cond_destroy(&state->changed);
+    pthread_mutex_destroy(&state->lock);
+    free(state);
+
+}
+
+/**
+ * Returns the registered parameter having the given name. The state lock
+ * must be held.
+ *
+ * @param state
+ *     The state to search.
+ *
+ * @param name
+ *     The name of the parameter.
+ *
+ * @return
+ *     The registered parameter, or NULL if no such parameter is registered.
+ */
+static guac_argv* guac_argv_find(guac_argv_state* state, const char* name) {
+
+    for (unsigned int i = 0; i < state->size; i++) {
+        if (strcmp(state->registered[i].name, name) == 0)
+            return &state->registered[i];
+    }
+
+    return NULL;
+
+}
+
+int guac_argv_register(guac_client* client, const char* name,
+        guac_argv_callback* callback, void* data, int options) {
+
+    guac_argv_state* state = client->__argv;
+    int retval = 1;
+
+    if (strlen(name) >= GUAC_ARGV_MAX_NAME_LENGTH)
+        return 1;
+
+    pthread_mutex_lock(&state->lock);
+
+    /* Re-registering a parameter replaces the previous registration */
+    guac_argv* argv = guac_argv_find(state, name);
+    if (argv == NULL && state->size < GUAC_ARGV_MAX_REGISTERED)
+        argv = &state->registered[state->size++];
+
+    if (argv != NULL) {
+        strcpy(argv->name, name);
+        argv->options = options;
+        argv->callback = callback;
+        argv->data = data;
+        argv->received = 0;
+        retval = 0;
+    }
+
+    pthread_mutex_unlock(&state->lock);
+    return retval;
+
+}
+
+/**
+ * Returns whether values for all given parameters have been received. The
+ * state lock must be held.
+ */
+static int guac_argv_all_received(guac_argv_state* state, const char** args) {
+
+    for (int i = 0; args[i] != NULL; i++) {
+        guac_argv* argv = guac_argv_find(state, args[i]);
+        if (argv == NULL || !argv->received)
+            return 0;
+    }
+
+    return 1;
+
+}
+
+int guac_argv_await(guac_client* client, const char** args) {
+
+    guac_argv_state* state = client->__argv;
+
+    pthread_mutex_lock(&state->lock);
+
+    while (!state->stopped && !guac_argv_all_received(state, args))
+        pthread_cond_wait(&state->changed, &state->lock);
+
+    int stopped = state->stopped;
+    pthread_mutex_unlock(&state->lock);
+
+    return stopped;
+
+}
+
+void guac_argv_stop(guac_client* client) {
+
+    guac_argv_state* state = client->__argv;
+    if (state == NULL)
+        return;
+
+    pthread_mutex_lock(&state->lock);
+    state->stopped = 1;
+    pthread_cond_broadcast(&state->changed);
+    pthread_mutex_unlock(&state->lock);
+
+}
+
+/**
+ * Handler for blobs of argv streams, appending the received data to the
+ * value being received. Data beyond GUAC_ARGV_MAX_LENGTH is truncated.
+ */
+static int guac_argv_blob_handler(guac_user* user, guac_stream* stream,
+        void* data, int length) {
+
+    guac_argv_stream* argv_stream = stream->data;
+
+    int remaining = sizeof(argv_stream->buffer) - argv_stream->length - 1;
+    if (length > remaining)
+        length = remaining;
+
+    memcpy(argv_stream->buffer + argv_stream->length, data, length);
+    argv_stream->length += length;
+    argv_stream->buffer[argv_stream->length] = '\0';
+
+    guac_protocol_send_ack(user->socket, stream, "Received",
+            GUAC_PROTOCOL_STATUS_SUCCESS);
+    guac_socket_flush(user->socket);
+    return 0;
+
+}
+
+/**
+ * Sends the given value of a connection parameter to the given user along a
+ * new argv stream, such that the user is aware of the current value.
+ */
+static void* guac_argv_echo_callback(guac_user* user, void* data) {
+
+    guac_argv_stream* argv_stream = data;
+    guac_socket* socket = user->socket;
+
+    guac_stream* stream = guac_user_alloc_stream(user);
+    if (stream == NULL)
+        return NULL;
+
+    guac_protocol_send_argv(socket, stream, argv_stream->mimetype,
+            argv_stream->name);
+
+    /* Send value in blobs of at most 6048 bytes */
+    for (int offset = 0; offset < argv_stream->length; offset += 6048) {
+        int length = argv_stream->length - offset;
+        if (length > 6048)
+            length = 6048;
+        guac_protocol_send_blob(socket, stream,
+                argv_stream->buffer + offset, length);
+    }
+
+    guac_protocol_send_end(socket, stream);
+    guac_socket_flush(socket);
+    guac_user_free_stream(user, stream);
+    return NULL;
+
+}
+
+/**
+ * Handler for the end of argv streams, passing the received value to the
+ * callback of the registered parameter and releasing any waiting threads.
+ */
+static int guac_argv_end_handler(guac_user* user, guac_stream* stream) {
+
+    guac_argv_stream* argv_stream = stream->data;
+    guac_argv_state* state = user->client->__argv;
+
+    pthread_mutex_lock(&state->lock);
+
+    guac_argv* argv = guac_argv_find(state, argv_stream->name);
+    if (argv != NULL && !(argv->received
+                && (argv->options & GUAC_ARGV_OPTION_ONCE))) {
+
+        int accepted = 1;
+        if (argv->callback != NULL)
+            accepted = !argv->callback(user, argv_stream->mimetype,
+                    argv_stream->name, argv_stream->buffer, argv->data);
+
+        if (accepted) {
+            argv->received = 1;
+            if (argv->options & GUAC_ARGV_OPTION_ECHO)
+                guac_client_foreach_user(user->client,
+                        guac_argv_echo_callback, argv_stream);
+        }
+
+        pthread_cond_broadcast(&state->changed);
+
+    }
+
+    pthread_mutex_unlock(&state->lock);
+
+    free(argv_stream);
+    stream->data = NULL;
+    return 0;
+
+}
+
+int guac_argv_handler(guac_user* user, guac_stream* stream, char* mimetype,
+        char* name) {
+
+    guac_argv_state* state = user->client->__argv;
+
+    /* Refuse parameters which are not registered or already received once */
+    pthread_mutex_lock(&state->lock);
+    guac_argv* argv = guac_argv_find(state, name);
+    int refused = argv == NULL
+        || (argv->received && (argv->options & GUAC_ARGV_OPTION_ONCE));
+    pthread_mutex_unlock(&state->lock);
+
+    if (refused
+            || strlen(mimetype) >= GUAC_ARGV_MAX_MIMETYPE_LENGTH) {
+        guac_protocol_send_ack(user->socket, stream,
+                "Changing this parameter is not allowed",
+                GUAC_PROTOCOL_STATUS_CLIENT_FORBIDDEN);
+        guac_socket_flush(user->socket);
+        return 0;
+    }
+
+    guac_argv_stream* argv_stream = calloc(1, sizeof(guac_argv_stream));
+    if (argv_stream == NULL) {
+        guac_protocol_send_ack(user->socket, stream,
+                "Out of memory", GUAC_PROTOCOL_STATUS_SERVER_ERROR);
+        guac_socket_flush(user->socket);
+        return 0;
+    }
+
+    strcpy(argv_stream->name, name);
+    strcpy(argv_stream->mimetype, mimetype);
+
+    stream->data = argv_stream;
+    stream->blob_handler = guac_argv_blob_handler;
+    stream->end_handler = guac_argv_end_handler;
+
+    guac_protocol_send_ack(user->socket, stream, "Ready for updated parameter",
+            GUAC_PROTOCOL_STATUS_SUCCESS);
+    guac_socket_flush(user->socket);
+    return 0;
+
+}
+
@@ -19,6 +19,8 @@
 
 #include "config.h"
 
+#include "argv.h"
+#include "argv-state.h"
 #include "client.h"
 #include "encode-png.h"
 #include "error.h"
@@ -174,6 +176,9 @@ guac_client* guac_client_alloc(char* cid) {
     /* Set up socket to broadcast to all users */
     client->socket = guac_socket_broadcast(client);
 
+    /* Init received connection parameters */
+    client->__argv = guac_argv_state_alloc();
+
     return client;
 
 }
@@ -184,6 +189,10 @@ void guac_client_free(guac_client* client) {
     while (client->__users != NULL)
         guac_client_remove_user(client, client->__users);
 
+    /* Notify application, if any */
+    if (client->event_handler)
+        client->event_handler(client, GUAC_CLIENT_EVENT_FREE, NULL);
+
     if (client->free_handler) {
 
         /* FIXME: Errors currently ignored... */
@@ -210,6 +219,8 @@ void guac_client_free(guac_client* client) {
             guac_client_log(client, GUAC_LOG_ERROR, "Unable to close plugin: %s", dlerror());
     }
 
+    guac_argv_state_free(client->__argv);
+
     pthread_rwlock_destroy(&(client->__users_lock));
     free(client->connection_id);
     free(client);
@@ -237,7 +248,16 @@ void guac_client_log(guac_client* client, guac_client_log_level level,
 }
 
 void guac_client_stop(guac_client* client) {
+
+    int running = client->state == GUAC_CLIENT_RUNNING;
+
     client->state = GUAC_CLIENT_STOPPING;
+    guac_argv_stop(client);
+
+    /* Notify application only once */
+    if (running && client->event_handler)
+        client->event_handler(client, GUAC_CLIENT_EVENT_STOP, NULL);
+
 }
 
 void vguac_client_abort(guac_client* client, guac_protocol_status status,
@@ -291,6 +311,11 @@ void guac_client_add_user(guac_user* user) {
         user->client->__owner = user;
 
     pthread_rwlock_unlock(&(user->client->__users_lock));
+
+    /* Notify application, if any */
+    if (user->client->event_handler)
+        user->client->event_handler(user->client, GUAC_CLIENT_EVENT_JOIN, user);
+
 }
 
 void guac_client_remove_user(guac_client* client, guac_user* user) {
@@ -321,6 +346,10 @@ void guac_client_remove_user(guac_client* client, guac_user* user) {
     else if (client->leave_handler)
         client->leave_handler(user);
 
+    /* Notify application, if any */
+    if (client->event_handler)
+        client->event_handler(client, GUAC_CLIENT_EVENT_LEAVE, user);
+
 }
 
 void guac_client_foreach_user(guac_client* client, guac_user_callback* callback, void* data) {
@@ -512,3 +541,40 @@ void guac_client_stream_png(guac_client* client, guac_socket* socket,
     guac_client_free_stream(client, stream);
 
 }
+
+/**
+ * Callback for guac_client_for_owner() which returns whether the owner
+ * supports the "required" instruction.
+ */
+static void* __guac_owner_supports_required(guac_user* user, void* data) {
+    return (void*) ((intptr_t) guac_user_supports_required(user));
+}
+
+int guac_client_owner_supports_required(guac_client* client) {
+    return (int) ((intptr_t) guac_client_for_owner(client,
+                __guac_owner_supports_required, NULL));
+}
+
+/**
+ * Callback for guac_client_for_owner() which sends the "required"
+ * instruction to the owner, if supported.
+ */
+static void* __guac_owner_send_required(guac_user* user, void* data) {
+
+    const char** required = (const char**) data;
+
+    if (!guac_user_supports_required(user))
+        return (void*) ((intptr_t) -1);
+
+    if (guac_protocol_send_required(user->socket, required)
+            || guac_socket_flush(user->socket))
+        return (void*) ((intptr_t) -1);
+
+    return NULL;
+
+}
+
+int guac_client_owner_send_required(guac_client* client, const char** required) {
+    return (int) ((intptr_t) guac_client_for_owner(client,
+                __guac_owner_send_required, required));
+}
@@ -0,0 +1,164 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+#ifndef _GUAC_ARGV_H
+#define _GUAC_ARGV_H
+
+/**
+ * Provides functions for automatically handling the receipt of updated
+ * connection parameters via "argv" streams, whether those parameters were
+ * requested by a "required" instruction or updated on an active connection.
+ *
+ * @file argv.h
+ */
+
+#include "client-types.h"
+#include "stream-types.h"
+#include "user-fntypes.h"
+#include "user-types.h"
+
+/**
+ * The maximum number of bytes to allow for any connection parameter value
+ * received via an argv stream, including the null terminator.
+ */
+#define GUAC_ARGV_MAX_LENGTH 16384
+
+/**
+ * The maximum number of bytes to allow for any connection parameter name,
+ * including the null terminator.
+ */
+#define GUAC_ARGV_MAX_NAME_LENGTH 256
+
+/**
+ * The maximum number of bytes to allow for any mimetype of a received
+ * connection parameter value, including the null terminator.
+ */
+#define GUAC_ARGV_MAX_MIMETYPE_LENGTH 4096
+
+/**
+ * The maximum number of connection parameters which may be registered for a
+ * single guac_client.
+ */
+#define GUAC_ARGV_MAX_REGISTERED 128
+
+/**
+ * Option flag which declares that a registered connection parameter should
+ * be accepted only once. After its value has been received, further values
+ * are refused.
+ */
+#define GUAC_ARGV_OPTION_ONCE 1
+
+/**
+ * Option flag which declares that each received value of a registered
+ * connection parameter should be echoed to all connected users, such that
+ * all users are aware of the current value.
+ */
+#define GUAC_ARGV_OPTION_ECHO 2
+
+/**
+ * Callback which is invoked when the value of a registered connection
+ * parameter has been received.
+ *
+ * @param user
+ *     The user that sent the value.
+ *
+ * @param mimetype
+ *     The mimetype of the received value.
+ *
+ * @param name
+ *     The name of the connection parameter.
+ *
+ * @param value
+ *     The received value, as a null-terminated string.
+ *
+ * @param data
+ *     The arbitrary data given when the parameter was registered.
+ *
+ * @return
+ *     Zero if the value was accepted, non-zero otherwise.
+ */
+typedef int guac_argv_callback(guac_user* user, const char* mimetype,
+        const char* name, const char* value, void* data);
+
+/**
+ * Registers the connection parameter having the given name, such that
+ * values received for that parameter via argv streams sent to the given
+ * client are accepted and passed to the given callback. Values of
+ * parameters which are not registered are refused.
+ *
+ * @param client
+ *     The client to register the connection parameter for.
+ *
+ * @param name
+ *     The name of the connection parameter.
+ *
+ * @param callback
+ *     The callback to invoke when a value has been received, or NULL if
+ *     values should only be tracked for guac_argv_await().
+ *
+ * @param data
+ *     Arbitrary data to pass to the callback.
+ *
+ * @param options
+ *     Zero or more of the GUAC_ARGV_OPTION flags, combined with bitwise OR.
+ *
+ * @return
+ *     Zero if the parameter was registered, non-zero if too many parameters
+ *     are already registered or the name is too long.
+ */
+int guac_argv_register(guac_client* client, const char* name,
+        guac_argv_callback* callback, void* data, int options);
+
+/**
+ * Waits until values for all connection parameters having the given names
+ * have been received by the given client, or until the client is stopped.
+ * Each of the parameters must first have been registered with
+ * guac_argv_register().
+ *
+ * @param client
+ *     The client which is expected to receive the values.
+ *
+ * @param args
+ *     A NULL-terminated array of the names of all connection parameters to
+ *     wait for.
+ *
+ * @return
+ *     Zero if all values have been received, non-zero if waiting was
+ *     aborted because the client is stopping.
+ */
+int guac_argv_await(guac_client* client, const char** args);
+
+/**
+ * Aborts all calls to guac_argv_await() on the given client. This is
+ * automatically invoked when the client is stopped.
+ *
+ * @param client
+ *     The client whose waiting threads should be released.
+ */
+void guac_argv_stop(guac_client* client);
+
+/**
+ * Handler for argv streams which receives the values of all connection
+ * parameters registered with guac_argv_register(). This handler may be
+ * assigned directly to the argv_handler of a guac_user.
+ */
+guac_user_argv_handler guac_argv_handler;
+
+#endif
+
@@ -93,5 +93,36 @@ typedef enum guac_client_log_level {
 
 } guac_client_log_level;
 
+/**
+ * Lifecycle events of a Guacamole client, reported to the event handler
+ * of the client.
+ */
+typedef enum guac_client_event {
+
+    /**
+     * A user has joined the connection and was added to the list of
+     * connected users.
+     */
+    GUAC_CLIENT_EVENT_JOIN,
+
+    /**
+     * A user has left the connection and was removed from the list of
+     * connected users.
+     */
+    GUAC_CLIENT_EVENT_LEAVE,
+
+    /**
+     * The client has changed from GUAC_CLIENT_RUNNING to
+     * GUAC_CLIENT_STOPPING.
+     */
+    GUAC_CLIENT_EVENT_STOP,
+
+    /**
+     * The client is about to be freed. All users have already left.
+     */
+    GUAC_CLIENT_EVENT_FREE
+
+} guac_client_event;
+
 #endif
 
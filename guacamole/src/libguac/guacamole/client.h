@@ -74,6 +74,22 @@ typedef int guac_client_free_handler(guac_client* client);
 typedef void guac_client_log_handler(guac_client* client,
         guac_client_log_level level, const char* format, va_list args);
 
+/**
+ * Handler for lifecycle events of a given guac_client instance.
+ *
+ * @param client
+ *     The client the event occurred on.
+ *
+ * @param event
+ *     The event which occurred.
+ *
+ * @param user
+ *     The user joining or leaving for GUAC_CLIENT_EVENT_JOIN and
+ *     GUAC_CLIENT_EVENT_LEAVE, NULL otherwise.
+ */
+typedef void guac_client_event_handler(guac_client* client,
+        guac_client_event event, guac_user* user);
+
 /**
  * The entry point of a client plugin which must initialize the given
  * guac_client. In practice, this function will be called "guac_client_init".
@@ -319,6 +335,24 @@ struct guac_client {
      */
     guac_user_leave_handler* leave_handler;
 
+    /**
+     * Handler for lifecycle events, called after a user joined or left the
+     * connection, once the client is stopping, and before the client is
+     * freed. Unlike the other handlers, this handler is not owned by the
+     * client plugin but by the application which allocated the client,
+     * hence it must not be assigned within guac_client_init.
+     *
+     * Example:
+     * @code
+     *     void event_handler(guac_client* client, guac_client_event event,
+     *             guac_user* user);
+     *
+     *     guac_client* client = guac_client_alloc(id);
+     *     client->event_handler = event_handler;
+     * @endcode
+     */
+    guac_client_event_handler* event_handler;
+
     /**
      * NULL-terminated array of all arguments accepted by this client , in
      * order. New users will specify these arguments when they join the
@@ -351,6 +385,12 @@ struct guac_client {
      * is used.
      */
     void* __plugin_handle;
+
+    /**
+     * The connection parameters registered with guac_argv_register(), and
+     * the values received for them.
+     */
+    struct guac_argv_state* __argv;
 };
 
 /**
@@ -674,6 +714,39 @@ void guac_client_stream_png(guac_client* client, guac_socket* socket,
         guac_composite_mode mode, const guac_layer* layer, int x, int y,
         cairo_surface_t* surface);
 
+/**
+ * Returns whether the owner of the given client supports the "required"
+ * instruction, based on the version of the Guacamole protocol negotiated
+ * with the owner.
+ *
+ * @param client
+ *     The Guacamole client whose owner should be checked.
+ *
+ * @return
+ *     Non-zero if the owner is connected and supports the "required"
+ *     instruction, zero otherwise.
+ */
+int guac_client_owner_supports_required(guac_client* client);
+
+/**
+ * Sends a "required" instruction to the owner of the given client,
+ * requesting the values of the given connection parameters. The values
+ * will be received along argv streams, and can be awaited with
+ * guac_argv_await().
+ *
+ * @param client
+ *     The Guacamole client whose owner should be asked for the parameters.
+ *
+ * @param required
+ *     A NULL-terminated array of the names of all required parameters.
+ *
+ * @return
+ *     Zero if the instruction was sent, non-zero if the owner is not
+ *     connected, does not support the "required" instruction, or an error
+ *     occurs.
+ */
+int guac_client_owner_send_required(guac_client* client, const char** required);
+
 /**
  * The default Guacamole client layer, layer 0.
  */
@@ -258,5 +258,38 @@ typedef enum guac_transfer_function {
 
 } guac_transfer_function;
 
+/**
+ * The versions of the Guacamole protocol known to libguac. Versions are
+ * negotiated during the handshake, and determine which instructions, such as
+ * "required" and "argv", are understood by the remote client. The numeric
+ * value of each version is ordered, such that newer versions compare greater
+ * than older versions.
+ */
+typedef enum guac_protocol_version {
+
+    /**
+     * An unknown version of the Guacamole protocol. Clients which do not
+     * declare any version are assumed to support version 1.0.0.
+     */
+    GUAC_PROTOCOL_VERSION_UNKNOWN = 0x000000,
+
+    /**
+     * The original version of the Guacamole protocol.
+     */
+    GUAC_PROTOCOL_VERSION_1_0_0 = 0x010000,
+
+    /**
+     * Version 1.1.0 of the Guacamole protocol, which introduces version
+     * negotiation, and the "required" and "argv" instructions.
+     */
+    GUAC_PROTOCOL_VERSION_1_1_0 = 0x010100
+
+} guac_protocol_version;
+
+/**
+ * The latest version of the Guacamole protocol supported by libguac.
+ */
+#define GUAC_PROTOCOL_LATEST_VERSION GUAC_PROTOCOL_VERSION_1_1_0
+
 #endif
 
@@ -485,6 +485,68 @@ int guac_protocol_send_clipboard(guac_socket* socket, const guac_stream* stream,
  */
 int guac_protocol_send_name(guac_socket* socket, const char* name);
 
+/**
+ * Sends an argv instruction over the given guac_socket connection, beginning
+ * a stream which contains the new value of the named connection parameter.
+ *
+ * If an error occurs sending the instruction, a non-zero value is
+ * returned, and guac_error is set appropriately.
+ *
+ * @param socket The guac_socket connection to use.
+ * @param stream The stream to use.
+ * @param mimetype The mimetype of the parameter value being sent.
+ * @param name The name of the connection parameter being updated.
+ * @return Zero on success, non-zero on error.
+ */
+int guac_protocol_send_argv(guac_socket* socket, const guac_stream* stream,
+        const char* mimetype, const char* name);
+
+/**
+ * Sends a required instruction over the given guac_socket connection,
+ * requesting the values of the given connection parameters, such as
+ * credentials which were not provided when the connection was established.
+ * The values are expected to be received along argv streams.
+ *
+ * If an error occurs sending the instruction, a non-zero value is
+ * returned, and guac_error is set appropriately.
+ *
+ * @param socket The guac_socket connection to use.
+ * @param required A NULL-terminated array of the names of all required
+ *                 connection parameters.
+ * @return Zero on success, non-zero on error.
+ */
+int guac_protocol_send_required(guac_socket* socket, const char** required);
+
+/**
+ * Returns the guac_protocol_version corresponding to the given version
+ * string, such as "VERSION_1_1_0".
+ *
+ * @param version_string The version string to convert.
+ * @return The corresponding version, or GUAC_PROTOCOL_VERSION_UNKNOWN if the
+ *         version string is not known.
+ */
+guac_protocol_version guac_protocol_string_to_version(const char* version_string);
+
+/**
+ * Returns the version string, such as "VERSION_1_1_0", of the given
+ * guac_protocol_version.
+ *
+ * @param version The version to convert.
+ * @return The corresponding version string, or NULL if the version is not
+ *         known.
+ */
+const char* guac_protocol_version_to_string(guac_protocol_version version);
+
+/**
+ * Returns whether the given version of the Guacamole protocol supports the
+ * "required" instruction.
+ *
+ * @param version The version to check.
+ * @return Non-zero if the "required" instruction is supported, zero
+ *         otherwise.
+ */
+int guac_protocol_version_supports_required(guac_protocol_version version);
+
 /**
  * Decodes the given base64-encoded string in-place. The base64 string must
  * be NULL-terminated.
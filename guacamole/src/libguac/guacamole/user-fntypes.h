@@ -401,5 +401,33 @@ typedef int guac_user_get_handler(guac_user* user, guac_object* object,
 typedef int guac_user_put_handler(guac_user* user, guac_object* object,
         guac_stream* stream, char* mimetype, char* name);
 
+/**
+ * Handler for Guacamole argument value (argv) streams received from a user.
+ * Each such stream begins when the user sends an "argv" instruction, either
+ * to provide a value requested by a "required" instruction or to update a
+ * connection parameter of an active connection. To handle received data along
+ * this stream, implementations of this handler must assign blob and end
+ * handlers to the given stream object.
+ *
+ * @param user
+ *     The user that opened the argument value stream.
+ *
+ * @param stream
+ *     The stream object allocated by libguac to represent the argument value
+ *     stream opened by the user.
+ *
+ * @param mimetype
+ *     The mimetype of the data that will be sent along the stream.
+ *
+ * @param name
+ *     The name of the connection parameter being updated.
+ *
+ * @return
+ *     Zero if the opening of the argument value stream has been handled
+ *     successfully, or non-zero if an error occurs.
+ */
+typedef int guac_user_argv_handler(guac_user* user, guac_stream* stream,
+        char* mimetype, char* name);
+
 #endif
 
@@ -116,6 +116,26 @@ struct guac_user_info {
      */
     int optimal_resolution;
 
+    /**
+     * NULL-terminated array of client-supported audio mimetypes. If the client
+     * does not support audio at all, this will be NULL.
+     */
+    const char** audio_mimetypes;
+
+    /**
+     * The timezone of the remote client, as an IANA timezone name such as
+     * "Europe/Berlin". If the client did not report a timezone, this will be
+     * NULL.
+     */
+    const char* timezone;
+
+    /**
+     * The version of the Guacamole protocol negotiated with the remote
+     * client. Features such as the "required" and "argv" instructions are
+     * only available if the negotiated version supports them.
+     */
+    guac_protocol_version protocol_version;
+
 };
 
 struct guac_user {
@@ -480,6 +500,27 @@ struct guac_user {
      */
     guac_user_put_handler* put_handler;
 
+    /**
+     * Handler for argv events (updated connection parameters) sent by the
+     * Guacamole web-client.
+     *
+     * The handler takes a guac_stream which contains the stream index and
+     * will persist through the duration of the transfer, the mimetype of the
+     * data being transferred, and the name of the connection parameter
+     * being updated.
+     *
+     * Example:
+     * @code
+     *     int argv_handler(guac_user* user, guac_stream* stream,
+     *             char* mimetype, char* name);
+     *
+     *     int guac_user_init(guac_user* user, int argc, char** argv) {
+     *         user->argv_handler = argv_handler;
+     *     }
+     * @endcode
+     */
+    guac_user_argv_handler* argv_handler;
+
 };
 
 /**
@@ -800,5 +841,18 @@ int guac_user_parse_args_int(guac_user* user, const char** arg_names,
 int guac_user_parse_args_boolean(guac_user* user, const char** arg_names,
         const char** argv, int index, int default_value);
 
+/**
+ * Returns whether the given user supports the "required" instruction, which
+ * depends on the version of the Guacamole protocol negotiated with the user.
+ *
+ * @param user
+ *     The user to check. This may be NULL.
+ *
+ * @return
+ *     Non-zero if the user supports the "required" instruction, zero
+ *     otherwise, including if the user is NULL.
+ */
+int guac_user_supports_required(guac_user* user);
+
 #endif
 
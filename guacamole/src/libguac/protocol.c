@@ -80,6 +80,26 @@ int guac_protocol_send_ack(guac_socket* socket, guac_stream* stream,
 
 }
 
+int guac_protocol_send_argv(guac_socket* socket, const guac_stream* stream,
+        const char* mimetype, const char* name) {
+
+    int ret_val;
+
+    guac_socket_instruction_begin(socket);
+    ret_val =
+           guac_socket_write_string(socket, "4.argv,")
+        || __guac_socket_write_length_int(socket, stream->index)
+        || guac_socket_write_string(socket, ",")
+        || __guac_socket_write_length_string(socket, mimetype)
+        || guac_socket_write_string(socket, ",")
+        || __guac_socket_write_length_string(socket, name)
+        || guac_socket_write_string(socket, ";");
+
+    guac_socket_instruction_end(socket);
+    return ret_val;
+
+}
+
 int guac_protocol_send_blob(guac_socket* socket, const guac_stream* stream,
         const void* data, int count) {
 
@@ -473,6 +493,26 @@ int guac_protocol_send_rect(guac_socket* socket,
 
 }
 
+int guac_protocol_send_required(guac_socket* socket, const char** required) {
+
+    int ret_val;
+
+    guac_socket_instruction_begin(socket);
+    ret_val = guac_socket_write_string(socket, "8.required");
+
+    for (int i = 0; !ret_val && required[i] != NULL; i++) {
+        ret_val =
+               guac_socket_write_string(socket, ",")
+            || __guac_socket_write_length_string(socket, required[i]);
+    }
+
+    ret_val = ret_val || guac_socket_write_string(socket, ";");
+
+    guac_socket_instruction_end(socket);
+    return ret_val;
+
+}
+
 int guac_protocol_send_shade(guac_socket* socket, const guac_layer* layer,
         int a) {
 
@@ -562,6 +602,46 @@ int guac_protocol_send_transfer(guac_socket* socket,
 /**
  * Returns the value of a single base64 character.
  */
+/**
+ * Mapping of protocol version strings, as sent within the args and connect
+ * instructions, to their corresponding guac_protocol_version values.
+ */
+static const struct {
+    guac_protocol_version version;
+    const char* version_string;
+} __guac_protocol_versions[] = {
+    { GUAC_PROTOCOL_VERSION_1_0_0, "VERSION_1_0_0" },
+    { GUAC_PROTOCOL_VERSION_1_1_0, "VERSION_1_1_0" },
+    { GUAC_PROTOCOL_VERSION_UNKNOWN, NULL }
+};
+
+guac_protocol_version guac_protocol_string_to_version(const char* version_string) {
+
+    for (int i = 0; __guac_protocol_versions[i].version_string != NULL; i++) {
+        if (strcmp(__guac_protocol_versions[i].version_string,
+                    version_string) == 0)
+            return __guac_protocol_versions[i].version;
+    }
+
+    return GUAC_PROTOCOL_VERSION_UNKNOWN;
+
+}
+
+const char* guac_protocol_version_to_string(guac_protocol_version version) {
+
+    for (int i = 0; __guac_protocol_versions[i].version_string != NULL; i++) {
+        if (__guac_protocol_versions[i].version == version)
+            return __guac_protocol_versions[i].version_string;
+    }
+
+    return NULL;
+
+}
+
+int guac_protocol_version_supports_required(guac_protocol_version version) {
+    return version >= GUAC_PROTOCOL_VERSION_1_1_0;
+}
+
 static int __guac_base64_value(char c) {
 
     if (c >= 'A' && c <= 'Z')
@@ -106,6 +106,13 @@ __guac_instruction_handler __guac_handle_clipboard;
  */
 __guac_instruction_handler __guac_handle_file;
 
+/**
+ * Internal initial handler for the argv instruction. When an argv instruction
+ * is received, this handler will be called. The user's argv handler will be
+ * invoked if defined.
+ */
+__guac_instruction_handler __guac_handle_argv;
+
 /**
  * Internal initial handler for the pipe instruction. When a pipe instruction
  * is received, this handler will be called. The client's pipe handler will
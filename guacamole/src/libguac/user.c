@@ -409,4 +409,13 @@ void guac_user_input_thread(guac_user* user, int usec_timeout) {
     }
     guac_parser_free(parser);
     return;
-}
\ No newline at end of file
+}
+
+int guac_user_supports_required(guac_user* user) {
+
+    if (user == NULL)
+        return 0;
+
+    return guac_protocol_version_supports_required(user->info.protocol_version);
+
+}
@@ -0,0 +1,96 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+#include "config.h"
+
+#include "argv.h"
+#include "rdp.h"
+#include "rdp_settings.h"
+
+#include <guacamole/argv.h>
+#include <guacamole/client.h>
+#include <guacamole/user.h>
+
+#include <stdlib.h>
+#include <string.h>
+
+int guac_rdp_argv_callback(guac_user* user, const char* mimetype,
+        const char* name, const char* value, void* data) {
+
+    guac_rdp_settings* settings = (guac_rdp_settings*) data;
+    char** setting;
+
+    if (strcmp(name, GUAC_RDP_ARGV_USERNAME) == 0)
+        setting = &settings->username;
+    else if (strcmp(name, GUAC_RDP_ARGV_PASSWORD) == 0)
+        setting = &settings->password;
+    else if (strcmp(name, GUAC_RDP_ARGV_DOMAIN) == 0)
+        setting = &settings->domain;
+    else
+        return 1;
+
+    free(*setting);
+    *setting = strdup(value);
+    return 0;
+
+}
+
+/**
+ * Returns whether the given setting has not been provided.
+ */
+static int guac_rdp_argv_missing(const char* value) {
+    return value == NULL || strcmp(value, "") == 0;
+}
+
+int guac_rdp_argv_await_credentials(guac_client* client) {
+
+    guac_rdp_client* rdp_client = (guac_rdp_client*) client->data;
+    guac_rdp_settings* settings = rdp_client->settings;
+
+    if (!guac_client_owner_supports_required(client))
+        return 0;
+
+    const char* params[4] = { NULL };
+    int i = 0;
+
+    if (guac_rdp_argv_missing(settings->username))
+        params[i++] = GUAC_RDP_ARGV_USERNAME;
+
+    if (guac_rdp_argv_missing(settings->password))
+        params[i++] = GUAC_RDP_ARGV_PASSWORD;
+
+    if (guac_rdp_argv_missing(settings->domain))
+        params[i++] = GUAC_RDP_ARGV_DOMAIN;
+
+    if (i == 0)
+        return 0;
+
+    for (int j = 0; j < i; j++)
+        guac_argv_register(client, params[j], guac_rdp_argv_callback,
+                settings, GUAC_ARGV_OPTION_ONCE);
+
+    if (guac_client_owner_send_required(client, params))
+        return 0;
+
+    guac_client_log(client, GUAC_LOG_DEBUG,
+            "Awaiting missing credentials from the owner");
+    return guac_argv_await(client, params);
+
+}
+
@@ -0,0 +1,70 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+#ifndef GUAC_RDP_ARGV_H
+#define GUAC_RDP_ARGV_H
+
+#include "config.h"
+
+#include <guacamole/argv.h>
+
+/**
+ * The name of the parameter which specifies the username, as requested
+ * from the owner by a "required" instruction.
+ */
+#define GUAC_RDP_ARGV_USERNAME "username"
+
+/**
+ * The name of the parameter which specifies the password, as requested
+ * from the owner by a "required" instruction.
+ */
+#define GUAC_RDP_ARGV_PASSWORD "password"
+
+/**
+ * The name of the parameter which specifies the domain, as requested from
+ * the owner by a "required" instruction.
+ */
+#define GUAC_RDP_ARGV_DOMAIN "domain"
+
+/**
+ * Handles a received value of the username, password or domain by storing
+ * it within the settings of the RDP connection, replacing any previous
+ * value. The data given to the callback must be the guac_rdp_settings of
+ * the connection.
+ */
+guac_argv_callback guac_rdp_argv_callback;
+
+/**
+ * Requests any missing credentials of the given RDP connection from the
+ * owner by a "required" instruction, and waits until all of them have been
+ * received. If the owner does not support the "required" instruction, this
+ * function does nothing.
+ *
+ * @param client
+ *     The guac_client of the RDP connection.
+ *
+ * @return
+ *     Zero if all credentials are present or have been received, or the
+ *     owner cannot be asked for them. Non-zero if the client stopped while
+ *     waiting.
+ */
+int guac_rdp_argv_await_credentials(guac_client* client);
+
+#endif
+
@@ -19,6 +19,7 @@
 
 #include "config.h"
 
+#include "argv.h"
 #include "client.h"
 #include "common/cursor.h"
 #include "common/display.h"
@@ -404,8 +405,9 @@ static BOOL rdp_freerdp_post_connect(freerdp* instance) {
 
 /**
  * Callback invoked by FreeRDP when authentication is required but a username
- * and password has not already been given. In the case of Guacamole, this
- * function always succeeds but does not populate the usename or password. The
+ * and password has not already been given. If the owner supports the
+ * "required" instruction, the missing credentials are requested from the
+ * owner and this function blocks until they are received. Otherwise, the
  * username/password must be given within the connection parameters.
  *
  * @param instance
@@ -423,17 +425,36 @@ static BOOL rdp_freerdp_post_connect(freerdp* instance) {
  *     user's account.
  *
  * @return
- *     Always TRUE.
+ *     TRUE, or FALSE if the connection stopped while awaiting credentials.
  */
 static BOOL rdp_freerdp_authenticate(freerdp* instance, char** username,
         char** password, char** domain) {
 
     rdpContext* context = instance->context;
     guac_client* client = ((rdp_freerdp_context*) context)->client;
+    guac_rdp_client* rdp_client = (guac_rdp_client*) client->data;
+    guac_rdp_settings* settings = rdp_client->settings;
+
+    /* Ask the owner for missing credentials, if supported */
+    if (guac_rdp_argv_await_credentials(client))
+        return FALSE;
+
+    if (settings->username == NULL || settings->password == NULL) {
+
+        /* Warn if connection is likely to fail due to lack of credentials */
+        guac_client_log(client, GUAC_LOG_INFO,
+                "Authentication requested but username or password not given");
+        return TRUE;
+
+    }
 
-    /* Warn if connection is likely to fail due to lack of credentials */
-    guac_client_log(client, GUAC_LOG_INFO,
-            "Authentication requested but username or password not given");
+    /* Replace credentials of FreeRDP with received ones */
+    free(*username);
+    free(*password);
+    free(*domain);
+    *username = strdup(settings->username);
+    *password = strdup(settings->password);
+    *domain = settings->domain != NULL ? strdup(settings->domain) : NULL;
     return TRUE;
 
 }
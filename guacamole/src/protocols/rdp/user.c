@@ -27,6 +27,7 @@
 #include "rdp_stream.h"
 #include "rdp_svc.h"
 
+#include <guacamole/argv.h>
 #include <guacamole/client.h>
 #include <guacamole/protocol.h>
 #include <guacamole/socket.h>
@@ -58,6 +59,9 @@ int guac_rdp_user_join_handler(guac_user* user, int argc, char** argv) {
         /* Store owner's settings at client level */
         rdp_client->settings = settings;
 
+        /* Accept credentials requested by "required" instructions */
+        user->argv_handler = guac_argv_handler;
+
         /* Start client thread */
         if (pthread_create(&rdp_client->client_thread, NULL,
                     guac_rdp_client_thread, user->client)) {
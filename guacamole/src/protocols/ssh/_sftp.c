@@ -0,0 +1,1084 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+#include "common/json.h"
+#include "_sftp.h"
+#include "_ssh.h"
+
+#include <guacamole/client.h>
+#include <guacamole/object.h>
+#include <guacamole/protocol.h>
+#include <guacamole/socket.h>
+#include <guacamole/stream.h>
+#include <guacamole/user.h>
+#include <libssh2.h>
+#include <libssh2_sftp.h>
+
+#include <pthread.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <sys/stat.h>
+
+/**
+ * An SFTP file which is being uploaded or downloaded through a stream.
+ */
+typedef struct guac_common_ssh_sftp_transfer {
+
+    /**
+     * The filesystem containing the file.
+     */
+    guac_common_ssh_sftp_filesystem* filesystem;
+
+    /**
+     * The open file being transferred.
+     */
+    LIBSSH2_SFTP_HANDLE* file;
+
+} guac_common_ssh_sftp_transfer;
+
+/**
+ * Translates the last error of the SFTP session of the given filesystem into
+ * a Guacamole protocol status.
+ *
+ * @param filesystem
+ *     The filesystem whose last error should be translated.
+ *
+ * @return
+ *     The Guacamole protocol status which best describes the last error.
+ */
+static guac_protocol_status guac_sftp_get_status(
+        guac_common_ssh_sftp_filesystem* filesystem) {
+
+    /* Errors which are not SFTP errors are errors of the SSH connection */
+    if (libssh2_session_last_errno(filesystem->ssh_session->session)
+            != LIBSSH2_ERROR_SFTP_PROTOCOL)
+        return GUAC_PROTOCOL_STATUS_UPSTREAM_ERROR;
+
+    switch (libssh2_sftp_last_error(filesystem->sftp_session)) {
+
+        case LIBSSH2_FX_OK:
+        case LIBSSH2_FX_EOF:
+            return GUAC_PROTOCOL_STATUS_SUCCESS;
+
+        case LIBSSH2_FX_NO_SUCH_FILE:
+        case LIBSSH2_FX_NO_SUCH_PATH:
+            return GUAC_PROTOCOL_STATUS_RESOURCE_NOT_FOUND;
+
+        case LIBSSH2_FX_PERMISSION_DENIED:
+        case LIBSSH2_FX_WRITE_PROTECT:
+            return GUAC_PROTOCOL_STATUS_CLIENT_FORBIDDEN;
+
+        case LIBSSH2_FX_FILE_ALREADY_EXISTS:
+        case LIBSSH2_FX_LOCK_CONFLICT:
+            return GUAC_PROTOCOL_STATUS_RESOURCE_CONFLICT;
+
+        case LIBSSH2_FX_QUOTA_EXCEEDED:
+        case LIBSSH2_FX_NO_SPACE_ON_FILESYSTEM:
+            return GUAC_PROTOCOL_STATUS_CLIENT_OVERRUN;
+
+        case LIBSSH2_FX_INVALID_FILENAME:
+        case LIBSSH2_FX_NOT_A_DIRECTORY:
+            return GUAC_PROTOCOL_STATUS_CLIENT_BAD_REQUEST;
+
+        case LIBSSH2_FX_OP_UNSUPPORTED:
+            return GUAC_PROTOCOL_STATUS_UNSUPPORTED;
+
+        case LIBSSH2_FX_NO_CONNECTION:
+        case LIBSSH2_FX_CONNECTION_LOST:
+            return GUAC_PROTOCOL_STATUS_UPSTREAM_UNAVAILABLE;
+
+    }
+
+    return GUAC_PROTOCOL_STATUS_UPSTREAM_ERROR;
+
+}
+
+int guac_common_ssh_sftp_normalize_path(char* fullpath,
+        const char* path) {
+
+    int i;
+
+    int path_depth = 0;
+    const char* path_components[GUAC_COMMON_SSH_SFTP_MAX_DEPTH];
+
+    /* If original path is not absolute, normalization fails */
+    if (path[0] != '\\' && path[0] != '/')
+        return 0;
+
+    /* Create scratch copy of path excluding leading slash (we will be
+     * replacing path separators with null terminators and referencing those
+     * substrings directly as path components) */
+    char path_scratch[GUAC_COMMON_SSH_SFTP_MAX_PATH - 1];
+    int length = strlen(path + 1);
+
+    /* Fail if provided path is too long */
+    if (length >= sizeof(path_scratch))
+        return 0;
+
+    memcpy(path_scratch, path + 1, length + 1);
+
+    /* Locate all path components within path */
+    const char* current_path_component = &(path_scratch[0]);
+    for (i = 0; i <= length; i++) {
+
+        /* If current character is a path separator, parse as component */
+        char c = path_scratch[i];
+        if (c == '/' || c == '\\' || c == '\0') {
+
+            /* Terminate current component */
+            path_scratch[i] = '\0';
+
+            /* If component refers to parent, just move up in depth */
+            if (strcmp(current_path_component, "..") == 0) {
+                if (path_depth > 0)
+                    path_depth--;
+            }
+
+            /* Otherwise, if component not current directory, add to list */
+            else if (strcmp(current_path_component, ".") != 0
+                    && strcmp(current_path_component, "") != 0) {
+
+                /* Fail normalization if path is too deep */
+                if (path_depth >= GUAC_COMMON_SSH_SFTP_MAX_DEPTH)
+                    return 0;
+
+                path_components[path_depth++] = current_path_component;
+
+            }
+
+            /* Update start of next component */
+            current_path_component = &(path_scratch[i+1]);
+
+        }
+
+    }
+
+    /* Add leading slash for resulting absolute path */
+    int written = 0;
+    fullpath[written++] = '/';
+
+    /* Join normalized components back together, the joined path can never
+     * be longer than the original path */
+    for (i = 0; i < path_depth; i++) {
+
+        if (i > 0)
+            fullpath[written++] = '/';
+
+        int component_length = strlen(path_components[i]);
+        memcpy(fullpath + written, path_components[i], component_length);
+        written += component_length;
+
+    }
+
+    fullpath[written] = '\0';
+    return 1;
+
+}
+
+/**
+ * Translates a path of the filesystem object, which is relative to the root
+ * directory, into the absolute path on the SSH server. Relative path
+ * components cannot leave the root directory.
+ *
+ * @param filesystem
+ *     The filesystem whose root directory the path is relative to.
+ *
+ * @param name
+ *     The absolute path within the filesystem object.
+ *
+ * @param fullpath
+ *     The buffer to populate with the path on the SSH server. This buffer
+ *     MUST be at least GUAC_COMMON_SSH_SFTP_MAX_PATH bytes in size.
+ *
+ * @return
+ *     Non-zero if the path was translated, zero if the path is invalid or
+ *     too long.
+ */
+static int guac_common_ssh_sftp_translate_name(
+        guac_common_ssh_sftp_filesystem* filesystem, const char* name,
+        char* fullpath) {
+
+    char normalized_path[GUAC_COMMON_SSH_SFTP_MAX_PATH];
+    if (!guac_common_ssh_sftp_normalize_path(normalized_path, name))
+        return 0;
+
+    /* Paths are not prefixed if the root directory is the server root */
+    if (strcmp(filesystem->root_path, "/") == 0) {
+        strcpy(fullpath, normalized_path);
+        return 1;
+    }
+
+    /* The root of the filesystem is the root directory itself */
+    if (strcmp(normalized_path, "/") == 0) {
+        strcpy(fullpath, filesystem->root_path);
+        return 1;
+    }
+
+    return snprintf(fullpath, GUAC_COMMON_SSH_SFTP_MAX_PATH, "%s%s",
+            filesystem->root_path, normalized_path)
+        < GUAC_COMMON_SSH_SFTP_MAX_PATH;
+
+}
+
+/**
+ * Returns whether the given normalized path on the SSH server is the root
+ * directory of the filesystem or lies within it.
+ *
+ * @param filesystem
+ *     The filesystem whose root directory should be checked.
+ *
+ * @param path
+ *     The absolute and normalized path on the SSH server.
+ *
+ * @return
+ *     Non-zero if the path is within the root directory, zero otherwise.
+ */
+static int guac_common_ssh_sftp_within_root(
+        guac_common_ssh_sftp_filesystem* filesystem, const char* path) {
+
+    /* Every path is within the server root */
+    if (strcmp(filesystem->root_path, "/") == 0)
+        return 1;
+
+    int length = strlen(filesystem->root_path);
+    return strncmp(path, filesystem->root_path, length) == 0
+        && (path[length] == '\0' || path[length] == '/');
+
+}
+
+/**
+ * Concatenates the given filename with the given path, separating the two
+ * with a single forward slash. The full result must be no more than
+ * GUAC_COMMON_SSH_SFTP_MAX_PATH bytes long, counting null terminator.
+ *
+ * @param fullpath
+ *     The buffer to store the result within. This buffer must be at least
+ *     GUAC_COMMON_SSH_SFTP_MAX_PATH bytes long.
+ *
+ * @param path
+ *     The path to append the filename to.
+ *
+ * @param filename
+ *     The filename to append to the path.
+ *
+ * @return
+ *     Non-zero if the filename is valid and was successfully appended to the
+ *     path, zero otherwise.
+ */
+static int guac_common_ssh_sftp_append_filename(char* fullpath,
+        const char* path, const char* filename) {
+
+    /* Disallow "." and ".." filenames */
+    if (strcmp(filename, "") == 0 || strcmp(filename, ".") == 0
+            || strcmp(filename, "..") == 0)
+        return 0;
+
+    /* Filenames may not contain slashes */
+    if (strchr(filename, '/') != NULL || strchr(filename, '\\') != NULL)
+        return 0;
+
+    /* Ensure path ends with trailing slash */
+    const char* separator = "/";
+    int length = strlen(path);
+    if (length > 0 && path[length - 1] == '/')
+        separator = "";
+
+    return snprintf(fullpath, GUAC_COMMON_SSH_SFTP_MAX_PATH, "%s%s%s",
+            path, separator, filename) < GUAC_COMMON_SSH_SFTP_MAX_PATH;
+
+}
+
+/**
+ * Returns a new string containing the last component of the given normalized
+ * path, or "/" if the path is the root directory. The returned string must
+ * eventually be freed.
+ *
+ * @param path
+ *     The absolute and normalized path to return the name of.
+ *
+ * @return
+ *     A new string containing the name of the path.
+ */
+static char* guac_common_ssh_sftp_get_filesystem_name(const char* path) {
+
+    const char* last = strrchr(path, '/');
+    if (last == NULL || last[1] == '\0')
+        return strdup("/");
+
+    return strdup(last + 1);
+
+}
+
+/**
+ * Opens the given file on the SSH server for writing, and begins receiving
+ * its contents from the given stream. The stream is acknowledged with the
+ * result of opening the file.
+ *
+ * @param filesystem
+ *     The filesystem containing the file.
+ *
+ * @param user
+ *     The user uploading the file.
+ *
+ * @param stream
+ *     The stream through which the file contents will be received.
+ *
+ * @param fullpath
+ *     The absolute path of the file on the SSH server.
+ */
+static void guac_common_ssh_sftp_open_upload(
+        guac_common_ssh_sftp_filesystem* filesystem, guac_user* user,
+        guac_stream* stream, const char* fullpath);
+
+/**
+ * Handler for blob messages which continue an inbound SFTP data transfer
+ * (upload). The data associated with the given stream is expected to be a
+ * pointer to a guac_common_ssh_sftp_transfer.
+ *
+ * @param user
+ *     The user receiving the blob message.
+ *
+ * @param stream
+ *     The Guacamole protocol stream associated with the received blob
+ *     message.
+ *
+ * @param data
+ *     The data received within the blob.
+ *
+ * @param length
+ *     The length of the received data, in bytes.
+ *
+ * @return
+ *     Zero if the blob is handled successfully, or non-zero on error.
+ */
+static int guac_common_ssh_sftp_blob_handler(guac_user* user,
+        guac_stream* stream, void* data, int length) {
+
+    guac_common_ssh_sftp_transfer* transfer =
+        (guac_common_ssh_sftp_transfer*) stream->data;
+    guac_common_ssh_sftp_filesystem* filesystem = transfer->filesystem;
+
+    pthread_mutex_lock(&(filesystem->lock));
+
+    /* Attempt write, which may complete partially */
+    const char* buffer = (const char*) data;
+    int remaining = length;
+    while (remaining > 0) {
+        ssize_t written = libssh2_sftp_write(transfer->file, buffer,
+                remaining);
+        if (written <= 0)
+            break;
+        buffer += written;
+        remaining -= written;
+    }
+
+    /* Acknowledge the data, or report the error */
+    if (remaining == 0) {
+        guac_user_log(user, GUAC_LOG_DEBUG, "%i bytes written", length);
+        guac_protocol_send_ack(user->socket, stream, "SFTP: OK",
+                GUAC_PROTOCOL_STATUS_SUCCESS);
+    }
+    else {
+        guac_user_log(user, GUAC_LOG_INFO, "Unable to write to file");
+        guac_protocol_send_ack(user->socket, stream, "SFTP: Write failed",
+                guac_sftp_get_status(filesystem));
+    }
+
+    guac_socket_flush(user->socket);
+    pthread_mutex_unlock(&(filesystem->lock));
+    return 0;
+
+}
+
+/**
+ * Handler for end messages which terminate an inbound SFTP data transfer
+ * (upload). The data associated with the given stream is expected to be a
+ * pointer to a guac_common_ssh_sftp_transfer, which is freed.
+ *
+ * @param user
+ *     The user receiving the end message.
+ *
+ * @param stream
+ *     The Guacamole protocol stream associated with the received end
+ *     message.
+ *
+ * @return
+ *     Zero if the end message is handled successfully, or non-zero on error.
+ */
+static int guac_common_ssh_sftp_end_handler(guac_user* user,
+        guac_stream* stream) {
+
+    guac_common_ssh_sftp_transfer* transfer =
+        (guac_common_ssh_sftp_transfer*) stream->data;
+    guac_common_ssh_sftp_filesystem* filesystem = transfer->filesystem;
+
+    pthread_mutex_lock(&(filesystem->lock));
+
+    /* Attempt to close file */
+    if (libssh2_sftp_close(transfer->file) == 0) {
+        guac_user_log(user, GUAC_LOG_DEBUG, "File closed");
+        guac_protocol_send_ack(user->socket, stream, "SFTP: OK",
+                GUAC_PROTOCOL_STATUS_SUCCESS);
+    }
+    else {
+        guac_user_log(user, GUAC_LOG_INFO, "Unable to close file");
+        guac_protocol_send_ack(user->socket, stream, "SFTP: Close failed",
+                guac_sftp_get_status(filesystem));
+    }
+
+    guac_socket_flush(user->socket);
+    pthread_mutex_unlock(&(filesystem->lock));
+
+    free(transfer);
+    stream->data = NULL;
+    return 0;
+
+}
+
+static void guac_common_ssh_sftp_open_upload(
+        guac_common_ssh_sftp_filesystem* filesystem, guac_user* user,
+        guac_stream* stream, const char* fullpath) {
+
+    /* Open file via SFTP */
+    LIBSSH2_SFTP_HANDLE* file = libssh2_sftp_open(filesystem->sftp_session,
+            fullpath, LIBSSH2_FXF_WRITE | LIBSSH2_FXF_CREAT | LIBSSH2_FXF_TRUNC,
+            S_IRUSR | S_IWUSR);
+
+    /* Inform of status */
+    if (file == NULL) {
+        guac_user_log(user, GUAC_LOG_INFO, "Unable to open file \"%s\"",
+                fullpath);
+        guac_protocol_send_ack(user->socket, stream, "SFTP: Open failed",
+                guac_sftp_get_status(filesystem));
+        guac_socket_flush(user->socket);
+        return;
+    }
+
+    guac_user_log(user, GUAC_LOG_DEBUG, "File \"%s\" opened", fullpath);
+    guac_protocol_send_ack(user->socket, stream, "SFTP: File opened",
+            GUAC_PROTOCOL_STATUS_SUCCESS);
+    guac_socket_flush(user->socket);
+
+    guac_common_ssh_sftp_transfer* transfer =
+        malloc(sizeof(guac_common_ssh_sftp_transfer));
+    transfer->filesystem = filesystem;
+    transfer->file = file;
+
+    /* Set handlers for file stream */
+    stream->blob_handler = guac_common_ssh_sftp_blob_handler;
+    stream->end_handler = guac_common_ssh_sftp_end_handler;
+    stream->data = transfer;
+
+}
+
+int guac_common_ssh_sftp_handle_file_stream(
+        guac_common_ssh_sftp_filesystem* filesystem, guac_user* user,
+        guac_stream* stream, char* mimetype, char* filename) {
+
+    char fullpath[GUAC_COMMON_SSH_SFTP_MAX_PATH];
+
+    /* Reject upload if disabled */
+    if (filesystem->disable_upload) {
+        guac_user_log(user, GUAC_LOG_INFO, "Upload of \"%s\" rejected, "
+                "uploads are disabled", filename);
+        guac_protocol_send_ack(user->socket, stream, "SFTP: Upload disabled",
+                GUAC_PROTOCOL_STATUS_CLIENT_FORBIDDEN);
+        guac_socket_flush(user->socket);
+        return 0;
+    }
+
+    pthread_mutex_lock(&(filesystem->lock));
+
+    /* Concatenate filename with path */
+    if (!guac_common_ssh_sftp_append_filename(fullpath,
+                filesystem->upload_path, filename)) {
+
+        guac_user_log(user, GUAC_LOG_DEBUG, "Filename \"%s\" is invalid or "
+                "resulting path is too long", filename);
+
+        /* Abort transfer - invalid filename */
+        guac_protocol_send_ack(user->socket, stream, "SFTP: Illegal filename",
+                GUAC_PROTOCOL_STATUS_CLIENT_BAD_REQUEST);
+        guac_socket_flush(user->socket);
+
+    }
+    else
+        guac_common_ssh_sftp_open_upload(filesystem, user, stream, fullpath);
+
+    pthread_mutex_unlock(&(filesystem->lock));
+    return 0;
+
+}
+
+/**
+ * Handler for ack messages which continue an outbound SFTP data transfer
+ * (download), signalling the current status and requesting additional data.
+ * The data associated with the given stream is expected to be a pointer to a
+ * guac_common_ssh_sftp_transfer, which is freed once the transfer ends.
+ *
+ * @param user
+ *     The user receiving the ack message.
+ *
+ * @param stream
+ *     The Guacamole protocol stream associated with the received ack message.
+ *
+ * @param message
+ *     An arbitrary human-readable message describing the nature of the
+ *     success or failure denoted by this ack message.
+ *
+ * @param status
+ *     The status code associated with this ack message, which may indicate
+ *     success or an error.
+ *
+ * @return
+ *     Zero if the file is read from successfully, or non-zero on error.
+ */
+static int guac_common_ssh_sftp_ack_handler(guac_user* user,
+        guac_stream* stream, char* message, guac_protocol_status status) {
+
+    guac_common_ssh_sftp_transfer* transfer =
+        (guac_common_ssh_sftp_transfer*) stream->data;
+    guac_common_ssh_sftp_filesystem* filesystem = transfer->filesystem;
+
+    pthread_mutex_lock(&(filesystem->lock));
+
+    /* If successful, read data */
+    int done = 1;
+    if (status == GUAC_PROTOCOL_STATUS_SUCCESS) {
+
+        /* Attempt read into buffer */
+        char buffer[4096];
+        int bytes_read = libssh2_sftp_read(transfer->file, buffer,
+                sizeof(buffer));
+
+        /* If bytes read, send as blob */
+        if (bytes_read > 0) {
+            guac_protocol_send_blob(user->socket, stream, buffer, bytes_read);
+            guac_user_log(user, GUAC_LOG_DEBUG, "%i bytes sent to user",
+                    bytes_read);
+            done = 0;
+        }
+
+        /* If EOF, send end */
+        else if (bytes_read == 0) {
+            guac_user_log(user, GUAC_LOG_DEBUG, "File sent");
+            guac_protocol_send_end(user->socket, stream);
+        }
+
+        /* Otherwise, fail stream */
+        else {
+            guac_user_log(user, GUAC_LOG_INFO, "Error reading file");
+            guac_protocol_send_end(user->socket, stream);
+        }
+
+        guac_socket_flush(user->socket);
+
+    }
+
+    /* Clean up once the transfer is complete or rejected */
+    if (done) {
+        libssh2_sftp_close(transfer->file);
+        guac_user_free_stream(user, stream);
+        free(transfer);
+    }
+
+    pthread_mutex_unlock(&(filesystem->lock));
+    return 0;
+
+}
+
+/**
+ * Opens the given file on the SSH server for reading, and begins an outbound
+ * stream of its contents to the given user. The stream is begun by the
+ * instruction which the given function sends.
+ *
+ * @param filesystem
+ *     The filesystem containing the file.
+ *
+ * @param user
+ *     The user receiving the file.
+ *
+ * @param fullpath
+ *     The absolute path of the file on the SSH server.
+ *
+ * @return
+ *     The stream allocated for the file, which is not yet begun, or NULL if
+ *     the file cannot be opened.
+ */
+static guac_stream* guac_common_ssh_sftp_open_download(
+        guac_common_ssh_sftp_filesystem* filesystem, guac_user* user,
+        const char* fullpath) {
+
+    /* Attempt to open file for reading */
+    LIBSSH2_SFTP_HANDLE* file = libssh2_sftp_open(filesystem->sftp_session,
+            fullpath, LIBSSH2_FXF_READ, 0);
+    if (file == NULL) {
+        guac_user_log(user, GUAC_LOG_INFO, "Unable to read file \"%s\"",
+                fullpath);
+        return NULL;
+    }
+
+    guac_common_ssh_sftp_transfer* transfer =
+        malloc(sizeof(guac_common_ssh_sftp_transfer));
+    transfer->filesystem = filesystem;
+    transfer->file = file;
+
+    /* Allocate stream, which is driven by acks */
+    guac_stream* stream = guac_user_alloc_stream(user);
+    stream->ack_handler = guac_common_ssh_sftp_ack_handler;
+    stream->data = transfer;
+
+    return stream;
+
+}
+
+guac_stream* guac_common_ssh_sftp_download_file(
+        guac_common_ssh_sftp_filesystem* filesystem, guac_user* user,
+        char* filename) {
+
+    char fullpath[GUAC_COMMON_SSH_SFTP_MAX_PATH];
+
+    /* Ignore download if downloads have been disabled */
+    if (filesystem->disable_download) {
+        guac_user_log(user, GUAC_LOG_WARNING, "A download attempt has "
+                "been blocked due to downloads being disabled, however it "
+                "should have been blocked at a higher level. This is likely "
+                "a bug.");
+        return NULL;
+    }
+
+    /* Only files within the root directory can be downloaded */
+    if (!guac_common_ssh_sftp_normalize_path(fullpath, filename)
+            || !guac_common_ssh_sftp_within_root(filesystem, fullpath)) {
+        guac_user_log(user, GUAC_LOG_INFO, "Download of \"%s\" rejected, "
+                "the file is not within the root directory \"%s\"",
+                filename, filesystem->root_path);
+        return NULL;
+    }
+
+    pthread_mutex_lock(&(filesystem->lock));
+
+    guac_stream* stream = guac_common_ssh_sftp_open_download(filesystem,
+            user, fullpath);
+
+    /* Send stream start, strip name */
+    if (stream != NULL) {
+        guac_protocol_send_file(user->socket, stream,
+                GUAC_COMMON_SSH_SFTP_FILE_MIMETYPE,
+                strrchr(fullpath, '/') + 1);
+        guac_socket_flush(user->socket);
+        guac_user_log(user, GUAC_LOG_DEBUG, "Sending file \"%s\"", fullpath);
+    }
+
+    pthread_mutex_unlock(&(filesystem->lock));
+    return stream;
+
+}
+
+void guac_common_ssh_sftp_set_upload_path(
+        guac_common_ssh_sftp_filesystem* filesystem, const char* path) {
+
+    guac_client* client = filesystem->ssh_session->client;
+    char normalized_path[GUAC_COMMON_SSH_SFTP_MAX_PATH];
+
+    /* Ignore paths which cannot be normalized or leave the root directory */
+    if (!guac_common_ssh_sftp_normalize_path(normalized_path, path)
+            || !guac_common_ssh_sftp_within_root(filesystem, normalized_path)) {
+        guac_client_log(client, GUAC_LOG_WARNING, "Upload path \"%s\" "
+                "ignored, the path is not within the root directory \"%s\"",
+                path, filesystem->root_path);
+        return;
+    }
+
+    pthread_mutex_lock(&(filesystem->lock));
+    strcpy(filesystem->upload_path, normalized_path);
+    pthread_mutex_unlock(&(filesystem->lock));
+
+    guac_client_log(client, GUAC_LOG_DEBUG, "Upload path set to \"%s\"",
+            normalized_path);
+
+}
+
+/**
+ * Handler for ack messages received due to receipt of a "body" or "blob"
+ * instruction associated with a SFTP directory list operation. The listing
+ * is sent as a JSON object which maps the paths of the entries to their
+ * mimetypes, blob by blob. The data associated with the given stream is
+ * expected to be a pointer to a guac_common_ssh_sftp_ls_state, which is
+ * freed once the listing ends.
+ *
+ * @param user
+ *     The user receiving the ack message.
+ *
+ * @param stream
+ *     The Guacamole protocol stream associated with the received ack message.
+ *
+ * @param message
+ *     An arbitrary human-readable message describing the nature of the
+ *     success or failure denoted by this ack message.
+ *
+ * @param status
+ *     The status code associated with this ack message, which may indicate
+ *     success or an error.
+ *
+ * @return
+ *     Zero on success, non-zero on error.
+ */
+static int guac_common_ssh_sftp_ls_ack_handler(guac_user* user,
+        guac_stream* stream, char* message, guac_protocol_status status) {
+
+    int bytes_read = 0;
+    int blob_written = 0;
+
+    char filename[GUAC_COMMON_SSH_SFTP_MAX_PATH];
+    LIBSSH2_SFTP_ATTRIBUTES attributes;
+
+    guac_common_ssh_sftp_ls_state* list_state =
+        (guac_common_ssh_sftp_ls_state*) stream->data;
+
+    guac_common_ssh_sftp_filesystem* filesystem = list_state->filesystem;
+    LIBSSH2_SFTP* sftp = filesystem->sftp_session;
+
+    pthread_mutex_lock(&(filesystem->lock));
+
+    /* If unsuccessful, free stream and abort */
+    if (status != GUAC_PROTOCOL_STATUS_SUCCESS) {
+        libssh2_sftp_closedir(list_state->directory);
+        guac_user_free_stream(user, stream);
+        free(list_state);
+        pthread_mutex_unlock(&(filesystem->lock));
+        return 0;
+    }
+
+    /* While directory entries remain and a blob has not yet been sent */
+    while (!blob_written && (bytes_read = libssh2_sftp_readdir(
+                    list_state->directory, filename, sizeof(filename),
+                    &attributes)) > 0) {
+
+        char absolute_path[GUAC_COMMON_SSH_SFTP_MAX_PATH];
+        char fullpath[GUAC_COMMON_SSH_SFTP_MAX_PATH];
+
+        /* Skip current and parent directory entries */
+        if (strcmp(filename, ".") == 0 || strcmp(filename, "..") == 0)
+            continue;
+
+        /* Concatenate into absolute path - skip if invalid */
+        if (!guac_common_ssh_sftp_append_filename(absolute_path,
+                    list_state->directory_name, filename)) {
+
+            guac_user_log(user, GUAC_LOG_DEBUG,
+                    "Skipping filename \"%s\" - filename is invalid or "
+                    "resulting path is too long", filename);
+
+            continue;
+        }
+
+        /* Stat explicitly if symbolic link (might point to directory) */
+        if (LIBSSH2_SFTP_S_ISLNK(attributes.permissions)
+                && guac_common_ssh_sftp_translate_name(filesystem,
+                    absolute_path, fullpath))
+            libssh2_sftp_stat(sftp, fullpath, &attributes);
+
+        /* Determine mimetype */
+        const char* mimetype;
+        if (LIBSSH2_SFTP_S_ISDIR(attributes.permissions))
+            mimetype = GUAC_USER_STREAM_INDEX_MIMETYPE;
+        else
+            mimetype = GUAC_COMMON_SSH_SFTP_FILE_MIMETYPE;
+
+        /* Write entry */
+        blob_written |= guac_common_json_write_property(user, stream,
+                &list_state->json_state, absolute_path, mimetype);
+
+    }
+
+    /* Complete JSON and cleanup at end of directory */
+    if (bytes_read <= 0) {
+
+        /* Complete JSON object */
+        guac_common_json_end_object(user, stream, &list_state->json_state);
+        guac_common_json_flush(user, stream, &list_state->json_state);
+
+        /* Clean up resources */
+        libssh2_sftp_closedir(list_state->directory);
+        free(list_state);
+
+        /* Signal of stream */
+        guac_protocol_send_end(user->socket, stream);
+        guac_user_free_stream(user, stream);
+
+    }
+
+    guac_socket_flush(user->socket);
+    pthread_mutex_unlock(&(filesystem->lock));
+    return 0;
+
+}
+
+/**
+ * Handler for get messages. In context of SFTP and the filesystem exposed via
+ * the Guacamole protocol, get messages request the body of a file within the
+ * filesystem, or the listing of a directory as a stream index.
+ *
+ * @param user
+ *     The user who sent the get message.
+ *
+ * @param object
+ *     The Guacamole protocol object associated with the get request itself.
+ *
+ * @param name
+ *     The name of the input stream (file) being requested.
+ *
+ * @return
+ *     Zero on success, non-zero on error.
+ */
+static int guac_common_ssh_sftp_get_handler(guac_user* user,
+        guac_object* object, char* name) {
+
+    guac_common_ssh_sftp_filesystem* filesystem =
+        (guac_common_ssh_sftp_filesystem*) object->data;
+
+    LIBSSH2_SFTP* sftp = filesystem->sftp_session;
+    LIBSSH2_SFTP_ATTRIBUTES attributes;
+
+    /* Attempt to translate requested path */
+    char fullpath[GUAC_COMMON_SSH_SFTP_MAX_PATH];
+    char normalized_name[GUAC_COMMON_SSH_SFTP_MAX_PATH];
+    if (!guac_common_ssh_sftp_normalize_path(normalized_name, name)
+            || !guac_common_ssh_sftp_translate_name(filesystem,
+                normalized_name, fullpath)) {
+        guac_user_log(user, GUAC_LOG_INFO, "Unable to generate real path "
+                "for stream \"%s\"", name);
+        return 0;
+    }
+
+    pthread_mutex_lock(&(filesystem->lock));
+
+    /* Attempt to read file information */
+    if (libssh2_sftp_stat(sftp, fullpath, &attributes)) {
+        guac_user_log(user, GUAC_LOG_INFO, "Unable to read file \"%s\"",
+                fullpath);
+        pthread_mutex_unlock(&(filesystem->lock));
+        return 0;
+    }
+
+    /* If directory, send contents of directory */
+    if (LIBSSH2_SFTP_S_ISDIR(attributes.permissions)) {
+
+        /* Open as directory */
+        LIBSSH2_SFTP_HANDLE* dir = libssh2_sftp_opendir(sftp, fullpath);
+        if (dir == NULL) {
+            guac_user_log(user, GUAC_LOG_INFO,
+                    "Unable to read directory \"%s\"", fullpath);
+            pthread_mutex_unlock(&(filesystem->lock));
+            return 0;
+        }
+
+        /* Init directory listing state */
+        guac_common_ssh_sftp_ls_state* list_state =
+            malloc(sizeof(guac_common_ssh_sftp_ls_state));
+
+        list_state->directory = dir;
+        list_state->filesystem = filesystem;
+        strcpy(list_state->directory_name, normalized_name);
+
+        /* Allocate stream for body */
+        guac_stream* stream = guac_user_alloc_stream(user);
+        stream->ack_handler = guac_common_ssh_sftp_ls_ack_handler;
+        stream->data = list_state;
+
+        /* Init JSON object state */
+        guac_common_json_begin_object(user, stream,
+                &list_state->json_state);
+
+        /* Associate new stream with get request */
+        guac_protocol_send_body(user->socket, object, stream,
+                GUAC_USER_STREAM_INDEX_MIMETYPE, name);
+
+    }
+
+    /* Otherwise, send file contents */
+    else {
+
+        /* Ignore download if downloads have been disabled */
+        if (filesystem->disable_download) {
+            guac_user_log(user, GUAC_LOG_WARNING, "User \"%s\" attempted "
+                    "to download \"%s\", but downloads are disabled",
+                    user->user_id, fullpath);
+            pthread_mutex_unlock(&(filesystem->lock));
+            return 0;
+        }
+
+        guac_stream* stream = guac_common_ssh_sftp_open_download(filesystem,
+                user, fullpath);
+
+        /* Associate new stream with get request */
+        if (stream != NULL)
+            guac_protocol_send_body(user->socket, object, stream,
+                    GUAC_COMMON_SSH_SFTP_FILE_MIMETYPE, name);
+
+    }
+
+    guac_socket_flush(user->socket);
+    pthread_mutex_unlock(&(filesystem->lock));
+    return 0;
+
+}
+
+/**
+ * Handler for put messages. In context of SFTP and the filesystem exposed via
+ * the Guacamole protocol, put messages request write access to a file within
+ * the filesystem.
+ *
+ * @param user
+ *     The user who sent the put message.
+ *
+ * @param object
+ *     The Guacamole protocol object associated with the put request itself.
+ *
+ * @param stream
+ *     The Guacamole protocol stream along which the user will be sending
+ *     file data.
+ *
+ * @param mimetype
+ *     The mimetype of the data being send along the stream.
+ *
+ * @param name
+ *     The name of the input stream (file) being requested.
+ *
+ * @return
+ *     Zero on success, non-zero on error.
+ */
+static int guac_common_ssh_sftp_put_handler(guac_user* user,
+        guac_object* object, guac_stream* stream, char* mimetype,
+        char* name) {
+
+    guac_common_ssh_sftp_filesystem* filesystem =
+        (guac_common_ssh_sftp_filesystem*) object->data;
+
+    /* Ignore upload if uploads have been disabled */
+    if (filesystem->disable_upload) {
+        guac_user_log(user, GUAC_LOG_WARNING, "User \"%s\" attempted to "
+                "upload \"%s\", but uploads are disabled", user->user_id,
+                name);
+        guac_protocol_send_ack(user->socket, stream, "SFTP: Upload disabled",
+                GUAC_PROTOCOL_STATUS_CLIENT_FORBIDDEN);
+        guac_socket_flush(user->socket);
+        return 0;
+    }
+
+    /* Attempt to translate requested path */
+    char fullpath[GUAC_COMMON_SSH_SFTP_MAX_PATH];
+    if (!guac_common_ssh_sftp_translate_name(filesystem, name, fullpath)) {
+        guac_user_log(user, GUAC_LOG_INFO, "Unable to generate real path "
+                "for stream \"%s\"", name);
+        guac_protocol_send_ack(user->socket, stream, "SFTP: Illegal filename",
+                GUAC_PROTOCOL_STATUS_CLIENT_BAD_REQUEST);
+        guac_socket_flush(user->socket);
+        return 0;
+    }
+
+    pthread_mutex_lock(&(filesystem->lock));
+    guac_common_ssh_sftp_open_upload(filesystem, user, stream, fullpath);
+    pthread_mutex_unlock(&(filesystem->lock));
+    return 0;
+
+}
+
+void* guac_common_ssh_expose_sftp_filesystem(guac_user* user, void* data) {
+
+    guac_common_ssh_sftp_filesystem* filesystem =
+        (guac_common_ssh_sftp_filesystem*) data;
+
+    /* No need to expose if there is no filesystem or the user has left */
+    if (user == NULL || filesystem == NULL)
+        return NULL;
+
+    /* Allocate and expose filesystem object for user */
+    guac_object* fs_object = guac_user_alloc_object(user);
+    fs_object->get_handler = guac_common_ssh_sftp_get_handler;
+    fs_object->put_handler = guac_common_ssh_sftp_put_handler;
+    fs_object->data = filesystem;
+
+    /* Send filesystem to user */
+    guac_protocol_send_filesystem(user->socket, fs_object, filesystem->name);
+    guac_socket_flush(user->socket);
+
+    return fs_object;
+
+}
+
+guac_common_ssh_sftp_filesystem* guac_common_ssh_create_sftp_filesystem(
+        guac_common_ssh_session* session, const char* root_path,
+        const char* name, int disable_download, int disable_upload) {
+
+    char normalized_root[GUAC_COMMON_SSH_SFTP_MAX_PATH];
+
+    /* Validate root path before starting SFTP */
+    if (!guac_common_ssh_sftp_normalize_path(normalized_root, root_path)) {
+        guac_client_log(session->client, GUAC_LOG_WARNING, "Cannot create "
+                "SFTP filesystem - \"%s\" is not a valid path.", root_path);
+        return NULL;
+    }
+
+    /* Request SFTP */
+    LIBSSH2_SFTP* sftp_session = libssh2_sftp_init(session->session);
+    if (sftp_session == NULL)
+        return NULL;
+
+    /* Allocate data for SFTP session */
+    guac_common_ssh_sftp_filesystem* filesystem =
+        malloc(sizeof(guac_common_ssh_sftp_filesystem));
+
+    /* Associate SSH session with SFTP data */
+    filesystem->ssh_session = session;
+    filesystem->sftp_session = sftp_session;
+    pthread_mutex_init(&(filesystem->lock), NULL);
+
+    /* Store the normalized root path, uploads go to the root by default */
+    strcpy(filesystem->root_path, normalized_root);
+    strcpy(filesystem->upload_path, normalized_root);
+
+    /* Generate filesystem name from root path if no name is provided */
+    if (name != NULL)
+        filesystem->name = strdup(name);
+    else
+        filesystem->name =
+            guac_common_ssh_sftp_get_filesystem_name(normalized_root);
+
+    filesystem->disable_download = disable_download;
+    filesystem->disable_upload = disable_upload;
+
+    return filesystem;
+
+}
+
+void guac_common_ssh_destroy_sftp_filesystem(
+        guac_common_ssh_sftp_filesystem* filesystem) {
+
+    /* Shutdown SFTP session */
+    libssh2_sftp_shutdown(filesystem->sftp_session);
+    pthread_mutex_destroy(&(filesystem->lock));
+
+    /* Free associated memory */
+    free(filesystem->name);
+    free(filesystem);
+
+}
@@ -0,0 +1,290 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+#ifndef GUAC_COMMON_SSH_SFTP_H
+#define GUAC_COMMON_SSH_SFTP_H
+
+#include "common/json.h"
+#include "_ssh.h"
+
+#include <guacamole/object.h>
+#include <guacamole/user.h>
+#include <libssh2.h>
+#include <libssh2_sftp.h>
+
+#include <pthread.h>
+
+/**
+ * Maximum number of bytes per path.
+ */
+#define GUAC_COMMON_SSH_SFTP_MAX_PATH 2048
+
+/**
+ * Maximum number of path components per path.
+ */
+#define GUAC_COMMON_SSH_SFTP_MAX_DEPTH 1024
+
+/**
+ * The mimetype of files sent by the SFTP filesystem.
+ */
+#define GUAC_COMMON_SSH_SFTP_FILE_MIMETYPE "application/octet-stream"
+
+/**
+ * Data for an SFTP-driven filesystem, which is exposed to the owner of the
+ * connection as a Guacamole filesystem object. All paths of the filesystem
+ * object are relative to the root directory, hence files outside of the root
+ * directory cannot be accessed through the object.
+ */
+typedef struct guac_common_ssh_sftp_filesystem {
+
+    /**
+     * The human-readable display name of this filesystem.
+     */
+    char* name;
+
+    /**
+     * The distinct SSH session used for SFTP.
+     */
+    guac_common_ssh_session* ssh_session;
+
+    /**
+     * SFTP session, used for file transfers.
+     */
+    LIBSSH2_SFTP* sftp_session;
+
+    /**
+     * Lock which serializes access to the SFTP session, which is used by the
+     * input threads of users as well as the SSH client thread.
+     */
+    pthread_mutex_t lock;
+
+    /**
+     * The absolute and normalized path of the root directory on the SSH
+     * server. Only files within this directory can be accessed.
+     */
+    char root_path[GUAC_COMMON_SSH_SFTP_MAX_PATH];
+
+    /**
+     * The absolute and normalized path of the directory on the SSH server to
+     * which files uploaded by "file" streams are written. The upload path is
+     * always within the root directory.
+     */
+    char upload_path[GUAC_COMMON_SSH_SFTP_MAX_PATH];
+
+    /**
+     * Whether downloads of files from the SSH server are disabled.
+     */
+    int disable_download;
+
+    /**
+     * Whether uploads of files to the SSH server are disabled.
+     */
+    int disable_upload;
+
+} guac_common_ssh_sftp_filesystem;
+
+/**
+ * The current state of a directory listing operation.
+ */
+typedef struct guac_common_ssh_sftp_ls_state {
+
+    /**
+     * The SFTP filesystem being listed.
+     */
+    guac_common_ssh_sftp_filesystem* filesystem;
+
+    /**
+     * Reference to the directory currently being listed over SFTP. This
+     * directory must already be open from a call to libssh2_sftp_opendir().
+     */
+    LIBSSH2_SFTP_HANDLE* directory;
+
+    /**
+     * The path of the directory being listed, relative to the root directory
+     * of the filesystem.
+     */
+    char directory_name[GUAC_COMMON_SSH_SFTP_MAX_PATH];
+
+    /**
+     * The current state of the JSON directory object being written.
+     */
+    guac_common_json_state json_state;
+
+} guac_common_ssh_sftp_ls_state;
+
+/**
+ * Creates a new Guacamole filesystem object which provides access to files
+ * and directories via SFTP using the given SSH session. The SSH session must
+ * be dedicated to the filesystem, as the SFTP session runs in blocking mode.
+ * When the filesystem will no longer be used, it must be explicitly
+ * destroyed with guac_common_ssh_destroy_sftp_filesystem().
+ *
+ * @param session
+ *     The session to use to provide SFTP.
+ *
+ * @param root_path
+ *     The absolute path of the directory on the SSH server which is exposed
+ *     as the root of the filesystem. Files outside of this directory cannot
+ *     be accessed.
+ *
+ * @param name
+ *     The name to send as the name of the filesystem, or NULL to use the last
+ *     component of the root path.
+ *
+ * @param disable_download
+ *     Whether downloads of files from the SSH server should be disabled.
+ *
+ * @param disable_upload
+ *     Whether uploads of files to the SSH server should be disabled.
+ *
+ * @return
+ *     A new SFTP filesystem object, not yet exposed to users, or NULL if the
+ *     SFTP session cannot be started or the root path is invalid.
+ */
+guac_common_ssh_sftp_filesystem* guac_common_ssh_create_sftp_filesystem(
+        guac_common_ssh_session* session, const char* root_path,
+        const char* name, int disable_download, int disable_upload);
+
+/**
+ * Destroys the given filesystem object, shutting down its SFTP session. The
+ * SSH session of the filesystem is not destroyed.
+ *
+ * @param filesystem
+ *     The filesystem object to destroy.
+ */
+void guac_common_ssh_destroy_sftp_filesystem(
+        guac_common_ssh_sftp_filesystem* filesystem);
+
+/**
+ * Allocates a new filesystem guac_object for the given user, returning the
+ * resulting guac_object. This function is provided for convenience, as it is
+ * can be used as the callback for guac_client_foreach_user() or
+ * guac_client_for_owner(). Note that this guac_object will be tracked
+ * internally by libguac, will be provided to us in the parameters of handlers
+ * related to that guac_object, and will automatically be freed when the
+ * associated guac_user is freed, so the return value of this function can
+ * safely be ignored.
+ *
+ * @param user
+ *     The user receiving the filesystem object, or NULL if that user has
+ *     left.
+ *
+ * @param data
+ *     A pointer to the guac_common_ssh_sftp_filesystem instance to expose to
+ *     the given user.
+ *
+ * @return
+ *     The guac_object representing the filesystem for the given user, or
+ *     NULL if there is no user or filesystem.
+ */
+void* guac_common_ssh_expose_sftp_filesystem(guac_user* user, void* data);
+
+/**
+ * Initiates an SFTP file download to the user via the Guacamole "file"
+ * instruction. The download is driven by the "ack" instructions of the user.
+ * The given path is a path on the SSH server, and must be within the root
+ * directory of the filesystem.
+ *
+ * @param filesystem
+ *     The filesystem containing the file to be downloaded.
+ *
+ * @param user
+ *     The user downloading the file.
+ *
+ * @param filename
+ *     The absolute path of the file to download.
+ *
+ * @return
+ *     The file stream created for the file download, or NULL if the file
+ *     cannot be downloaded.
+ */
+guac_stream* guac_common_ssh_sftp_download_file(
+        guac_common_ssh_sftp_filesystem* filesystem, guac_user* user,
+        char* filename);
+
+/**
+ * Handles an incoming stream from a Guacamole "file" instruction, saving the
+ * contents of that stream to the file having the given name within the
+ * upload directory of the filesystem.
+ *
+ * @param filesystem
+ *     The filesystem that should receive the uploaded file.
+ *
+ * @param user
+ *     The user who is attempting to open the file stream (the user that sent
+ *     the "file" instruction).
+ *
+ * @param stream
+ *     The stream through which the uploaded file data will be received.
+ *
+ * @param mimetype
+ *     The mimetype of the data being received.
+ *
+ * @param filename
+ *     The filename of the file to write to. This filename will always be
+ *     taken relative to the upload path of the filesystem.
+ *
+ * @return
+ *     Zero if the incoming stream has been handled successfully, non-zero on
+ *     failure.
+ */
+int guac_common_ssh_sftp_handle_file_stream(
+        guac_common_ssh_sftp_filesystem* filesystem, guac_user* user,
+        guac_stream* stream, char* mimetype, char* filename);
+
+/**
+ * Sets the destination directory for future uploads submitted via Guacamole
+ * "file" instruction. The given path is a path on the SSH server, and is
+ * ignored unless it is within the root directory of the filesystem.
+ *
+ * @param filesystem
+ *     The filesystem to set the upload path of.
+ *
+ * @param path
+ *     The absolute path of the directory to upload files to.
+ */
+void guac_common_ssh_sftp_set_upload_path(
+        guac_common_ssh_sftp_filesystem* filesystem, const char* path);
+
+/**
+ * Given an arbitrary absolute path, which may contain "..", ".", and
+ * backslashes, creates an equivalent absolute path which does NOT contain
+ * relative path components (".." or "."), backslashes, or empty path
+ * components. With the exception of paths referring to the root directory,
+ * the resulting path is guaranteed to not contain trailing slashes.
+ *
+ * Normalization will fail if the given path is not absolute, is too long, or
+ * contains more than GUAC_COMMON_SSH_SFTP_MAX_DEPTH path components.
+ *
+ * @param fullpath
+ *     The buffer to populate with the normalized path. The normalized path
+ *     will not contain relative path components like ".." or ".", nor will it
+ *     contain backslashes. This buffer MUST be at least
+ *     GUAC_COMMON_SSH_SFTP_MAX_PATH bytes in size.
+ *
+ * @param path
+ *     The absolute path to normalize.
+ *
+ * @return
+ *     Non-zero if normalization succeeded, zero otherwise.
+ */
+int guac_common_ssh_sftp_normalize_path(char* fullpath,
+        const char* path);
+
+#endif
@@ -331,10 +331,24 @@ static int guac_common_ssh_authenticate(guac_common_ssh_session* common_session)
             return 1;
         }
 
-        /* Attempt public key auth */
-        if (libssh2_userauth_publickey(session, username,
+        int result;
+
+        /* Present the certificate instead of the public key if given, its
+         * signature algorithms are negotiated by libssh2 itself */
+        if (user->certificate != NULL)
+            result = libssh2_userauth_publickey_frommemory(session,
+                    username, strlen(username),
+                    user->certificate, strlen(user->certificate),
+                    key->private_key, key->private_key_length,
+                    user->passphrase);
+
+        /* Otherwise, attempt public key auth */
+        else
+            result = libssh2_userauth_publickey(session, username,
                     (unsigned char*) key->public_key, key->public_key_length,
-                    guac_common_ssh_sign_callback, (void**) key)) {
+                    guac_common_ssh_sign_callback, (void**) key);
+
+        if (result) {
 
             /* Abort on failure */
             char* error_message;
@@ -413,6 +427,87 @@ static int guac_common_ssh_authenticate(guac_common_ssh_session* common_session)
 
 }
 
+/**
+ * Restricts the host key algorithms of the given session to the key types of
+ * the given known hosts, such that the SSH server presents a host key which
+ * can be verified, even if it has keys of other types as well. This must be
+ * called before the handshake.
+ *
+ * @param client
+ *     The Guacamole client associated with the session.
+ *
+ * @param session
+ *     The SSH session whose host key algorithms should be restricted.
+ *
+ * @param host_key
+ *     The known hosts, one OpenSSH known_hosts line per line, or NULL.
+ */
+static void guac_common_ssh_set_host_key_methods(guac_client* client,
+        LIBSSH2_SESSION* session, const char* host_key) {
+
+    char methods[1024];
+    int length = 0;
+
+    if (host_key == NULL)
+        return;
+
+    /* Collect the key type of each line, i.e. the second field */
+    const char* line = host_key;
+    while (*line != '\0') {
+
+        const char* end = strchr(line, '\n');
+        if (end == NULL)
+            end = line + strlen(line);
+
+        /* Skip comments and marked lines */
+        if (*line != '#' && *line != '@') {
+
+            const char* type = line;
+            while (type < end && *type != ' ' && *type != '\t')
+                type++;
+            while (type < end && (*type == ' ' || *type == '\t'))
+                type++;
+
+            const char* type_end = type;
+            while (type_end < end && *type_end != ' ' && *type_end != '\t')
+                type_end++;
+
+            /* RSA keys are also used by the SHA-2 signature algorithms */
+            const char* type_methods = NULL;
+            int type_length = type_end - type;
+            if (type_length == 7 && strncmp(type, "ssh-rsa", 7) == 0) {
+                type_methods = "rsa-sha2-512,rsa-sha2-256,ssh-rsa";
+                type_length = strlen(type_methods);
+            }
+            else
+                type_methods = type;
+
+            if (type_length > 0
+                    && length + type_length + 2 < sizeof(methods)) {
+                if (length > 0)
+                    methods[length++] = ',';
+                memcpy(methods + length, type_methods, type_length);
+                length += type_length;
+            }
+
+        }
+
+        line = (*end != '\0') ? end + 1 : end;
+
+    }
+
+    methods[length] = '\0';
+    if (length == 0)
+        return;
+
+    /* Unsupported key types are ignored by libssh2 */
+    if (libssh2_session_method_pref(session, LIBSSH2_METHOD_HOSTKEY, methods))
+        guac_client_log(client, GUAC_LOG_WARNING, "Unable to restrict host "
+                "key types to \"%s\", host key verification may fail.",
+                methods);
+
+}
+
 guac_common_ssh_session* guac_common_ssh_create_session(guac_client* client,
         const char* hostname, const char* port, guac_common_ssh_user* user, int keepalive,
         const char* host_key) {
@@ -510,6 +605,9 @@ guac_common_ssh_session* guac_common_ssh_create_session(guac_client* client,
         return NULL;
     }
 
+    /* Request host keys of the known types only */
+    guac_common_ssh_set_host_key_methods(client, session, host_key);
+
     /* Perform handshake */
     if (libssh2_session_handshake(session, fd)) {
         guac_client_abort(client, GUAC_PROTOCOL_STATUS_UPSTREAM_ERROR,
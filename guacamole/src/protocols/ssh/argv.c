@@ -0,0 +1,133 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+#include "config.h"
+
+#include "argv.h"
+#include "settings.h"
+#include "ssh.h"
+#include "terminal.h"
+
+#include <guacamole/argv.h>
+#include <guacamole/client.h>
+#include <guacamole/user.h>
+#include <libssh2.h>
+
+#include <pthread.h>
+#include <stdlib.h>
+#include <string.h>
+
+/**
+ * Replaces the given string setting with a copy of the given value.
+ */
+static void guac_ssh_argv_set(char** setting, const char* value) {
+    free(*setting);
+    *setting = strdup(value);
+}
+
+int guac_ssh_argv_callback(guac_user* user, const char* mimetype,
+        const char* name, const char* value, void* data) {
+
+    guac_client* client = (guac_client*) data;
+    guac_ssh_client* ssh_client = (guac_ssh_client*) client->data;
+    guac_ssh_settings* settings = ssh_client->settings;
+    guac_terminal* terminal = ssh_client->term;
+
+    /* Credentials */
+    if (strcmp(name, GUAC_SSH_ARGV_USERNAME) == 0)
+        guac_ssh_argv_set(&settings->username, value);
+
+    else if (strcmp(name, GUAC_SSH_ARGV_PASSWORD) == 0)
+        guac_ssh_argv_set(&settings->password, value);
+
+    /* Terminal color scheme */
+    else if (strcmp(name, GUAC_SSH_ARGV_COLOR_SCHEME) == 0) {
+        guac_ssh_argv_set(&settings->color_scheme, value);
+        if (terminal != NULL)
+            guac_terminal_apply_color_scheme(terminal, value);
+    }
+
+    /* Terminal font */
+    else if (strcmp(name, GUAC_SSH_ARGV_FONT_NAME) == 0
+            || strcmp(name, GUAC_SSH_ARGV_FONT_SIZE) == 0) {
+
+        if (strcmp(name, GUAC_SSH_ARGV_FONT_NAME) == 0)
+            guac_ssh_argv_set(&settings->font_name, value);
+        else {
+            int size = atoi(value);
+            if (size <= 0)
+                return 1;
+            settings->font_size = size;
+        }
+
+        if (terminal != NULL) {
+
+            guac_terminal_apply_font(terminal, settings->font_name,
+                    settings->font_size, settings->resolution);
+
+            /* Update SSH pty size if connected */
+            if (ssh_client->term_channel != NULL) {
+                pthread_mutex_lock(&(ssh_client->term_channel_lock));
+                libssh2_channel_request_pty_size(ssh_client->term_channel,
+                        terminal->term_width, terminal->term_height);
+                pthread_mutex_unlock(&(ssh_client->term_channel_lock));
+            }
+
+        }
+
+    }
+
+    else
+        return 1;
+
+    return 0;
+
+}
+
+void guac_ssh_argv_register_terminal(guac_client* client) {
+
+    const char* params[] = {
+        GUAC_SSH_ARGV_COLOR_SCHEME,
+        GUAC_SSH_ARGV_FONT_NAME,
+        GUAC_SSH_ARGV_FONT_SIZE,
+        NULL
+    };
+
+    for (int i = 0; params[i] != NULL; i++)
+        guac_argv_register(client, params[i], guac_ssh_argv_callback, client,
+                GUAC_ARGV_OPTION_ECHO);
+
+}
+
+int guac_ssh_argv_await(guac_client* client, const char* name) {
+
+    if (!guac_client_owner_supports_required(client))
+        return 1;
+
+    const char* params[] = { name, NULL };
+    guac_argv_register(client, name, guac_ssh_argv_callback, client,
+            GUAC_ARGV_OPTION_ONCE);
+
+    if (guac_client_owner_send_required(client, params))
+        return 1;
+
+    return guac_argv_await(client, params);
+
+}
+
@@ -0,0 +1,95 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+#ifndef GUAC_SSH_ARGV_H
+#define GUAC_SSH_ARGV_H
+
+#include "config.h"
+
+#include <guacamole/argv.h>
+#include <guacamole/client.h>
+
+/**
+ * The name of the parameter which specifies the username, as requested
+ * from the owner by a "required" instruction.
+ */
+#define GUAC_SSH_ARGV_USERNAME "username"
+
+/**
+ * The name of the parameter which specifies the password, as requested
+ * from the owner by a "required" instruction.
+ */
+#define GUAC_SSH_ARGV_PASSWORD "password"
+
+/**
+ * The name of the parameter which specifies the color scheme of the
+ * terminal, which may be changed on an active connection.
+ */
+#define GUAC_SSH_ARGV_COLOR_SCHEME "color-scheme"
+
+/**
+ * The name of the parameter which specifies the font name of the terminal,
+ * which may be changed on an active connection.
+ */
+#define GUAC_SSH_ARGV_FONT_NAME "font-name"
+
+/**
+ * The name of the parameter which specifies the font size of the terminal,
+ * which may be changed on an active connection.
+ */
+#define GUAC_SSH_ARGV_FONT_SIZE "font-size"
+
+/**
+ * Handles a received value of any of the GUAC_SSH_ARGV parameters, storing
+ * it within the connection settings and applying it to the terminal if the
+ * terminal is already running. The data given to the callback must be the
+ * guac_client of the SSH connection.
+ */
+guac_argv_callback guac_ssh_argv_callback;
+
+/**
+ * Registers the terminal parameters which may be changed on an active SSH
+ * connection, such that new values received via argv streams are applied
+ * and echoed to all connected users.
+ *
+ * @param client
+ *     The guac_client of the SSH connection.
+ */
+void guac_ssh_argv_register_terminal(guac_client* client);
+
+/**
+ * Requests the value of the given credential from the owner by a "required"
+ * instruction, and waits until it has been received.
+ *
+ * @param client
+ *     The guac_client of the SSH connection.
+ *
+ * @param name
+ *     The name of the credential, either GUAC_SSH_ARGV_USERNAME or
+ *     GUAC_SSH_ARGV_PASSWORD.
+ *
+ * @return
+ *     Zero if the value has been received, non-zero if the owner does not
+ *     support the "required" instruction or the client stopped while
+ *     waiting.
+ */
+int guac_ssh_argv_await(guac_client* client, const char* name);
+
+#endif
+
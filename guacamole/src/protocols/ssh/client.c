@@ -85,6 +85,21 @@ int guac_ssh_client_free_handler(guac_client* client) {
     if (ssh_client->term_channel != NULL)
         libssh2_channel_free(ssh_client->term_channel);
 
+#ifdef ENABLE_SSH_AGENT
+    /* Free agent channel, if any */
+    if (ssh_client->auth_agent != NULL) {
+        libssh2_channel_free(ssh_client->auth_agent->channel);
+        free(ssh_client->auth_agent);
+    }
+#endif
+
+    /* Clean up the SFTP filesystem object and session */
+    if (ssh_client->sftp_filesystem != NULL)
+        guac_common_ssh_destroy_sftp_filesystem(ssh_client->sftp_filesystem);
+
+    if (ssh_client->sftp_session != NULL)
+        guac_common_ssh_destroy_session(ssh_client->sftp_session);
+
     /* Free interactive SSH session */
     if (ssh_client->session != NULL)
         guac_common_ssh_destroy_session(ssh_client->session);
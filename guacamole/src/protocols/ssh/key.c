@@ -372,14 +372,23 @@ void guac_common_ssh_key_free(guac_common_ssh_key* key) {
 
 int guac_common_ssh_key_sign(guac_common_ssh_key* key, const char* data,
         int length, unsigned char* sig) {
+    return guac_common_ssh_key_sign_hash(key, NID_sha1, data, length, sig);
+}
+
+int guac_common_ssh_key_sign_hash(guac_common_ssh_key* key, int hash,
+        const char* data, int length, unsigned char* sig) {
 
     const EVP_MD* md;
 
     unsigned char digest[EVP_MAX_MD_SIZE];
     unsigned int dlen, len;
 
-    /* Get SHA1 digest */
-    if ((md = EVP_get_digestbynid(NID_sha1)) == NULL)
+    /* DSA signatures of SSH are defined for SHA1 only */
+    if (key->type == SSH_KEY_DSA && hash != NID_sha1)
+        return -1;
+
+    /* Get digest */
+    if ((md = EVP_get_digestbynid(hash)) == NULL)
         return -1;
 
     /* Allocate digest context */
@@ -399,7 +408,7 @@ int guac_common_ssh_key_sign(guac_common_ssh_key* key, const char* data,
     switch (key->type) {
 
         case SSH_KEY_RSA:
-            if (RSA_sign(NID_sha1, digest, dlen, sig, &len, key->rsa) == 1)
+            if (RSA_sign(hash, digest, dlen, sig, &len, key->rsa) == 1)
                 return len;
             break;
 
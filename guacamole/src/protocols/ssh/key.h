@@ -27,6 +27,8 @@
 
 #include <openssl/ossl_typ.h>
 
+#include <stdint.h>
+
 /**
  * The expected header of RSA private keys.
  */
@@ -169,6 +171,72 @@ void guac_common_ssh_key_free(guac_common_ssh_key* key);
 int guac_common_ssh_key_sign(guac_common_ssh_key* key, const char* data,
         int length, unsigned char* sig);
 
+/**
+ * Signs the given data using the given key and hash algorithm, returning the
+ * length of the signature in bytes, or a value less than zero on error. DSA
+ * keys can only sign SHA1 digests, while RSA keys can also sign the SHA-256
+ * and SHA-512 digests of the rsa-sha2-256 and rsa-sha2-512 signatures.
+ *
+ * @param key
+ *     The key to use when signing the given data.
+ *
+ * @param hash
+ *     The OpenSSL NID of the hash algorithm, such as NID_sha1, NID_sha256 or
+ *     NID_sha512.
+ *
+ * @param data
+ *     The arbitrary data to sign.
+ *
+ * @param length
+ *     The length of the arbitrary data being signed, in bytes.
+ *
+ * @param sig
+ *     The buffer into which the signature should be written, as described by
+ *     guac_common_ssh_key_sign().
+ *
+ * @return
+ *     The number of bytes in the resulting signature.
+ */
+int guac_common_ssh_key_sign_hash(guac_common_ssh_key* key, int hash,
+        const char* data, int length, unsigned char* sig);
+
+/**
+ * Writes the given byte to the given buffer, advancing the buffer pointer by
+ * one byte.
+ */
+void guac_common_ssh_buffer_write_byte(char** buffer, uint8_t value);
+
+/**
+ * Writes the given integer to the given buffer, advancing the buffer pointer
+ * four bytes.
+ */
+void guac_common_ssh_buffer_write_uint32(char** buffer, uint32_t value);
+
+/**
+ * Writes the given string and its length to the given buffer, advancing the
+ * buffer pointer by the size of the length (four bytes) and the size of the
+ * string.
+ */
+void guac_common_ssh_buffer_write_string(char** buffer, const char* string,
+        int length);
+
+/**
+ * Reads a single byte from the given buffer, advancing the buffer by one byte.
+ */
+uint8_t guac_common_ssh_buffer_read_byte(char** buffer);
+
+/**
+ * Reads an integer from the given buffer, advancing the buffer by four bytes.
+ */
+uint32_t guac_common_ssh_buffer_read_uint32(char** buffer);
+
+/**
+ * Reads a string and its length from the given buffer, advancing the buffer
+ * by the size of the length (four bytes) and the size of the string, and
+ * returning a pointer to the string within the buffer.
+ */
+char* guac_common_ssh_buffer_read_string(char** buffer, int* length);
+
 /**
  * Verifies the host key for the given hostname/port combination against
  * one or more known_hosts entries.  The known_host entries can either be a
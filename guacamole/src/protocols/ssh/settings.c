@@ -39,6 +39,7 @@ const char* GUAC_SSH_CLIENT_ARGS[] = {
     "font-size",
     "private-key",
     "passphrase",
+    "public-key",
 #ifdef ENABLE_SSH_AGENT
     "enable-agent",
 #endif
@@ -51,6 +52,10 @@ const char* GUAC_SSH_CLIENT_ARGS[] = {
     "server-alive-interval",
     "backspace",
     "terminal-type",
+    "enable-sftp",
+    "sftp-root-directory",
+    "sftp-disable-download",
+    "sftp-disable-upload",
     NULL
 };
 
@@ -101,6 +106,12 @@ enum SSH_ARGS_IDX {
      */
     IDX_PASSPHRASE,
 
+    /**
+     * The OpenSSH certificate of the private key, if any, which is presented
+     * instead of the public key.
+     */
+    IDX_PUBLIC_KEY,
+
 #ifdef ENABLE_SSH_AGENT
     /**
      * Whether SSH agent forwarding support should be enabled.
@@ -169,6 +180,31 @@ enum SSH_ARGS_IDX {
      */
     IDX_TERMINAL_TYPE,
 
+    /**
+     * "true" if SFTP should be enabled for the SSH connection, "false" or
+     * blank otherwise.
+     */
+    IDX_ENABLE_SFTP,
+
+    /**
+     * The path of the directory within the SSH server to expose as the root
+     * of the SFTP filesystem. Files outside of this directory cannot be
+     * accessed. If omitted, "/" will be used by default.
+     */
+    IDX_SFTP_ROOT_DIRECTORY,
+
+    /**
+     * "true" if downloads from the SFTP filesystem should be disabled,
+     * "false" or blank otherwise.
+     */
+    IDX_SFTP_DISABLE_DOWNLOAD,
+
+    /**
+     * "true" if uploads to the SFTP filesystem should be disabled, "false"
+     * or blank otherwise.
+     */
+    IDX_SFTP_DISABLE_UPLOAD,
+
     SSH_ARGS_COUNT
 };
 
@@ -211,6 +247,10 @@ guac_ssh_settings* guac_ssh_parse_args(guac_user* user,
         guac_user_parse_args_string(user, GUAC_SSH_CLIENT_ARGS, argv,
                 IDX_PASSPHRASE, NULL);
 
+    settings->public_key =
+        guac_user_parse_args_string(user, GUAC_SSH_CLIENT_ARGS, argv,
+                IDX_PUBLIC_KEY, NULL);
+
     /* Read font name */
     settings->font_name =
         guac_user_parse_args_string(user, GUAC_SSH_CLIENT_ARGS, argv,
@@ -282,6 +322,25 @@ guac_ssh_settings* guac_ssh_parse_args(guac_user* user,
         guac_user_parse_args_string(user, GUAC_SSH_CLIENT_ARGS, argv,
                 IDX_TERMINAL_TYPE, "linux");
 
+    /* SFTP enable/disable */
+    settings->enable_sftp =
+        guac_user_parse_args_boolean(user, GUAC_SSH_CLIENT_ARGS, argv,
+                IDX_ENABLE_SFTP, false);
+
+    /* Read SFTP root directory */
+    settings->sftp_root_directory =
+        guac_user_parse_args_string(user, GUAC_SSH_CLIENT_ARGS, argv,
+                IDX_SFTP_ROOT_DIRECTORY, "/");
+
+    /* Parse SFTP transfer restrictions */
+    settings->sftp_disable_download =
+        guac_user_parse_args_boolean(user, GUAC_SSH_CLIENT_ARGS, argv,
+                IDX_SFTP_DISABLE_DOWNLOAD, false);
+
+    settings->sftp_disable_upload =
+        guac_user_parse_args_boolean(user, GUAC_SSH_CLIENT_ARGS, argv,
+                IDX_SFTP_DISABLE_UPLOAD, false);
+
     /* Parsing was successful */
     return settings;
 
@@ -299,6 +358,7 @@ void guac_ssh_settings_free(guac_ssh_settings* settings) {
     free(settings->password);
     free(settings->key_base64);
     free(settings->key_passphrase);
+    free(settings->public_key);
 
     /* Free display preferences */
     free(settings->font_name);
@@ -314,6 +374,9 @@ void guac_ssh_settings_free(guac_ssh_settings* settings) {
     /* Free terminal emulator type. */
     free(settings->terminal_type);
 
+    /* Free SFTP settings */
+    free(settings->sftp_root_directory);
+
     /* Free overall structure */
     free(settings);
 
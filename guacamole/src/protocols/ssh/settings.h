@@ -99,6 +99,12 @@ typedef struct guac_ssh_settings {
      */
     char* key_passphrase;
 
+    /**
+     * The OpenSSH certificate of the private key in the authorized_keys
+     * format, if any. If no certificate is specified, this will be NULL.
+     */
+    char* public_key;
+
     /**
      * Whether this connection is read-only, and user input should be dropped.
      */
@@ -179,6 +185,28 @@ typedef struct guac_ssh_settings {
      */
     char* terminal_type;
 
+    /**
+     * Whether SFTP is enabled.
+     */
+    bool enable_sftp;
+
+    /**
+     * The path of the directory within the SSH server to expose as the root
+     * of the SFTP filesystem. Files outside of this directory cannot be
+     * accessed through SFTP.
+     */
+    char* sftp_root_directory;
+
+    /**
+     * Whether downloads from the SFTP filesystem are disabled.
+     */
+    bool sftp_disable_download;
+
+    /**
+     * Whether uploads to the SFTP filesystem are disabled.
+     */
+    bool sftp_disable_upload;
+
 } guac_ssh_settings;
 
 /**
@@ -0,0 +1,87 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+#ifndef GUAC_SSH_SFTP_H
+#define GUAC_SSH_SFTP_H
+
+#include "config.h"
+
+#include <guacamole/client.h>
+#include <guacamole/stream.h>
+#include <guacamole/user.h>
+
+/**
+ * Handles an incoming stream from a Guacamole "file" instruction, saving the
+ * contents of that stream to the file having the given name within the
+ * upload directory set by guac_sftp_set_upload_path().
+ *
+ * @param user
+ *     The user uploading the file.
+ *
+ * @param stream
+ *     The stream through which the uploaded file data will be received.
+ *
+ * @param mimetype
+ *     The mimetype of the data being received.
+ *
+ * @param filename
+ *     The filename of the file to write to. This filename will always be taken
+ *     relative to the upload path set by
+ *     guac_sftp_set_upload_path().
+ *
+ * @return
+ *     Zero if the incoming stream has been handled successfully, non-zero on
+ *     failure.
+ */
+guac_user_file_handler guac_sftp_file_handler;
+
+/**
+ * Initiates an SFTP file download to the owner of the given client. The file
+ * must be within the root directory of the SFTP filesystem.
+ *
+ * @param client
+ *     The client associated with the terminal emulator receiving the file.
+ *
+ * @param filename
+ *     The absolute path of the file on the SSH server which should be sent to
+ *     the owner.
+ *
+ * @return
+ *     The file transfer stream which has been allocated and sent to the owner,
+ *     or NULL if the file could not be sent.
+ */
+guac_stream* guac_sftp_download_file(guac_client* client, char* filename);
+
+/**
+ * Sets the destination directory for future uploads submitted via Guacamole
+ * "file" instruction. This function has no bearing on the destination
+ * directories of files uploaded through "put" instructions, and the
+ * directory is ignored if it is not within the root directory of the SFTP
+ * filesystem.
+ *
+ * @param client
+ *     The client setting the upload path.
+ *
+ * @param path
+ *     The absolute path of the directory on the SSH server to which future
+ *     uploads submitted via "file" instructions should be written.
+ */
+void guac_sftp_set_upload_path(guac_client* client, char* path);
+
+#endif
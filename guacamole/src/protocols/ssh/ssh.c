@@ -20,7 +20,9 @@
 #include "config.h"
 
 #include "_ssh.h"
+#include "argv.h"
 #include "settings.h"
+#include "sftp.h"
 #include "ssh.h"
 #include "terminal.h"
 #include "ttymode.h"
@@ -70,8 +72,10 @@ static guac_common_ssh_user* guac_ssh_get_user(guac_client* client) {
 
     guac_common_ssh_user* user;
 
-    /* Get username */
-    if (settings->username == NULL)
+    /* Get username, asking the owner if supported */
+    if (settings->username == NULL
+            && guac_ssh_argv_await(client, GUAC_SSH_ARGV_USERNAME)
+            && settings->username == NULL)
         settings->username = guac_terminal_prompt(ssh_client->term,
                 "Login as: ", true);
 
@@ -120,6 +124,18 @@ static guac_common_ssh_user* guac_ssh_get_user(guac_client* client) {
 
         } /* end decrypt key with passphrase */
 
+        /* Present certificate of the key, if given */
+        if (settings->public_key != NULL
+                && guac_common_ssh_user_import_certificate(user,
+                    settings->public_key)) {
+            guac_client_abort(client,
+                    GUAC_PROTOCOL_STATUS_CLIENT_BAD_REQUEST,
+                    "Certificate import failed: public-key is not an "
+                    "OpenSSH certificate");
+            guac_common_ssh_destroy_user(user);
+            return NULL;
+        }
+
         /* Success */
         guac_client_log(client, GUAC_LOG_INFO,
                 "Auth key successfully imported.");
@@ -129,8 +145,10 @@ static guac_common_ssh_user* guac_ssh_get_user(guac_client* client) {
     /* Otherwise, use password */
     else {
 
-        /* Get password if not provided */
-        if (settings->password == NULL)
+        /* Get password if not provided, asking the owner if supported */
+        if (settings->password == NULL
+                && guac_ssh_argv_await(client, GUAC_SSH_ARGV_PASSWORD)
+                && settings->password == NULL)
             settings->password = guac_terminal_prompt(ssh_client->term,
                     "Password: ", false);
 
@@ -203,6 +221,9 @@ void* ssh_client_thread(void* data) {
         return NULL;
     }
 
+    /* Allow terminal parameters to be changed on the active connection */
+    guac_ssh_argv_register_terminal(client);
+
     /* Set up typescript, if requested */
     if (settings->typescript_path != NULL) {
         guac_terminal_create_typescript(ssh_client->term,
@@ -229,6 +250,47 @@ void* ssh_client_thread(void* data) {
 
     pthread_mutex_init(&ssh_client->term_channel_lock, NULL);
 
+    /* Start SFTP session as well, if enabled */
+    if (settings->enable_sftp) {
+
+        /* Create SSH session specific for SFTP */
+        guac_client_log(client, GUAC_LOG_DEBUG, "Reconnecting for SFTP...");
+        ssh_client->sftp_session =
+            guac_common_ssh_create_session(client, settings->hostname,
+                    settings->port, ssh_client->user,
+                    settings->server_alive_interval, settings->host_key);
+        if (ssh_client->sftp_session == NULL) {
+            /* Already aborted within guac_common_ssh_create_session() */
+            return NULL;
+        }
+
+        /* Request SFTP */
+        ssh_client->sftp_filesystem = guac_common_ssh_create_sftp_filesystem(
+                    ssh_client->sftp_session, settings->sftp_root_directory,
+                    NULL, settings->sftp_disable_download,
+                    settings->sftp_disable_upload);
+        if (ssh_client->sftp_filesystem == NULL) {
+            guac_client_abort(client, GUAC_PROTOCOL_STATUS_UPSTREAM_ERROR,
+                    "Unable to start SFTP session.");
+            return NULL;
+        }
+
+        /* Expose filesystem to connection owner */
+        guac_client_for_owner(client,
+                guac_common_ssh_expose_sftp_filesystem,
+                ssh_client->sftp_filesystem);
+
+        /* Init handlers for Guacamole-specific console codes */
+        if (!settings->sftp_disable_upload)
+            ssh_client->term->upload_path_handler = guac_sftp_set_upload_path;
+
+        if (!settings->sftp_disable_download)
+            ssh_client->term->file_download_handler = guac_sftp_download_file;
+
+        guac_client_log(client, GUAC_LOG_DEBUG, "SFTP session initialized");
+
+    }
+
     /* Open channel for terminal */
     ssh_client->term_channel =
         libssh2_channel_open_session(ssh_client->session->session);
@@ -239,9 +301,10 @@ void* ssh_client_thread(void* data) {
     }
 
 #ifdef ENABLE_SSH_AGENT
-    /* Start SSH agent forwarding, if enabled */
-    if (ssh_client->enable_agent) {
-        libssh2_session_callback_set(ssh_client->session,
+    /* Start SSH agent forwarding, if enabled and a key can be offered */
+    ssh_client->auth_agent = NULL;
+    if (settings->enable_agent && ssh_client->user->private_key != NULL) {
+        libssh2_session_callback_set(ssh_client->session->session,
                 LIBSSH2_CALLBACK_AUTH_AGENT, (void*) ssh_auth_agent_callback);
 
         /* Request agent forwarding */
@@ -250,8 +313,6 @@ void* ssh_client_thread(void* data) {
         else
             guac_client_log(client, GUAC_LOG_INFO, "Agent forwarding enabled.");
     }
-
-    ssh_client->auth_agent = NULL;
 #endif
 
     /* Set up the ttymode array prior to requesting the PTY */
@@ -357,8 +418,11 @@ void* ssh_client_thread(void* data) {
             bytes_read = ssh_auth_agent_read(ssh_client->auth_agent);
             if (bytes_read > 0)
                 total_read += bytes_read;
-            else if (bytes_read < 0 && bytes_read != LIBSSH2_ERROR_EAGAIN)
+            else if (bytes_read < 0 && bytes_read != LIBSSH2_ERROR_EAGAIN) {
+                libssh2_channel_free(ssh_client->auth_agent->channel);
+                free(ssh_client->auth_agent);
                 ssh_client->auth_agent = NULL;
+            }
         }
 #endif
 
@@ -23,6 +23,7 @@
 #include "config.h"
 
 #include "common/clipboard.h"
+#include "_sftp.h"
 #include "_ssh.h"
 #include "user.h"
 #include "settings.h"
@@ -68,6 +69,16 @@ typedef struct guac_ssh_client {
      */
     guac_common_ssh_session* session;
 
+    /**
+     * SFTP session, used for file transfers.
+     */
+    guac_common_ssh_session* sftp_session;
+
+    /**
+     * The filesystem object exposed for the SFTP session.
+     */
+    guac_common_ssh_sftp_filesystem* sftp_filesystem;
+
     /**
      * SSH terminal channel, used by the SSH client thread.
      */
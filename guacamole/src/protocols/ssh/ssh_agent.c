@@ -19,53 +19,70 @@
 
 #include "config.h"
 
-#include "client.h"
+#include "_ssh.h"
+#include "key.h"
+#include "ssh.h"
 #include "ssh_agent.h"
-#include "ssh_buffer.h"
+#include "user.h"
 
 #include <guacamole/client.h>
 #include <libssh2.h>
-#include <openssl/err.h>
-#include <openssl/evp.h>
-#include <openssl/pem.h>
-#include <openssl/rsa.h>
+#include <openssl/obj_mac.h>
 
-#include <stdio.h>
 #include <stdint.h>
 #include <stdlib.h>
-#include <unistd.h>
+#include <string.h>
 
-void ssh_auth_agent_sign(ssh_auth_agent* agent, char* data, int data_length) {
+void ssh_auth_agent_sign(ssh_auth_agent* agent, char* data, int data_length,
+        uint32_t flags) {
 
     LIBSSH2_CHANNEL* channel = agent->channel;
-    ssh_key* key = agent->identity;
+    guac_common_ssh_key* key = agent->user->private_key;
 
-    char sig[4096];
+    unsigned char sig[4096];
     int sig_len;
 
     char buffer[4096];
     char* pos = buffer;
 
+    const char* sig_type;
+    int hash;
+
+    /* Select signature algorithm */
+    if (key->type == SSH_KEY_DSA) {
+        sig_type = "ssh-dss";
+        hash = NID_sha1;
+    }
+    else if (flags & SSH_AGENT_RSA_SHA2_512) {
+        sig_type = "rsa-sha2-512";
+        hash = NID_sha512;
+    }
+    else if (flags & SSH_AGENT_RSA_SHA2_256) {
+        sig_type = "rsa-sha2-256";
+        hash = NID_sha256;
+    }
+    else {
+        sig_type = "ssh-rsa";
+        hash = NID_sha1;
+    }
+
     /* Sign with key */
-    sig_len = ssh_key_sign(key, data, data_length, (u_char*) sig);
-    if (sig_len < 0)
+    sig_len = guac_common_ssh_key_sign_hash(key, hash, data, data_length, sig);
+    if (sig_len < 0) {
+        libssh2_channel_write(channel, UNSUPPORTED, sizeof(UNSUPPORTED)-1);
         return;
+    }
 
-    buffer_write_uint32(&pos, 1+4 + 4+7 + 4+sig_len);
-
-    buffer_write_byte(&pos, SSH2_AGENT_SIGN_RESPONSE);
-    buffer_write_uint32(&pos, 4+7 + 4+sig_len);
+    int sig_type_length = strlen(sig_type);
+    guac_common_ssh_buffer_write_uint32(&pos,
+            1+4 + 4+sig_type_length + 4+sig_len);
 
-    /* Write key type */
-    if (key->type == SSH_KEY_RSA)
-        buffer_write_string(&pos, "ssh-rsa", 7);
-    else if (key->type == SSH_KEY_DSA)
-        buffer_write_string(&pos, "ssh-dss", 7);
-    else
-        return;
+    guac_common_ssh_buffer_write_byte(&pos, SSH2_AGENT_SIGN_RESPONSE);
+    guac_common_ssh_buffer_write_uint32(&pos, 4+sig_type_length + 4+sig_len);
 
     /* Write signature */
-    buffer_write_string(&pos, sig, sig_len);
+    guac_common_ssh_buffer_write_string(&pos, sig_type, sig_type_length);
+    guac_common_ssh_buffer_write_string(&pos, (char*) sig, sig_len);
 
     libssh2_channel_write(channel, buffer, pos-buffer);
     libssh2_channel_flush(channel);
@@ -75,20 +92,41 @@ void ssh_auth_agent_sign(ssh_auth_agent* agent, char* data, int data_length) {
 void ssh_auth_agent_list_identities(ssh_auth_agent* auth_agent) {
 
     LIBSSH2_CHANNEL* channel = auth_agent->channel;
-    ssh_key* key = auth_agent->identity;
+    guac_common_ssh_user* user = auth_agent->user;
+    guac_common_ssh_key* key = user->private_key;
 
-    char buffer[4096];
+    int identities = user->certificate_blob != NULL ? 2 : 1;
+    int comment_length = sizeof(SSH_AGENT_COMMENT)-1;
+
+    char buffer[8192];
     char* pos = buffer;
 
-    buffer_write_uint32(&pos, 1+4
-                              + key->public_key_length+4
-                              + sizeof(SSH_AGENT_COMMENT)+3);
+    int length = 1+4 + 4+key->public_key_length + 4+comment_length;
+    if (user->certificate_blob != NULL)
+        length += 4+user->certificate_length + 4+comment_length;
 
-    buffer_write_byte(&pos, SSH2_AGENT_IDENTITIES_ANSWER);
-    buffer_write_uint32(&pos, 1);
+    /* Refuse identities which do not fit into a single packet */
+    if (length + 4 > sizeof(buffer)) {
+        libssh2_channel_write(channel, UNSUPPORTED, sizeof(UNSUPPORTED)-1);
+        return;
+    }
+
+    guac_common_ssh_buffer_write_uint32(&pos, length);
+    guac_common_ssh_buffer_write_byte(&pos, SSH2_AGENT_IDENTITIES_ANSWER);
+    guac_common_ssh_buffer_write_uint32(&pos, identities);
+
+    /* The certificate is offered before the plain key */
+    if (user->certificate_blob != NULL) {
+        guac_common_ssh_buffer_write_string(&pos, user->certificate_blob,
+                user->certificate_length);
+        guac_common_ssh_buffer_write_string(&pos, SSH_AGENT_COMMENT,
+                comment_length);
+    }
 
-    buffer_write_string(&pos, key->public_key, key->public_key_length);
-    buffer_write_string(&pos, SSH_AGENT_COMMENT, sizeof(SSH_AGENT_COMMENT)-1);
+    guac_common_ssh_buffer_write_string(&pos, key->public_key,
+            key->public_key_length);
+    guac_common_ssh_buffer_write_string(&pos, SSH_AGENT_COMMENT,
+            comment_length);
 
     libssh2_channel_write(channel, buffer, pos-buffer);
     libssh2_channel_flush(channel);
@@ -109,27 +147,46 @@ void ssh_auth_agent_handle_packet(ssh_auth_agent* auth_agent, uint8_t type,
         case SSH2_AGENT_SIGN_REQUEST: {
 
             char* pos = data;
+            char* end = data + data_length;
 
             int key_blob_length;
             int sign_data_length;
             char* sign_data;
+            uint32_t flags = 0;
+
+            /* Skip past key, read data and flags */
+            if (end - pos < 4)
+                goto unsupported;
+            guac_common_ssh_buffer_read_string(&pos, &key_blob_length);
+            if (key_blob_length < 0 || end - pos < 4)
+                goto unsupported;
+
+            sign_data = guac_common_ssh_buffer_read_string(&pos,
+                    &sign_data_length);
+            if (sign_data_length < 0 || pos > end)
+                goto unsupported;
 
-            /* Skip past key, read data, ignore flags */
-            buffer_read_string(&pos, &key_blob_length);
-            sign_data = buffer_read_string(&pos, &sign_data_length);
+            if (end - pos >= 4)
+                flags = guac_common_ssh_buffer_read_uint32(&pos);
 
             /* Sign given data */
-            ssh_auth_agent_sign(auth_agent, sign_data, sign_data_length);
+            ssh_auth_agent_sign(auth_agent, sign_data, sign_data_length,
+                    flags);
             break;
         }
 
         /* Otherwise, return failure */
         default:
-            libssh2_channel_write(auth_agent->channel,
-                    UNSUPPORTED, sizeof(UNSUPPORTED)-1);
+            goto unsupported;
 
     }
 
+    return;
+
+unsupported:
+    libssh2_channel_write(auth_agent->channel,
+            UNSUPPORTED, sizeof(UNSUPPORTED)-1);
+
 }
 
 int ssh_auth_agent_read(ssh_auth_agent* auth_agent) {
@@ -185,17 +242,24 @@ void ssh_auth_agent_callback(LIBSSH2_SESSION *session,
         LIBSSH2_CHANNEL *channel, void **abstract) {
 
     /* Get client data */
-    guac_client* client = (guac_client*) *abstract;
-    ssh_guac_client_data* client_data = (ssh_guac_client_data*) client->data;
+    guac_common_ssh_session* common_session =
+        (guac_common_ssh_session*) *abstract;
+    guac_client* client = common_session->client;
+    guac_ssh_client* ssh_client = (guac_ssh_client*) client->data;
+
+    /* Only a single agent channel is served */
+    if (ssh_client->auth_agent != NULL) {
+        libssh2_channel_free(channel);
+        return;
+    }
 
     /* Init auth agent */
     ssh_auth_agent* auth_agent = malloc(sizeof(ssh_auth_agent));
     auth_agent->channel = channel;
-    auth_agent->identity = client_data->key;
+    auth_agent->user = common_session->user;
     auth_agent->buffer_length = 0;
 
     /* Store auth agent */
-    client_data->auth_agent = auth_agent;
+    ssh_client->auth_agent = auth_agent;
 
 }
-
@@ -23,7 +23,11 @@
 
 #include "config.h"
 
-#include "ssh_key.h"
+#include "user.h"
+
+#include <libssh2.h>
+
+#include <stdint.h>
 
 /**
  * Packet type of an agent identity request.
@@ -45,6 +49,16 @@
  */
 #define SSH2_AGENT_SIGN_RESPONSE 0x0E
 
+/**
+ * Flag of an agent sign request asking for an rsa-sha2-256 signature.
+ */
+#define SSH_AGENT_RSA_SHA2_256 0x02
+
+/**
+ * Flag of an agent sign request asking for an rsa-sha2-512 signature.
+ */
+#define SSH_AGENT_RSA_SHA2_512 0x04
+
 /**
  * The comment to associate with public keys when listed.
  */
@@ -66,9 +80,10 @@ typedef struct ssh_auth_agent {
     LIBSSH2_CHANNEL* channel;
 
     /**
-     * The single private key to use for authentication.
+     * The user whose private key, and certificate if any, is offered for
+     * authentication.
      */
-    ssh_key* identity;
+    guac_common_ssh_user* user;
 
     /**
      * Data read from the agent channel.
@@ -83,13 +98,15 @@ typedef struct ssh_auth_agent {
 } ssh_auth_agent;
 
 /**
- * Handler for an agent sign request.
+ * Handler for an agent sign request, the flags select the signature
+ * algorithm of RSA keys.
  */
 void ssh_auth_agent_sign(ssh_auth_agent* auth_agent,
-        char* data, int data_length);
+        char* data, int data_length, uint32_t flags);
 
 /**
- * Handler for an agent identity request.
+ * Handler for an agent identity request, which lists the private key and, if
+ * any, its certificate.
  */
 void ssh_auth_agent_list_identities(ssh_auth_agent* auth_agent);
 
@@ -175,6 +175,17 @@ void guac_terminal_reset(guac_terminal* term) {
     term->application_cursor_keys = false;
     term->automatic_carriage_return = false;
     term->insert_mode = false;
+    term->mouse_report_buttons = false;
+    term->mouse_report_drag = false;
+    term->mouse_report_motion = false;
+    term->mouse_sgr = false;
+    term->bracketed_paste = false;
+    term->mouse_report_row = term->mouse_report_column = -1;
+
+    /* Discard any partially-read OSC 52 sequence */
+    term->osc52_read_data = false;
+    term->osc52_overflow = false;
+    term->osc52_length = 0;
 
     /* Reset tabs */
     term->tab_interval = 8;
@@ -501,6 +512,40 @@ static void guac_terminal_parse_color_scheme(guac_client* client,
     }
 }
 
+/**
+ * Expands the given name of a predefined color scheme, such as
+ * GUAC_TERMINAL_SCHEME_GREEN_BLACK, into its full color scheme definition.
+ * Color schemes which are not predefined are returned unchanged.
+ *
+ * @param color_scheme
+ *     The color scheme to expand, which may be NULL.
+ *
+ * @return
+ *     The full color scheme definition.
+ */
+static const char* guac_terminal_expand_color_scheme(const char* color_scheme) {
+
+    /* Special cases. */
+    if (color_scheme == NULL || color_scheme[0] == '\0') {
+        /* guac_terminal_parse_color_scheme defaults to gray-black */
+    }
+    else if (strcmp(color_scheme, GUAC_TERMINAL_SCHEME_GRAY_BLACK) == 0) {
+        color_scheme = "foreground:color7;background:color0";
+    }
+    else if (strcmp(color_scheme, GUAC_TERMINAL_SCHEME_BLACK_WHITE) == 0) {
+        color_scheme = "foreground:color0;background:color15";
+    }
+    else if (strcmp(color_scheme, GUAC_TERMINAL_SCHEME_GREEN_BLACK) == 0) {
+        color_scheme = "foreground:color2;background:color0";
+    }
+    else if (strcmp(color_scheme, GUAC_TERMINAL_SCHEME_WHITE_BLACK) == 0) {
+        color_scheme = "foreground:color15;background:color0";
+    }
+
+    return color_scheme;
+
+}
+
 guac_terminal* guac_terminal_create(guac_client* client,
         guac_common_clipboard* clipboard,
         const char* font_name, int font_size, int dpi,
@@ -523,24 +568,8 @@ guac_terminal* guac_terminal_create(guac_client* client,
     guac_terminal_color (*default_palette)[256] = (guac_terminal_color(*)[256])
             malloc(sizeof(guac_terminal_color[256]));
 
-    /* Special cases. */
-    if (color_scheme == NULL || color_scheme[0] == '\0') {
-        /* guac_terminal_parse_color_scheme defaults to gray-black */
-    }
-    else if (strcmp(color_scheme, GUAC_TERMINAL_SCHEME_GRAY_BLACK) == 0) {
-        color_scheme = "foreground:color7;background:color0";
-    }
-    else if (strcmp(color_scheme, GUAC_TERMINAL_SCHEME_BLACK_WHITE) == 0) {
-        color_scheme = "foreground:color0;background:color15";
-    }
-    else if (strcmp(color_scheme, GUAC_TERMINAL_SCHEME_GREEN_BLACK) == 0) {
-        color_scheme = "foreground:color2;background:color0";
-    }
-    else if (strcmp(color_scheme, GUAC_TERMINAL_SCHEME_WHITE_BLACK) == 0) {
-        color_scheme = "foreground:color15;background:color0";
-    }
-
-    guac_terminal_parse_color_scheme(client, color_scheme,
+    guac_terminal_parse_color_scheme(client,
+                                     guac_terminal_expand_color_scheme(color_scheme),
                                      &default_char.attributes.foreground,
                                      &default_char.attributes.background,
                                      default_palette);
@@ -604,6 +633,9 @@ guac_terminal* guac_terminal_create(guac_client* client,
     /* Init pipe stream (output to display by default) */
     term->pipe_stream = NULL;
 
+    /* Init storage of the clipboard contents set by OSC 52 */
+    term->osc52_data = malloc(GUAC_TERMINAL_OSC52_MAX_LENGTH + 1);
+
     /* No typescript by default */
     term->typescript = NULL;
 
@@ -684,6 +716,9 @@ void guac_terminal_free(guac_terminal* term) {
     /* Free scrollbar */
     guac_terminal_scrollbar_free(term->scrollbar);
 
+    /* Free storage of the clipboard contents set by OSC 52 */
+    free(term->osc52_data);
+
     /* Free the terminal itself */
     free(term);
 
@@ -1727,6 +1762,53 @@ int guac_terminal_send_string(guac_terminal* term, const char* data) {
     return guac_terminal_write_all(term->stdin_pipe_fd[1], data, strlen(data));
 }
 
+/**
+ * Sends the contents of the clipboard to the remote application as typed
+ * text, surrounded by the bracketed paste markers if the application has
+ * enabled bracketed paste (xterm mode 2004). Within the markers, ESC
+ * characters are removed from the contents, such that pasted text can
+ * neither end the paste early by its own end marker nor otherwise be
+ * interpreted as a control sequence by the application.
+ *
+ * @param term
+ *     The terminal whose clipboard should be pasted.
+ *
+ * @return
+ *     Zero if the contents were sent successfully, non-zero otherwise.
+ */
+static int __guac_terminal_paste(guac_terminal* term) {
+
+    const char* data = term->clipboard->buffer;
+    int length = term->clipboard->length;
+
+    if (!term->bracketed_paste)
+        return guac_terminal_send_data(term, data, length);
+
+    if (guac_terminal_send_string(term, "\x1B[200~"))
+        return 1;
+
+    /* Send contents in runs of characters between ESC characters */
+    while (length > 0) {
+
+        const char* escape = memchr(data, 0x1B, length);
+        int run = escape != NULL ? escape - data : length;
+
+        if (run > 0 && guac_terminal_send_data(term, data, run))
+            return 1;
+
+        /* Skip the ESC character itself, if any */
+        if (escape != NULL)
+            run++;
+
+        data += run;
+        length -= run;
+
+    }
+
+    return guac_terminal_send_string(term, "\x1B[201~");
+
+}
+
 static int __guac_terminal_send_key(guac_terminal* term, int keysym, int pressed) {
 
     /* Hide mouse cursor if not already hidden */
@@ -1749,7 +1831,7 @@ static int __guac_terminal_send_key(guac_terminal* term, int keysym, int pressed
 
         /* Ctrl+Shift+V shortcut for paste */
         if (keysym == 'V' && term->mod_ctrl)
-            return guac_terminal_send_data(term, term->clipboard->buffer, term->clipboard->length);
+            return __guac_terminal_paste(term);
 
         /* Shift+PgUp / Shift+PgDown shortcuts for scrolling */
         if (term->mod_shift) {
@@ -1899,6 +1981,161 @@ int guac_terminal_send_key(guac_terminal* term, int keysym, int pressed) {
 
 }
 
+/**
+ * The xterm button codes of the mouse buttons of the Guacamole protocol, in
+ * the order of their bits within a button mask.
+ */
+static const int __guac_terminal_mouse_buttons[] = {
+    0,  /* GUAC_CLIENT_MOUSE_LEFT */
+    1,  /* GUAC_CLIENT_MOUSE_MIDDLE */
+    2,  /* GUAC_CLIENT_MOUSE_RIGHT */
+    64, /* GUAC_CLIENT_MOUSE_SCROLL_UP */
+    65  /* GUAC_CLIENT_MOUSE_SCROLL_DOWN */
+};
+
+/**
+ * Sends a single mouse event to the remote application in the encoding it
+ * has selected, i.e. SGR (xterm mode 1006) or the legacy X10 encoding.
+ *
+ * @param term
+ *     The terminal whose remote application tracks the mouse.
+ *
+ * @param button
+ *     The xterm button code of the event, including the motion flag (32)
+ *     but excluding the modifier flags.
+ *
+ * @param release
+ *     Whether the event is the release of the button.
+ *
+ * @param row
+ *     The zero-based row of the event.
+ *
+ * @param column
+ *     The zero-based column of the event.
+ *
+ * @return
+ *     Zero if the event was sent successfully, non-zero otherwise.
+ */
+static int __guac_terminal_report_mouse_event(guac_terminal* term,
+        int button, bool release, int row, int column) {
+
+    char event[32];
+
+    /* Modifier flags, shift is never reported as it selects locally */
+    if (term->mod_alt)
+        button |= 8;
+    if (term->mod_ctrl)
+        button |= 16;
+
+    /* SGR encoding, "CSI < button ; column ; row M" or "m" on release */
+    if (term->mouse_sgr) {
+        snprintf(event, sizeof(event), "\x1B[<%i;%i;%i%c",
+                button, column + 1, row + 1, release ? 'm' : 'M');
+        return guac_terminal_send_string(term, event);
+    }
+
+    /* The legacy encoding does not tell which button was released, and
+     * cannot represent coordinates beyond 223 */
+    if (release)
+        button = (button & ~3) | 3;
+    if (column + 1 > 223 || row + 1 > 223)
+        return 0;
+
+    event[0] = 0x1B;
+    event[1] = '[';
+    event[2] = 'M';
+    event[3] = (char) (32 + button);
+    event[4] = (char) (32 + column + 1);
+    event[5] = (char) (32 + row + 1);
+    return guac_terminal_send_data(term, event, 6);
+
+}
+
+/**
+ * Reports the changes of the mouse state to the remote application as
+ * enabled by the xterm mouse tracking modes 1000, 1002 and 1003.
+ *
+ * @param term
+ *     The terminal whose remote application tracks the mouse.
+ *
+ * @param x
+ *     The X coordinate of the mouse pointer, in pixels.
+ *
+ * @param y
+ *     The Y coordinate of the mouse pointer, in pixels.
+ *
+ * @param pressed_mask
+ *     The buttons which were just pressed.
+ *
+ * @param released_mask
+ *     The buttons which were just released.
+ *
+ * @param mask
+ *     The buttons which are currently held.
+ *
+ * @return
+ *     Zero if all events were sent successfully, non-zero otherwise.
+ */
+static int __guac_terminal_report_mouse(guac_terminal* term, int x, int y,
+        int pressed_mask, int released_mask, int mask) {
+
+    int i;
+
+    /* Clamp the position to the terminal */
+    int row = y / term->display->char_height;
+    int column = x / term->display->char_width;
+
+    if (row < 0) row = 0;
+    if (row >= term->term_height) row = term->term_height - 1;
+    if (column < 0) column = 0;
+    if (column >= term->term_width) column = term->term_width - 1;
+
+    bool moved = row != term->mouse_report_row
+              || column != term->mouse_report_column;
+
+    term->mouse_report_row = row;
+    term->mouse_report_column = column;
+
+    /* Releases of the buttons, the scroll wheel is not released */
+    for (i = 0; i < 3; i++) {
+        if (released_mask & (1 << i)) {
+            if (__guac_terminal_report_mouse_event(term,
+                        __guac_terminal_mouse_buttons[i], true, row, column))
+                return 1;
+        }
+    }
+
+    /* Presses of the buttons and the scroll wheel */
+    for (i = 0; i < 5; i++) {
+        if (pressed_mask & (1 << i)) {
+            if (__guac_terminal_report_mouse_event(term,
+                        __guac_terminal_mouse_buttons[i], false, row, column))
+                return 1;
+        }
+    }
+
+    /* Motion into another cell, with the lowest held button or none */
+    if (moved && !pressed_mask && !released_mask) {
+
+        int held = mask & (GUAC_CLIENT_MOUSE_LEFT | GUAC_CLIENT_MOUSE_MIDDLE
+                | GUAC_CLIENT_MOUSE_RIGHT);
+
+        if (held && (term->mouse_report_drag || term->mouse_report_motion)) {
+            for (i = 0; !(held & (1 << i)); i++);
+            return __guac_terminal_report_mouse_event(term,
+                    32 + __guac_terminal_mouse_buttons[i], false, row, column);
+        }
+
+        if (!held && term->mouse_report_motion)
+            return __guac_terminal_report_mouse_event(term,
+                    32 + 3, false, row, column);
+
+    }
+
+    return 0;
+
+}
+
 static int __guac_terminal_send_mouse(guac_terminal* term, guac_user* user,
         int x, int y, int mask) {
 
@@ -1936,9 +2173,25 @@ static int __guac_terminal_send_mouse(guac_terminal* term, guac_user* user,
         guac_terminal_notify(term);
     }
 
+    /* Pass the mouse to the remote application if it tracks the mouse,
+     * unless shift is held to select text locally as in xterm */
+    if ((term->mouse_report_buttons || term->mouse_report_drag
+                || term->mouse_report_motion) && !term->mod_shift) {
+
+        /* Abandon any local selection */
+        if (term->text_selected) {
+            term->text_selected = false;
+            guac_terminal_notify(term);
+        }
+
+        return __guac_terminal_report_mouse(term, x, y,
+                pressed_mask, released_mask, mask);
+
+    }
+
     /* Paste contents of clipboard on right or middle mouse button up */
     if ((released_mask & GUAC_CLIENT_MOUSE_RIGHT) || (released_mask & GUAC_CLIENT_MOUSE_MIDDLE))
-        return guac_terminal_send_data(term, term->clipboard->buffer, term->clipboard->length);
+        return __guac_terminal_paste(term);
 
     /* If text selected, change state based on left mouse mouse button */
     if (term->text_selected) {
@@ -2223,3 +2476,78 @@ void guac_terminal_dup(guac_terminal* term, guac_user* user,
 
 }
 
+void guac_terminal_apply_color_scheme(guac_terminal* terminal,
+        const char* color_scheme) {
+
+    guac_client* client = terminal->client;
+    guac_terminal_display* display = terminal->display;
+    guac_terminal_char* default_char = &terminal->default_char;
+
+    /* Initialized by guac_terminal_parse_color_scheme. */
+    guac_terminal_color (*default_palette)[256] = (guac_terminal_color(*)[256])
+            malloc(sizeof(guac_terminal_color[256]));
+
+    guac_terminal_lock(terminal);
+
+    guac_terminal_parse_color_scheme(client,
+                                     guac_terminal_expand_color_scheme(color_scheme),
+                                     &default_char->attributes.foreground,
+                                     &default_char->attributes.background,
+                                     default_palette);
+
+    /* Replace default colors of display */
+    free((void*) display->default_palette);
+    display->default_palette =
+        (const guac_terminal_color(*)[256]) default_palette;
+    display->default_foreground = display->glyph_foreground =
+        default_char->attributes.foreground;
+    display->default_background = display->glyph_background =
+        default_char->attributes.background;
+    guac_terminal_display_reset_palette(display);
+
+    /* Redraw background and all visible characters */
+    guac_terminal_repaint_default_layer(terminal, client->socket);
+    __guac_terminal_redraw_rect(terminal, 0, 0,
+            terminal->term_height - 1, terminal->term_width - 1);
+
+    guac_terminal_unlock(terminal);
+    guac_terminal_notify(terminal);
+
+}
+
+void guac_terminal_apply_font(guac_terminal* terminal, const char* font_name,
+        int font_size, int dpi) {
+
+    guac_terminal_display* display = terminal->display;
+
+    guac_terminal_lock(terminal);
+
+    /* Keep previous font if new font cannot be loaded */
+    if (guac_terminal_display_set_font(display, font_name, font_size, dpi)) {
+        guac_terminal_unlock(terminal);
+        return;
+    }
+
+    /* Calculate available display area */
+    int available_width = terminal->width - GUAC_TERMINAL_SCROLLBAR_WIDTH;
+    if (available_width < 0)
+        available_width = 0;
+
+    /* Calculate dimensions using new character size */
+    int rows    = terminal->height / display->char_height;
+    int columns = available_width / display->char_width;
+
+    guac_terminal_scrollbar_parent_resized(terminal->scrollbar,
+            terminal->width, terminal->height, rows);
+    guac_terminal_scrollbar_set_bounds(terminal->scrollbar,
+            rows - terminal->buffer->length, 0);
+
+    /* Resize terminal, and redraw all visible characters with new font */
+    __guac_terminal_resize(terminal, columns, rows);
+    terminal->scroll_end = rows - 1;
+    __guac_terminal_redraw_rect(terminal, 0, 0, rows - 1, columns - 1);
+
+    guac_terminal_unlock(terminal);
+    guac_terminal_notify(terminal);
+
+}
@@ -58,6 +58,12 @@
  */
 #define GUAC_TERMINAL_WHEEL_SCROLL_AMOUNT 3
 
+/**
+ * The maximum number of base64 characters of the clipboard contents set by
+ * an OSC 52 sequence. Longer sequences are ignored.
+ */
+#define GUAC_TERMINAL_OSC52_MAX_LENGTH 262144
+
 /**
  * The name of the color scheme having black foreground and white background.
  */
@@ -412,6 +418,72 @@ struct guac_terminal {
      */
     bool insert_mode;
 
+    /**
+     * Whether mouse button presses and releases are reported to the remote
+     * application (xterm mode 1000).
+     */
+    bool mouse_report_buttons;
+
+    /**
+     * Whether mouse motion while a button is held is reported to the remote
+     * application, in addition to presses and releases (xterm mode 1002).
+     */
+    bool mouse_report_drag;
+
+    /**
+     * Whether all mouse motion is reported to the remote application, in
+     * addition to presses and releases (xterm mode 1003).
+     */
+    bool mouse_report_motion;
+
+    /**
+     * Whether reported mouse events use the SGR encoding rather than the
+     * legacy X10 encoding (xterm mode 1006).
+     */
+    bool mouse_sgr;
+
+    /**
+     * Whether pasted text is surrounded by "ESC [ 200 ~" and "ESC [ 201 ~",
+     * such that the remote application can tell it from typed text (xterm
+     * mode 2004).
+     */
+    bool bracketed_paste;
+
+    /**
+     * Whether the selection parameter of the OSC 52 sequence being read has
+     * been read, the base64 contents of the clipboard follow it.
+     */
+    bool osc52_read_data;
+
+    /**
+     * Whether the contents of the OSC 52 sequence being read exceed
+     * GUAC_TERMINAL_OSC52_MAX_LENGTH, the sequence is then ignored.
+     */
+    bool osc52_overflow;
+
+    /**
+     * The number of base64 characters of the OSC 52 sequence being read which
+     * have been stored within osc52_data.
+     */
+    int osc52_length;
+
+    /**
+     * The base64 contents of the OSC 52 sequence being read, which has room
+     * for GUAC_TERMINAL_OSC52_MAX_LENGTH characters and a null terminator.
+     */
+    char* osc52_data;
+
+    /**
+     * The row of the last mouse event reported to the remote application,
+     * motion within the same character cell is not reported.
+     */
+    int mouse_report_row;
+
+    /**
+     * The column of the last mouse event reported to the remote application.
+     */
+    int mouse_report_column;
+
     /**
      * Whether the alt key is currently being held down.
      */
@@ -738,6 +810,40 @@ void guac_terminal_set_columns(guac_terminal* terminal, int row,
  */
 int guac_terminal_resize(guac_terminal* term, int width, int height);
 
+/**
+ * Replaces the color scheme of the given terminal, redrawing its contents
+ * using the new default colors.
+ *
+ * @param terminal
+ *     The terminal whose color scheme should be replaced.
+ *
+ * @param color_scheme
+ *     The new color scheme, either the name of a predefined color scheme
+ *     or a full color scheme definition.
+ */
+void guac_terminal_apply_color_scheme(guac_terminal* terminal,
+        const char* color_scheme);
+
+/**
+ * Replaces the font of the given terminal, resizing the terminal to fit its
+ * display using the new character size and redrawing its contents. If the
+ * font cannot be loaded, the previous font remains in use.
+ *
+ * @param terminal
+ *     The terminal whose font should be replaced.
+ *
+ * @param font_name
+ *     The name of the new font.
+ *
+ * @param font_size
+ *     The size of the new font, in points.
+ *
+ * @param dpi
+ *     The resolution of the display, in DPI.
+ */
+void guac_terminal_apply_font(guac_terminal* terminal, const char* font_name,
+        int font_size, int dpi);
+
 /**
  * Flushes all pending operations within the given guac_terminal.
  */
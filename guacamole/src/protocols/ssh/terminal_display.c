@@ -140,13 +140,12 @@ int __guac_terminal_set_colors(guac_terminal_display* display,
 }
 
 /**
- * Sends the given character to the terminal at the given row and column,
- * rendering the character immediately. This bypasses the guac_terminal_display
- * mechanism and is intended for flushing of updates only.
+ * Renders the given codepoint with the current glyph colors of the display
+ * into a new image of the given width in columns. The returned image must be
+ * destroyed with cairo_surface_destroy().
  */
-int __guac_terminal_set(guac_terminal_display* display, int row, int col, int codepoint) {
-
-    int width;
+static cairo_surface_t* __guac_terminal_render_glyph(
+        guac_terminal_display* display, int codepoint, int width) {
 
     int bytes;
     char utf8[4];
@@ -165,15 +164,6 @@ int __guac_terminal_set(guac_terminal_display* display, int row, int col, int co
     int layout_width, layout_height;
     int ideal_layout_width, ideal_layout_height;
 
-    /* Calculate width in columns */
-    width = wcwidth(codepoint);
-    if (width < 0)
-        width = 1;
-
-    /* Do nothing if glyph is empty */
-    if (width == 0)
-        return 0;
-
     /* Convert to UTF-8 */
     bytes = guac_terminal_encode_utf8(codepoint, utf8);
 
@@ -229,31 +219,219 @@ int __guac_terminal_set(guac_terminal_display* display, int row, int col, int co
     cairo_move_to(cairo, 0.0, 0.0);
     pango_cairo_show_layout(cairo, layout);
 
+    /* Free all but the rendered image */
+    g_object_unref(layout);
+    cairo_destroy(cairo);
+
+    return surface;
+
+}
+
+/**
+ * Returns the width of the given codepoint in columns, which is zero if the
+ * codepoint has no glyph to render.
+ */
+static int __guac_terminal_glyph_width(int codepoint) {
+
+    int width = wcwidth(codepoint);
+    if (width < 0)
+        return 1;
+
+    return width;
+
+}
+
+/**
+ * Sends the given character to the terminal at the given row and column,
+ * rendering the character immediately. This bypasses the guac_terminal_display
+ * mechanism and is intended for flushing of updates only.
+ */
+int __guac_terminal_set(guac_terminal_display* display, int row, int col, int codepoint) {
+
+    cairo_surface_t* surface;
+
+    /* Do nothing if glyph is empty */
+    int width = __guac_terminal_glyph_width(codepoint);
+    if (width == 0)
+        return 0;
+
     /* Draw */
+    surface = __guac_terminal_render_glyph(display, codepoint, width);
     guac_common_surface_draw(display->display_surface,
         display->char_width * col,
         display->char_height * row,
         surface);
 
-    /* Free all */
-    g_object_unref(layout);
-    cairo_destroy(cairo);
     cairo_surface_destroy(surface);
+    return 0;
+
+}
+
+/**
+ * Empties the glyph cache of the given display, resizing its glyph buffer to
+ * the current character dimensions.
+ */
+static void __guac_terminal_display_clear_glyphs(guac_terminal_display* display) {
+
+    int i;
+
+    for (i = 0; i < GUAC_TERMINAL_GLYPH_CACHE_SIZE; i++)
+        display->glyphs[i].codepoint = -1;
+
+    for (i = 0; i < GUAC_TERMINAL_GLYPH_CACHE_BUCKETS; i++)
+        display->glyph_buckets[i] = -1;
+
+    display->next_glyph = 0;
+
+    guac_common_surface_resize(display->glyph_surface,
+            GUAC_TERMINAL_GLYPH_CACHE_COLUMNS * GUAC_TERMINAL_MAX_CHAR_WIDTH
+                * display->char_width,
+            GUAC_TERMINAL_GLYPH_CACHE_SIZE / GUAC_TERMINAL_GLYPH_CACHE_COLUMNS
+                * display->char_height);
+
+}
+
+/**
+ * Returns the hash bucket of the given codepoint rendered with the given
+ * colors.
+ */
+static int __guac_terminal_glyph_bucket(int codepoint,
+        const guac_terminal_color* foreground,
+        const guac_terminal_color* background) {
+
+    unsigned int hash = (unsigned int) codepoint * 2654435761u;
+
+    hash ^= (foreground->red << 16) | (foreground->green << 8) | foreground->blue;
+    hash *= 16777619u;
+    hash ^= (background->red << 16) | (background->green << 8) | background->blue;
+    hash *= 16777619u;
+
+    return hash % GUAC_TERMINAL_GLYPH_CACHE_BUCKETS;
+
+}
+
+/**
+ * Sends the given character to the terminal at the given row and column by
+ * copying it from the glyph cache, rendering it into the cache first if it
+ * is not yet cached with the current glyph colors.
+ */
+static int __guac_terminal_copy_glyph(guac_terminal_display* display,
+        int row, int col, int codepoint) {
+
+    const guac_terminal_color* foreground = &display->glyph_foreground;
+    const guac_terminal_color* background = &display->glyph_background;
+
+    int entry_width = GUAC_TERMINAL_MAX_CHAR_WIDTH * display->char_width;
+    int bucket, index;
+    guac_terminal_glyph* glyph;
+
+    /* Do nothing if glyph is empty */
+    int width = __guac_terminal_glyph_width(codepoint);
+    if (width == 0)
+        return 0;
+
+    bucket = __guac_terminal_glyph_bucket(codepoint, foreground, background);
+    index = display->glyph_buckets[bucket];
+
+    /* Render into the next entry of the cache if not cached */
+    if (index == -1
+            || (glyph = &display->glyphs[index])->codepoint != codepoint
+            || guac_terminal_colorcmp(&glyph->foreground, foreground) != 0
+            || guac_terminal_colorcmp(&glyph->background, background) != 0) {
+
+        cairo_surface_t* surface;
+
+        index = display->next_glyph;
+        display->next_glyph = (index + 1) % GUAC_TERMINAL_GLYPH_CACHE_SIZE;
+        display->glyph_buckets[bucket] = index;
+
+        glyph = &display->glyphs[index];
+        glyph->codepoint = codepoint;
+        glyph->foreground = *foreground;
+        glyph->background = *background;
+
+        surface = __guac_terminal_render_glyph(display, codepoint, width);
+        guac_common_surface_draw(display->glyph_surface,
+                index % GUAC_TERMINAL_GLYPH_CACHE_COLUMNS * entry_width,
+                index / GUAC_TERMINAL_GLYPH_CACHE_COLUMNS * display->char_height,
+                surface);
+        cairo_surface_destroy(surface);
+
+    }
+
+    /* Copy glyph from cache */
+    guac_common_surface_copy(display->glyph_surface,
+            index % GUAC_TERMINAL_GLYPH_CACHE_COLUMNS * entry_width,
+            index / GUAC_TERMINAL_GLYPH_CACHE_COLUMNS * display->char_height,
+            width * display->char_width,
+            display->char_height,
+            display->display_surface,
+            display->char_width * col,
+            display->char_height * row);
 
     return 0;
 
 }
 
-guac_terminal_display* guac_terminal_display_alloc(guac_client* client,
-        const char* font_name, int font_size, int dpi,
-        guac_terminal_color* foreground, guac_terminal_color* background,
-        const guac_terminal_color (*palette)[256]) {
+int guac_terminal_display_set_font(guac_terminal_display* display,
+        const char* font_name, int font_size, int dpi) {
 
     PangoFontMap* font_map;
     PangoFont* font;
     PangoFontMetrics* metrics;
     PangoContext* context;
 
+    /* Build description of new font */
+    PangoFontDescription* font_desc = pango_font_description_new();
+    pango_font_description_set_family(font_desc, font_name);
+    pango_font_description_set_weight(font_desc, PANGO_WEIGHT_NORMAL);
+    pango_font_description_set_size(font_desc,
+            font_size * PANGO_SCALE * dpi / 96);
+
+    font_map = pango_cairo_font_map_get_default();
+    context = pango_font_map_create_context(font_map);
+
+    font = pango_font_map_load_font(font_map, context, font_desc);
+    if (font == NULL) {
+        guac_client_log(display->client, GUAC_LOG_WARNING,
+                "Unable to get font \"%s\"", font_name);
+        pango_font_description_free(font_desc);
+        return 1;
+    }
+
+    metrics = pango_font_get_metrics(font, NULL);
+    if (metrics == NULL) {
+        guac_client_log(display->client, GUAC_LOG_WARNING,
+                "Unable to get font metrics for font \"%s\"", font_name);
+        pango_font_description_free(font_desc);
+        return 1;
+    }
+
+    /* Replace previous font, if any */
+    if (display->font_desc != NULL)
+        pango_font_description_free(display->font_desc);
+    display->font_desc = font_desc;
+
+    /* Calculate character dimensions */
+    display->char_width =
+        pango_font_metrics_get_approximate_digit_width(metrics) / PANGO_SCALE;
+    display->char_height =
+        (pango_font_metrics_get_descent(metrics)
+            + pango_font_metrics_get_ascent(metrics)) / PANGO_SCALE;
+
+    /* Glyphs of the previous font can no longer be used */
+    if (display->glyph_surface != NULL)
+        __guac_terminal_display_clear_glyphs(display);
+
+    return 0;
+
+}
+
+guac_terminal_display* guac_terminal_display_alloc(guac_client* client,
+        const char* font_name, int font_size, int dpi,
+        guac_terminal_color* foreground, guac_terminal_color* background,
+        const guac_terminal_color (*palette)[256]) {
+
     /* Allocate display */
     guac_terminal_display* display = malloc(sizeof(guac_terminal_display));
     display->client = client;
@@ -269,41 +447,25 @@ guac_terminal_display* guac_terminal_display_alloc(guac_client* client,
             display->display_layer, 0, 0, 0);
 
     /* Get font */
-    display->font_desc = pango_font_description_new();
-    pango_font_description_set_family(display->font_desc, font_name);
-    pango_font_description_set_weight(display->font_desc, PANGO_WEIGHT_NORMAL);
-    pango_font_description_set_size(display->font_desc,
-            font_size * PANGO_SCALE * dpi / 96);
-
-    font_map = pango_cairo_font_map_get_default();
-    context = pango_font_map_create_context(font_map);
-
-    font = pango_font_map_load_font(font_map, context, display->font_desc);
-    if (font == NULL) {
-        guac_client_abort(display->client, GUAC_PROTOCOL_STATUS_SERVER_ERROR, "Unable to get font \"%s\"", font_name);
-        free(display);
-        return NULL;
-    }
-
-    metrics = pango_font_get_metrics(font, NULL);
-    if (metrics == NULL) {
+    display->font_desc = NULL;
+    display->glyph_surface = NULL;
+    if (guac_terminal_display_set_font(display, font_name, font_size, dpi)) {
         guac_client_abort(display->client, GUAC_PROTOCOL_STATUS_SERVER_ERROR,
-                "Unable to get font metrics for font \"%s\"", font_name);
+                "Unable to load font \"%s\"", font_name);
         free(display);
         return NULL;
     }
 
+    /* Create initially-empty glyph cache */
+    display->glyph_buffer = guac_client_alloc_buffer(client);
+    display->glyph_surface = guac_common_surface_alloc(client,
+            client->socket, display->glyph_buffer, 0, 0);
+    __guac_terminal_display_clear_glyphs(display);
+
     display->default_foreground = display->glyph_foreground = *foreground;
     display->default_background = display->glyph_background = *background;
     display->default_palette = palette;
 
-    /* Calculate character dimensions */
-    display->char_width =
-        pango_font_metrics_get_approximate_digit_width(metrics) / PANGO_SCALE;
-    display->char_height =
-        (pango_font_metrics_get_descent(metrics)
-            + pango_font_metrics_get_ascent(metrics)) / PANGO_SCALE;
-
     /* Initially empty */
     display->width = 0;
     display->height = 0;
@@ -325,6 +487,10 @@ void guac_terminal_display_free(guac_terminal_display* display) {
     /* Free operations buffers */
     free(display->operations);
 
+    /* Free glyph cache */
+    guac_common_surface_free(display->glyph_surface);
+    guac_client_free_buffer(display->client, display->glyph_buffer);
+
     /* Free display */
     free(display);
 
@@ -839,6 +1005,16 @@ void __guac_terminal_display_flush_set(guac_terminal_display* display) {
 
     /* For each operation */
     for (row=0; row<display->height; row++) {
+
+        /* Copy the glyphs of the row from the glyph cache, unless so many
+         * changed that an image of them is smaller */
+        int changed = 0;
+        for (col=0; col<display->width; col++) {
+            if (current[col].type == GUAC_CHAR_SET)
+                changed++;
+        }
+        bool copy = changed <= GUAC_TERMINAL_GLYPH_COPY_LIMIT;
+
         for (col=0; col<display->width; col++) {
 
             /* Perform given operation */
@@ -855,7 +1031,10 @@ void __guac_terminal_display_flush_set(guac_terminal_display* display) {
                         &(current->character.attributes));
 
                 /* Send character */
-                __guac_terminal_set(display, row, col, codepoint);
+                if (copy)
+                    __guac_terminal_copy_glyph(display, row, col, codepoint);
+                else
+                    __guac_terminal_set(display, row, col, codepoint);
 
                 /* Mark operation as handled */
                 current->type = GUAC_CHAR_NOP;
@@ -885,6 +1064,9 @@ void guac_terminal_display_flush(guac_terminal_display* display) {
 void guac_terminal_display_dup(guac_terminal_display* display, guac_user* user,
         guac_socket* socket) {
 
+    /* Send glyph cache, the source of later copies */
+    guac_common_surface_dup(display->glyph_surface, user, socket);
+
     /* Create default surface */
     guac_common_surface_dup(display->display_surface, user, socket);
 
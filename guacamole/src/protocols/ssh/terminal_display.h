@@ -39,6 +39,29 @@
  */
 #define GUAC_TERMINAL_MAX_CHAR_WIDTH 2
 
+/**
+ * The number of glyphs within each row of the glyph cache.
+ */
+#define GUAC_TERMINAL_GLYPH_CACHE_COLUMNS 64
+
+/**
+ * The number of glyphs held by the glyph cache. Once the cache is full, the
+ * glyph which was rendered first is replaced.
+ */
+#define GUAC_TERMINAL_GLYPH_CACHE_SIZE 1024
+
+/**
+ * The number of hash buckets used to look up the glyphs of the glyph cache.
+ */
+#define GUAC_TERMINAL_GLYPH_CACHE_BUCKETS 4096
+
+/**
+ * The maximum number of changed glyphs within a row which are copied from the
+ * glyph cache. Rows having more changed glyphs are drawn as an image, which is
+ * smaller than a copy instruction for each glyph.
+ */
+#define GUAC_TERMINAL_GLYPH_COPY_LIMIT 8
+
 /**
  * All available terminal operations which affect character cells.
  */
@@ -92,6 +115,29 @@ typedef struct guac_terminal_operation {
 
 } guac_terminal_operation;
 
+/**
+ * A glyph rendered within the glyph cache, identified by its codepoint and
+ * the colors it was rendered with.
+ */
+typedef struct guac_terminal_glyph {
+
+    /**
+     * The codepoint of the glyph, or -1 if this entry of the cache is unused.
+     */
+    int codepoint;
+
+    /**
+     * The color the glyph was rendered with.
+     */
+    guac_terminal_color foreground;
+
+    /**
+     * The background color the glyph was rendered on.
+     */
+    guac_terminal_color background;
+
+} guac_terminal_glyph;
+
 /**
  * Set of all pending operations for the currently-visible screen area, and the
  * contextual information necessary to interpret and render those changes.
@@ -181,6 +227,36 @@ typedef struct guac_terminal_display {
      */
     guac_layer* select_layer;
 
+    /**
+     * Off-screen buffer holding each rendered glyph once, such that drawing
+     * a glyph again is a copy from this buffer rather than a new image.
+     */
+    guac_layer* glyph_buffer;
+
+    /**
+     * The surface of the glyph buffer. Each entry of the glyph cache has room
+     * for a glyph of GUAC_TERMINAL_MAX_CHAR_WIDTH columns.
+     */
+    guac_common_surface* glyph_surface;
+
+    /**
+     * The glyphs within the glyph cache, by their location within the glyph
+     * buffer.
+     */
+    guac_terminal_glyph glyphs[GUAC_TERMINAL_GLYPH_CACHE_SIZE];
+
+    /**
+     * The entry of the glyph cache last stored by the hash of each glyph, or
+     * -1 if none. An entry may have been replaced by another glyph since.
+     */
+    int glyph_buckets[GUAC_TERMINAL_GLYPH_CACHE_BUCKETS];
+
+    /**
+     * The entry of the glyph cache which the next newly-rendered glyph will
+     * be stored in.
+     */
+    int next_glyph;
+
     /**
      * Whether text is being selected.
      */
@@ -229,6 +305,29 @@ guac_terminal_display* guac_terminal_display_alloc(guac_client* client,
  */
 void guac_terminal_display_free(guac_terminal_display* display);
 
+/**
+ * Replaces the font of the given display, recalculating the dimensions of
+ * each character. The contents of the display are not redrawn.
+ *
+ * @param display
+ *     The display whose font should be replaced.
+ *
+ * @param font_name
+ *     The name of the new font.
+ *
+ * @param font_size
+ *     The size of the new font, in points.
+ *
+ * @param dpi
+ *     The resolution of the display, in DPI.
+ *
+ * @return
+ *     Zero if the font was replaced, non-zero if the font could not be
+ *     loaded, in which case the previous font remains in use.
+ */
+int guac_terminal_display_set_font(guac_terminal_display* display,
+        const char* font_name, int font_size, int dpi);
+
 /**
  * Resets the palette of the given display to the initial, default color
  * values, as defined by default_palette or GUAC_TERMINAL_INITIAL_PALETTE.
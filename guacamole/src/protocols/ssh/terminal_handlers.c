@@ -19,6 +19,7 @@
 
 #include "config.h"
 
+#include "common/clipboard.h"
 #include "terminal_char_mappings.h"
 #include "terminal_palette.h"
 #include "terminal.h"
@@ -32,6 +33,7 @@
 
 #include <stdbool.h>
 #include <stdlib.h>
+#include <string.h>
 #include <wchar.h>
 
 /**
@@ -425,7 +427,12 @@ static bool* __guac_terminal_get_flag(guac_terminal* term, int num, char private
 
     if (private_mode == '?') {
         switch (num) {
-            case 1:  return &(term->application_cursor_keys); /* DECCKM */
+            case 1:    return &(term->application_cursor_keys); /* DECCKM */
+            case 1000: return &(term->mouse_report_buttons); /* X11 mouse */
+            case 1002: return &(term->mouse_report_drag); /* Button motion */
+            case 1003: return &(term->mouse_report_motion); /* Any motion */
+            case 1006: return &(term->mouse_sgr); /* SGR mouse encoding */
+            case 2004: return &(term->bracketed_paste); /* Bracketed paste */
         }
     }
 
@@ -872,20 +879,24 @@ int guac_terminal_csi(guac_terminal* term, unsigned char c) {
             /* h: Set Mode */
             case 'h':
              
-                /* Look up flag and set */ 
-                flag = __guac_terminal_get_flag(term, argv[0], private_mode_character);
-                if (flag != NULL)
-                    *flag = true;
+                /* Look up flags and set, e.g. "CSI ? 1002 ; 1006 h" */
+                for (i=0; i<argc; i++) {
+                    flag = __guac_terminal_get_flag(term, argv[i], private_mode_character);
+                    if (flag != NULL)
+                        *flag = true;
+                }
 
                 break;
 
             /* l: Reset Mode */
             case 'l':
               
-                /* Look up flag and clear */ 
-                flag = __guac_terminal_get_flag(term, argv[0], private_mode_character);
-                if (flag != NULL)
-                    *flag = false;
+                /* Look up flags and clear */
+                for (i=0; i<argc; i++) {
+                    flag = __guac_terminal_get_flag(term, argv[i], private_mode_character);
+                    if (flag != NULL)
+                        *flag = false;
+                }
 
                 break;
 
@@ -1215,6 +1226,52 @@ int guac_terminal_window_title(guac_terminal* term, unsigned char c) {
 
 }
 
+int guac_terminal_set_clipboard(guac_terminal* term, unsigned char c) {
+
+    char* data = term->osc52_data;
+
+    /* Stop on ECMA-48 ST (String Terminator) */
+    if (c == 0x9C || c == 0x5C || c == 0x07) {
+
+        data[term->osc52_length] = '\0';
+
+        /* A query ("?") would disclose the clipboard of the user to the
+         * remote application, it is not answered */
+        if (term->osc52_read_data && !term->osc52_overflow
+                && strcmp(data, "?") != 0) {
+
+            int decoded = guac_protocol_decode_base64(data);
+
+            /* Set and broadcast the new clipboard contents */
+            guac_common_clipboard_reset(term->clipboard, "text/plain");
+            guac_common_clipboard_append(term->clipboard, data, decoded);
+            guac_common_clipboard_send(term->clipboard, term->client);
+            guac_socket_flush(term->client->socket);
+
+        }
+
+        term->osc52_read_data = term->osc52_overflow = false;
+        term->osc52_length = 0;
+        term->char_handler = guac_terminal_echo;
+
+    }
+
+    /* Skip the selection parameter, all selections are the clipboard */
+    else if (!term->osc52_read_data) {
+        if (c == ';')
+            term->osc52_read_data = true;
+    }
+
+    /* Store the base64 contents, space permitting */
+    else if (term->osc52_length < GUAC_TERMINAL_OSC52_MAX_LENGTH)
+        data[term->osc52_length++] = (char) c;
+    else
+        term->osc52_overflow = true;
+
+    return 0;
+
+}
+
 int guac_terminal_xterm_palette(guac_terminal* term, unsigned char c) {
 
     /**
@@ -1331,6 +1388,13 @@ int guac_terminal_osc(guac_terminal* term, unsigned char c) {
         else if (operation == 4)
             term->char_handler = guac_terminal_xterm_palette;
 
+        /* xterm clipboard manipulation */
+        else if (operation == 52) {
+            term->osc52_read_data = term->osc52_overflow = false;
+            term->osc52_length = 0;
+            term->char_handler = guac_terminal_set_clipboard;
+        }
+
         /* Reset parameter for next OSC */
         operation = 0;
 
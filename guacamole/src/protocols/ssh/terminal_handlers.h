@@ -151,6 +151,19 @@ int guac_terminal_close_pipe_stream(guac_terminal* term, unsigned char c);
  */
 int guac_terminal_window_title(guac_terminal* term, unsigned char c);
 
+/**
+ * Parses the remainder of xterm's OSC 52 sequence for setting the clipboard.
+ * The base64 contents following the selection parameter replace the
+ * clipboard of all users once the OSC sequence is complete.
+ *
+ * @param term
+ *     The terminal that received the given character of data.
+ *
+ * @param c
+ *     The character that was received by the given terminal.
+ */
+int guac_terminal_set_clipboard(guac_terminal* term, unsigned char c);
+
 /**
  * Parses the remainder of xterm's OSC sequence for redefining the terminal
  * emulator's palette.
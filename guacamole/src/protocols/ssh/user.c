@@ -26,8 +26,11 @@
 #include "user.h"
 #include "ssh.h"
 #include "settings.h"
+#include "sftp.h"
 
+#include <guacamole/argv.h>
 #include <guacamole/client.h>
+#include <guacamole/protocol.h>
 #include <guacamole/socket.h>
 #include <guacamole/user.h>
 
@@ -59,6 +62,9 @@ int guac_ssh_user_join_handler(guac_user* user, int argc, char** argv) {
         /* Store owner's settings at client level */
         ssh_client->settings = settings;
 
+        /* Accept credentials and terminal parameters via argv streams */
+        user->argv_handler = guac_argv_handler;
+
         /* Start client thread */
         if (pthread_create(&(ssh_client->client_thread), NULL,
                     ssh_client_thread, (void*) client)) {
@@ -92,6 +98,10 @@ int guac_ssh_user_join_handler(guac_user* user, int argc, char** argv) {
         /* Display size change events */
         user->size_handler = guac_ssh_user_size_handler;
 
+        /* Set generic (non-filesystem) file upload handler */
+        if (settings->enable_sftp && !settings->sftp_disable_upload)
+            user->file_handler = guac_sftp_file_handler;
+
     }
 
     return 0;
@@ -125,6 +135,10 @@ guac_common_ssh_user* guac_common_ssh_create_user(const char* username) {
     user->username = strdup(username);
     user->password = NULL;
     user->private_key = NULL;
+    user->passphrase = NULL;
+    user->certificate = NULL;
+    user->certificate_blob = NULL;
+    user->certificate_length = 0;
 
     return user;
 
@@ -137,6 +151,9 @@ void guac_common_ssh_destroy_user(guac_common_ssh_user* user) {
         guac_common_ssh_key_free(user->private_key);
 
     /* Free all other data */
+    free(user->certificate_blob);
+    free(user->certificate);
+    free(user->passphrase);
     free(user->password);
     free(user->username);
     free(user);
@@ -170,7 +187,57 @@ int guac_common_ssh_user_import_key(guac_common_ssh_user* user,
                 strlen(private_key), passphrase);
 
     /* Fail if key could not be read */
-    return user->private_key == NULL;
+    if (user->private_key == NULL)
+        return 1;
+
+    /* Keep passphrase for authenticating with a certificate */
+    free(user->passphrase);
+    user->passphrase = passphrase != NULL ? strdup(passphrase) : NULL;
+    return 0;
 
 }
 
+int guac_common_ssh_user_import_certificate(guac_common_ssh_user* user,
+        const char* certificate) {
+
+    /* The certificate belongs to the private key */
+    if (user->private_key == NULL)
+        return 1;
+
+    /* Split key type from base64-encoded certificate */
+    const char* base64 = strchr(certificate, ' ');
+    if (base64 == NULL)
+        return 1;
+
+    int type_length = base64 - certificate;
+    int suffix_length = sizeof(GUAC_SSH_CERT_SUFFIX) - 1;
+
+    /* Only certificates are accepted in place of the public key */
+    if (type_length <= suffix_length
+            || memcmp(base64 - suffix_length, GUAC_SSH_CERT_SUFFIX,
+                suffix_length) != 0)
+        return 1;
+
+    /* Decode certificate in place, ignoring the comment */
+    base64++;
+    char* blob = strndup(base64, strcspn(base64, " \r\n"));
+    int length = guac_protocol_decode_base64(blob);
+
+    /* The encoded certificate starts with its key type */
+    char* pos = blob;
+    if (length < 4 + type_length
+            || guac_common_ssh_buffer_read_uint32(&pos) != type_length
+            || memcmp(pos, certificate, type_length) != 0) {
+        free(blob);
+        return 1;
+    }
+
+    /* Replace current certificate */
+    free(user->certificate_blob);
+    free(user->certificate);
+    user->certificate = strndup(certificate, strcspn(certificate, "\r\n"));
+    user->certificate_blob = blob;
+    user->certificate_length = length;
+    return 0;
+
+}
@@ -26,6 +26,12 @@
 
 #include "key.h"
 
+/**
+ * The suffix of the key types of OpenSSH certificates, such as
+ * "ssh-rsa-cert-v01@openssh.com".
+ */
+#define GUAC_SSH_CERT_SUFFIX "-cert-v01@openssh.com"
+
 /**
  * Data describing an SSH user, including their credentials.
  */
@@ -48,6 +54,29 @@ typedef struct guac_common_ssh_user {
      */
     guac_common_ssh_key* private_key;
 
+    /**
+     * The passphrase which was used to decrypt the private key, if any, or
+     * NULL if the private key is not encrypted.
+     */
+    char* passphrase;
+
+    /**
+     * The OpenSSH certificate of the private key in the authorized_keys
+     * format, if any, which is presented instead of the public key.
+     */
+    char* certificate;
+
+    /**
+     * The certificate, encoded as necessary for SSH, or NULL if there is no
+     * certificate.
+     */
+    char* certificate_blob;
+
+    /**
+     * The length of the encoded certificate, in bytes.
+     */
+    int certificate_length;
+
 } guac_common_ssh_user;
 
 /**
@@ -108,6 +137,26 @@ void guac_common_ssh_user_set_password(guac_common_ssh_user* user,
 int guac_common_ssh_user_import_key(guac_common_ssh_user* user,
         char* private_key, char* passphrase);
 
+/**
+ * Imports the given OpenSSH certificate of the private key of the given user,
+ * such that the certificate is presented instead of the public key for future
+ * authentication attempts. The private key must have been imported already.
+ *
+ * @param user
+ *     The user to associate with the given certificate.
+ *
+ * @param certificate
+ *     The certificate to import in the authorized_keys format, i.e. the key
+ *     type, the base64-encoded certificate and an optional comment separated
+ *     by spaces.
+ *
+ * @return
+ *     Zero if the certificate is successfully imported, or non-zero if the
+ *     certificate could not be imported due to an error.
+ */
+int guac_common_ssh_user_import_certificate(guac_common_ssh_user* user,
+        const char* certificate);
+
 /**
  * Handler for joining users.
  */
@@ -27,6 +27,7 @@
 
 #include <pthread.h>
 #include <cairo/cairo.h>
+#include <guacamole/argv.h>
 #include <guacamole/user.h>
 #include <guacamole/layer.h>
 #include <guacamole/client.h>
@@ -433,6 +434,9 @@ int guac_vnc_user_join_handler(guac_user* user, int argc, char** argv) {
         /* Store owner's settings at client level */
         vnc_client->settings = settings;
 
+        /* Accept the password requested by a "required" instruction */
+        user->argv_handler = guac_argv_handler;
+
         /* Start client thread */
         if (pthread_create(&vnc_client->client_thread, NULL, guac_vnc_client_thread, user->client)) {
             guac_user_log(user, GUAC_LOG_ERROR, "Unable to start VNC client thread.");
@@ -1015,10 +1019,27 @@ void guac_vnc_cut_text(rfbClient* client, const char* text, int textlen) {
 
 }
 
+/**
+ * Handles a received value of the VNC password by storing it within the
+ * connection settings, replacing any previous value.
+ */
+static int guac_vnc_argv_callback(guac_user* user, const char* mimetype,
+        const char* name, const char* value, void* data) {
+
+    guac_vnc_settings* settings = (guac_vnc_settings*) data;
+
+    free(settings->password);
+    settings->password = strdup(value);
+    return 0;
+
+}
+
 /**
  * Callback which is invoked by libVNCServer when it needs to read the user's
  * VNC password. As ths user's password, if any, will be stored in the
- * connection settings, this function does nothing more than return that value.
+ * connection settings, this function returns that value. If no password was
+ * given and the owner supports the "required" instruction, the password is
+ * requested from the owner and this function blocks until it is received.
  *
  * @param client
  *     The rfbClient associated with the VNC connection requiring the password.
@@ -1027,8 +1048,25 @@ void guac_vnc_cut_text(rfbClient* client, const char* text, int textlen) {
  *     The password to provide to the VNC server.
  */
 char* guac_vnc_get_password(rfbClient* client) {
+
     guac_client* gc = rfbClientGetClientData(client, GUAC_VNC_CLIENT_KEY);
-    return ((guac_vnc_client*) gc->data)->settings->password;
+    guac_vnc_settings* settings = ((guac_vnc_client*) gc->data)->settings;
+
+    /* Ask the owner for the missing password, if supported */
+    if ((settings->password == NULL || strcmp(settings->password, "") == 0)
+            && guac_client_owner_supports_required(gc)) {
+
+        const char* params[] = { "password", NULL };
+        guac_argv_register(gc, "password", guac_vnc_argv_callback, settings,
+                GUAC_ARGV_OPTION_ONCE);
+
+        if (!guac_client_owner_send_required(gc, params))
+            guac_argv_await(gc, params);
+
+    }
+
+    return settings->password;
+
 }
 
 /**
@@ -0,0 +1,263 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package bench implements a load generator of occamy. It opens
+// concurrent sessions against a server, replays recorded client input
+// and measures the performance of the server.
+package bench
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"changkun.de/x/occamy/client"
+)
+
+// Errors of a benchmark
+var (
+	ErrConfig     = errors.New("bench: invalid config")
+	ErrTerminated = errors.New("bench: session terminated before the end")
+)
+
+// Config is the configuration of a benchmark
+type Config struct {
+	// Addr is the address of the occamy server, e.g. http://0.0.0.0:5636.
+	Addr string
+	// Credentials are assigned to the sessions in turn. Connections
+	// with the same credentials join the same session of the server,
+	// hence different credentials are required for separate sessions.
+	Credentials []client.Credentials
+	// Sessions is the number of concurrent connections.
+	Sessions int
+	// Ramp is the duration to open all connections, which are opened
+	// at once if zero.
+	Ramp time.Duration
+	// Duration is the duration of each connection.
+	Duration time.Duration
+	// Recording is the client input replayed by each connection, it
+	// is repeated until the connection ends.
+	Recording []Event
+	// Speed is the replay speed, 1 is real time and 2 is twice as fast.
+	// The recording is replayed as fast as possible if not positive:
+	// its instructions are sent without delays, and each repetition
+	// waits for the server to draw a frame, at most for noDelayWait.
+	Speed float64
+	// Options are the options of all connections.
+	Options client.Options
+}
+
+// noDelayWait is the longest wait for a frame between repetitions of a
+// recording which is replayed as fast as possible, in case its input
+// does not change the display.
+const noDelayWait = time.Second
+
+// result is the measurement of a single connection
+type result struct {
+	err        error
+	login      time.Duration
+	handshake  time.Duration // time to dial until the first instruction
+	firstFrame time.Duration // time to dial until the first sync
+	latency    []time.Duration
+
+	bytes, instructions, frames, inputs uint64
+	images, copies                      uint64
+}
+
+// Run runs a benchmark and reports its results. Canceling the given
+// context ends all connections early.
+func Run(ctx context.Context, conf Config) (*Report, error) {
+	if conf.Sessions <= 0 || len(conf.Credentials) == 0 || conf.Duration <= 0 {
+		return nil, ErrConfig
+	}
+	conf.Options.Discard = false
+
+	results := make([]*result, conf.Sessions)
+	opened := conf.Sessions
+	start := time.Now()
+	wg := sync.WaitGroup{}
+	for i := 0; i < conf.Sessions; i++ {
+		if conf.Ramp > 0 {
+			at := start.Add(conf.Ramp * time.Duration(i) / time.Duration(conf.Sessions))
+			if !sleep(ctx, time.Until(at)) {
+				opened = i
+				break
+			}
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = runSession(ctx, &conf, conf.Credentials[i%len(conf.Credentials)])
+		}(i)
+	}
+	wg.Wait()
+	return report(results[:opened], time.Since(start)), nil
+}
+
+func runSession(ctx context.Context, conf *Config, cred client.Credentials) *result {
+	res := &result{}
+	t := time.Now()
+	token, err := client.Login(ctx, conf.Addr, cred)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	res.login = time.Since(t)
+
+	t = time.Now()
+	c, err := client.Dial(ctx, conf.Addr, token, &conf.Options)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	res.handshake = time.Since(t)
+
+	// the sync latency is the time from an input until the next frame
+	var (
+		mu      sync.Mutex
+		pending time.Time
+	)
+	received := make(chan struct{})
+	frame := make(chan struct{}, 1)
+	go func() {
+		defer close(received)
+		for m := range c.Messages() {
+			if _, ok := m.(*client.Sync); !ok {
+				continue
+			}
+			now := time.Now()
+			mu.Lock()
+			res.frames++
+			if res.firstFrame == 0 {
+				res.firstFrame = now.Sub(t)
+			}
+			if !pending.IsZero() {
+				res.latency = append(res.latency, now.Sub(pending))
+				pending = time.Time{}
+			}
+			mu.Unlock()
+			select {
+			case frame <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, conf.Duration)
+	defer cancel()
+	res.err = replay(ctx, c, conf, frame, func() {
+		mu.Lock()
+		res.inputs++
+		if pending.IsZero() {
+			pending = time.Now()
+		}
+		mu.Unlock()
+	})
+	c.Close()
+	<-received
+	res.bytes, res.instructions = c.Received()
+	res.images, res.copies = c.Drawn()
+	return res
+}
+
+// replay replays the recording until the context is done, sent is
+// called after each sent instruction. frame receives a value after
+// each frame drawn by the server.
+func replay(ctx context.Context, c *client.Client, conf *Config, frame <-chan struct{}, sent func()) error {
+	terminated := func() error {
+		if err := c.Err(); err != nil {
+			return err
+		}
+		return ErrTerminated
+	}
+	if len(conf.Recording) == 0 {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.Done():
+			return terminated()
+		}
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	wait := func(d time.Duration, frame <-chan struct{}) error {
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(d)
+		select {
+		case <-timer.C:
+		case <-frame:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.Done():
+			return terminated()
+		}
+		return nil
+	}
+	for {
+		begin := time.Now()
+		if conf.Speed <= 0 {
+			// only frames of this repetition are awaited
+			select {
+			case <-frame:
+			default:
+			}
+		}
+		for _, e := range conf.Recording {
+			if conf.Speed > 0 {
+				err := wait(time.Until(begin.Add(time.Duration(float64(e.Offset)/conf.Speed))), nil)
+				if err != nil {
+					return ignoreDone(ctx, err)
+				}
+			} else if ctx.Err() != nil {
+				return nil
+			}
+
+			err := c.Send(&client.Raw{Op: e.Ins.Opcode(), Args: e.Ins.Args()})
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return terminated()
+			}
+			sent()
+		}
+		if conf.Speed <= 0 {
+			if err := wait(noDelayWait, frame); err != nil {
+				return ignoreDone(ctx, err)
+			}
+		}
+	}
+}
+
+// ignoreDone returns nil if the error is caused by the end of the
+// given context.
+func ignoreDone(ctx context.Context, err error) error {
+	if err == ctx.Err() {
+		return nil
+	}
+	return err
+}
+
+// sleep sleeps for the given duration, it reports false if the context
+// is done before.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
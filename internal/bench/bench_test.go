@@ -0,0 +1,144 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package bench_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/client"
+	"changkun.de/x/occamy/internal/bench"
+	"github.com/gorilla/websocket"
+)
+
+// fakeServer serves the login and connect APIs, it completes a frame
+// of a copy after each received key.
+func fakeServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/login", func(w http.ResponseWriter, r *http.Request) {
+		var cred client.Credentials
+		json.NewDecoder(r.Body).Decode(&cred)
+		if cred.Password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":401,"message":"incorrect Username or Password"}`))
+			return
+		}
+		w.Write([]byte(`{"code":200,"token":"token"}`))
+	})
+	mux.HandleFunc("/api/v1/connect", func(w http.ResponseWriter, r *http.Request) {
+		up := websocket.Upgrader{Subprotocols: []string{"guacamole"}}
+		ws, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		ws.WriteMessage(websocket.TextMessage, []byte("4.size,1.0,2.64,2.64;4.sync,1.1;"))
+		for {
+			_, data, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(string(data), "3.key") {
+				ws.WriteMessage(websocket.TextMessage, []byte("4.copy,1.0,1.0,1.0,1.1,1.1,2.14,1.0,1.1,1.1;4.sync,1.2;"))
+			}
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestReadRecording(t *testing.T) {
+	events, err := bench.ReadRecording(strings.NewReader(`# recorded input
+0 "5.mouse,1.1,1.2,1.0;"
+
+120 "3.key,2.65,1.1;"
+240 "9.clipboard,1.1,3.a\nb;"
+`))
+	if err != nil {
+		t.Fatalf("read recording error: %v", err)
+	}
+	if len(events) != 3 || events[1].Offset != 120*time.Millisecond || events[1].Ins.Opcode() != "key" ||
+		events[2].Ins.Args()[1] != "a\nb" {
+		t.Fatalf("read recording, got: %+v", events)
+	}
+
+	for _, bad := range []string{"0", `x "3.key,2.65,1.1;"`, `0 "3.key,2.65,1.1"`, "0 3.key,2.65,1.1;"} {
+		_, err := bench.ReadRecording(strings.NewReader(bad))
+		if err == nil {
+			t.Errorf("read bad recording %q should fail", bad)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	srv := fakeServer()
+	defer srv.Close()
+
+	recording, err := bench.ReadRecording(strings.NewReader("0 \"3.key,2.65,1.1;\"\n40 \"3.key,2.65,1.0;\"\n"))
+	if err != nil {
+		t.Fatalf("read recording error: %v", err)
+	}
+	r, err := bench.Run(context.Background(), bench.Config{
+		Addr: srv.URL,
+		Credentials: []client.Credentials{
+			{Protocol: "vnc", Host: "a:5900", Password: "secret"},
+			{Protocol: "vnc", Host: "b:5900", Password: "secret"},
+			{Protocol: "vnc", Host: "c:5900", Password: "bad"},
+		},
+		Sessions:  6,
+		Ramp:      30 * time.Millisecond,
+		Duration:  200 * time.Millisecond,
+		Recording: recording,
+		Speed:     2,
+	})
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	if r.Sessions != 6 || r.Failed != 2 || r.ErrorRate != float64(2)/6 || len(r.Errors) != 1 {
+		t.Fatalf("unexpected sessions of report: %+v", r)
+	}
+	if r.Handshake.Count != 4 || r.FirstFrame.Count != 4 || r.SyncLatency.Count == 0 {
+		t.Fatalf("unexpected measurements of report: %+v", r)
+	}
+	if r.Throughput.Bytes <= 0 || r.Throughput.Frames <= 0 || r.Throughput.Inputs <= 0 ||
+		r.Throughput.Copies <= 0 || r.Throughput.Images != 0 {
+		t.Fatalf("unexpected throughput of report: %+v", r.Throughput)
+	}
+
+	_, err = bench.Run(context.Background(), bench.Config{})
+	if err != bench.ErrConfig {
+		t.Fatalf("run without sessions should fail, got: %v", err)
+	}
+}
+
+func TestRun_NoDelay(t *testing.T) {
+	srv := fakeServer()
+	defer srv.Close()
+
+	recording, err := bench.ReadRecording(strings.NewReader("0 \"3.key,2.65,1.1;\"\n40 \"3.key,2.65,1.0;\"\n"))
+	if err != nil {
+		t.Fatalf("read recording error: %v", err)
+	}
+	r, err := bench.Run(context.Background(), bench.Config{
+		Addr:        srv.URL,
+		Credentials: []client.Credentials{{Protocol: "vnc", Host: "a:5900", Password: "secret"}},
+		Sessions:    1,
+		Duration:    200 * time.Millisecond,
+		Recording:   recording,
+		Speed:       -1,
+	})
+	if err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	// each repetition of the recording waits for a frame of the server
+	inputs, frames := r.Throughput.Inputs*r.Elapsed/1000, r.Throughput.Frames*r.Elapsed/1000
+	if r.Failed != 0 || inputs == 0 || inputs > 2*(frames+1)+0.5 {
+		t.Fatalf("unexpected replay without delays: %+v", r.Throughput)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package bench
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"changkun.de/x/occamy/internal/protocol"
+)
+
+// Event is an instruction of a recording sent at the given offset
+// since the session began.
+type Event struct {
+	Offset time.Duration
+	Ins    *protocol.Instruction
+}
+
+// ReadRecording reads a recording of client input as written by
+// server.RecordInput, the instructions are JSON strings, e.g.:
+//
+//	120 "5.mouse,3.100,3.200,1.0;"
+//	250 "3.key,5.65293,1.1;"
+//
+// Empty lines and lines starting with # are skipped.
+func ReadRecording(r io.Reader) ([]Event, error) {
+	var events []Event
+	s := bufio.NewScanner(r)
+	// an escaped byte takes at most 6 bytes of a JSON string
+	s.Buffer(make([]byte, protocol.InstructionMaxLength), 6*protocol.InstructionMaxLength+32)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("bench: recording line %d: missing instruction", line)
+		}
+		ms, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil || ms < 0 {
+			return nil, fmt.Errorf("bench: recording line %d: bad offset %q", line, fields[0])
+		}
+		var raw string
+		if err := json.Unmarshal([]byte(fields[1]), &raw); err != nil {
+			return nil, fmt.Errorf("bench: recording line %d: bad instruction: %w", line, err)
+		}
+		ins, err := protocol.ParseInstruction([]byte(raw))
+		if err != nil {
+			return nil, fmt.Errorf("bench: recording line %d: %w", line, err)
+		}
+		events = append(events, Event{Offset: time.Duration(ms) * time.Millisecond, Ins: ins})
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("bench: read recording failed: %w", err)
+	}
+	return events, nil
+}
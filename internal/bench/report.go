@@ -0,0 +1,132 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package bench
+
+import (
+	"sort"
+	"time"
+)
+
+// Report is the machine-readable result of a benchmark. All durations
+// are reported in milliseconds.
+type Report struct {
+	Sessions  int            `json:"sessions"`
+	Failed    int            `json:"failed"`
+	ErrorRate float64        `json:"error_rate"`
+	Errors    map[string]int `json:"errors,omitempty"`
+	Elapsed   float64        `json:"elapsed"`
+
+	Login       Distribution `json:"login"`
+	Handshake   Distribution `json:"handshake"`
+	FirstFrame  Distribution `json:"first_frame"`
+	SyncLatency Distribution `json:"sync_latency"`
+
+	Throughput Throughput `json:"throughput"`
+}
+
+// Distribution summarizes a set of durations in milliseconds
+type Distribution struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// Throughput is the data received from the server by all sessions
+// per second.
+type Throughput struct {
+	Bytes        float64 `json:"bytes_per_second"`
+	Instructions float64 `json:"instructions_per_second"`
+	Frames       float64 `json:"frames_per_second"`
+	Inputs       float64 `json:"inputs_per_second"`
+	// Images are the bytes of images and Copies the copy instructions,
+	// which tell how a protocol spends its bandwidth on drawing.
+	Images float64 `json:"image_bytes_per_second"`
+	Copies float64 `json:"copies_per_second"`
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func distribution(ds []time.Duration) Distribution {
+	if len(ds) == 0 {
+		return Distribution{}
+	}
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	var sum time.Duration
+	for _, d := range ds {
+		sum += d
+	}
+	percentile := func(p float64) float64 {
+		return ms(ds[int(p*float64(len(ds)-1))])
+	}
+	return Distribution{
+		Count: len(ds),
+		Min:   ms(ds[0]),
+		Mean:  ms(sum / time.Duration(len(ds))),
+		P50:   percentile(0.50),
+		P90:   percentile(0.90),
+		P99:   percentile(0.99),
+		Max:   ms(ds[len(ds)-1]),
+	}
+}
+
+// report aggregates the results of all sessions.
+func report(results []*result, elapsed time.Duration) *Report {
+	r := &Report{
+		Sessions: len(results),
+		Errors:   make(map[string]int),
+		Elapsed:  ms(elapsed),
+	}
+	var (
+		login, handshake, firstFrame, latency []time.Duration
+		bytes, instructions, frames, inputs   uint64
+		images, copies                        uint64
+	)
+	for _, res := range results {
+		if res.err != nil {
+			r.Failed++
+			r.Errors[res.err.Error()]++
+		}
+		if res.login > 0 {
+			login = append(login, res.login)
+		}
+		if res.handshake > 0 {
+			handshake = append(handshake, res.handshake)
+		}
+		if res.firstFrame > 0 {
+			firstFrame = append(firstFrame, res.firstFrame)
+		}
+		latency = append(latency, res.latency...)
+		bytes += res.bytes
+		instructions += res.instructions
+		frames += res.frames
+		inputs += res.inputs
+		images += res.images
+		copies += res.copies
+	}
+	if r.Sessions > 0 {
+		r.ErrorRate = float64(r.Failed) / float64(r.Sessions)
+	}
+	r.Login = distribution(login)
+	r.Handshake = distribution(handshake)
+	r.FirstFrame = distribution(firstFrame)
+	r.SyncLatency = distribution(latency)
+	if s := elapsed.Seconds(); s > 0 {
+		r.Throughput = Throughput{
+			Bytes:        float64(bytes) / s,
+			Instructions: float64(instructions) / s,
+			Frames:       float64(frames) / s,
+			Inputs:       float64(inputs) / s,
+			Images:       float64(images) / s,
+			Copies:       float64(copies) / s,
+		}
+	}
+	return r
+}
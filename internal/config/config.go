@@ -6,13 +6,10 @@ package config
 
 import (
 	"crypto/md5"
-	"flag"
 	"fmt"
 	"io/ioutil"
-	"log"
-	"os"
+	"time"
 
-	"github.com/gin-gonic/gin"
 	"gopkg.in/yaml.v2"
 )
 
@@ -21,49 +18,119 @@ type JWT struct {
 	Protocol string `form:"protocol" json:"protocol" binding:"required"`
 	Host     string `form:"host"     json:"host"     binding:"required"`
 	Username string `form:"username" json:"username"`
-	Password string `form:"password" json:"password" binding:"required"`
+	Password string `form:"password" json:"password"`
+	// HostKeyMode is the mode of the host key verification of SSH
+	// servers, i.e. tofu or strict, optional.
+	HostKeyMode string `form:"host_key_mode" json:"host_key_mode"`
+	// JumpHosts are the SSH jump hosts to the host in order, optional.
+	JumpHosts []JumpHost `json:"jump_hosts"`
+}
+
+// JumpHost is an SSH jump host of a connection and its credentials.
+type JumpHost struct {
+	Host       string `json:"host"`
+	Username   string `json:"username"`
+	Password   string `json:"password,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
 }
 
 // GenerateID generates a unique id based on JWT information
 func (j *JWT) GenerateID() string {
 	h := md5.New()
 	h.Write([]byte(fmt.Sprintf("%s%s%s%s", j.Protocol, j.Host, j.Username, j.Password)))
+	for _, hop := range j.JumpHosts {
+		h.Write([]byte(hop.Username + "@" + hop.Host))
+	}
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
-// config is a
-type config struct {
+// UserInfo is the display and media information reported by the browser
+// client while connecting. The parameter names are the same as the ones
+// guacamole-common-js sends with the tunnel connect request, in addition,
+// GUAC_VERSION declares the protocol version of the client, such as
+// VERSION_1_1_0, and GUAC_TERMINAL requests the terminal mode of Go
+// terminal protocols, such as vt for clients that render the raw VT
+// output themselves.
+type UserInfo struct {
+	Version  string   `form:"GUAC_VERSION"`
+	Width    int      `form:"GUAC_WIDTH"`
+	Height   int      `form:"GUAC_HEIGHT"`
+	DPI      int      `form:"GUAC_DPI"`
+	Timezone string   `form:"GUAC_TIMEZONE"`
+	Audio    []string `form:"GUAC_AUDIO"`
+	Video    []string `form:"GUAC_VIDEO"`
+	Image    []string `form:"GUAC_IMAGE"`
+	Terminal string   `form:"GUAC_TERMINAL"`
+}
+
+// Config is the runtime configuration of an occamy daemon
+type Config struct {
 	Address string `yaml:"address"`
 	Mode    string `yaml:"mode"`
 	Auth    struct {
 		JWTSecret    string `yaml:"jwt_secret"`
 		JWTAlgorithm string `yaml:"jwt_alg"`
+		// AdminToken is the bearer token of the admin API, which is
+		// disabled if empty.
+		AdminToken string `yaml:"admin_token"`
 	} `yaml:"auth"`
 	Client bool `yaml:"client"`
+	// Protocols are the connection arguments of protocols by protocol
+	// name.
+	Protocols map[string]map[string]string `yaml:"protocols"`
+	// HostKeys is the known_hosts file of the host key store of SSH
+	// servers, host keys are not verified by occamy if empty.
+	HostKeys string `yaml:"host_keys"`
+	// SSHCA is the certificate authority of SSH users, certificates are
+	// not issued by occamy if the key is empty.
+	SSHCA struct {
+		// Key is the unencrypted private key file of the authority.
+		Key string `yaml:"key"`
+		// Validity is the validity of the issued certificates.
+		Validity time.Duration `yaml:"validity"`
+		// Principals are the principals of the certificates by username,
+		// certificates are only issued for the usernames listed here.
+		Principals map[string][]string `yaml:"principals"`
+	} `yaml:"ssh_ca"`
+	// Dialer is the URL of the dialer of remote hosts, e.g. a SOCKS5
+	// proxy socks5://host:1080, the plugins dial directly if empty.
+	Dialer string `yaml:"dialer"`
+	// Egress is the policy of the remote hosts that occamy connects to,
+	// all remote hosts are permitted if it has neither rules nor default.
+	Egress struct {
+		// Default is the action of connections which no rule matches,
+		// allow or deny, connections are denied if empty.
+		Default string       `yaml:"default"`
+		Rules   []EgressRule `yaml:"rules"`
+	} `yaml:"egress"`
 }
 
-// Runtime configurations
-var Runtime = &config{}
-
-// Init initialize the runtime configurations
-func Init() {
-	log.SetPrefix("occamy: ")
-	log.SetFlags(log.LstdFlags | log.Lshortfile | log.Lmsgprefix)
+// EgressRule is a rule of the egress policy, it matches connections of
+// any of its protocols to any of its hosts and ports. All connections
+// match an empty list.
+type EgressRule struct {
+	// Action is allow or deny.
+	Action    string   `yaml:"action"`
+	Protocols []string `yaml:"protocols"`
+	// Hosts are CIDR blocks, addresses or host names, a name *.example.com
+	// matches all subdomains of example.com.
+	Hosts []string `yaml:"hosts"`
+	// Ports are ports or port ranges, e.g. 5900-5910.
+	Ports []string `yaml:"ports"`
+}
 
-	loc := flag.String("conf", "./conf.yaml", "path to the runtime config file")
-	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, `a modern guacamole protocol based remote desktop proxy written in Go.
-Usage:`)
-		flag.PrintDefaults()
-	}
-	flag.Parse()
-	raw, err := ioutil.ReadFile(*loc)
+// Load reads and parses the runtime configurations from the given
+// config file.
+func Load(path string) (*Config, error) {
+	raw, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatalf("cannot open given config file, error: %v", err)
+		return nil, fmt.Errorf("cannot open given config file: %w", err)
 	}
-	err = yaml.Unmarshal(raw, Runtime)
+	c := &Config{}
+	err = yaml.Unmarshal(raw, c)
 	if err != nil {
-		log.Fatalf("failed of parsing config file, error: %v", err)
+		return nil, fmt.Errorf("failed of parsing config file: %w", err)
 	}
-	gin.SetMode(Runtime.Mode)
+	return c, nil
 }
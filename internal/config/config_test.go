@@ -5,16 +5,33 @@
 package config_test
 
 import (
-	"os"
 	"testing"
 
 	"changkun.de/x/occamy/internal/config"
 )
 
-func TestJWT_GenerateID(t *testing.T) {
-	os.Args[1] = "-conf=../../conf.yaml"
-	config.Init()
+func TestLoad(t *testing.T) {
+	c, err := config.Load("../../conf.yaml")
+	if err != nil {
+		t.Fatalf("load config error: %v", err)
+	}
+	if c.Address != "0.0.0.0:5636" {
+		t.Fatalf("unexpected address, got: %s", c.Address)
+	}
+	if c.Auth.JWTAlgorithm != "HS256" {
+		t.Fatalf("unexpected jwt algorithm, got: %s", c.Auth.JWTAlgorithm)
+	}
+	if c.Protocols["telnet"]["color-scheme"] != "gray-black" {
+		t.Fatalf("unexpected telnet arguments, got: %v", c.Protocols["telnet"])
+	}
 
+	_, err = config.Load("not-exist.yaml")
+	if err == nil {
+		t.Fatalf("load a non-exist config should fail")
+	}
+}
+
+func TestJWT_GenerateID(t *testing.T) {
 	j := config.JWT{
 		Protocol: "vnc",
 		Host:     "0.0.0.0:5636",
@@ -27,6 +27,14 @@ void init_client_log(guac_client* client, int level) {
 	client->log_handler = occamy_client_log;
 	max_log_level = level;
 }
+
+extern void occamyClientEvent(guac_client* client, int event, guac_user* user);
+static void occamy_client_event(guac_client* client, guac_client_event event, guac_user* user) {
+	occamyClientEvent(client, (int) event, user);
+}
+static void init_client_events(guac_client* client) {
+	client->event_handler = occamy_client_event;
+}
 */
 import "C"
 import (
@@ -85,20 +93,17 @@ var clientLogLevelTable = map[string]clientLogLevel{
 type Client struct {
 	guacClient *C.struct_guac_client
 	ID         string
-	running    bool
 	data       interface{}
 	lastSent   time.Time
 
 	mu             sync.RWMutex
+	running        bool
 	users          *User // list of all connected users
 	owner          *User
 	connectedUsers int64
-
-	handlerFree  func()
-	handlerLog   func()
-	handlerJoin  func()
-	handlerLeave func()
-	args         []string
+	known          map[*C.struct_guac_user]*User // users created for the client
+	hooks          []Hook
+	args           []string
 }
 
 // NewClient creates a new guacamole client
@@ -111,14 +116,18 @@ func NewClient() (*Client, error) {
 		return nil, errorStatus()
 	}
 
-	return &Client{
+	c := &Client{
 		guacClient: cli,
 
 		ID:       id,
 		running:  true,
 		lastSent: time.Now(),
+		known:    make(map[*C.struct_guac_user]*User),
 		args:     []string{},
-	}, nil
+	}
+	registerClient(c)
+	C.init_client_events(cli)
+	return c, nil
 }
 
 // isRunning checks if a client is still running
@@ -8,6 +8,7 @@ package lib
 #cgo LDFLAGS: -L/usr/local/lib -lguac
 #include <stdlib.h>
 #include <string.h>
+#include <errno.h>
 #include "../../guacamole/src/libguac/guacamole/error.h"
 #include "../../guacamole/src/libguac/guacamole/client.h"
 
@@ -15,11 +16,18 @@ void guac_error_reset() {
 	guac_error = GUAC_STATUS_SUCCESS;
 	guac_error_message = NULL;
 }
+void get_error(int* status, char** message, int* err) {
+	*err = errno;
+	*status = (int) guac_error;
+	*message = (char*) guac_error_message;
+}
 */
 import "C"
 import (
 	"errors"
-	"unsafe"
+	"syscall"
+
+	"changkun.de/x/occamy/internal/protocol"
 )
 
 // status defines all consts in guac_status
@@ -111,54 +119,188 @@ const (
 	statusWouldBlock
 )
 
-// Err returns a status error given a status
-func Err(status status) error {
-	return statusError[status]
-}
+// Errors reported by libguac, errors returned by this package match
+// them by errors.Is.
+var (
+	ErrNoMemory         = errors.New("Insufficient memory")
+	ErrClosed           = errors.New("Closed")
+	ErrTimeout          = errors.New("Timed out")
+	ErrIO               = errors.New("Input/output error")
+	ErrInvalidArgument  = errors.New("Invalid argument")
+	ErrInternal         = errors.New("Internal error")
+	ErrNoSpace          = errors.New("Insufficient space")
+	ErrInputTooLarge    = errors.New("Input too large")
+	ErrResultTooLarge   = errors.New("Result too large")
+	ErrPermissionDenied = errors.New("Permission denied")
+	ErrBusy             = errors.New("Resource busy")
+	ErrNotAvailable     = errors.New("Resource not available")
+	ErrNotSupported     = errors.New("Not supported")
+	ErrNotImplemented   = errors.New("Not implemented")
+	ErrTryAgain         = errors.New("Temporary failure")
+	ErrProtocol         = errors.New("Protocol violation")
+	ErrNotFound         = errors.New("Not found")
+	ErrCanceled         = errors.New("Canceled")
+	ErrOutOfRange       = errors.New("Value out of range")
+	ErrRefused          = errors.New("Operation refused")
+	ErrTooMany          = errors.New("Insufficient resources")
+	ErrWouldBlock       = errors.New("Operation would block")
+
+	// ErrUserJoin indicates the protocol plugin refused a joining user,
+	// usually because the remote desktop cannot be connected.
+	ErrUserJoin = errors.New("occamy-lib: user cannot join")
+)
 
+// statusError maps a status to its error, statusSuccess and
+// statusSeeErrno have no corresponding error.
 var statusError = map[status]error{
-	statusSuccess:          errors.New("Success"),
-	statusNoMemory:         errors.New("Insufficient memory"),
-	statusClosed:           errors.New("Closed"),
-	statusTimeout:          errors.New("Timed out"),
-	statusSeeErrno:         errors.New("Input/output error"),
-	statusIOError:          errors.New("Invalid argument"),
-	statusInvalidArgument:  errors.New("Internal error"),
-	statusInternalError:    errors.New("UNKNOWN STATUS CODE"),
-	statusNoSpace:          errors.New("Insufficient space"),
-	statusInputTooLarge:    errors.New("Input too large"),
-	statusResultTooLarge:   errors.New("Result too large"),
-	statusPermissionDenied: errors.New("Permission denied"),
-	statusBusy:             errors.New("Resource busy"),
-	statusNotAvailable:     errors.New("Resource not available"),
-	statusNotSupported:     errors.New("Not supported"),
-	statusNotImplemented:   errors.New("Not implemented"),
-	statusTryAgain:         errors.New("Temporary failure"),
-	statusProtocolError:    errors.New("Protocol violation"),
-	statusNotFound:         errors.New("Not found"),
-	statusCanceled:         errors.New("Canceled"),
-	statusOutOfRange:       errors.New("Value out of range"),
-	statusRefused:          errors.New("Operation refused"),
-	statusTooMany:          errors.New("Insufficient resources"),
-	statusWouldBlock:       errors.New("Operation would block"),
+	statusNoMemory:         ErrNoMemory,
+	statusClosed:           ErrClosed,
+	statusTimeout:          ErrTimeout,
+	statusIOError:          ErrIO,
+	statusInvalidArgument:  ErrInvalidArgument,
+	statusInternalError:    ErrInternal,
+	statusNoSpace:          ErrNoSpace,
+	statusInputTooLarge:    ErrInputTooLarge,
+	statusResultTooLarge:   ErrResultTooLarge,
+	statusPermissionDenied: ErrPermissionDenied,
+	statusBusy:             ErrBusy,
+	statusNotAvailable:     ErrNotAvailable,
+	statusNotSupported:     ErrNotSupported,
+	statusNotImplemented:   ErrNotImplemented,
+	statusTryAgain:         ErrTryAgain,
+	statusProtocolError:    ErrProtocol,
+	statusNotFound:         ErrNotFound,
+	statusCanceled:         ErrCanceled,
+	statusOutOfRange:       ErrOutOfRange,
+	statusRefused:          ErrRefused,
+	statusTooMany:          ErrTooMany,
+	statusWouldBlock:       ErrWouldBlock,
+}
+
+// statusProtocol maps a status to the guacamole protocol status that is
+// reported to the browser client.
+var statusProtocol = map[status]protocol.Status{
+	statusSuccess:          protocol.StatusSuccess,
+	statusNoMemory:         protocol.StatusServerError,
+	statusClosed:           protocol.StatusResourceClosed,
+	statusTimeout:          protocol.StatusUpstreamTimeout,
+	statusIOError:          protocol.StatusUpstreamError,
+	statusInvalidArgument:  protocol.StatusClientBadRequest,
+	statusInternalError:    protocol.StatusServerError,
+	statusNoSpace:          protocol.StatusServerError,
+	statusInputTooLarge:    protocol.StatusClientOverrun,
+	statusResultTooLarge:   protocol.StatusServerError,
+	statusPermissionDenied: protocol.StatusClientForbidden,
+	statusBusy:             protocol.StatusServerBusy,
+	statusNotAvailable:     protocol.StatusUpstreamUnavailable,
+	statusNotSupported:     protocol.StatusUnsupported,
+	statusNotImplemented:   protocol.StatusUnsupported,
+	statusTryAgain:         protocol.StatusServerBusy,
+	statusProtocolError:    protocol.StatusClientBadRequest,
+	statusNotFound:         protocol.StatusResourceNotFound,
+	statusCanceled:         protocol.StatusResourceClosed,
+	statusOutOfRange:       protocol.StatusClientBadRequest,
+	statusRefused:          protocol.StatusClientForbidden,
+	statusTooMany:          protocol.StatusClientTooMany,
+	statusWouldBlock:       protocol.StatusServerBusy,
+}
+
+// Error is an error reported by libguac. It wraps the error of its
+// status, or its errno if libguac reported GUAC_STATUS_SEE_ERRNO.
+type Error struct {
+	status  status
+	Message string        // guac_error_message, if any
+	Errno   syscall.Errno // errno, if any
+}
+
+func (e *Error) Error() string {
+	reason := "Invalid status code"
+	if e.status == statusSuccess {
+		reason = "Success"
+	} else if err := e.Unwrap(); err != nil {
+		reason = err.Error()
+	}
+	if e.Message == "" || e.Message == reason {
+		return "occamy-lib: " + reason
+	}
+	return "occamy-lib: " + e.Message + ": " + reason
+}
+
+// Unwrap returns the error of the status, or the errno.
+func (e *Error) Unwrap() error {
+	if e.status == statusSeeErrno {
+		return e.Errno
+	}
+	return statusError[e.status]
+}
+
+// Status returns the guacamole protocol status of the error.
+func (e *Error) Status() protocol.Status {
+	if e.status != statusSeeErrno {
+		if s, ok := statusProtocol[e.status]; ok {
+			return s
+		}
+		return protocol.StatusServerError
+	}
+	switch e.Errno {
+	case syscall.ETIMEDOUT:
+		return protocol.StatusUpstreamTimeout
+	case syscall.ECONNREFUSED, syscall.ECONNRESET:
+		return protocol.StatusUpstreamUnavailable
+	case syscall.EHOSTUNREACH, syscall.ENETUNREACH:
+		return protocol.StatusUpstreamNotFound
+	}
+	return protocol.StatusServerError
+}
+
+// ProtocolStatus returns the guacamole protocol status of an error
+// returned by this package, other errors are server errors.
+func ProtocolStatus(err error) protocol.Status {
+	var e *Error
+	switch {
+	case err == nil:
+		return protocol.StatusSuccess
+	case errors.As(err, &e):
+		return e.Status()
+	case errors.Is(err, ErrUserJoin):
+		return protocol.StatusUpstreamError
+	}
+	return protocol.StatusServerError
+}
+
+// lastError returns the error which occurred during the last libguac
+// call of the current thread, or nil if no error occurred.
+func lastError() error {
+	var (
+		s       C.int
+		message *C.char
+		errno   C.int
+	)
+	C.get_error(&s, &message, &errno)
+	if status(s) == statusSuccess {
+		return nil
+	}
+	e := &Error{status: status(s), Message: C.GoString(message)}
+	if e.status == statusSeeErrno {
+		e.Errno = syscall.Errno(errno)
+	}
+	return e
 }
 
-// errorStatus reports a message describing the error which occurred during the last
-// function call. If an error occurred, but no message is associated with it,
-// NULL is returned. This value is undefined if no error occurred.
+// errorStatus returns the error which occurred during the last libguac
+// call, which is known to have failed.
 func errorStatus() error {
-	s := status((*(*C.guac_status)(unsafe.Pointer(C.__guac_error()))))
-	err, ok := statusError[s]
-	if !ok {
-		return errors.New("Invalid status code")
+	err := lastError()
+	if err == nil {
+		return &Error{status: statusInternalError, Message: "unknown libguac error"}
 	}
 	return err
 }
 
 // ResetErrors resets guacamole runtime error
-// and returns the former error message
+// and returns the former error
 func ResetErrors() error {
-	old := errorStatus()
+	old := lastError()
 	C.guac_error_reset()
 	return old
 }
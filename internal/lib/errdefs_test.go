@@ -5,11 +5,58 @@
 package lib_test
 
 import (
+	"errors"
+	"fmt"
+	"runtime"
 	"testing"
 
 	"changkun.de/x/occamy/internal/lib"
+	"changkun.de/x/occamy/internal/protocol"
 )
 
 func TestResetErrors(t *testing.T) {
 	t.Log(lib.ResetErrors())
 }
+
+func TestError(t *testing.T) {
+	// libguac errors are thread local
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cli, err := lib.NewClient()
+	if err != nil {
+		t.Fatalf("new client error: %v", err)
+	}
+	defer cli.Close()
+
+	err = cli.LoadProtocolPlugin("occamy-nonexistent")
+	var e *lib.Error
+	if !errors.As(err, &e) || !errors.Is(err, lib.ErrNotFound) {
+		t.Fatalf("load nonexistent plugin should fail with not found, got: %v", err)
+	}
+	if e.Message == "" {
+		t.Fatalf("libguac error should carry its message, got: %v", err)
+	}
+	if s := lib.ProtocolStatus(fmt.Errorf("wrapped: %w", err)); s != protocol.StatusResourceNotFound {
+		t.Fatalf("status of wrapped error, want: %v, got: %v", protocol.StatusResourceNotFound, s)
+	}
+	if lib.ResetErrors() == nil || lib.ResetErrors() != nil {
+		t.Fatalf("reset should return the former error once")
+	}
+}
+
+func TestProtocolStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want protocol.Status
+	}{
+		{nil, protocol.StatusSuccess},
+		{lib.ErrUserJoin, protocol.StatusUpstreamError},
+		{errors.New("unknown"), protocol.StatusServerError},
+	}
+	for _, tt := range tests {
+		if got := lib.ProtocolStatus(tt.err); got != tt.want {
+			t.Errorf("status of %v, want: %v, got: %v", tt.err, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package lib
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// ErrExecutorClosed indicates a call to a closed executor
+var ErrExecutorClosed = errors.New("occamy-lib: executor is closed")
+
+// Executor runs functions one by one on a dedicated goroutine that is
+// locked to an OS thread. libguac keeps guac_error in thread-local
+// storage, hence a failed call and the read of its error must run on
+// the same executor.
+type Executor struct {
+	queue chan func()
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewExecutor starts a new executor.
+func NewExecutor() *Executor {
+	e := &Executor{
+		queue: make(chan func()),
+		done:  make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *Executor) run() {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	// the thread returns to the scheduler, without a stale error state
+	defer ResetErrors()
+
+	for {
+		select {
+		case f := <-e.queue:
+			f()
+		case <-e.done:
+			return
+		}
+	}
+}
+
+// Do runs the given function on the executor and waits for its result.
+// Do must not be called by the function itself.
+func (e *Executor) Do(f func() error) error {
+	errc := make(chan error, 1)
+	select {
+	case e.queue <- func() { errc <- f() }:
+	case <-e.done:
+		return ErrExecutorClosed
+	}
+	return <-errc
+}
+
+// Close stops the executor after the running function returns. Calls
+// of Do after Close fail with ErrExecutorClosed.
+func (e *Executor) Close() {
+	e.once.Do(func() { close(e.done) })
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package lib_test
+
+import (
+	"errors"
+	"sync"
+	"syscall"
+	"testing"
+
+	"changkun.de/x/occamy/internal/lib"
+)
+
+func TestExecutor(t *testing.T) {
+	e := lib.NewExecutor()
+
+	var cli *lib.Client
+	err := e.Do(func() (err error) {
+		cli, err = lib.NewClient()
+		return
+	})
+	if err != nil {
+		t.Fatalf("new client error: %v", err)
+	}
+
+	err = e.Do(func() error { return cli.LoadProtocolPlugin("occamy-nonexistent") })
+	if !errors.Is(err, lib.ErrNotFound) {
+		t.Fatalf("load nonexistent plugin should fail with not found, got: %v", err)
+	}
+	// the error state stays on the thread of the executor
+	err = e.Do(lib.ResetErrors)
+	if !errors.Is(err, lib.ErrNotFound) {
+		t.Fatalf("error state should be kept by the executor, got: %v", err)
+	}
+
+	e.Do(func() error { cli.Close(); return nil })
+	e.Close()
+	if err := e.Do(func() error { return nil }); err != lib.ErrExecutorClosed {
+		t.Fatalf("do after close should fail, got: %v", err)
+	}
+}
+
+func TestExecutor_Thread(t *testing.T) {
+	e := lib.NewExecutor()
+	defer e.Close()
+
+	// functions of all goroutines run on the same thread
+	tids := make(chan int, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(tids); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.Do(func() error { tids <- syscall.Gettid(); return nil })
+		}()
+	}
+	wg.Wait()
+	close(tids)
+	first := <-tids
+	for tid := range tids {
+		if tid != first {
+			t.Fatalf("executor ran on threads %d and %d", first, tid)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package lib
+
+/*
+#include "../../guacamole/src/libguac/guacamole/client.h"
+*/
+import "C"
+import "sync"
+
+// Event is a lifecycle event of a client
+type Event int
+
+// All lifecycle events, they correspond to guac_client_event.
+const (
+	EventJoin  Event = C.GUAC_CLIENT_EVENT_JOIN  // a user joined
+	EventLeave Event = C.GUAC_CLIENT_EVENT_LEAVE // a user left
+	EventStop  Event = C.GUAC_CLIENT_EVENT_STOP  // the client is stopping
+	EventFree  Event = C.GUAC_CLIENT_EVENT_FREE  // the client is freed
+)
+
+func (e Event) String() string {
+	switch e {
+	case EventJoin:
+		return "join"
+	case EventLeave:
+		return "leave"
+	case EventStop:
+		return "stop"
+	case EventFree:
+		return "free"
+	}
+	return "unknown"
+}
+
+// Hook is called on lifecycle events of a client, the user is nil
+// except for EventJoin and EventLeave. Hooks may be called from threads
+// of the protocol plugin, and must not block.
+type Hook func(c *Client, e Event, u *User)
+
+// clients maps guac_client to their Client for libguac callbacks
+var clients = struct {
+	sync.RWMutex
+	m map[*C.struct_guac_client]*Client
+}{m: make(map[*C.struct_guac_client]*Client)}
+
+func registerClient(c *Client) {
+	clients.Lock()
+	clients.m[c.guacClient] = c
+	clients.Unlock()
+}
+
+func unregisterClient(c *Client) {
+	clients.Lock()
+	delete(clients.m, c.guacClient)
+	clients.Unlock()
+}
+
+//export occamyClientEvent
+func occamyClientEvent(client *C.guac_client, event C.int, user *C.guac_user) {
+	clients.RLock()
+	c := clients.m[client]
+	clients.RUnlock()
+	if c != nil {
+		c.dispatch(Event(event), user)
+	}
+}
+
+// AddHook registers a hook which is called on all following lifecycle
+// events of the client.
+func (c *Client) AddHook(h Hook) {
+	c.mu.Lock()
+	c.hooks = append(c.hooks, h)
+	c.mu.Unlock()
+}
+
+// dispatch keeps the user list in sync with libguac and calls hooks.
+func (c *Client) dispatch(e Event, gu *C.struct_guac_user) {
+	var u *User
+	c.mu.Lock()
+	switch e {
+	case EventJoin:
+		u = c.known[gu]
+		if u != nil {
+			c.link(u)
+		}
+	case EventLeave:
+		u = c.known[gu]
+		if u != nil {
+			c.unlink(u)
+		}
+	case EventStop:
+		c.running = false
+	}
+	hooks := c.hooks
+	c.mu.Unlock()
+
+	if e == EventFree {
+		defer unregisterClient(c)
+	}
+	if u == nil && (e == EventJoin || e == EventLeave) {
+		return // the user is not created by NewUser
+	}
+	for _, h := range hooks {
+		h(c, e, u)
+	}
+}
+
+// link adds a joined user to the head of the user list.
+func (c *Client) link(u *User) {
+	u.prev, u.next = nil, c.users
+	if c.users != nil {
+		c.users.prev = u
+	}
+	c.users = u
+	c.connectedUsers++
+	if u.owner {
+		c.owner = u
+	}
+}
+
+// unlink removes a leaving user from the user list.
+func (c *Client) unlink(u *User) {
+	if u.prev != nil {
+		u.prev.next = u.next
+	} else if c.users == u {
+		c.users = u.next
+	} else {
+		return // not linked
+	}
+	if u.next != nil {
+		u.next.prev = u.prev
+	}
+	u.prev, u.next = nil, nil
+	c.connectedUsers--
+	if c.owner == u {
+		c.owner = nil
+	}
+}
+
+// Users returns all connected users, the most recently joined first.
+func (c *Client) Users() []*User {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	users := make([]*User, 0, c.connectedUsers)
+	for u := c.users; u != nil; u = u.next {
+		users = append(users, u)
+	}
+	return users
+}
+
+// Owner returns the connected owner of the client, or nil if the owner
+// is not connected.
+func (c *Client) Owner() *User {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.owner
+}
+
+// Running reports whether the client is still running.
+func (c *Client) Running() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.running
+}
@@ -0,0 +1,96 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package lib_test
+
+import (
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/internal/config"
+	"changkun.de/x/occamy/internal/lib"
+)
+
+func TestClient_Hooks(t *testing.T) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatalf("cannot create socketpair: %v", err)
+	}
+	sock, err := lib.NewSocket(fds[0])
+	if err != nil {
+		t.Fatalf("create socket error: %v", err)
+	}
+	cli, err := lib.NewClient()
+	if err != nil {
+		t.Fatalf("create client error: %v", err)
+	}
+
+	var (
+		mu     sync.Mutex
+		events []string
+		tids   []int // threads of the join and leave events
+	)
+	joined := make(chan struct{})
+	cli.AddHook(func(c *lib.Client, e lib.Event, u *lib.User) {
+		if c != cli {
+			t.Errorf("hook called with another client")
+		}
+		mu.Lock()
+		events = append(events, e.String())
+		if e == lib.EventJoin || e == lib.EventLeave {
+			tids = append(tids, syscall.Gettid())
+		}
+		mu.Unlock()
+		if e == lib.EventJoin {
+			if len(c.Users()) != 1 || c.Owner() != u || !u.Owner() {
+				t.Errorf("joined user is not in sync, users: %v", c.Users())
+			}
+			close(joined)
+		}
+		if e == lib.EventLeave && (len(c.Users()) != 0 || c.Owner() != nil) {
+			t.Errorf("left user is not in sync, users: %v", c.Users())
+		}
+	})
+
+	jwt := &config.JWT{Protocol: "vnc", Host: "0.0.0.0:5901"}
+	user, err := lib.NewUser(sock, cli, true, jwt, &config.UserInfo{})
+	if err != nil {
+		t.Fatalf("create user error: %v", err)
+	}
+	done := make(chan struct{})
+	e := lib.NewExecutor()
+	defer e.Close()
+	var tid int
+	e.Do(func() error { tid = syscall.Gettid(); return nil })
+	go user.HandleConnection(e, done)
+	select {
+	case <-joined:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("join hook was not called")
+	}
+
+	syscall.Close(fds[1]) // disconnect the user
+	<-done
+	user.Close()
+	sock.Close()
+	if !cli.Running() {
+		t.Fatalf("client should be running before close")
+	}
+	cli.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(events, " "); got != "join leave stop free" {
+		t.Fatalf("unexpected events: %s", got)
+	}
+	// the user joins and leaves libguac on the thread of the executor
+	for _, got := range tids {
+		if got != tid {
+			t.Fatalf("user handled on thread %d, want the executor thread %d", got, tid)
+		}
+	}
+}
@@ -12,6 +12,8 @@ package lib
 import "C"
 import (
 	"bufio"
+	"net"
+	"os"
 	"sync"
 	"syscall"
 
@@ -68,6 +70,18 @@ func (s *Socket) Close() {
 	})
 }
 
+// conn returns a connection of the file descriptor of the socket, which
+// supports deadlines. The connection is closed independently.
+func (s *Socket) conn() (net.Conn, error) {
+	fd, err := syscall.Dup(s.fd)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), "occamy-socket")
+	defer f.Close()
+	return net.FileConn(f)
+}
+
 // Read data from the socket, filling up to the specified number
 // of bytes in the given buffer.
 func (s *Socket) Read(buf []byte) (int, error) {
@@ -7,19 +7,23 @@ package lib
 /*
 #cgo LDFLAGS: -L/usr/local/lib -lguac
 #include <stdlib.h>
+#include "../../guacamole/src/libguac/guacamole/error.h"
 #include "../../guacamole/src/libguac/guacamole/parser.h"
 #include "../../guacamole/src/libguac/guacamole/user.h"
 #include "../../guacamole/src/libguac/guacamole/client.h"
 #include "../../guacamole/src/libguac/guacamole/protocol.h"
 #include "../../guacamole/src/libguac/guacamole/socket.h"
 
-const char *mimetypes[] = {"", NULL};
-void set_user_info(guac_user* user) {
-	user->info.optimal_width = 1024;
-	user->info.optimal_height = 768;
-	user->info.optimal_resolution = 96;
-	user->info.video_mimetypes = (const char**) mimetypes;
-	user->info.image_mimetypes = (const char**) mimetypes;
+void set_user_info(guac_user* user, int version, int width, int height, int dpi,
+	char* timezone, char** audio, char** video, char** image) {
+	user->info.protocol_version = (guac_protocol_version) version;
+	user->info.optimal_width = width;
+	user->info.optimal_height = height;
+	user->info.optimal_resolution = dpi;
+	user->info.timezone = (const char*) timezone;
+	user->info.audio_mimetypes = (const char**) audio;
+	user->info.video_mimetypes = (const char**) video;
+	user->info.image_mimetypes = (const char**) image;
 }
 int get_args_length(const char** args) {
 	int i = 0;
@@ -47,6 +51,24 @@ static int join_handler_bridge(guac_user* user, int argc, char** argv) {
 		retval = user->client->join_handler(user, argc, argv);
 	return retval;
 }
+static int client_running(guac_user* user) {
+	return user->client->state == GUAC_CLIENT_RUNNING && user->active;
+}
+static void user_abort_timeout(guac_user* user) {
+	guac_user_abort(user, GUAC_PROTOCOL_STATUS_CLIENT_TIMEOUT, "User is not responding.");
+}
+static int handle_instruction(guac_user* user, char* opcode, int argc, char** argv) {
+	// handlers are not guaranteed to set the error state
+	guac_error = GUAC_STATUS_SUCCESS;
+	guac_error_message = NULL;
+	if (guac_user_handle_instruction(user, opcode, argc, argv) < 0) {
+		guac_user_log(user, GUAC_LOG_WARNING, "User connection aborted");
+		guac_user_log(user, GUAC_LOG_DEBUG, "Failing instruction handler in user was \"%s\"", opcode);
+		guac_user_stop(user);
+		return -1;
+	}
+	return 0;
+}
 */
 import "C"
 import (
@@ -59,9 +81,18 @@ import (
 	"unsafe"
 
 	"changkun.de/x/occamy/internal/config"
+	"changkun.de/x/occamy/internal/protocol"
 	"changkun.de/x/occamy/internal/uuid"
 )
 
+// Default display information for users whose client does not report
+// its own screen size or resolution.
+const (
+	UserDefaultWidth      = 1024
+	UserDefaultHeight     = 768
+	UserDefaultResolution = 96
+)
+
 // UserMaxStreams is the character prefix which identifies a user ID.
 const UserMaxStreams = 64
 
@@ -84,6 +115,16 @@ type User struct {
 	sock       *Socket
 	prev, next *User // points to next connected user
 	data       interface{}
+
+	// C allocations referenced by guacUser.info, released on Close
+	cTimezone  *C.char
+	cMimetypes []cStrings
+}
+
+// cStrings is a NULL-terminated C string array
+type cStrings struct {
+	array  **C.char
+	length int
 }
 
 type connectInformation struct {
@@ -92,15 +133,18 @@ type connectInformation struct {
 	Username string
 	Password string
 
+	protocolVersion   protocol.Version
 	optimalWidth      int
 	optimalHeight     int
 	optimalResolution int
+	timezone          string
+	audioMimetypes    []string
 	videoMimetypes    []string
 	imageMimetypes    []string
 }
 
 // NewUser creates a user and associate the user with any specific client
-func NewUser(s *Socket, c *Client, owner bool, jwt *config.JWT) (*User, error) {
+func NewUser(s *Socket, c *Client, owner bool, jwt *config.JWT, info *config.UserInfo) (*User, error) {
 	id := uuid.NewID("@")
 	uid := C.CString(id)
 
@@ -123,7 +167,7 @@ func NewUser(s *Socket, c *Client, owner bool, jwt *config.JWT) (*User, error) {
 		return nil, err
 	}
 
-	return &User{
+	u := &User{
 		guacUser:   user,
 		guacClient: c.guacClient,
 
@@ -135,18 +179,51 @@ func NewUser(s *Socket, c *Client, owner bool, jwt *config.JWT) (*User, error) {
 			Port:     port,
 			Username: jwt.Username,
 			Password: jwt.Password,
+
+			protocolVersion:   protocol.NegotiateVersion(info.Version),
+			optimalWidth:      positiveOr(info.Width, UserDefaultWidth),
+			optimalHeight:     positiveOr(info.Height, UserDefaultHeight),
+			optimalResolution: positiveOr(info.DPI, UserDefaultResolution),
+			timezone:          info.Timezone,
+			audioMimetypes:    info.Audio,
+			videoMimetypes:    info.Video,
+			imageMimetypes:    info.Image,
 		},
 		client: c,
-	}, nil
+		sock:   s,
+	}
+	c.mu.Lock()
+	c.known[user] = u
+	c.mu.Unlock()
+	return u, nil
+}
+
+func positiveOr(v, fallback int) int {
+	if v > 0 {
+		return v
+	}
+	return fallback
 }
 
 // Close frees the user and detach the association to the attached client
 func (u *User) Close() {
 	u.once.Do(func() {
+		u.client.mu.Lock()
+		delete(u.client.known, u.guacUser)
+		u.client.mu.Unlock()
 		C.guac_user_free(u.guacUser)
+		for _, m := range u.cMimetypes {
+			C.freeCharArray(m.array, C.int(m.length))
+		}
+		C.free(unsafe.Pointer(u.cTimezone))
 	})
 }
 
+// Owner reports whether the user is the owner of its client.
+func (u *User) Owner() bool {
+	return u.owner
+}
+
 // isActive checks if a user is still active
 func (u *User) isActive() bool {
 	if u.guacUser.active != 0 {
@@ -155,11 +232,17 @@ func (u *User) isActive() bool {
 	return false
 }
 
-const usecTimeout time.Duration = 15 * time.Millisecond
+// inputTimeout is the time within which a user must send an instruction,
+// as the timeout of guacd.
+const inputTimeout = 15 * time.Second
 
-func (u *User) Prepare() error {
+// Prepare joins the user to its client with the connection arguments of
+// the JWT. Other arguments of the client, e.g. enable-sftp of ssh, are
+// taken from the given arguments of the operator, which are also used
+// for credentials the JWT leaves empty.
+func (u *User) Prepare(base map[string]string) error {
 	// general args
-	C.set_user_info(u.guacUser)
+	u.setInfo()
 
 	// client args
 	length := int(C.get_args_length(u.guacClient.args))
@@ -169,18 +252,21 @@ func (u *User) Prepare() error {
 		args[i] = C.GoString(s)
 	}
 	for i := range args {
+		v := ""
 		switch args[i] {
 		case "hostname":
-			args[i] = u.info.Host
+			v = u.info.Host
 		case "port":
-			args[i] = u.info.Port
+			v = u.info.Port
 		case "username":
-			args[i] = u.info.Username
+			v = u.info.Username
 		case "password":
-			args[i] = u.info.Password
-		default:
-			args[i] = ""
+			v = u.info.Password
 		}
+		if v == "" {
+			v = base[args[i]]
+		}
+		args[i] = v
 	}
 
 	// create args for C
@@ -200,24 +286,114 @@ func (u *User) Prepare() error {
 	if int(ret) != 0 {
 		log.Printf("User %s could NOT join connection %s",
 			C.GoString(u.guacUser.user_id), C.GoString(u.guacClient.connection_id))
-		return errors.New("occamy-lib: user cannot join")
+		return ErrUserJoin
 	}
 
 	return nil
 }
 
+// setInfo copies the display and media information reported by the
+// browser into guac_user.info. The allocated C strings are owned by
+// the user and released on Close.
+func (u *User) setInfo() {
+	var timezone *C.char
+	if u.info.timezone != "" {
+		timezone = C.CString(u.info.timezone)
+	}
+	u.cTimezone = timezone
+	audio := u.newCStrings(u.info.audioMimetypes)
+	video := u.newCStrings(u.info.videoMimetypes)
+	image := u.newCStrings(u.info.imageMimetypes)
+
+	C.set_user_info(u.guacUser,
+		C.int(u.info.protocolVersion),
+		C.int(u.info.optimalWidth),
+		C.int(u.info.optimalHeight),
+		C.int(u.info.optimalResolution),
+		timezone, audio, video, image)
+}
+
+// newCStrings allocates a NULL-terminated C string array from given
+// strings. The array is tracked by the user and released on Close.
+func (u *User) newCStrings(strs []string) **C.char {
+	size := len(strs) + 1
+	array := C.makeCharArray(C.int(size))
+	for i, s := range strs {
+		C.setArrayString(array, C.CString(s), C.int(i))
+	}
+	u.cMimetypes = append(u.cMimetypes, cStrings{array, size})
+	return array
+}
+
 // HandleConnection handles all I/O for the portion of a user's Occamy
 // connection without the handshake process. This function blocks until
 // the connection/user is aborted or the user disconnects.
-func (u *User) HandleConnection(done chan struct{}) {
+//
+// The instructions of the user are read by the calling goroutine, and
+// handled by libguac on the given executor of the client, hence no
+// libguac call runs outside the executor while the read blocks.
+func (u *User) HandleConnection(e *Executor, done chan struct{}) {
+	defer close(done)
+	defer e.Do(func() error {
+		C.guac_client_remove_user(u.guacClient, u.guacUser)
+		log.Printf("User %s disconnected (%d users remain)", u.ID, int(u.guacClient.connected_users))
+		C.guac_protocol_send_disconnect(u.guacUser.socket)
+		C.guac_socket_flush(u.guacUser.socket)
+		return nil
+	})
 	// this should be called only if handshake is success.
-	C.guac_client_add_user(u.guacUser)
-	C.guac_user_input_thread(u.guacUser, C.int(int(usecTimeout))) // block here
-	C.guac_client_remove_user(u.guacClient, u.guacUser)
-	log.Printf("User %s disconnected (%d users remain)", u.ID, int(u.guacClient.connected_users))
-	C.guac_protocol_send_disconnect(u.guacUser.socket)
-	C.guac_socket_flush(u.guacUser.socket)
-	close(done)
+	e.Do(func() error { C.guac_client_add_user(u.guacUser); return nil })
+
+	conn, err := u.sock.conn()
+	if err != nil {
+		log.Printf("User %s cannot read its connection: %v", u.ID, err)
+		e.Do(func() error { C.guac_user_stop(u.guacUser); return nil })
+		return
+	}
+	defer conn.Close()
+
+	p := protocol.NewParser()
+	for {
+		running := false
+		e.Do(func() error { running = C.client_running(u.guacUser) != 0; return nil })
+		if !running {
+			return
+		}
+
+		conn.SetReadDeadline(time.Now().Add(inputTimeout))
+		elements, err := p.Next(conn)
+		if err != nil {
+			var nerr net.Error
+			e.Do(func() error {
+				if errors.As(err, &nerr) && nerr.Timeout() {
+					C.user_abort_timeout(u.guacUser)
+				} else {
+					C.guac_user_stop(u.guacUser)
+				}
+				return nil
+			})
+			return
+		}
+		handled := false
+		e.Do(func() error { handled = u.handle(elements); return nil })
+		if !handled {
+			return
+		}
+	}
+}
+
+// handle calls the instruction handler of libguac of the given
+// instruction elements, the user is stopped if the handler fails.
+func (u *User) handle(elements [][]byte) bool {
+	opcode := C.CString(string(elements[0]))
+	defer C.free(unsafe.Pointer(opcode))
+	argc := len(elements) - 1
+	argv := C.makeCharArray(C.int(argc + 1))
+	defer C.freeCharArray(argv, C.int(argc))
+	for i, arg := range elements[1:] {
+		C.setArrayString(argv, C.CString(string(arg)), C.int(i))
+	}
+	return C.handle_instruction(u.guacUser, opcode, C.int(argc), argv) == 0
 }
 
 // Stop signals the given user that it must disconnect, or advises
@@ -8,6 +8,7 @@ import (
 	"syscall"
 	"testing"
 
+	"changkun.de/x/occamy/internal/config"
 	"changkun.de/x/occamy/internal/lib"
 )
 
@@ -34,7 +35,9 @@ func TestNewUser(t *testing.T) {
 		t.Error("create client in NewUser error: ", err)
 		t.FailNow()
 	}
-	user, err := lib.NewUser(sock1, cli, true)
+	jwt := &config.JWT{Protocol: "vnc", Host: "0.0.0.0:5901"}
+	info := &config.UserInfo{Width: 1920, Height: 1080, DPI: 192, Timezone: "Europe/Berlin"}
+	user, err := lib.NewUser(sock1, cli, true, jwt, info)
 	if err != nil {
 		t.Error("NewUser error: ", err)
 		t.FailNow()
@@ -43,11 +46,11 @@ func TestNewUser(t *testing.T) {
 	t.Run("handle-conn", func(t *testing.T) {
 		done := make(chan bool, 2)
 		go func() {
-			err := user.HandleConnection()
-			if err != nil {
-				t.Error("user handle connection error: ", err)
-				t.FailNow()
-			}
+			finished := make(chan struct{})
+			e := lib.NewExecutor()
+			defer e.Close()
+			user.HandleConnection(e, finished)
+			<-finished
 			done <- true
 		}()
 		go func() {
@@ -55,7 +58,6 @@ func TestNewUser(t *testing.T) {
 			_, err := sock2.Read(buf)
 			if err != nil {
 				t.Error("read user handle connection message error: ", err)
-				t.FailNow()
 			}
 			done <- true
 		}()
@@ -106,3 +106,54 @@ func BenchmarkInstructionParser(b *testing.B) {
 		})
 	}
 }
+
+// BenchmarkParser compares the legacy parser with the incremental parser:
+//
+//  go test -run=NONE -bench=BenchmarkParser -benchmem
+func BenchmarkParser(b *testing.B) {
+	for idx := range instructions {
+		raw := instructions[idx]
+		b.Run(fmt.Sprintf("guac-%d", len(raw)), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(raw)))
+			for i := 0; i < b.N; i++ {
+				ParseInstructionGuac(raw)
+			}
+		})
+		b.Run(fmt.Sprintf("append-%d", len(raw)), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(raw)))
+			p := protocol.NewParser()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				p.Append(raw)
+			}
+		})
+		b.Run(fmt.Sprintf("next-%d", len(raw)), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(raw)))
+			p := protocol.NewParser()
+			r := &repeatReader{data: raw}
+			p.Next(r)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				p.Next(r)
+			}
+		})
+	}
+}
+
+// repeatReader repeats the given data forever
+type repeatReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *repeatReader) Read(buf []byte) (n int, err error) {
+	for n < len(buf) {
+		c := copy(buf[n:], r.data[r.pos:])
+		n += c
+		r.pos = (r.pos + c) % len(r.data)
+	}
+	return n, nil
+}
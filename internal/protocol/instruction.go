@@ -9,7 +9,7 @@ import (
 	"bytes"
 	"errors"
 	"strconv"
-	"strings"
+	"sync"
 	"unicode/utf8"
 )
 
@@ -37,59 +37,19 @@ func NewInstruction(elements []string) *Instruction {
 
 // ParseInstruction parses an instruction: 1.a,2.bc,3.def,10.abcdefghij;
 func ParseInstruction(raw []byte) (ins *Instruction, err error) {
-	var (
-		cursor   int
-		elements []string
-	)
-
-	bytes := len(raw)
-	for cursor < bytes {
-
-		// 1. parse digit
-		lengthEnd := -1
-		for i := cursor; i < bytes; i++ {
-			if raw[i]^'.' == 0 {
-				lengthEnd = i
-				break
-			}
-		}
-		if lengthEnd == -1 { // cannot find '.'
-			return nil, ErrInstructionMissDot
-		}
-		length, err := strconv.Atoi(string(raw[cursor:lengthEnd]))
-		if err != nil {
-			return nil, ErrInstructionBadDigit
-		}
-
-		// 2. parse rune
-		cursor = lengthEnd + 1
-		element := new(strings.Builder)
-		for i := 1; i <= length; i++ {
-			r, n := utf8.DecodeRune(raw[cursor:])
-			if r == utf8.RuneError {
-				return nil, ErrInstructionBadRune
-			}
-			cursor += n
-			element.WriteRune(r)
-		}
-		elements = append(elements, element.String())
-
-		// 3. done
-		if cursor == bytes-1 {
-			break
-		}
-
-		// 4. parse next
-		if raw[cursor]^',' != 0 {
-			return nil, ErrInstructionMissComma
-		}
-
-		cursor++
-	}
+	p := parserPool.Get().(*Parser)
+	defer parserPool.Put(p)
 
-	return NewInstruction(elements), nil
+	ins = &Instruction{}
+	err = p.Parse(raw, ins)
+	if err != nil {
+		return nil, err
+	}
+	return ins, nil
 }
 
+var parserPool = sync.Pool{New: func() interface{} { return NewParser() }}
+
 func (i Instruction) String() string {
 	buffer := new(bytes.Buffer)
 	buffer.WriteString(strconv.FormatInt(int64(utf8.RuneCountInString(i.elements[0])), 10))
@@ -132,8 +92,9 @@ func (i Instruction) Args() []string {
 // InstructionIO implements io.Reader and io.Writer
 type InstructionIO struct {
 	conn   *IO
-	input  *bufio.Reader
+	input  *Parser
 	output *bufio.Writer
+	once   sync.Once
 }
 
 // NewInstructionIO ...
@@ -141,28 +102,39 @@ func NewInstructionIO(fd int) *InstructionIO {
 	conn := NewIO(fd)
 	return &InstructionIO{
 		conn:   conn,
-		input:  bufio.NewReaderSize(conn, MaxInstructionLength),
+		input:  NewParser(),
 		output: bufio.NewWriter(conn),
 	}
 }
 
-// Close closes the InstructionIO
-func (io *InstructionIO) Close() error {
-	return io.conn.Close()
+// Close closes the InstructionIO. Only the first call closes the file
+// descriptor, which may be reused by the time of another call.
+func (io *InstructionIO) Close() (err error) {
+	io.once.Do(func() { err = io.conn.Close() })
+	return
 }
 
-// ReadRaw reads raw data from io input
+// ReadRaw reads the raw data of the next instruction from io input.
+// The returned buffer is only valid until the next read.
 func (io *InstructionIO) ReadRaw() ([]byte, error) {
-	return io.input.ReadBytes(byte(';'))
+	_, err := io.input.Next(io.conn)
+	if err != nil {
+		return nil, err
+	}
+	return io.input.Raw(), nil
 }
 
 // Read reads and parses the instruction from io input
 func (io *InstructionIO) Read() (*Instruction, error) {
-	raw, err := io.ReadRaw()
+	elements, err := io.input.Next(io.conn)
 	if err != nil {
 		return nil, err
 	}
-	return ParseInstruction(raw)
+	ins := &Instruction{elements: make([]string, len(elements))}
+	for i := range elements {
+		ins.elements[i] = string(elements[i])
+	}
+	return ins, nil
 }
 
 // WriteRaw writes raw buffer into io output
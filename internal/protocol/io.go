@@ -4,7 +4,10 @@
 
 package protocol
 
-import "syscall"
+import (
+	"io"
+	"syscall"
+)
 
 // IO is a fd wrap that implements io.Reader and io.Writer
 type IO struct {
@@ -21,6 +24,10 @@ func (i IO) Read(buf []byte) (n int, err error) {
 	n, err = syscall.Read(i.fd, buf)
 	if err != nil {
 		n = 0
+		return
+	}
+	if n == 0 && len(buf) > 0 {
+		err = io.EOF
 	}
 	return
 }
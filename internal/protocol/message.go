@@ -0,0 +1,707 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Errors while decoding an instruction into a message
+var (
+	ErrMessageUnknown = errors.New("unknown instruction opcode")
+	ErrMessageArgs    = errors.New("invalid instruction arguments")
+)
+
+// Message is a typed guacamole instruction
+type Message interface {
+	// Opcode returns the opcode of the message
+	Opcode() string
+	// Encode encodes the message into an instruction
+	Encode() *Instruction
+	// Decode decodes the arguments of the given instruction into the
+	// message, the opcode of the instruction is not checked.
+	Decode(ins *Instruction) error
+}
+
+// Decode decodes an instruction into its typed message.
+func Decode(ins *Instruction) (Message, error) {
+	var m Message
+	switch ins.Opcode() {
+	case "mouse":
+		m = &Mouse{}
+	case "key":
+		m = &Key{}
+	case "size":
+		if len(ins.Args()) == 3 {
+			m = &LayerSize{}
+		} else {
+			m = &Size{}
+		}
+	case "clipboard":
+		m = &Clipboard{}
+	case "file":
+		m = &File{}
+	case "pipe":
+		m = &Pipe{}
+	case "blob":
+		m = &Blob{}
+	case "end":
+		m = &End{}
+	case "ack":
+		m = &Ack{}
+	case "img":
+		m = &Img{}
+	case "png":
+		m = &PNG{}
+	case "rect":
+		m = &Rect{}
+	case "cfill":
+		m = &Cfill{}
+	case "copy":
+		m = &Copy{}
+	case "cursor":
+		m = &Cursor{}
+	case "sync":
+		m = &Sync{}
+	case "disconnect":
+		m = &Disconnect{}
+	case "error":
+		m = &Error{}
+	case "args":
+		m = &Args{}
+	case "connect":
+		m = &Connect{}
+	case "ready":
+		m = &Ready{}
+	case "required":
+		m = &Required{}
+	case "argv":
+		m = &Argv{}
+	case "msg":
+		m = &Msg{}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrMessageUnknown, ins.Opcode())
+	}
+	err := m.Decode(ins)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CompositeMode is the channel mask of draw instructions, which
+// describes how source and destination are composed.
+type CompositeMode int
+
+// All composite modes supported by guacamole
+const (
+	CompositeRout  CompositeMode = 0x2 // Clears destination where source opaque
+	CompositeAtop  CompositeMode = 0x6 // Fill where destination opaque only
+	CompositeXor   CompositeMode = 0xA // XOR
+	CompositeRover CompositeMode = 0xB // Fill where destination transparent only
+	CompositeOver  CompositeMode = 0xE // Draw normally
+	CompositePlus  CompositeMode = 0xF // Add
+	CompositeRin   CompositeMode = 0x1
+	CompositeIn    CompositeMode = 0x4
+	CompositeOut   CompositeMode = 0x8
+	CompositeRatop CompositeMode = 0x9
+	CompositeSrc   CompositeMode = 0xC
+)
+
+// Mouse button masks of the mouse instruction
+const (
+	MouseLeft       = 0x01
+	MouseMiddle     = 0x02
+	MouseRight      = 0x04
+	MouseScrollUp   = 0x08
+	MouseScrollDown = 0x10
+)
+
+// Mouse is the mouse instruction, it reports the position and the
+// pressed buttons of the mouse as a combination of Mouse button masks.
+type Mouse struct {
+	X, Y int
+	Mask int
+}
+
+// Key is the key instruction, it reports a pressed or released X11 keysym.
+type Key struct {
+	Keysym  int
+	Pressed bool
+}
+
+// Size is the size instruction sent by the client, it requests the
+// optimal display size.
+type Size struct {
+	Width, Height int
+}
+
+// LayerSize is the size instruction sent by the server, it resizes
+// the given layer.
+type LayerSize struct {
+	Layer         int
+	Width, Height int
+}
+
+// Clipboard is the clipboard instruction, it begins a stream of new
+// clipboard contents.
+type Clipboard struct {
+	Stream   int
+	Mimetype string
+}
+
+// File is the file instruction, it begins a stream of a named file.
+type File struct {
+	Stream   int
+	Mimetype string
+	Filename string
+}
+
+// Pipe is the pipe instruction, it begins a stream of a named pipe.
+type Pipe struct {
+	Stream   int
+	Mimetype string
+	Name     string
+}
+
+// Blob is the blob instruction, it transfers a chunk of data over
+// the given stream.
+type Blob struct {
+	Stream int
+	Data   []byte
+}
+
+// End is the end instruction, it terminates the given stream.
+type End struct {
+	Stream int
+}
+
+// Ack is the ack instruction, it acknowledges data received over
+// the given stream.
+type Ack struct {
+	Stream  int
+	Message string
+	Status  Status
+}
+
+// Img is the img instruction, it begins a stream of image data which
+// is drawn to the given layer once the stream ends.
+type Img struct {
+	Stream   int
+	Mode     CompositeMode
+	Layer    int
+	Mimetype string
+	X, Y     int
+}
+
+// PNG is the legacy png instruction, it draws the given PNG image to
+// the given layer.
+type PNG struct {
+	Mode  CompositeMode
+	Layer int
+	X, Y  int
+	Data  []byte
+}
+
+// Rect is the rect instruction, it adds a rectangle to the current
+// path of the given layer.
+type Rect struct {
+	Layer         int
+	X, Y          int
+	Width, Height int
+}
+
+// Cfill is the cfill instruction, it fills the current path of the
+// given layer with a color.
+type Cfill struct {
+	Mode       CompositeMode
+	Layer      int
+	R, G, B, A int
+}
+
+// Copy is the copy instruction, it copies a rectangle of a source
+// layer to a destination layer.
+type Copy struct {
+	SrcLayer            int
+	SrcX, SrcY          int
+	SrcWidth, SrcHeight int
+	Mode                CompositeMode
+	DstLayer            int
+	DstX, DstY          int
+}
+
+// Cursor is the cursor instruction, it sets the client cursor to a
+// rectangle of a source layer with the given hotspot.
+type Cursor struct {
+	X, Y                int
+	SrcLayer            int
+	SrcX, SrcY          int
+	SrcWidth, SrcHeight int
+}
+
+// Sync is the sync instruction, it marks the end of a frame with the
+// given timestamp in milliseconds.
+type Sync struct {
+	Timestamp int64
+}
+
+// Disconnect is the disconnect instruction, it ends the connection.
+type Disconnect struct{}
+
+// Error is the error instruction, it notifies an error and closes
+// the connection.
+type Error struct {
+	Message string
+	Status  Status
+}
+
+// Args is the args instruction, it lists the connection parameters
+// accepted by the protocol plugin.
+type Args struct {
+	Names []string
+}
+
+// Connect is the connect instruction, it provides the values of the
+// connection parameters listed by args.
+type Connect struct {
+	Values []string
+}
+
+// Ready is the ready instruction, it reports the connection ID once
+// the connection is ready.
+type Ready struct {
+	ConnectionID string
+}
+
+// Required is the required instruction, it requests the values of the
+// named parameters, e.g. credentials, which are missing.
+type Required struct {
+	Names []string
+}
+
+// Argv is the argv instruction, it begins a stream of a new value for
+// the named connection parameter.
+type Argv struct {
+	Stream   int
+	Mimetype string
+	Name     string
+}
+
+// Msg is the msg instruction, it notifies a general message with the
+// given code and arguments.
+type Msg struct {
+	Code int
+	Args []string
+}
+
+// Opcode implements Message
+func (*Mouse) Opcode() string      { return "mouse" }
+func (*Key) Opcode() string        { return "key" }
+func (*Size) Opcode() string       { return "size" }
+func (*LayerSize) Opcode() string  { return "size" }
+func (*Clipboard) Opcode() string  { return "clipboard" }
+func (*File) Opcode() string       { return "file" }
+func (*Pipe) Opcode() string       { return "pipe" }
+func (*Blob) Opcode() string       { return "blob" }
+func (*End) Opcode() string        { return "end" }
+func (*Ack) Opcode() string        { return "ack" }
+func (*Img) Opcode() string        { return "img" }
+func (*PNG) Opcode() string        { return "png" }
+func (*Rect) Opcode() string       { return "rect" }
+func (*Cfill) Opcode() string      { return "cfill" }
+func (*Copy) Opcode() string       { return "copy" }
+func (*Cursor) Opcode() string     { return "cursor" }
+func (*Sync) Opcode() string       { return "sync" }
+func (*Disconnect) Opcode() string { return "disconnect" }
+func (*Error) Opcode() string      { return "error" }
+func (*Args) Opcode() string       { return "args" }
+func (*Connect) Opcode() string    { return "connect" }
+func (*Ready) Opcode() string      { return "ready" }
+func (*Required) Opcode() string   { return "required" }
+func (*Argv) Opcode() string       { return "argv" }
+func (*Msg) Opcode() string        { return "msg" }
+
+// Encode implements Message
+func (m *Mouse) Encode() *Instruction {
+	return encode(m, itoa(m.X), itoa(m.Y), itoa(m.Mask))
+}
+
+// Encode implements Message
+func (m *Key) Encode() *Instruction {
+	pressed := "0"
+	if m.Pressed {
+		pressed = "1"
+	}
+	return encode(m, itoa(m.Keysym), pressed)
+}
+
+// Encode implements Message
+func (m *Size) Encode() *Instruction {
+	return encode(m, itoa(m.Width), itoa(m.Height))
+}
+
+// Encode implements Message
+func (m *LayerSize) Encode() *Instruction {
+	return encode(m, itoa(m.Layer), itoa(m.Width), itoa(m.Height))
+}
+
+// Encode implements Message
+func (m *Clipboard) Encode() *Instruction {
+	return encode(m, itoa(m.Stream), m.Mimetype)
+}
+
+// Encode implements Message
+func (m *File) Encode() *Instruction {
+	return encode(m, itoa(m.Stream), m.Mimetype, m.Filename)
+}
+
+// Encode implements Message
+func (m *Pipe) Encode() *Instruction {
+	return encode(m, itoa(m.Stream), m.Mimetype, m.Name)
+}
+
+// Encode implements Message
+func (m *Blob) Encode() *Instruction {
+	return encode(m, itoa(m.Stream), base64.StdEncoding.EncodeToString(m.Data))
+}
+
+// Encode implements Message
+func (m *End) Encode() *Instruction {
+	return encode(m, itoa(m.Stream))
+}
+
+// Encode implements Message
+func (m *Ack) Encode() *Instruction {
+	return encode(m, itoa(m.Stream), m.Message, itoa(int(m.Status)))
+}
+
+// Encode implements Message
+func (m *Img) Encode() *Instruction {
+	return encode(m, itoa(m.Stream), itoa(int(m.Mode)), itoa(m.Layer),
+		m.Mimetype, itoa(m.X), itoa(m.Y))
+}
+
+// Encode implements Message
+func (m *PNG) Encode() *Instruction {
+	return encode(m, itoa(int(m.Mode)), itoa(m.Layer), itoa(m.X), itoa(m.Y),
+		base64.StdEncoding.EncodeToString(m.Data))
+}
+
+// Encode implements Message
+func (m *Rect) Encode() *Instruction {
+	return encode(m, itoa(m.Layer), itoa(m.X), itoa(m.Y),
+		itoa(m.Width), itoa(m.Height))
+}
+
+// Encode implements Message
+func (m *Cfill) Encode() *Instruction {
+	return encode(m, itoa(int(m.Mode)), itoa(m.Layer),
+		itoa(m.R), itoa(m.G), itoa(m.B), itoa(m.A))
+}
+
+// Encode implements Message
+func (m *Copy) Encode() *Instruction {
+	return encode(m, itoa(m.SrcLayer), itoa(m.SrcX), itoa(m.SrcY),
+		itoa(m.SrcWidth), itoa(m.SrcHeight), itoa(int(m.Mode)),
+		itoa(m.DstLayer), itoa(m.DstX), itoa(m.DstY))
+}
+
+// Encode implements Message
+func (m *Cursor) Encode() *Instruction {
+	return encode(m, itoa(m.X), itoa(m.Y), itoa(m.SrcLayer),
+		itoa(m.SrcX), itoa(m.SrcY), itoa(m.SrcWidth), itoa(m.SrcHeight))
+}
+
+// Encode implements Message
+func (m *Sync) Encode() *Instruction {
+	return encode(m, strconv.FormatInt(m.Timestamp, 10))
+}
+
+// Encode implements Message
+func (m *Disconnect) Encode() *Instruction {
+	return encode(m)
+}
+
+// Encode implements Message
+func (m *Error) Encode() *Instruction {
+	return encode(m, m.Message, itoa(int(m.Status)))
+}
+
+// Encode implements Message
+func (m *Args) Encode() *Instruction {
+	return encode(m, m.Names...)
+}
+
+// Encode implements Message
+func (m *Connect) Encode() *Instruction {
+	return encode(m, m.Values...)
+}
+
+// Encode implements Message
+func (m *Ready) Encode() *Instruction {
+	return encode(m, m.ConnectionID)
+}
+
+// Encode implements Message
+func (m *Required) Encode() *Instruction {
+	return encode(m, m.Names...)
+}
+
+// Encode implements Message
+func (m *Argv) Encode() *Instruction {
+	return encode(m, itoa(m.Stream), m.Mimetype, m.Name)
+}
+
+// Encode implements Message
+func (m *Msg) Encode() *Instruction {
+	return encode(m, append([]string{itoa(m.Code)}, m.Args...)...)
+}
+
+// Decode implements Message
+func (m *Mouse) Decode(ins *Instruction) error {
+	a := newArgs(ins, 3)
+	m.X, m.Y, m.Mask = a.int(), a.int(), a.int()
+	return a.err
+}
+
+// Decode implements Message
+func (m *Key) Decode(ins *Instruction) error {
+	a := newArgs(ins, 2)
+	m.Keysym, m.Pressed = a.int(), a.int() != 0
+	return a.err
+}
+
+// Decode implements Message
+func (m *Size) Decode(ins *Instruction) error {
+	a := newArgs(ins, 2)
+	m.Width, m.Height = a.int(), a.int()
+	return a.err
+}
+
+// Decode implements Message
+func (m *LayerSize) Decode(ins *Instruction) error {
+	a := newArgs(ins, 3)
+	m.Layer, m.Width, m.Height = a.int(), a.int(), a.int()
+	return a.err
+}
+
+// Decode implements Message
+func (m *Clipboard) Decode(ins *Instruction) error {
+	a := newArgs(ins, 2)
+	m.Stream, m.Mimetype = a.int(), a.string()
+	return a.err
+}
+
+// Decode implements Message
+func (m *File) Decode(ins *Instruction) error {
+	a := newArgs(ins, 3)
+	m.Stream, m.Mimetype, m.Filename = a.int(), a.string(), a.string()
+	return a.err
+}
+
+// Decode implements Message
+func (m *Pipe) Decode(ins *Instruction) error {
+	a := newArgs(ins, 3)
+	m.Stream, m.Mimetype, m.Name = a.int(), a.string(), a.string()
+	return a.err
+}
+
+// Decode implements Message
+func (m *Blob) Decode(ins *Instruction) error {
+	a := newArgs(ins, 2)
+	m.Stream, m.Data = a.int(), a.base64()
+	return a.err
+}
+
+// Decode implements Message
+func (m *End) Decode(ins *Instruction) error {
+	a := newArgs(ins, 1)
+	m.Stream = a.int()
+	return a.err
+}
+
+// Decode implements Message
+func (m *Ack) Decode(ins *Instruction) error {
+	a := newArgs(ins, 3)
+	m.Stream, m.Message, m.Status = a.int(), a.string(), Status(a.int())
+	return a.err
+}
+
+// Decode implements Message
+func (m *Img) Decode(ins *Instruction) error {
+	a := newArgs(ins, 6)
+	m.Stream, m.Mode, m.Layer = a.int(), CompositeMode(a.int()), a.int()
+	m.Mimetype, m.X, m.Y = a.string(), a.int(), a.int()
+	return a.err
+}
+
+// Decode implements Message
+func (m *PNG) Decode(ins *Instruction) error {
+	a := newArgs(ins, 5)
+	m.Mode, m.Layer = CompositeMode(a.int()), a.int()
+	m.X, m.Y, m.Data = a.int(), a.int(), a.base64()
+	return a.err
+}
+
+// Decode implements Message
+func (m *Rect) Decode(ins *Instruction) error {
+	a := newArgs(ins, 5)
+	m.Layer, m.X, m.Y = a.int(), a.int(), a.int()
+	m.Width, m.Height = a.int(), a.int()
+	return a.err
+}
+
+// Decode implements Message
+func (m *Cfill) Decode(ins *Instruction) error {
+	a := newArgs(ins, 6)
+	m.Mode, m.Layer = CompositeMode(a.int()), a.int()
+	m.R, m.G, m.B, m.A = a.int(), a.int(), a.int(), a.int()
+	return a.err
+}
+
+// Decode implements Message
+func (m *Copy) Decode(ins *Instruction) error {
+	a := newArgs(ins, 9)
+	m.SrcLayer, m.SrcX, m.SrcY = a.int(), a.int(), a.int()
+	m.SrcWidth, m.SrcHeight = a.int(), a.int()
+	m.Mode, m.DstLayer = CompositeMode(a.int()), a.int()
+	m.DstX, m.DstY = a.int(), a.int()
+	return a.err
+}
+
+// Decode implements Message
+func (m *Cursor) Decode(ins *Instruction) error {
+	a := newArgs(ins, 7)
+	m.X, m.Y, m.SrcLayer = a.int(), a.int(), a.int()
+	m.SrcX, m.SrcY = a.int(), a.int()
+	m.SrcWidth, m.SrcHeight = a.int(), a.int()
+	return a.err
+}
+
+// Decode implements Message
+func (m *Sync) Decode(ins *Instruction) error {
+	a := newArgs(ins, 1)
+	m.Timestamp = a.int64()
+	return a.err
+}
+
+// Decode implements Message
+func (m *Disconnect) Decode(ins *Instruction) error {
+	return newArgs(ins, 0).err
+}
+
+// Decode implements Message
+func (m *Error) Decode(ins *Instruction) error {
+	a := newArgs(ins, 2)
+	m.Message, m.Status = a.string(), Status(a.int())
+	return a.err
+}
+
+// Decode implements Message
+func (m *Args) Decode(ins *Instruction) error {
+	m.Names = append([]string(nil), ins.Args()...)
+	return nil
+}
+
+// Decode implements Message
+func (m *Connect) Decode(ins *Instruction) error {
+	m.Values = append([]string(nil), ins.Args()...)
+	return nil
+}
+
+// Decode implements Message
+func (m *Ready) Decode(ins *Instruction) error {
+	a := newArgs(ins, 1)
+	m.ConnectionID = a.string()
+	return a.err
+}
+
+// Decode implements Message
+func (m *Required) Decode(ins *Instruction) error {
+	m.Names = append([]string(nil), ins.Args()...)
+	return nil
+}
+
+// Decode implements Message
+func (m *Argv) Decode(ins *Instruction) error {
+	a := newArgs(ins, 3)
+	m.Stream, m.Mimetype, m.Name = a.int(), a.string(), a.string()
+	return a.err
+}
+
+// Decode implements Message
+func (m *Msg) Decode(ins *Instruction) error {
+	if len(ins.Args()) < 1 {
+		return fmt.Errorf("%w: msg requires at least 1 argument", ErrMessageArgs)
+	}
+	a := newArgs(ins, len(ins.Args()))
+	m.Code = a.int()
+	m.Args = append([]string(nil), ins.Args()[1:]...)
+	return a.err
+}
+
+func encode(m Message, args ...string) *Instruction {
+	return NewInstruction(append([]string{m.Opcode()}, args...))
+}
+
+func itoa(i int) string { return strconv.Itoa(i) }
+
+// args decodes the arguments of an instruction in order, the first
+// failure is kept in err.
+type args struct {
+	ins  *Instruction
+	list []string
+	err  error
+}
+
+func newArgs(ins *Instruction, n int) *args {
+	a := &args{ins: ins, list: ins.Args()}
+	if len(a.list) != n {
+		a.err = fmt.Errorf("%w: %s requires %d arguments, got %d",
+			ErrMessageArgs, ins.Opcode(), n, len(a.list))
+		a.list = make([]string, n)
+	}
+	return a
+}
+
+func (a *args) next() string {
+	s := a.list[0]
+	a.list = a.list[1:]
+	return s
+}
+
+func (a *args) string() string {
+	return a.next()
+}
+
+func (a *args) int() int {
+	s := a.next()
+	i, err := strconv.Atoi(s)
+	if err != nil && a.err == nil {
+		a.err = fmt.Errorf("%w: %s with bad integer %q", ErrMessageArgs, a.ins.Opcode(), s)
+	}
+	return i
+}
+
+func (a *args) int64() int64 {
+	s := a.next()
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil && a.err == nil {
+		a.err = fmt.Errorf("%w: %s with bad integer %q", ErrMessageArgs, a.ins.Opcode(), s)
+	}
+	return i
+}
+
+func (a *args) base64() []byte {
+	s := a.next()
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil && a.err == nil {
+		a.err = fmt.Errorf("%w: %s with bad base64 data", ErrMessageArgs, a.ins.Opcode())
+	}
+	return b
+}
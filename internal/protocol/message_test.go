@@ -0,0 +1,99 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"changkun.de/x/occamy/internal/protocol"
+)
+
+func TestMessage_RoundTrip(t *testing.T) {
+	tests := []struct {
+		msg protocol.Message
+		raw string
+	}{
+		{&protocol.Mouse{X: 10, Y: 20, Mask: protocol.MouseLeft}, "5.mouse,2.10,2.20,1.1;"},
+		{&protocol.Key{Keysym: 65307, Pressed: true}, "3.key,5.65307,1.1;"},
+		{&protocol.Size{Width: 1024, Height: 768}, "4.size,4.1024,3.768;"},
+		{&protocol.LayerSize{Layer: -1, Width: 64, Height: 32}, "4.size,2.-1,2.64,2.32;"},
+		{&protocol.Clipboard{Stream: 1, Mimetype: "text/plain"}, "9.clipboard,1.1,10.text/plain;"},
+		{&protocol.File{Stream: 2, Mimetype: "text/plain", Filename: "文件.txt"}, "4.file,1.2,10.text/plain,6.文件.txt;"},
+		{&protocol.Pipe{Stream: 3, Mimetype: "application/octet-stream", Name: "p"}, "4.pipe,1.3,24.application/octet-stream,1.p;"},
+		{&protocol.Blob{Stream: 1, Data: []byte("hello")}, "4.blob,1.1,8.aGVsbG8=;"},
+		{&protocol.End{Stream: 1}, "3.end,1.1;"},
+		{&protocol.Ack{Stream: 1, Message: "OK", Status: protocol.StatusSuccess}, "3.ack,1.1,2.OK,1.0;"},
+		{&protocol.Img{Stream: 4, Mode: protocol.CompositeOver, Layer: 0, Mimetype: "image/png", X: 1, Y: 2}, "3.img,1.4,2.14,1.0,9.image/png,1.1,1.2;"},
+		{&protocol.PNG{Mode: protocol.CompositeSrc, Layer: 1, X: 0, Y: 0, Data: []byte{0x89}}, "3.png,2.12,1.1,1.0,1.0,4.iQ==;"},
+		{&protocol.Rect{Layer: 0, X: 1, Y: 2, Width: 3, Height: 4}, "4.rect,1.0,1.1,1.2,1.3,1.4;"},
+		{&protocol.Cfill{Mode: protocol.CompositeOver, Layer: 0, R: 255, G: 128, B: 0, A: 255}, "5.cfill,2.14,1.0,3.255,3.128,1.0,3.255;"},
+		{&protocol.Copy{SrcLayer: -1, SrcX: 0, SrcY: 0, SrcWidth: 8, SrcHeight: 8, Mode: protocol.CompositeOver, DstLayer: 0, DstX: 4, DstY: 4}, "4.copy,2.-1,1.0,1.0,1.8,1.8,2.14,1.0,1.4,1.4;"},
+		{&protocol.Cursor{X: 0, Y: 0, SrcLayer: -1, SrcX: 0, SrcY: 0, SrcWidth: 11, SrcHeight: 16}, "6.cursor,1.0,1.0,2.-1,1.0,1.0,2.11,2.16;"},
+		{&protocol.Sync{Timestamp: 1571443200000}, "4.sync,13.1571443200000;"},
+		{&protocol.Disconnect{}, "10.disconnect;"},
+		{&protocol.Error{Message: "Aborted", Status: protocol.StatusUpstreamError}, "5.error,7.Aborted,3.515;"},
+		{&protocol.Args{Names: []string{"VERSION_1_1_0", "hostname", "port"}}, "4.args,13.VERSION_1_1_0,8.hostname,4.port;"},
+		{&protocol.Connect{Values: []string{"VERSION_1_1_0", "localhost", "22"}}, "7.connect,13.VERSION_1_1_0,9.localhost,2.22;"},
+		{&protocol.Ready{ConnectionID: "$abc"}, "5.ready,4.$abc;"},
+		{&protocol.Required{Names: []string{"username", "password"}}, "8.required,8.username,8.password;"},
+		{&protocol.Argv{Stream: 5, Mimetype: "text/plain", Name: "color-scheme"}, "4.argv,1.5,10.text/plain,12.color-scheme;"},
+		{&protocol.Msg{Code: 1, Args: []string{"user"}}, "3.msg,1.1,4.user;"},
+	}
+
+	for _, tt := range tests {
+		ins := tt.msg.Encode()
+		if ins.String() != tt.raw {
+			t.Errorf("encode %s error, want: %s, got: %s", tt.msg.Opcode(), tt.raw, ins.String())
+			continue
+		}
+		parsed, err := protocol.ParseInstruction([]byte(tt.raw))
+		if err != nil {
+			t.Errorf("parse %s error: %v", tt.raw, err)
+			continue
+		}
+		msg, err := protocol.Decode(parsed)
+		if err != nil {
+			t.Errorf("decode %s error: %v", tt.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(msg, tt.msg) {
+			t.Errorf("decode %s error, want: %#v, got: %#v", tt.raw, tt.msg, msg)
+		}
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want error
+	}{
+		{"7.unknown,1.1;", protocol.ErrMessageUnknown},
+		{"5.mouse,1.1,1.2;", protocol.ErrMessageArgs},
+		{"5.mouse,1.1,1.2,1.x;", protocol.ErrMessageArgs},
+		{"4.blob,1.1,1.!;", protocol.ErrMessageArgs},
+		{"3.msg;", protocol.ErrMessageArgs},
+	}
+	for _, tt := range tests {
+		ins, err := protocol.ParseInstruction([]byte(tt.raw))
+		if err != nil {
+			t.Fatalf("parse %s error: %v", tt.raw, err)
+		}
+		_, err = protocol.Decode(ins)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("decode %s error, want: %v, got: %v", tt.raw, tt.want, err)
+		}
+	}
+}
+
+func TestStatus_String(t *testing.T) {
+	if s := protocol.StatusClientTooMany.String(); s != "CLIENT_TOO_MANY" {
+		t.Errorf("status string error, got: %s", s)
+	}
+	if s := protocol.Status(0x0999).String(); s != "STATUS_0x0999" {
+		t.Errorf("status string error, got: %s", s)
+	}
+}
@@ -5,13 +5,14 @@
 package protocol
 
 import (
-	"strconv"
-	"strings"
+	"errors"
+	"fmt"
+	"io"
 	"unicode/utf8"
 )
 
 const (
-	// InstructionMaxLength is the maximum number of characters per
+	// InstructionMaxLength is the maximum number of bytes per
 	// instruction.
 	InstructionMaxLength = 8192
 	// InstructionMaxDigits is the maximum number of digits to allow per
@@ -22,6 +23,28 @@ const (
 	InstructionMaxElements = 128
 )
 
+// Errors while parsing instruction that exceeds parser limits
+var (
+	ErrInstructionTooLong         = errors.New("instruction too long")
+	ErrInstructionTooManyDigits   = errors.New("instruction with too many digits")
+	ErrInstructionTooManyElements = errors.New("instruction with too many elements")
+	ErrInstructionTrailing        = errors.New("instruction with trailing data")
+)
+
+// ParseError is the error reported by a Parser. It wraps one of the
+// ErrInstruction errors and can be inspected by errors.Is.
+type ParseError struct {
+	Offset int // offset of the bad byte within the instruction
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("protocol: %v at offset %d", e.Err, e.Offset)
+}
+
+// Unwrap returns the underlying ErrInstruction error
+func (e *ParseError) Unwrap() error { return e.Err }
+
 // ParserState is the parsing state of a parser
 type ParserState int
 
@@ -33,65 +56,249 @@ const (
 	ParserStateError
 )
 
-// Parser parses an occamy instruction
+// Parser is an incremental occamy instruction parser. Input can be
+// given in arbitrary chunks, the parser resumes from where the previous
+// chunk stopped. Elements of a parsed instruction are slices of an
+// internal buffer which is reused by the next instruction, hence a
+// parser does not allocate once it is created.
 type Parser struct {
 	state ParserState
+	err   error
+
+	buf      []byte   // raw bytes of the current instruction
+	elements [][]byte // parsed elements, slices of buf
+
+	length    int // character length of the current element
+	digits    int // number of digits of the current length prefix
+	remaining int // remaining characters of the current element
+	trailing  int // remaining continuation bytes of the current rune
+	rstart    int // offset of the current rune in buf
+	start     int // offset of the current element in buf
+
+	// read buffer used by Next
+	rbuf       []byte
+	rpos, rend int
 }
 
 // NewParser creates an occamy protocol parser.
-func NewParser() Parser {
-	return Parser{}
+func NewParser() *Parser {
+	return &Parser{
+		buf:      make([]byte, 0, InstructionMaxLength),
+		elements: make([][]byte, 0, InstructionMaxElements),
+	}
 }
 
-// Parse parses raw inputs into a occamy instruction
-func (p Parser) Parse(raw []byte, ins *Instruction) (err error) {
-	cursor := 0
-	bytes := len(raw)
+// State returns the current state of the parser
+func (p *Parser) State() ParserState {
+	return p.state
+}
 
-	for cursor < bytes {
+// Reset discards the instruction in progress as well as any parsing
+// error, and any buffered input of Next.
+func (p *Parser) Reset() {
+	p.reset()
+	p.rpos, p.rend = 0, 0
+}
 
-		// 1. parse digit
-		lengthEnd := -1
-		for i := cursor; i < bytes; i++ {
-			if raw[i]^'.' == 0 {
-				lengthEnd = i
-				break
-			}
-		}
-		if lengthEnd == -1 { // cannot find '.'
-			return ErrInstructionMissDot
-		}
-		length, err := strconv.Atoi(string(raw[cursor:lengthEnd]))
-		if err != nil {
-			return ErrInstructionBadDigit
+func (p *Parser) reset() {
+	p.state = ParserStateLength
+	p.err = nil
+	p.buf = p.buf[:0]
+	p.elements = p.elements[:0]
+	p.length, p.digits = 0, 0
+	p.remaining, p.trailing = 0, 0
+}
+
+// Elements returns the elements of the completed instruction, the
+// first element is the opcode. The returned slices are only valid
+// until the next call to Append, Next, Parse or Reset.
+func (p *Parser) Elements() [][]byte {
+	if p.state != ParserStateComplete {
+		return nil
+	}
+	return p.elements
+}
+
+// Raw returns the raw bytes of the completed instruction. The returned
+// slice is only valid until the next call to Append, Next, Parse or Reset.
+func (p *Parser) Raw() []byte {
+	if p.state != ParserStateComplete {
+		return nil
+	}
+	return p.buf
+}
+
+// Append feeds the given data into the parser and returns the number
+// of bytes consumed. Parsing stops as soon as an instruction is
+// complete, the unconsumed data should be given to the next call of
+// Append. Appending to a parser in complete state starts a new
+// instruction.
+//
+// Once an error is reported, the parser stays in error state and
+// reports the same error until it is Reset.
+func (p *Parser) Append(data []byte) (n int, err error) {
+	switch p.state {
+	case ParserStateError:
+		return 0, p.err
+	case ParserStateComplete:
+		p.reset()
+	}
+
+	for n < len(data) {
+		b := data[n]
+		if len(p.buf) == cap(p.buf) {
+			return n, p.fail(ErrInstructionTooLong)
 		}
+		p.buf = append(p.buf, b)
+		n++
 
-		// 2. parse rune
-		cursor = lengthEnd + 1
-		element := new(strings.Builder)
-		element.Grow(length)
-		for i := 1; i <= length; i++ {
-			r, n := utf8.DecodeRune(raw[cursor:])
-			if r == utf8.RuneError {
-				return ErrInstructionBadRune
+		switch p.state {
+		case ParserStateLength:
+			switch {
+			case b >= '0' && b <= '9':
+				if p.digits == InstructionMaxDigits {
+					return n, p.fail(ErrInstructionTooManyDigits)
+				}
+				p.digits++
+				p.length = p.length*10 + int(b-'0')
+			case b == '.':
+				if p.digits == 0 {
+					return n, p.fail(ErrInstructionBadDigit)
+				}
+				if len(p.elements) == InstructionMaxElements {
+					return n, p.fail(ErrInstructionTooManyElements)
+				}
+				p.state = ParserStateContent
+				p.remaining = p.length
+				p.start = len(p.buf)
+			case p.digits == 0:
+				return n, p.fail(ErrInstructionBadDigit)
+			default:
+				return n, p.fail(ErrInstructionMissDot)
+			}
+		case ParserStateContent:
+			if b&0xC0 == 0x80 { // continuation byte
+				if p.trailing == 0 {
+					return n, p.fail(ErrInstructionBadRune)
+				}
+				p.trailing--
+				// the complete rune must be valid, e.g. not a surrogate
+				// or an overlong encoding
+				if p.trailing == 0 {
+					if _, size := utf8.DecodeRune(p.buf[p.rstart:]); size != len(p.buf)-p.rstart {
+						return n, p.fail(ErrInstructionBadRune)
+					}
+				}
+				continue
+			}
+			if p.trailing > 0 {
+				return n, p.fail(ErrInstructionBadRune)
+			}
+			if p.remaining > 0 {
+				size := runeSize(b)
+				if size == 0 {
+					return n, p.fail(ErrInstructionBadRune)
+				}
+				p.trailing = size - 1
+				p.rstart = len(p.buf) - 1
+				p.remaining--
+				continue
 			}
-			cursor += n
-			element.WriteRune(r)
-		}
-		ins.elements = append(ins.elements, element.String())
 
-		// 3. done
-		if cursor == bytes-1 {
-			break
+			// element is terminated by either ',' or ';'
+			p.elements = append(p.elements, p.buf[p.start:len(p.buf)-1])
+			switch b {
+			case ',':
+				p.state = ParserStateLength
+				p.length, p.digits = 0, 0
+			case ';':
+				p.state = ParserStateComplete
+				return n, nil
+			default:
+				return n, p.fail(ErrInstructionMissComma)
+			}
 		}
+	}
+	return n, nil
+}
+
+func (p *Parser) fail(err error) error {
+	p.state = ParserStateError
+	p.err = &ParseError{Offset: len(p.buf) - 1, Err: err}
+	return p.err
+}
+
+// runeSize returns the number of bytes of an UTF-8 encoded rune by
+// its leading byte, or 0 if the byte cannot lead a rune.
+func runeSize(b byte) int {
+	switch {
+	case b < 0x80:
+		return 1
+	case b&0xE0 == 0xC0:
+		return 2
+	case b&0xF0 == 0xE0:
+		return 3
+	case b&0xF8 == 0xF0:
+		return 4
+	}
+	return 0
+}
 
-		// 4. parse next
-		if raw[cursor]^',' != 0 {
-			return ErrInstructionMissComma
+// Next reads from the given reader until the next instruction is
+// complete, and returns its elements. Data read beyond the instruction
+// is buffered by the parser for the following call. The returned
+// slices are only valid until the next call to Next.
+//
+// If the reader ends in the middle of an instruction, the returned
+// error is io.ErrUnexpectedEOF.
+func (p *Parser) Next(r io.Reader) ([][]byte, error) {
+	if p.rbuf == nil {
+		p.rbuf = make([]byte, InstructionMaxLength)
+	}
+	if p.state == ParserStateComplete {
+		p.reset()
+	}
+	for {
+		if p.rpos < p.rend {
+			n, err := p.Append(p.rbuf[p.rpos:p.rend])
+			p.rpos += n
+			if err != nil {
+				return nil, err
+			}
+			if p.state == ParserStateComplete {
+				return p.elements, nil
+			}
 		}
 
-		cursor++
+		n, err := r.Read(p.rbuf)
+		p.rpos, p.rend = 0, n
+		if n > 0 || err == nil {
+			continue
+		}
+		if err == io.EOF && len(p.buf) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
 	}
+}
 
+// Parse parses exactly one complete instruction from raw inputs into
+// the given occamy instruction.
+func (p *Parser) Parse(raw []byte, ins *Instruction) error {
+	p.reset()
+	n, err := p.Append(raw)
+	if err != nil {
+		return err
+	}
+	if p.state != ParserStateComplete {
+		return &ParseError{Offset: n, Err: ErrInstructionMissSemi}
+	}
+	if n != len(raw) {
+		return &ParseError{Offset: n, Err: ErrInstructionTrailing}
+	}
+	ins.elements = ins.elements[:0]
+	for _, e := range p.elements {
+		ins.elements = append(ins.elements, string(e))
+	}
 	return nil
 }
@@ -0,0 +1,78 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package protocol_test
+
+import (
+	"errors"
+	"testing"
+
+	"changkun.de/x/occamy/internal/protocol"
+)
+
+// FuzzParser checks that the parser never panics, parses chunked input
+// the same as the complete input, and that a parsed instruction is
+// parsed again to the same elements after encoding.
+//
+//  go test -fuzz=FuzzParser
+func FuzzParser(f *testing.F) {
+	for _, ins := range instructions {
+		f.Add(ins, uint8(3))
+	}
+	f.Add([]byte("5.hello,2.世界,0.;"), uint8(1))
+	f.Add([]byte("00003.abc;"), uint8(2))
+	f.Add([]byte("3.a;b;4.sync;"), uint8(5))
+
+	f.Fuzz(func(t *testing.T, raw []byte, chunk uint8) {
+		p := protocol.NewParser()
+		ins := protocol.Instruction{}
+		err := p.Parse(raw, &ins)
+		if err != nil {
+			var perr *protocol.ParseError
+			if !errors.As(err, &perr) {
+				t.Fatalf("parse error is not a ParseError: %v", err)
+			}
+		}
+
+		// chunked input
+		size := int(chunk)%16 + 1
+		q := protocol.NewParser()
+		var qerr error
+		consumed := 0
+		for consumed < len(raw) && qerr == nil && q.State() != protocol.ParserStateComplete {
+			end := consumed + size
+			if end > len(raw) {
+				end = len(raw)
+			}
+			var n int
+			n, qerr = q.Append(raw[consumed:end])
+			consumed += n
+		}
+		if err == nil {
+			if qerr != nil || q.State() != protocol.ParserStateComplete {
+				t.Fatalf("chunked parse failed: %v", qerr)
+			}
+			if len(q.Elements()) != len(ins.Args())+1 {
+				t.Fatalf("chunked parse got different elements")
+			}
+			for i, e := range q.Elements() {
+				if i == 0 && string(e) != ins.Opcode() || i > 0 && string(e) != ins.Args()[i-1] {
+					t.Fatalf("chunked parse got different element %d: %q", i, e)
+				}
+			}
+
+			// round trip
+			again := protocol.Instruction{}
+			if err := p.Parse([]byte(ins.String()), &again); err != nil {
+				t.Fatalf("parse encoded instruction error: %v", err)
+			}
+			if again.String() != ins.String() {
+				t.Fatalf("round trip mismatch, want: %q, got: %q", ins.String(), again.String())
+			}
+		}
+	})
+}
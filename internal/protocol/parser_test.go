@@ -5,7 +5,11 @@
 package protocol_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"testing"
 
 	"changkun.de/x/occamy/internal/protocol"
@@ -21,8 +25,10 @@ func TestParser_Parse(t *testing.T) {
 		t.Run(fmt.Sprintf("occamy-ins-len-%d", len(instructions[idx])), func(t *testing.T) {
 			p := protocol.NewParser()
 			ins := protocol.Instruction{}
-			p.Parse(instructions[idx], &ins)
-
+			err := p.Parse(instructions[idx], &ins)
+			if err != nil {
+				t.Fatalf("parse instruction error: %v", err)
+			}
 			if ins.String() != string(instructions[idx]) {
 				t.Fatalf("parse instruction not success")
 			}
@@ -30,6 +36,142 @@ func TestParser_Parse(t *testing.T) {
 	}
 }
 
+func TestParser_Append(t *testing.T) {
+	raw := []byte("5.hello,2.世界,0.,3.a;b;4.sync,3.123;")
+	want := [][]string{{"hello", "世界", "", "a;b"}, {"sync", "123"}}
+
+	// feed the parser byte by byte, elements must survive the chunk boundaries
+	p := protocol.NewParser()
+	var got [][]string
+	for i := 0; i < len(raw); i++ {
+		n, err := p.Append(raw[i : i+1])
+		if err != nil {
+			t.Fatalf("append error: %v", err)
+		}
+		if n != 1 {
+			t.Fatalf("append should consume one byte, got: %d", n)
+		}
+		if p.State() != protocol.ParserStateComplete {
+			continue
+		}
+		var elements []string
+		for _, e := range p.Elements() {
+			elements = append(elements, string(e))
+		}
+		got = append(got, elements)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("parse chunks wrong, want: %q, got: %q", want, got)
+	}
+
+	// a complete instruction stops consuming
+	p.Reset()
+	n, err := p.Append(raw)
+	if err != nil {
+		t.Fatalf("append error: %v", err)
+	}
+	if p.State() != protocol.ParserStateComplete || n != len("5.hello,2.世界,0.,3.a;b;") {
+		t.Fatalf("append should stop after the first instruction, got: %d", n)
+	}
+	if string(p.Raw()) != string(raw[:n]) {
+		t.Fatalf("raw instruction wrong, got: %s", p.Raw())
+	}
+}
+
+func TestParser_Errors(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want error
+	}{
+		{"x.abc;", protocol.ErrInstructionBadDigit},
+		{".abc;", protocol.ErrInstructionBadDigit},
+		{"3,abc;", protocol.ErrInstructionMissDot},
+		{"3.abcd;", protocol.ErrInstructionMissComma},
+		{"3.ab\xff;", protocol.ErrInstructionBadRune},
+		{"1.\x80;", protocol.ErrInstructionBadRune},
+		{"2.\xed\xb8\x960;", protocol.ErrInstructionBadRune},
+		{"1.\xc0\x80;", protocol.ErrInstructionBadRune},
+		{"1.\xf5\x80\x80\x80;", protocol.ErrInstructionBadRune},
+		{"123456.a;", protocol.ErrInstructionTooManyDigits},
+		{"4.sync", protocol.ErrInstructionMissSemi},
+		{"4.sync;3.nop;", protocol.ErrInstructionTrailing},
+		{fmt.Sprintf("4.blob,%d.%s;", protocol.InstructionMaxLength, strings.Repeat("a", protocol.InstructionMaxLength)), protocol.ErrInstructionTooLong},
+		{"1.a" + strings.Repeat(",0.", protocol.InstructionMaxElements) + ";", protocol.ErrInstructionTooManyElements},
+	}
+	for _, tt := range tests {
+		raw := tt.raw
+		p := protocol.NewParser()
+		err := p.Parse([]byte(raw), &protocol.Instruction{})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("parse %.20q, want: %v, got: %v", raw, tt.want, err)
+			continue
+		}
+		var perr *protocol.ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("parse %.20q, error is not a ParseError: %v", raw, err)
+		}
+	}
+
+	// the parser stays in error state until reset
+	p := protocol.NewParser()
+	_, err := p.Append([]byte("x"))
+	if err == nil || p.State() != protocol.ParserStateError {
+		t.Fatalf("parser should fail")
+	}
+	if _, err2 := p.Append([]byte("4.sync;")); err2 != err {
+		t.Fatalf("parser should keep the error, got: %v", err2)
+	}
+	p.Reset()
+	if _, err := p.Append([]byte("4.sync;")); err != nil || p.State() != protocol.ParserStateComplete {
+		t.Fatalf("parser should recover after reset, got: %v", err)
+	}
+}
+
+// chunkReader returns at most n bytes per read.
+type chunkReader struct {
+	r io.Reader
+	n int
+}
+
+func (c chunkReader) Read(buf []byte) (int, error) {
+	if len(buf) > c.n {
+		buf = buf[:c.n]
+	}
+	return c.r.Read(buf)
+}
+
+func TestParser_Next(t *testing.T) {
+	var stream bytes.Buffer
+	for _, ins := range instructions {
+		stream.Write(ins)
+	}
+
+	for _, size := range []int{1, 7, 4096, protocol.InstructionMaxLength} {
+		t.Run(fmt.Sprintf("chunk-%d", size), func(t *testing.T) {
+			p := protocol.NewParser()
+			r := chunkReader{bytes.NewReader(stream.Bytes()), size}
+			for idx := range instructions {
+				_, err := p.Next(r)
+				if err != nil {
+					t.Fatalf("next error: %v", err)
+				}
+				if string(p.Raw()) != string(instructions[idx]) {
+					t.Fatalf("next instruction wrong, got: %.20s", p.Raw())
+				}
+			}
+			if _, err := p.Next(r); err != io.EOF {
+				t.Fatalf("next should report EOF, got: %v", err)
+			}
+		})
+	}
+
+	p := protocol.NewParser()
+	_, err := p.Next(strings.NewReader("4.sync,3.12"))
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("next should report unexpected EOF, got: %v", err)
+	}
+}
+
 func BenchmarkParser_Parse(b *testing.B) {
 
 	for idx := range instructions {
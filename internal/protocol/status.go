@@ -0,0 +1,102 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import "fmt"
+
+// Status is a guacamole protocol status code, used by the ack and
+// error instructions. There is a general correspondence of these status
+// codes with HTTP response codes.
+type Status int
+
+// All guacamole protocol status codes
+const (
+	// The operation succeeded.
+	StatusSuccess Status = 0x0000
+	// The requested operation is unsupported.
+	StatusUnsupported Status = 0x0100
+	// The operation could not be performed due to an internal failure.
+	StatusServerError Status = 0x0200
+	// The operation could not be performed due as the server is busy.
+	StatusServerBusy Status = 0x0201
+	// The operation could not be performed because the upstream server
+	// is not responding.
+	StatusUpstreamTimeout Status = 0x0202
+	// The operation was unsuccessful due to an error or otherwise
+	// unexpected condition of the upstream server.
+	StatusUpstreamError Status = 0x0203
+	// The operation could not be performed as the requested resource
+	// does not exist.
+	StatusResourceNotFound Status = 0x0204
+	// The operation could not be performed as the requested resource is
+	// already in use.
+	StatusResourceConflict Status = 0x0205
+	// The operation could not be performed as the requested resource is
+	// now closed.
+	StatusResourceClosed Status = 0x0206
+	// The operation could not be performed because the upstream server
+	// does not appear to exist.
+	StatusUpstreamNotFound Status = 0x0207
+	// The operation could not be performed because the upstream server
+	// is not available to service the request.
+	StatusUpstreamUnavailable Status = 0x0208
+	// The session within the upstream server has ended because it
+	// conflicted with another session.
+	StatusSessionConflict Status = 0x0209
+	// The session within the upstream server has ended because it
+	// appeared to be inactive.
+	StatusSessionTimeout Status = 0x020A
+	// The session within the upstream server has been forcibly terminated.
+	StatusSessionClosed Status = 0x020B
+	// The operation could not be performed because bad parameters were
+	// given.
+	StatusClientBadRequest Status = 0x0300
+	// Permission was denied to perform the operation, as the user is not
+	// yet authorized (not yet logged in, for example).
+	StatusClientUnauthorized Status = 0x0301
+	// Permission was denied to perform the operation, and this
+	// permission will not be granted even if the user is authorized.
+	StatusClientForbidden Status = 0x0303
+	// The client took too long to respond.
+	StatusClientTimeout Status = 0x0308
+	// The client sent too much data.
+	StatusClientOverrun Status = 0x030D
+	// The client sent data of an unsupported or unexpected type.
+	StatusClientBadType Status = 0x030F
+	// The operation failed because the current client is already using
+	// too many resources.
+	StatusClientTooMany Status = 0x031D
+)
+
+var statusText = map[Status]string{
+	StatusSuccess:             "SUCCESS",
+	StatusUnsupported:         "UNSUPPORTED",
+	StatusServerError:         "SERVER_ERROR",
+	StatusServerBusy:          "SERVER_BUSY",
+	StatusUpstreamTimeout:     "UPSTREAM_TIMEOUT",
+	StatusUpstreamError:       "UPSTREAM_ERROR",
+	StatusResourceNotFound:    "RESOURCE_NOT_FOUND",
+	StatusResourceConflict:    "RESOURCE_CONFLICT",
+	StatusResourceClosed:      "RESOURCE_CLOSED",
+	StatusUpstreamNotFound:    "UPSTREAM_NOT_FOUND",
+	StatusUpstreamUnavailable: "UPSTREAM_UNAVAILABLE",
+	StatusSessionConflict:     "SESSION_CONFLICT",
+	StatusSessionTimeout:      "SESSION_TIMEOUT",
+	StatusSessionClosed:       "SESSION_CLOSED",
+	StatusClientBadRequest:    "CLIENT_BAD_REQUEST",
+	StatusClientUnauthorized:  "CLIENT_UNAUTHORIZED",
+	StatusClientForbidden:     "CLIENT_FORBIDDEN",
+	StatusClientTimeout:       "CLIENT_TIMEOUT",
+	StatusClientOverrun:       "CLIENT_OVERRUN",
+	StatusClientBadType:       "CLIENT_BAD_TYPE",
+	StatusClientTooMany:       "CLIENT_TOO_MANY",
+}
+
+func (s Status) String() string {
+	if text, ok := statusText[s]; ok {
+		return text
+	}
+	return fmt.Sprintf("STATUS_0x%04X", int(s))
+}
@@ -0,0 +1,70 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrVersionInvalid indicates a malformed protocol version string
+var ErrVersionInvalid = errors.New("invalid protocol version")
+
+// Version is a guacamole protocol version. It is encoded as 0xMMmmpp
+// for version MM.mm.pp, the same as guac_protocol_version of libguac,
+// hence versions can be compared directly.
+type Version int
+
+// All protocol versions known to occamy
+const (
+	VersionUnknown Version = 0x000000
+	Version100     Version = 0x010000 // the original protocol
+	Version110     Version = 0x010100 // adds required and argv
+	VersionLatest          = Version110
+)
+
+// ParseVersion parses a version string of the form VERSION_1_1_0.
+func ParseVersion(s string) (Version, error) {
+	parts := strings.Split(s, "_")
+	if len(parts) != 4 || parts[0] != "VERSION" {
+		return VersionUnknown, fmt.Errorf("%w: %q", ErrVersionInvalid, s)
+	}
+	var v Version
+	for _, p := range parts[1:] {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || n > 0xff {
+			return VersionUnknown, fmt.Errorf("%w: %q", ErrVersionInvalid, s)
+		}
+		v = v<<8 | Version(n)
+	}
+	return v, nil
+}
+
+// NegotiateVersion returns the protocol version to use with a client
+// that declares the given version. Clients without a valid version are
+// assumed to speak the original protocol, and clients newer than occamy
+// are served with the latest version.
+func NegotiateVersion(client string) Version {
+	v, err := ParseVersion(client)
+	if err != nil || v < Version100 {
+		return Version100
+	}
+	if v > VersionLatest {
+		return VersionLatest
+	}
+	return v
+}
+
+// SupportsRequired reports whether the version supports the required
+// and argv instructions.
+func (v Version) SupportsRequired() bool {
+	return v >= Version110
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("VERSION_%d_%d_%d", v>>16&0xff, v>>8&0xff, v&0xff)
+}
@@ -0,0 +1,50 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package protocol_test
+
+import (
+	"errors"
+	"testing"
+
+	"changkun.de/x/occamy/internal/protocol"
+)
+
+func TestParseVersion(t *testing.T) {
+	v, err := protocol.ParseVersion("VERSION_1_1_0")
+	if err != nil || v != protocol.Version110 {
+		t.Fatalf("parse version error, got: %v, %v", v, err)
+	}
+	if v.String() != "VERSION_1_1_0" {
+		t.Fatalf("version string error, got: %s", v)
+	}
+	for _, s := range []string{"", "1.1.0", "VERSION_1_1", "VERSION_1_x_0", "VERSION_1_256_0"} {
+		_, err := protocol.ParseVersion(s)
+		if !errors.Is(err, protocol.ErrVersionInvalid) {
+			t.Errorf("parse %q should fail, got: %v", s, err)
+		}
+	}
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	tests := []struct {
+		client string
+		want   protocol.Version
+	}{
+		{"", protocol.Version100},
+		{"bogus", protocol.Version100},
+		{"VERSION_0_9_0", protocol.Version100},
+		{"VERSION_1_0_0", protocol.Version100},
+		{"VERSION_1_1_0", protocol.Version110},
+		{"VERSION_1_5_0", protocol.VersionLatest},
+	}
+	for _, tt := range tests {
+		if got := protocol.NegotiateVersion(tt.client); got != tt.want {
+			t.Errorf("negotiate %q, want: %v, got: %v", tt.client, tt.want, got)
+		}
+	}
+	if protocol.Version100.SupportsRequired() || !protocol.Version110.SupportsRequired() {
+		t.Errorf("required is supported since VERSION_1_1_0")
+	}
+}
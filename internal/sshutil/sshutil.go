@@ -0,0 +1,121 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package sshutil implements the SSH client logins which are shared by
+// the ssh-go plugin and the jump hosts of the server.
+package sshutil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+	"golang.org/x/crypto/ssh"
+)
+
+// Credentials are the credentials of an SSH login.
+type Credentials struct {
+	Password   string
+	PrivateKey string // in the PEM format
+	Passphrase string // of the private key, optional
+	// Certificate is the certificate of the private key in the
+	// authorized_keys format, optional.
+	Certificate string
+}
+
+// Auth returns the authentication methods of the given credentials. The
+// private key is preferred over the password, which is also used to
+// answer keyboard-interactive prompts. The key is presented with its
+// certificate if given.
+func Auth(c Credentials) ([]ssh.AuthMethod, error) {
+	var auth []ssh.AuthMethod
+	if c.PrivateKey != "" {
+		var (
+			signer ssh.Signer
+			err    error
+		)
+		if c.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(c.PrivateKey), []byte(c.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(c.PrivateKey))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ssh: invalid private key: %w", err)
+		}
+		if c.Certificate != "" {
+			signer, err = certSigner(signer, c.Certificate)
+			if err != nil {
+				return nil, err
+			}
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if password := c.Password; password != "" {
+		auth = append(auth,
+			ssh.Password(password),
+			ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}))
+	}
+	return auth, nil
+}
+
+// certSigner returns the signer of the given key which presents the
+// given certificate in the authorized_keys format.
+func certSigner(signer ssh.Signer, pub string) (ssh.Signer, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pub))
+	if err != nil {
+		return nil, fmt.Errorf("ssh: invalid certificate: %w", err)
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("ssh: invalid certificate: %s is not a certificate", key.Type())
+	}
+	signer, err = ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: invalid certificate: %w", err)
+	}
+	return signer, nil
+}
+
+// NewClient establishes an SSH connection over the given connection,
+// which is not closed on errors. The errors of the host key verification
+// are returned as they are, and failed authentications as plugin errors
+// of StatusClientUnauthorized.
+func NewClient(nc net.Conn, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	var hostKeyErr error
+	cfg := *config
+	cfg.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		hostKeyErr = config.HostKeyCallback(hostname, remote, key)
+		return hostKeyErr
+	}
+	sc, chans, reqs, err := ssh.NewClientConn(nc, addr, &cfg)
+	switch {
+	case hostKeyErr != nil:
+		return nil, hostKeyErr
+	case isAuthError(err):
+		return nil, plugin.StatusError(protocol.StatusClientUnauthorized, err)
+	case err != nil:
+		return nil, err
+	}
+	return ssh.NewClient(sc, chans, reqs), nil
+}
+
+// isAuthError reports whether the handshake failed as no authentication
+// method was accepted. The client of x/crypto/ssh reports the failure
+// as text which is matched if the error is not a *ssh.ServerAuthError.
+func isAuthError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var authErr *ssh.ServerAuthError
+	return errors.As(err, &authErr) || strings.Contains(err.Error(), "ssh: unable to authenticate")
+}
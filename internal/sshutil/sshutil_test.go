@@ -0,0 +1,141 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package sshutil_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/internal/sshutil"
+	"changkun.de/x/occamy/plugin"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestAuth(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key error: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("marshal key error: %v", err)
+	}
+	key := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("create signer error: %v", err)
+	}
+	cert := &ssh.Certificate{Key: signer.PublicKey(), CertType: ssh.UserCert, ValidPrincipals: []string{"occamy"}}
+	if err := cert.SignCert(rand.Reader, signer); err != nil {
+		t.Fatalf("sign certificate error: %v", err)
+	}
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert)))
+
+	tests := []struct {
+		name    string
+		creds   sshutil.Credentials
+		methods int
+		err     bool
+	}{
+		{"none", sshutil.Credentials{}, 0, false},
+		{"password", sshutil.Credentials{Password: "secret"}, 2, false},
+		{"private key", sshutil.Credentials{PrivateKey: key}, 1, false},
+		{"key and password", sshutil.Credentials{PrivateKey: key, Password: "secret"}, 3, false},
+		{"certificate", sshutil.Credentials{PrivateKey: key, Certificate: line}, 1, false},
+		{"invalid private key", sshutil.Credentials{PrivateKey: "key"}, 0, true},
+		{"wrong passphrase", sshutil.Credentials{PrivateKey: key, Passphrase: "secret"}, 0, true},
+		{"not a certificate", sshutil.Credentials{PrivateKey: key,
+			Certificate: string(ssh.MarshalAuthorizedKey(signer.PublicKey()))}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := sshutil.Auth(tt.creds)
+			if (err != nil) != tt.err {
+				t.Fatalf("auth error: %v", err)
+			}
+			if len(auth) != tt.methods {
+				t.Fatalf("methods: got %d, want %d", len(auth), tt.methods)
+			}
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key error: %v", err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("create signer error: %v", err)
+	}
+	server := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) != "secret" {
+				return nil, errors.New("wrong password")
+			}
+			return nil, nil
+		},
+	}
+	server.AddHostKey(hostKey)
+
+	unknown := func(string, net.Addr, ssh.PublicKey) error { return errors.New("unknown host key") }
+	tests := []struct {
+		name     string
+		password string
+		hostKey  ssh.HostKeyCallback
+		status   protocol.Status
+	}{
+		{"password", "secret", ssh.FixedHostKey(hostKey.PublicKey()), protocol.StatusSuccess},
+		{"wrong password", "wrong", ssh.FixedHostKey(hostKey.PublicKey()), protocol.StatusClientUnauthorized},
+		{"unknown host key", "secret", unknown, protocol.StatusUpstreamError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listen error: %v", err)
+			}
+			defer l.Close()
+			go func() {
+				peer, err := l.Accept()
+				if err != nil {
+					return
+				}
+				defer peer.Close()
+				if sc, _, _, err := ssh.NewServerConn(peer, server); err == nil {
+					sc.Close()
+				}
+			}()
+			nc, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				t.Fatalf("dial error: %v", err)
+			}
+			defer nc.Close()
+			auth, err := sshutil.Auth(sshutil.Credentials{Password: tt.password})
+			if err != nil {
+				t.Fatalf("auth error: %v", err)
+			}
+			client, err := sshutil.NewClient(nc, l.Addr().String(), &ssh.ClientConfig{
+				User:            "occamy",
+				Auth:            auth,
+				HostKeyCallback: tt.hostKey,
+			})
+			if err == nil {
+				client.Close()
+			}
+			if got := plugin.StatusOf(err); got != tt.status {
+				t.Fatalf("status: got %v, want %v (%v)", got, tt.status, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import "image/color"
+
+// Color is a terminal color, either an index of the 256 color palette,
+// a 24-bit RGB color, or the default color of the color scheme.
+type Color int32
+
+// colorRGB flags a 24-bit RGB color
+const colorRGB Color = 1 << 24
+
+// ColorDefault is the default foreground or background color
+const ColorDefault Color = -1
+
+// RGB returns a 24-bit color.
+func RGB(r, g, b uint8) Color {
+	return colorRGB | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+// palette is the 256 color palette, the first 16 colors are the colors
+// of the libguac terminal.
+var palette = func() [256]color.RGBA {
+	var p [256]color.RGBA
+	base := [16][3]uint8{
+		{0x00, 0x00, 0x00}, {0x99, 0x3E, 0x3E}, {0x3E, 0x99, 0x3E}, {0x99, 0x99, 0x3E},
+		{0x3E, 0x3E, 0x99}, {0x99, 0x3E, 0x99}, {0x3E, 0x99, 0x99}, {0x99, 0x99, 0x99},
+		{0x3E, 0x3E, 0x3E}, {0xFF, 0x67, 0x67}, {0x67, 0xFF, 0x67}, {0xFF, 0xFF, 0x67},
+		{0x67, 0x67, 0xFF}, {0xFF, 0x67, 0xFF}, {0x67, 0xFF, 0xFF}, {0xFF, 0xFF, 0xFF},
+	}
+	for i, c := range base {
+		p[i] = color.RGBA{c[0], c[1], c[2], 0xFF}
+	}
+	// 6x6x6 color cube
+	levels := [6]uint8{0x00, 0x5F, 0x87, 0xAF, 0xD7, 0xFF}
+	for i := 0; i < 216; i++ {
+		p[16+i] = color.RGBA{levels[i/36], levels[i/6%6], levels[i%6], 0xFF}
+	}
+	// grayscale ramp
+	for i := 0; i < 24; i++ {
+		v := uint8(8 + 10*i)
+		p[232+i] = color.RGBA{v, v, v, 0xFF}
+	}
+	return p
+}()
+
+// Scheme is a color scheme, the default foreground and background
+type Scheme struct {
+	Foreground, Background Color
+}
+
+// schemes are the color schemes of the libguac terminal by name
+var schemes = map[string]Scheme{
+	"gray-black":  {Foreground: 7, Background: 0},
+	"black-white": {Foreground: 0, Background: 15},
+	"green-black": {Foreground: 2, Background: 0},
+	"white-black": {Foreground: 15, Background: 0},
+}
+
+// SchemeByName returns the named color scheme, gray-black by default.
+func SchemeByName(name string) Scheme {
+	if s, ok := schemes[name]; ok {
+		return s
+	}
+	return schemes["gray-black"]
+}
+
+// rgba resolves a color, the default color is resolved by the given
+// fallback of the scheme.
+func (c Color) rgba(fallback Color) color.RGBA {
+	if c == ColorDefault {
+		c = fallback
+	}
+	if c&colorRGB != 0 {
+		return color.RGBA{uint8(c >> 16), uint8(c >> 8), uint8(c), 0xFF}
+	}
+	return palette[c&0xFF]
+}
@@ -0,0 +1,31 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package terminal
+
+//go:generate sh -c "cd mkfont && go run . -o ../fontdata.go"
+
+// Size of a character cell of the built-in font in pixels
+const (
+	CellWidth  = 8
+	CellHeight = 16
+)
+
+// glyphs indexes the glyphs of the built-in font by rune
+var glyphs = func() map[rune]string {
+	m := make(map[rune]string, len(glyphRunes))
+	for i, r := range glyphRunes {
+		m[r] = glyphData[i*CellHeight : (i+1)*CellHeight]
+	}
+	return m
+}()
+
+// glyph returns the rows of the glyph of the given rune, runes without
+// a glyph are drawn as a question mark.
+func glyph(r rune) string {
+	if g, ok := glyphs[r]; ok {
+		return g
+	}
+	return glyphs['?']
+}
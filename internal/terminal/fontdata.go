@@ -0,0 +1,219 @@
+// Code generated by mkfont.go; DO NOT EDIT.
+
+// The glyphs are rasterized from DejaVu Sans Mono, see
+// https://dejavu-fonts.github.io/License.html
+
+package terminal
+
+// glyphRunes are the runes of the built-in font in order of glyphData
+var glyphRunes = []rune{
+	0x0020, 0x0021, 0x0022, 0x0023, 0x0024, 0x0025, 0x0026, 0x0027, 0x0028, 0x0029, 0x002a, 0x002b,
+	0x002c, 0x002d, 0x002e, 0x002f, 0x0030, 0x0031, 0x0032, 0x0033, 0x0034, 0x0035, 0x0036, 0x0037,
+	0x0038, 0x0039, 0x003a, 0x003b, 0x003c, 0x003d, 0x003e, 0x003f, 0x0040, 0x0041, 0x0042, 0x0043,
+	0x0044, 0x0045, 0x0046, 0x0047, 0x0048, 0x0049, 0x004a, 0x004b, 0x004c, 0x004d, 0x004e, 0x004f,
+	0x0050, 0x0051, 0x0052, 0x0053, 0x0054, 0x0055, 0x0056, 0x0057, 0x0058, 0x0059, 0x005a, 0x005b,
+	0x005c, 0x005d, 0x005e, 0x005f, 0x0060, 0x0061, 0x0062, 0x0063, 0x0064, 0x0065, 0x0066, 0x0067,
+	0x0068, 0x0069, 0x006a, 0x006b, 0x006c, 0x006d, 0x006e, 0x006f, 0x0070, 0x0071, 0x0072, 0x0073,
+	0x0074, 0x0075, 0x0076, 0x0077, 0x0078, 0x0079, 0x007a, 0x007b, 0x007c, 0x007d, 0x007e, 0x00a0,
+	0x00a1, 0x00a2, 0x00a3, 0x00a4, 0x00a5, 0x00a6, 0x00a7, 0x00a8, 0x00a9, 0x00aa, 0x00ab, 0x00ac,
+	0x00ad, 0x00ae, 0x00af, 0x00b0, 0x00b1, 0x00b2, 0x00b3, 0x00b4, 0x00b5, 0x00b6, 0x00b7, 0x00b8,
+	0x00b9, 0x00ba, 0x00bb, 0x00bc, 0x00bd, 0x00be, 0x00bf, 0x00c0, 0x00c1, 0x00c2, 0x00c3, 0x00c4,
+	0x00c5, 0x00c6, 0x00c7, 0x00c8, 0x00c9, 0x00ca, 0x00cb, 0x00cc, 0x00cd, 0x00ce, 0x00cf, 0x00d0,
+	0x00d1, 0x00d2, 0x00d3, 0x00d4, 0x00d5, 0x00d6, 0x00d7, 0x00d8, 0x00d9, 0x00da, 0x00db, 0x00dc,
+	0x00dd, 0x00de, 0x00df, 0x00e0, 0x00e1, 0x00e2, 0x00e3, 0x00e4, 0x00e5, 0x00e6, 0x00e7, 0x00e8,
+	0x00e9, 0x00ea, 0x00eb, 0x00ec, 0x00ed, 0x00ee, 0x00ef, 0x00f0, 0x00f1, 0x00f2, 0x00f3, 0x00f4,
+	0x00f5, 0x00f6, 0x00f7, 0x00f8, 0x00f9, 0x00fa, 0x00fb, 0x00fc, 0x00fd, 0x00fe, 0x00ff, 0x2500,
+	0x2501, 0x2502, 0x2503, 0x2504, 0x2505, 0x2506, 0x2507, 0x2508, 0x2509, 0x250a, 0x250b, 0x250c,
+	0x250d, 0x250e, 0x250f, 0x2510, 0x2511, 0x2512, 0x2513, 0x2514, 0x2515, 0x2516, 0x2517, 0x2518,
+	0x2519, 0x251a, 0x251b, 0x251c, 0x251d, 0x251e, 0x251f, 0x2520, 0x2521, 0x2522, 0x2523, 0x2524,
+	0x2525, 0x2526, 0x2527, 0x2528, 0x2529, 0x252a, 0x252b, 0x252c, 0x252d, 0x252e, 0x252f, 0x2530,
+	0x2531, 0x2532, 0x2533, 0x2534, 0x2535, 0x2536, 0x2537, 0x2538, 0x2539, 0x253a, 0x253b, 0x253c,
+	0x253d, 0x253e, 0x253f, 0x2540, 0x2541, 0x2542, 0x2543, 0x2544, 0x2545, 0x2546, 0x2547, 0x2548,
+	0x2549, 0x254a, 0x254b, 0x254c, 0x254d, 0x254e, 0x254f, 0x2550, 0x2551, 0x2552, 0x2553, 0x2554,
+	0x2555, 0x2556, 0x2557, 0x2558, 0x2559, 0x255a, 0x255b, 0x255c, 0x255d, 0x255e, 0x255f, 0x2560,
+	0x2561, 0x2562, 0x2563, 0x2564, 0x2565, 0x2566, 0x2567, 0x2568, 0x2569, 0x256a, 0x256b, 0x256c,
+	0x256d, 0x256e, 0x256f, 0x2570, 0x2571, 0x2572, 0x2573, 0x2574, 0x2575, 0x2576, 0x2577, 0x2578,
+	0x2579, 0x257a, 0x257b, 0x257c, 0x257d, 0x257e, 0x257f, 0x2580, 0x2581, 0x2582, 0x2583, 0x2584,
+	0x2585, 0x2586, 0x2587, 0x2588, 0x2589, 0x258a, 0x258b, 0x258c, 0x258d, 0x258e, 0x258f, 0x2590,
+	0x2591, 0x2592, 0x2593, 0x2594, 0x2595, 0x2596, 0x2597, 0x2598, 0x2599, 0x259a, 0x259b, 0x259c,
+	0x259d, 0x259e, 0x259f,
+}
+
+// glyphData are the 16 rows of 8 pixels of each glyph
+var glyphData = "" +
+	"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x10\x10\x10\x10\x10\x10\x10\x00\x10\x10\x00\x00\x00\x00" +
+	"\x00\x00\x28\x28\x28\x28\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x12\x12\x16\x7f\x24\x24\xfe\x28\x48\x48\x00\x00\x00\x00" +
+	"\x00\x08\x08\x3e\x69\x48\x68\x3e\x0b\x09\x4b\x3e\x08\x08\x00\x00\x00\x00\x60\x90\x90\x62\x0c\x30\x46\x09\x09\x06\x00\x00\x00\x00" +
+	"\x00\x00\x1c\x20\x20\x30\x30\x49\x4d\x47\x66\x3f\x00\x00\x00\x00\x00\x00\x10\x10\x10\x10\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x0c\x08\x08\x10\x10\x10\x10\x10\x10\x08\x08\x0c\x00\x00\x00\x00\x30\x10\x10\x08\x08\x08\x08\x08\x08\x10\x10\x30\x00\x00\x00" +
+	"\x00\x00\x08\x49\x1c\x1c\x49\x08\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x10\x10\x10\xfe\x10\x10\x10\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x18\x18\x10\x20\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x38\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x18\x18\x00\x00\x00\x00\x00\x00\x02\x04\x04\x08\x08\x18\x10\x10\x20\x20\x40\x00\x00\x00" +
+	"\x00\x00\x1c\x22\x41\x41\x41\x49\x41\x41\x22\x1c\x00\x00\x00\x00\x00\x00\x38\x08\x08\x08\x08\x08\x08\x08\x08\x3e\x00\x00\x00\x00" +
+	"\x00\x00\x3e\x43\x01\x01\x02\x06\x0c\x10\x20\x7f\x00\x00\x00\x00\x00\x00\x3e\x43\x01\x03\x1e\x03\x01\x01\x43\x3e\x00\x00\x00\x00" +
+	"\x00\x00\x06\x0e\x0a\x12\x32\x22\x42\x7f\x02\x02\x00\x00\x00\x00\x00\x00\x7e\x40\x40\x40\x7c\x03\x01\x01\x43\x3c\x00\x00\x00\x00" +
+	"\x00\x00\x1e\x31\x60\x40\x5e\x63\x41\x41\x23\x1e\x00\x00\x00\x00\x00\x00\x7f\x03\x02\x06\x04\x08\x08\x10\x10\x20\x00\x00\x00\x00" +
+	"\x00\x00\x3e\x63\x41\x63\x3e\x63\x41\x41\x63\x3e\x00\x00\x00\x00\x00\x00\x3c\x62\x41\x41\x63\x3d\x01\x03\x46\x3c\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x18\x18\x00\x00\x00\x18\x18\x00\x00\x00\x00\x00\x00\x00\x00\x00\x18\x18\x00\x00\x00\x18\x18\x10\x20\x00\x00" +
+	"\x00\x00\x00\x00\x00\x03\x0e\x70\x70\x0e\x03\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7f\x00\x00\x7f\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x60\x38\x07\x07\x38\x60\x00\x00\x00\x00\x00\x00\x00\x38\x44\x04\x0c\x18\x10\x10\x00\x10\x10\x00\x00\x00\x00" +
+	"\x00\x00\x00\x1e\x33\x61\x47\x49\x49\x49\x47\x20\x30\x1e\x00\x00\x00\x00\x08\x1c\x14\x14\x36\x22\x22\x3e\x63\x41\x00\x00\x00\x00" +
+	"\x00\x00\x7e\x43\x41\x43\x7e\x43\x41\x41\x43\x7e\x00\x00\x00\x00\x00\x00\x1e\x21\x60\x40\x40\x40\x40\x60\x31\x1e\x00\x00\x00\x00" +
+	"\x00\x00\x7c\x46\x43\x41\x41\x41\x41\x43\x46\x7c\x00\x00\x00\x00\x00\x00\x7f\x40\x40\x40\x7f\x40\x40\x40\x40\x7f\x00\x00\x00\x00" +
+	"\x00\x00\x7f\x40\x40\x40\x7f\x40\x40\x40\x40\x40\x00\x00\x00\x00\x00\x00\x1e\x21\x60\x40\x40\x43\x41\x61\x21\x1e\x00\x00\x00\x00" +
+	"\x00\x00\x41\x41\x41\x41\x7f\x41\x41\x41\x41\x41\x00\x00\x00\x00\x00\x00\x7c\x10\x10\x10\x10\x10\x10\x10\x10\x7c\x00\x00\x00\x00" +
+	"\x00\x00\x0e\x02\x02\x02\x02\x02\x02\x02\x66\x3c\x00\x00\x00\x00\x00\x00\x42\x44\x48\x50\x70\x68\x48\x44\x46\x42\x00\x00\x00\x00" +
+	"\x00\x00\x40\x40\x40\x40\x40\x40\x40\x40\x40\x7f\x00\x00\x00\x00\x00\x00\x63\x63\x77\x55\x55\x5d\x49\x41\x41\x41\x00\x00\x00\x00" +
+	"\x00\x00\x61\x61\x51\x51\x49\x49\x45\x45\x43\x43\x00\x00\x00\x00\x00\x00\x1c\x22\x41\x41\x41\x41\x41\x41\x22\x1c\x00\x00\x00\x00" +
+	"\x00\x00\x7e\x43\x41\x41\x43\x7e\x40\x40\x40\x40\x00\x00\x00\x00\x00\x00\x1c\x22\x41\x41\x41\x41\x41\x41\x22\x1e\x06\x02\x00\x00" +
+	"\x00\x00\x7e\x43\x41\x41\x43\x7e\x42\x41\x41\x40\x00\x00\x00\x00\x00\x00\x3e\x61\x40\x40\x30\x0e\x01\x01\x43\x3e\x00\x00\x00\x00" +
+	"\x00\x00\xfe\x10\x10\x10\x10\x10\x10\x10\x10\x10\x00\x00\x00\x00\x00\x00\x41\x41\x41\x41\x41\x41\x41\x41\x63\x3e\x00\x00\x00\x00" +
+	"\x00\x00\x41\x63\x22\x22\x22\x36\x14\x14\x1c\x08\x00\x00\x00\x00\x00\x00\x81\x81\x81\xdb\x5a\x5a\x7e\x66\x66\x66\x00\x00\x00\x00" +
+	"\x00\x00\x63\x22\x36\x1c\x08\x1c\x14\x36\x22\x41\x00\x00\x00\x00\x00\x00\x82\x44\x6c\x28\x38\x10\x10\x10\x10\x10\x00\x00\x00\x00" +
+	"\x00\x00\x7f\x03\x02\x04\x0c\x18\x10\x20\x60\x7f\x00\x00\x00\x00\x00\x1c\x10\x10\x10\x10\x10\x10\x10\x10\x10\x10\x1c\x00\x00\x00" +
+	"\x00\x00\x40\x20\x20\x10\x10\x18\x08\x08\x04\x04\x02\x00\x00\x00\x00\x38\x08\x08\x08\x08\x08\x08\x08\x08\x08\x08\x38\x00\x00\x00" +
+	"\x00\x00\x10\x38\x44\xc6\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\xff\x00" +
+	"\x00\x30\x18\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x1c\x22\x3e\x62\x42\x66\x3a\x00\x00\x00\x00" +
+	"\x00\x40\x40\x40\x40\x7c\x66\x42\x42\x42\x66\x7c\x00\x00\x00\x00\x00\x00\x00\x00\x00\x1c\x62\x40\x40\x40\x22\x1c\x00\x00\x00\x00" +
+	"\x00\x02\x02\x02\x02\x3e\x66\x42\x42\x42\x66\x3a\x00\x00\x00\x00\x00\x00\x00\x00\x00\x3c\x66\x42\x7e\x40\x62\x3c\x00\x00\x00\x00" +
+	"\x00\x0e\x10\x10\x10\x7e\x10\x10\x10\x10\x10\x10\x00\x00\x00\x00\x00\x00\x00\x00\x00\x3e\x66\x42\x42\x42\x66\x3a\x02\x26\x1c\x00" +
+	"\x00\x40\x40\x40\x40\x5c\x66\x42\x42\x42\x42\x42\x00\x00\x00\x00\x00\x08\x08\x00\x00\x38\x08\x08\x08\x08\x08\x3e\x00\x00\x00\x00" +
+	"\x00\x08\x08\x00\x00\x38\x08\x08\x08\x08\x08\x08\x08\x08\x70\x00\x00\x40\x40\x40\x40\x44\x48\x50\x70\x48\x44\x42\x00\x00\x00\x00" +
+	"\x00\x70\x10\x10\x10\x10\x10\x10\x10\x10\x10\x0e\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7e\x49\x49\x49\x49\x49\x49\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x5c\x66\x42\x42\x42\x42\x42\x00\x00\x00\x00\x00\x00\x00\x00\x00\x3c\x66\x42\x42\x42\x66\x3c\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x7c\x66\x42\x42\x42\x66\x7c\x40\x40\x40\x00\x00\x00\x00\x00\x00\x3e\x66\x42\x42\x42\x66\x3a\x02\x02\x02\x00" +
+	"\x00\x00\x00\x00\x00\x3e\x32\x20\x20\x20\x20\x20\x00\x00\x00\x00\x00\x00\x00\x00\x00\x3c\x42\x60\x3c\x02\x42\x3c\x00\x00\x00\x00" +
+	"\x00\x00\x00\x10\x10\x7e\x10\x10\x10\x10\x10\x0e\x00\x00\x00\x00\x00\x00\x00\x00\x00\x42\x42\x42\x42\x42\x66\x3a\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x42\x66\x24\x24\x3c\x18\x18\x00\x00\x00\x00\x00\x00\x00\x00\x00\x81\x81\x5a\x5a\x5a\x24\x24\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x66\x24\x18\x18\x18\x24\x66\x00\x00\x00\x00\x00\x00\x00\x00\x00\x42\x22\x24\x34\x14\x18\x08\x08\x10\x30\x00" +
+	"\x00\x00\x00\x00\x00\x7e\x06\x0c\x18\x30\x60\x7e\x00\x00\x00\x00\x00\x0c\x10\x10\x10\x10\x10\x60\x10\x10\x10\x10\x1c\x00\x00\x00" +
+	"\x00\x10\x10\x10\x10\x10\x10\x10\x10\x10\x10\x10\x10\x10\x00\x00\x00\x60\x10\x10\x10\x10\x10\x0c\x10\x10\x10\x10\x60\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x00\x79\x4f\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x10\x10\x00\x10\x10\x10\x10\x10\x10\x10\x00\x00\x00\x00\x08\x08\x3c\x6a\x48\x48\x48\x6a\x3c\x08\x08\x00\x00" +
+	"\x00\x00\x1c\x34\x20\x20\x20\x78\x20\x20\x20\xfc\x00\x00\x00\x00\x00\x00\x00\x00\x00\x42\x3c\x24\x24\x3c\x42\x00\x00\x00\x00\x00" +
+	"\x00\x00\x82\x44\x44\x28\xfe\x10\xfe\x10\x10\x10\x00\x00\x00\x00\x00\x00\x00\x10\x10\x10\x10\x10\x00\x00\x10\x10\x10\x10\x10\x00" +
+	"\x00\x00\x3c\x40\x60\x38\x4c\x44\x64\x38\x0c\x04\x78\x00\x00\x00\x00\x00\x28\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x3c\x42\x9d\xa1\xa1\xdf\x46\x3c\x00\x00\x00\x00\x00\x00\x00\x3c\x02\x1e\x22\x3e\x00\x3e\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x12\x36\x6c\x6c\x36\x12\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7f\x01\x01\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x00\x00\x38\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x3c\x42\xbd\xa5\xb9\xef\x46\x3c\x00\x00\x00\x00\x00" +
+	"\x00\x00\x3c\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x18\x24\x24\x18\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x10\x10\xfe\x10\x10\x00\xfe\x00\x00\x00\x00\x00\x00\x3c\x04\x08\x10\x3c\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x3c\x04\x18\x04\x3c\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x0c\x18\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x42\x42\x42\x42\x42\x66\x7f\x40\x40\x40\x00\x00\x00\x3f\x7d\x7d\x7d\x3d\x05\x05\x05\x05\x05\x05\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x18\x18\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x18\x08\x38\x00" +
+	"\x00\x00\x18\x08\x08\x08\x1c\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x1c\x22\x22\x22\x1c\x00\x3e\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x48\x6c\x36\x36\x6c\x48\x00\x00\x00\x00\x00\x00\x60\x20\x20\x20\x70\x06\x78\xc4\x04\x04\x1e\x04\x00\x00\x00" +
+	"\x00\x60\x20\x20\x20\x70\x06\x78\xde\x02\x04\x08\x1e\x00\x00\x00\x00\x78\x08\x30\x08\x78\x06\x78\xc4\x04\x04\x1e\x04\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x08\x08\x00\x08\x08\x18\x30\x20\x22\x1c\x00\x10\x08\x08\x1c\x14\x14\x36\x22\x22\x3e\x63\x41\x00\x00\x00\x00" +
+	"\x04\x08\x08\x1c\x14\x14\x36\x22\x22\x3e\x63\x41\x00\x00\x00\x00\x08\x14\x08\x1c\x14\x14\x36\x22\x22\x3e\x63\x41\x00\x00\x00\x00" +
+	"\x3a\x2e\x08\x1c\x14\x14\x36\x22\x22\x3e\x63\x41\x00\x00\x00\x00\x00\x14\x08\x1c\x14\x14\x36\x22\x22\x3e\x63\x41\x00\x00\x00\x00" +
+	"\x1c\x14\x08\x08\x1c\x14\x14\x36\x3e\x22\x63\x41\x00\x00\x00\x00\x00\x00\x3f\x28\x28\x28\x6f\x48\x48\x78\x88\x8f\x00\x00\x00\x00" +
+	"\x00\x00\x1e\x21\x60\x40\x40\x40\x40\x60\x31\x1e\x0c\x04\x1c\x00\x20\x10\x7f\x40\x40\x40\x7f\x40\x40\x40\x40\x7f\x00\x00\x00\x00" +
+	"\x08\x10\x7f\x40\x40\x40\x7f\x40\x40\x40\x40\x7f\x00\x00\x00\x00\x18\x24\x7f\x40\x40\x40\x7f\x40\x40\x40\x40\x7f\x00\x00\x00\x00" +
+	"\x00\x14\x7f\x40\x40\x40\x7f\x40\x40\x40\x40\x7f\x00\x00\x00\x00\x20\x10\x7c\x10\x10\x10\x10\x10\x10\x10\x10\x7c\x00\x00\x00\x00" +
+	"\x08\x10\x7c\x10\x10\x10\x10\x10\x10\x10\x10\x7c\x00\x00\x00\x00\x38\x28\x7c\x10\x10\x10\x10\x10\x10\x10\x10\x7c\x00\x00\x00\x00" +
+	"\x00\x28\x7c\x10\x10\x10\x10\x10\x10\x10\x10\x7c\x00\x00\x00\x00\x00\x00\x7c\x46\x43\x41\xf1\x41\x41\x43\x46\x7c\x00\x00\x00\x00" +
+	"\x3a\x2e\x61\x61\x51\x51\x49\x49\x45\x45\x43\x43\x00\x00\x00\x00\x10\x08\x1c\x22\x41\x41\x41\x41\x41\x41\x22\x1c\x00\x00\x00\x00" +
+	"\x04\x08\x1c\x22\x41\x41\x41\x41\x41\x41\x22\x1c\x00\x00\x00\x00\x08\x14\x1c\x22\x41\x41\x41\x41\x41\x41\x22\x1c\x00\x00\x00\x00" +
+	"\x3a\x2e\x1c\x22\x41\x41\x41\x41\x41\x41\x22\x1c\x00\x00\x00\x00\x00\x14\x1c\x22\x41\x41\x41\x41\x41\x41\x22\x1c\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x42\x24\x18\x18\x24\x42\x00\x00\x00\x00\x00\x00\x00\x1f\x23\x43\x47\x4d\x59\x71\x61\x62\xbc\x00\x00\x00\x00" +
+	"\x10\x08\x41\x41\x41\x41\x41\x41\x41\x41\x63\x3e\x00\x00\x00\x00\x04\x08\x41\x41\x41\x41\x41\x41\x41\x41\x63\x3e\x00\x00\x00\x00" +
+	"\x08\x14\x41\x41\x41\x41\x41\x41\x41\x41\x63\x3e\x00\x00\x00\x00\x00\x14\x41\x41\x41\x41\x41\x41\x41\x41\x63\x3e\x00\x00\x00\x00" +
+	"\x08\x10\x82\x44\x6c\x28\x38\x10\x10\x10\x10\x10\x00\x00\x00\x00\x00\x00\x40\x7e\x43\x41\x41\x43\x7e\x40\x40\x40\x00\x00\x00\x00" +
+	"\x00\x38\x4c\x44\x48\x50\x50\x5c\x46\x42\x42\x5c\x00\x00\x00\x00\x00\x30\x18\x00\x00\x1c\x22\x3e\x62\x42\x66\x3a\x00\x00\x00\x00" +
+	"\x00\x0c\x18\x00\x00\x1c\x22\x3e\x62\x42\x66\x3a\x00\x00\x00\x00\x00\x18\x24\x00\x00\x1c\x22\x3e\x62\x42\x66\x3a\x00\x00\x00\x00" +
+	"\x00\x00\x34\x2c\x00\x1c\x22\x3e\x62\x42\x66\x3a\x00\x00\x00\x00\x00\x00\x28\x00\x00\x1c\x22\x3e\x62\x42\x66\x3a\x00\x00\x00\x00" +
+	"\x18\x24\x24\x18\x00\x1c\x22\x3e\x62\x42\x66\x3a\x00\x00\x00\x00\x00\x00\x00\x00\x00\x6c\x12\x12\x7e\x50\x52\x6e\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x1c\x62\x40\x40\x40\x22\x1c\x0c\x04\x1c\x00\x00\x30\x18\x00\x00\x3c\x66\x42\x7e\x40\x62\x3c\x00\x00\x00\x00" +
+	"\x00\x0c\x18\x00\x00\x3c\x66\x42\x7e\x40\x62\x3c\x00\x00\x00\x00\x00\x18\x24\x00\x00\x3c\x66\x42\x7e\x40\x62\x3c\x00\x00\x00\x00" +
+	"\x00\x00\x48\x00\x00\x3c\x66\x42\x7e\x40\x62\x3c\x00\x00\x00\x00\x00\x30\x18\x00\x00\x38\x08\x08\x08\x08\x08\x3e\x00\x00\x00\x00" +
+	"\x00\x0c\x18\x00\x00\x38\x08\x08\x08\x08\x08\x3e\x00\x00\x00\x00\x00\x30\x48\x00\x00\x38\x08\x08\x08\x08\x08\x3e\x00\x00\x00\x00" +
+	"\x00\x00\x14\x00\x00\x38\x08\x08\x08\x08\x08\x3e\x00\x00\x00\x00\x00\x30\x1c\x38\x04\x3c\x66\x42\x42\x42\x66\x3c\x00\x00\x00\x00" +
+	"\x00\x00\x34\x2c\x00\x5c\x66\x42\x42\x42\x42\x42\x00\x00\x00\x00\x00\x30\x18\x00\x00\x3c\x66\x42\x42\x42\x66\x3c\x00\x00\x00\x00" +
+	"\x00\x0c\x18\x00\x00\x3c\x66\x42\x42\x42\x66\x3c\x00\x00\x00\x00\x00\x18\x24\x00\x00\x3c\x66\x42\x42\x42\x66\x3c\x00\x00\x00\x00" +
+	"\x00\x00\x34\x2c\x00\x3c\x66\x42\x42\x42\x66\x3c\x00\x00\x00\x00\x00\x00\x24\x00\x00\x3c\x66\x42\x42\x42\x66\x3c\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x18\x18\x00\xff\x00\x18\x18\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x3e\x66\x4e\x5a\x72\x66\x7c\x00\x00\x00\x00" +
+	"\x00\x30\x18\x00\x00\x42\x42\x42\x42\x42\x66\x3a\x00\x00\x00\x00\x00\x0c\x18\x00\x00\x42\x42\x42\x42\x42\x66\x3a\x00\x00\x00\x00" +
+	"\x00\x18\x24\x00\x00\x42\x42\x42\x42\x42\x66\x3a\x00\x00\x00\x00\x00\x00\x24\x00\x00\x42\x42\x42\x42\x42\x66\x3a\x00\x00\x00\x00" +
+	"\x00\x0c\x18\x00\x00\x42\x22\x24\x34\x14\x18\x08\x08\x10\x30\x00\x00\x40\x40\x40\x40\x7c\x66\x42\x42\x42\x66\x7c\x40\x40\x40\x00" +
+	"\x00\x00\x28\x00\x00\x42\x22\x24\x34\x14\x18\x08\x08\x10\x30\x00\x00\x00\x00\x00\x00\x00\x00\xff\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\xff\xff\xff\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x00\xdb\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x5a\xdb\x5a\x00\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x00\x00\x18\x18\x18\x00\x00\x18\x18\x18\x00\x00" +
+	"\x18\x18\x18\x18\x00\x18\x18\x18\x18\x18\x00\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x00\xff\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x00\xff\x00\x00\x00\x00\x00\x00\x00\x00\x00\x18\x18\x00\x18\x18\x10\x00\x18\x18\x00\x00\x18\x18\x00\x00" +
+	"\x18\x18\x18\x00\x18\x18\x18\x00\x18\x18\x18\x00\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x00\x1f\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x00\x00\x00\x00\x00\x00\x0f\x1f\x1f\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x00\x1f\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x00\x00\x00\x00\x00\x00\x1f\x1f\x1f\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x00\xf8\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x00\x00\x00\x00\x00\x00\xf0\xf8\xf8\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x00\xf8\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x00\x00\x00\x00\x00\x00\xf8\xf8\xf8\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x1f\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x18\x18\x18\x18\x18\x18\x1f\x1f\x0f\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x18\x18\x18\x18\x1f\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x18\x18\x18\x18\x18\x18\x1f\x1f\x1f\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x18\x18\x18\x18\xf8\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x18\x18\x18\x18\x18\x18\xf8\xf8\xf0\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x18\x18\x18\x18\xf8\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x18\x18\x18\x18\x18\x18\xf8\xf8\xf8\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x18\x18\x18\x18\x1f\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\x1f\x1f\x1f\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x1f\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\x18\x1f\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x1f\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\x1f\x1f\x1f\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x1f\x1f\x1f\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\x1f\x1f\x1f\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\xf8\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\xf8\xf8\xf8\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\xf8\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\x18\xf8\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\xf8\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\xf8\xf8\xf8\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\xf8\xf8\xf8\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\xf8\xf8\xf8\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x00\xff\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x00\x00\x00\x00\x00\x00\xf0\xff\xf8\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x0f\xff\x1f\x18\x18\x18\x18\x18\x18\x18" +
+	"\x00\x00\x00\x00\x00\x00\xff\xff\xff\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x00\xff\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x00\x00\x00\x00\x00\x00\xf8\xff\xf8\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x1f\xff\x1f\x18\x18\x18\x18\x18\x18\x18" +
+	"\x00\x00\x00\x00\x00\x00\xff\xff\xff\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\xff\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x18\x18\x18\x18\x18\x18\xf8\xff\xf0\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x18\x18\x18\x1f\xff\x0f\x00\x00\x00\x00\x00\x00\x00" +
+	"\x18\x18\x18\x18\x18\x18\xff\xff\xff\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x18\x18\x18\x18\xff\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x18\x18\x18\x18\x18\x18\xf8\xff\xf8\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x18\x18\x18\x1f\xff\x1f\x00\x00\x00\x00\x00\x00\x00" +
+	"\x18\x18\x18\x18\x18\x18\xff\xff\xff\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x18\x18\x18\x18\xff\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\xf8\xff\xf8\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x1f\xff\x1f\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\xff\xff\xff\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\xff\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\x18\xff\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\xff\x18\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\xf8\xff\xf8\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x1f\xff\x1f\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\xf8\xff\xf8\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x1f\xff\x1f\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\xff\xff\xff\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\xff\xff\xff\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\xf8\xff\xf8\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x1f\xff\x1f\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\xff\xff\xff\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x00\xff\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x66\xff\x66\x00\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x18\x18\x00\x00\x00\x18\x18\x18\x18\x18\x00\x00" +
+	"\x00\x18\x18\x18\x18\x18\x00\x00\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x00\x00\xff\x00\xff\x00\x00\x00\x00\x00\x00\x00" +
+	"\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x00\x00\x00\x00\x00\x00\x1f\x18\x1f\x18\x18\x18\x18\x18\x18\x18" +
+	"\x00\x00\x00\x00\x00\x00\x00\x3f\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x00\x00\x00\x00\x00\x00\x3f\x30\x3f\x3c\x3c\x3c\x3c\x3c\x3c\x3c" +
+	"\x00\x00\x00\x00\x00\x00\xf8\x18\xf8\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x00\xfc\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c" +
+	"\x00\x00\x00\x00\x00\x00\xfc\x0c\xfc\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x18\x18\x18\x18\x18\x18\x1f\x18\x1f\x00\x00\x00\x00\x00\x00\x00" +
+	"\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3f\x00\x00\x00\x00\x00\x00\x00\x00\x3c\x3c\x3c\x3c\x3c\x3c\x37\x30\x3f\x00\x00\x00\x00\x00\x00\x00" +
+	"\x18\x18\x18\x18\x18\x18\xf8\x18\xf8\x00\x00\x00\x00\x00\x00\x00\x3c\x3c\x3c\x3c\x3c\x3c\x3c\xfc\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x3c\x3c\x3c\x3c\x3c\x3c\xec\x0c\xfc\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x18\x18\x18\x1f\x18\x1f\x18\x18\x18\x18\x18\x18\x18" +
+	"\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3f\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x37\x30\x3f\x3c\x3c\x3c\x3c\x3c\x3c\x3c" +
+	"\x18\x18\x18\x18\x18\x18\xf8\x18\xf8\x18\x18\x18\x18\x18\x18\x18\x3c\x3c\x3c\x3c\x3c\x3c\x3c\xfc\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c" +
+	"\x3c\x3c\x3c\x3c\x3c\x3c\xec\x0c\xfc\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x00\x00\x00\x00\x00\x00\xff\x00\xff\x18\x18\x18\x18\x18\x18\x18" +
+	"\x00\x00\x00\x00\x00\x00\x00\xff\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x00\x00\x00\x00\x00\x00\xff\x00\xff\x3c\x3c\x3c\x3c\x3c\x3c\x3c" +
+	"\x18\x18\x18\x18\x18\x18\xff\x00\xff\x00\x00\x00\x00\x00\x00\x00\x3c\x3c\x3c\x3c\x3c\x3c\x3c\xff\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x3c\x3c\x3c\x3c\x3c\x3c\xe7\x00\xff\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x18\x18\x18\xff\x18\xff\x18\x18\x18\x18\x18\x18\x18" +
+	"\x3c\x3c\x3c\x3c\x3c\x3c\x3c\xff\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\x3c\xe7\x00\xff\x3c\x3c\x3c\x3c\x3c\x3c\x3c" +
+	"\x00\x00\x00\x00\x00\x00\x00\x0f\x08\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x00\xf0\x10\x18\x18\x18\x18\x18\x18\x18" +
+	"\x18\x18\x18\x18\x18\x18\x10\xf0\x00\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x18\x18\x18\x08\x0f\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x01\x03\x02\x06\x04\x0c\x08\x18\x10\x30\x20\x60\x40\xc0\x80\x80\x80\xc0\x40\x60\x20\x30\x10\x18\x08\x0c\x04\x06\x02\x03\x01\x01" +
+	"\x81\xc3\x42\x66\x24\x3c\x18\x18\x18\x3c\x24\x66\x42\xc3\x81\x81\x00\x00\x00\x00\x00\x00\x00\xf0\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x0f\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\xf0\xf0\xf0\x00\x00\x00\x00\x00\x00\x00" +
+	"\x18\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x0f\x0f\x0f\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x00\x18\x18\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\x0f\xff\x0f\x00\x00\x00\x00\x00\x00\x00" +
+	"\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x00\x00\x00\x00\x00\x00\xf0\xff\xf0\x00\x00\x00\x00\x00\x00\x00" +
+	"\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\x18\xff\xff\xff\xff\xff\xff\xff\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\xff\xff\xff\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\xff\xff\xff\xff\xff" +
+	"\x00\x00\x00\x00\x00\x00\x00\x00\x00\xff\xff\xff\xff\xff\xff\xff\x00\x00\x00\x00\x00\x00\x00\xff\xff\xff\xff\xff\xff\xff\xff\xff" +
+	"\x00\x00\x00\x00\x00\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\x00\x00\x00\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff" +
+	"\x00\x00\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff" +
+	"\xfe\xfe\xfe\xfe\xfe\xfe\xfe\xfe\xfe\xfe\xfe\xfe\xfe\xfe\xfe\xfe\xfc\xfc\xfc\xfc\xfc\xfc\xfc\xfc\xfc\xfc\xfc\xfc\xfc\xfc\xfc\xfc" +
+	"\xf8\xf8\xf8\xf8\xf8\xf8\xf8\xf8\xf8\xf8\xf8\xf8\xf8\xf8\xf8\xf8\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0" +
+	"\xe0\xe0\xe0\xe0\xe0\xe0\xe0\xe0\xe0\xe0\xe0\xe0\xe0\xe0\xe0\xe0\xc0\xc0\xc0\xc0\xc0\xc0\xc0\xc0\xc0\xc0\xc0\xc0\xc0\xc0\xc0\xc0" +
+	"\x80\x80\x80\x80\x80\x80\x80\x80\x80\x80\x80\x80\x80\x80\x80\x80\x0f\x0f\x0f\x0f\x0f\x0f\x0f\x0f\x0f\x0f\x0f\x0f\x0f\x0f\x0f\x0f" +
+	"\x88\x00\x22\x00\x88\x00\x22\x00\x88\x00\x22\x00\x88\x00\x22\x22\x96\x69\x69\x96\x6f\x69\x96\xff\x69\x96\xff\x69\x96\xf6\x69\x69" +
+	"\x77\xff\xdd\xff\x77\xff\xdd\xff\x77\xff\xdd\xff\x77\xff\xdd\xdd\xff\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\x01\x01\x01\x01\x01\x01\x01\x01\x01\x01\x01\x01\x01\x01\x01\x01\x00\x00\x00\x00\x00\x00\x00\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0" +
+	"\x00\x00\x00\x00\x00\x00\x00\x0f\x0f\x0f\x0f\x0f\x0f\x0f\x0f\x0f\xf0\xf0\xf0\xf0\xf0\xf0\xf0\x00\x00\x00\x00\x00\x00\x00\x00\x00" +
+	"\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xff\xff\xff\xff\xff\xff\xff\xff\xff\xf0\xf0\xf0\xf0\xf0\xf0\xf0\x0f\x0f\x0f\x0f\x0f\x0f\x0f\x0f\x0f" +
+	"\xff\xff\xff\xff\xff\xff\xff\xff\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xff\xff\xff\xff\xff\xff\xff\xff\x0f\x0f\x0f\x0f\x0f\x0f\x0f\x0f" +
+	"\x0f\x0f\x0f\x0f\x0f\x0f\x0f\x00\x00\x00\x00\x00\x00\x00\x00\x00\x0f\x0f\x0f\x0f\x0f\x0f\x0f\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0\xf0" +
+	"\x0f\x0f\x0f\x0f\x0f\x0f\x0f\xff\xff\xff\xff\xff\xff\xff\xff\xff"
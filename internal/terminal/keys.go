@@ -0,0 +1,196 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import "unicode/utf8"
+
+// Keysyms of modifiers and special keys
+const (
+	keyShiftL  = 0xFFE1
+	keyShiftR  = 0xFFE2
+	keyCtrlL   = 0xFFE3
+	keyCtrlR   = 0xFFE4
+	keyMetaL   = 0xFFE7
+	keyMetaR   = 0xFFE8
+	keyAltL    = 0xFFE9
+	keyAltR    = 0xFFEA
+	keyPageUp  = 0xFF55
+	keyPageDn  = 0xFF56
+	keyUnicode = 0x01000000 // keysyms of unicode characters
+)
+
+// specialKeys are the sequences of keys which do not type characters
+var specialKeys = map[int]string{
+	0xFF09: "\t",       // Tab
+	0xFF0D: "\r",       // Return
+	0xFF1B: "\x1b",     // Escape
+	0xFF50: "\x1b[1~",  // Home
+	0xFF57: "\x1b[4~",  // End
+	0xFF55: "\x1b[5~",  // Page Up
+	0xFF56: "\x1b[6~",  // Page Down
+	0xFF63: "\x1b[2~",  // Insert
+	0xFFFF: "\x1b[3~",  // Delete
+	0xFFBE: "\x1bOP",   // F1
+	0xFFBF: "\x1bOQ",   // F2
+	0xFFC0: "\x1bOR",   // F3
+	0xFFC1: "\x1bOS",   // F4
+	0xFFC2: "\x1b[15~", // F5
+	0xFFC3: "\x1b[17~", // F6
+	0xFFC4: "\x1b[18~", // F7
+	0xFFC5: "\x1b[19~", // F8
+	0xFFC6: "\x1b[20~", // F9
+	0xFFC7: "\x1b[21~", // F10
+	0xFFC8: "\x1b[23~", // F11
+	0xFFC9: "\x1b[24~", // F12
+	0xFF8D: "\r",       // KP Enter
+	0xFFAA: "*",
+	0xFFAB: "+",
+	0xFFAC: ",",
+	0xFFAD: "-",
+	0xFFAE: ".",
+	0xFFAF: "/",
+}
+
+// arrowKeys are the final bytes of the arrow keys
+var arrowKeys = map[int]byte{
+	0xFF51: 'D', // Left
+	0xFF52: 'A', // Up
+	0xFF53: 'C', // Right
+	0xFF54: 'B', // Down
+}
+
+// Key handles a key event of a user and returns the bytes typed to the
+// remote program, if any. Shift+Page Up and Shift+Page Down scroll
+// through the scrollback instead.
+func (t *Terminal) Key(keysym int, pressed bool) []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch keysym {
+	case keyShiftL, keyShiftR:
+		t.mods.shift = pressed
+		return nil
+	case keyCtrlL, keyCtrlR:
+		t.mods.ctrl = pressed
+		return nil
+	case keyAltL, keyAltR, keyMetaL, keyMetaR:
+		t.mods.alt = pressed
+		return nil
+	}
+	if !pressed {
+		return nil
+	}
+	if t.mods.shift && (keysym == keyPageUp || keysym == keyPageDn) {
+		n := t.rows / 2
+		if keysym == keyPageDn {
+			n = -n
+		}
+		t.scrollView(n)
+		return nil
+	}
+
+	b := t.keyBytes(keysym)
+	if b == nil {
+		return nil
+	}
+	if t.view > 0 {
+		t.scrollView(-t.view) // typing returns to the screen
+	}
+	return b
+}
+
+func (t *Terminal) keyBytes(keysym int) []byte {
+	if keysym == 0xFF08 { // BackSpace
+		return []byte{t.opts.Backspace}
+	}
+	if s, ok := specialKeys[keysym]; ok {
+		return []byte(s)
+	}
+	if c, ok := arrowKeys[keysym]; ok {
+		if t.appCursor {
+			return []byte{0x1b, 'O', c}
+		}
+		return []byte{0x1b, '[', c}
+	}
+
+	var r rune
+	switch {
+	case keysym >= 0xFFB0 && keysym <= 0xFFB9: // KP 0-9
+		r = rune('0' + keysym - 0xFFB0)
+	case keysym >= 0x20 && keysym <= 0xFF: // Latin-1
+		r = rune(keysym)
+	case keysym&0xFF000000 == keyUnicode:
+		r = rune(keysym & 0xFFFFFF)
+	default:
+		return nil
+	}
+	if t.mods.ctrl {
+		if c, ok := ctrlChar(r); ok {
+			r = c
+		}
+	}
+	b := make([]byte, 0, utf8.UTFMax+1)
+	if t.mods.alt {
+		b = append(b, 0x1b)
+	}
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	return append(b, buf[:n]...)
+}
+
+// ctrlChar returns the control character typed by a character while
+// control is held.
+func ctrlChar(r rune) (rune, bool) {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return r - 'a' + 1, true
+	case r >= '@' && r <= '_':
+		return r - '@', true
+	case r == ' ' || r == '2':
+		return 0, true
+	case r >= '3' && r <= '7':
+		return r - '3' + 0x1b, true
+	case r == '8' || r == '?':
+		return 0x7f, true
+	case r == '/':
+		return 0x1f, true
+	}
+	return 0, false
+}
+
+// Mouse handles a mouse event of a user, the scroll wheel scrolls
+// through the scrollback.
+func (t *Terminal) Mouse(x, y, mask int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pressed := mask &^ t.mask
+	t.mask = mask
+	switch {
+	case pressed&maskScrollUp != 0:
+		t.scrollView(wheelLines)
+	case pressed&maskScrollDown != 0:
+		t.scrollView(-wheelLines)
+	}
+}
+
+// Mouse button masks of the scroll wheel
+const (
+	maskScrollUp   = 8
+	maskScrollDown = 16
+)
+
+// wheelLines is the number of lines scrolled by the scroll wheel
+const wheelLines = 3
+
+// scrollView scrolls the view n lines back into the scrollback, or
+// forward if n is negative.
+func (t *Terminal) scrollView(n int) {
+	view := clamp(t.view+n, 0, len(t.scrollback))
+	if view == t.view {
+		return
+	}
+	t.view = view
+	t.markAll()
+	t.render()
+}
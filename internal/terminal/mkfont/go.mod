@@ -0,0 +1,8 @@
+module changkun.de/x/occamy/internal/terminal/mkfont
+
+go 1.15
+
+require (
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
+	golang.org/x/image v0.0.0-20210628002857-a66eb6448b8d
+)
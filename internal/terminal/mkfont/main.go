@@ -0,0 +1,135 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// mkfont rasterizes a monospace TrueType font into the 1-bit glyphs
+// of the built-in terminal font.
+//
+// It is a module of its own with the vendored font rasterizer, which
+// the terminal does not depend on. Run it from its directory:
+//
+//	go run . -font /usr/share/fonts/truetype/dejavu/DejaVuSansMono.ttf -o ../fontdata.go
+//
+// or by go generate in the terminal package.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"image"
+	"image/draw"
+	"io/ioutil"
+	"log"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	cellWidth  = 8
+	cellHeight = 16
+	baseline   = 12
+)
+
+// ranges are the rune ranges of the built-in font
+var ranges = [][2]rune{
+	{0x20, 0x7e},     // ASCII
+	{0xa0, 0xff},     // Latin-1
+	{0x2500, 0x257f}, // box drawing
+	{0x2580, 0x259f}, // block elements
+}
+
+func main() {
+	path := flag.String("font", "/usr/share/fonts/truetype/dejavu/DejaVuSansMono.ttf", "path to a monospace TrueType font")
+	out := flag.String("o", "fontdata.go", "output file")
+	flag.Parse()
+
+	raw, err := ioutil.ReadFile(*path)
+	if err != nil {
+		log.Fatalf("read font: %v", err)
+	}
+	f, err := truetype.Parse(raw)
+	if err != nil {
+		log.Fatalf("parse font: %v", err)
+	}
+	// the size in pixels such that the advance is the cell width
+	advance := float64(f.HMetric(fixed.Int26_6(f.FUnitsPerEm()), f.Index('M')).AdvanceWidth)
+	size := cellWidth * float64(f.FUnitsPerEm()) / advance
+	face := truetype.NewFace(f, &truetype.Options{Size: size, DPI: 72, Hinting: font.HintingFull})
+
+	var (
+		runes []rune
+		data  bytes.Buffer
+	)
+	for _, r := range ranges {
+		for c := r[0]; c <= r[1]; c++ {
+			if f.Index(c) == 0 {
+				continue
+			}
+			cell := image.NewAlpha(image.Rect(0, 0, cellWidth, cellHeight))
+			dr, mask, mp, _, ok := face.Glyph(fixed.P(0, baseline), c)
+			if !ok {
+				continue
+			}
+			draw.DrawMask(cell, dr, image.Opaque, image.Point{}, mask, mp, draw.Over)
+			rows := make([]byte, cellHeight)
+			for y := range rows {
+				for x := 0; x < cellWidth; x++ {
+					if cell.AlphaAt(x, y).A >= 0x70 {
+						rows[y] |= 0x80 >> uint(x)
+					}
+				}
+			}
+			// box drawing and block elements connect to the next row
+			if c >= 0x2500 && rows[cellHeight-1] == 0 {
+				rows[cellHeight-1] = rows[cellHeight-2]
+			}
+			data.Write(rows)
+			runes = append(runes, c)
+		}
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by mkfont.go; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&src, "// The glyphs are rasterized from DejaVu Sans Mono, see\n")
+	fmt.Fprintf(&src, "// https://dejavu-fonts.github.io/License.html\n\n")
+	fmt.Fprintf(&src, "package terminal\n\n")
+	fmt.Fprintf(&src, "// glyphRunes are the runes of the built-in font in order of glyphData\n")
+	fmt.Fprintf(&src, "var glyphRunes = []rune{")
+	for i, c := range runes {
+		if i%12 == 0 {
+			fmt.Fprintf(&src, "\n")
+		}
+		fmt.Fprintf(&src, "%#04x, ", c)
+	}
+	fmt.Fprintf(&src, "\n}\n\n")
+	fmt.Fprintf(&src, "// glyphData are the %d rows of 8 pixels of each glyph\n", cellHeight)
+	fmt.Fprintf(&src, "var glyphData = \"\" +\n")
+	b := data.Bytes()
+	for i := 0; i < len(b); i += cellHeight * 2 {
+		end := i + cellHeight*2
+		if end > len(b) {
+			end = len(b)
+		}
+		fmt.Fprintf(&src, "\t\"")
+		for _, v := range b[i:end] {
+			fmt.Fprintf(&src, "\\x%02x", v)
+		}
+		if end == len(b) {
+			fmt.Fprintf(&src, "\"\n")
+		} else {
+			fmt.Fprintf(&src, "\" +\n")
+		}
+	}
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		log.Fatalf("format: %v", err)
+	}
+	err = ioutil.WriteFile(*out, formatted, 0644)
+	if err != nil {
+		log.Fatalf("write: %v", err)
+	}
+}
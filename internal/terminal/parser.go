@@ -0,0 +1,468 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// parserState is the state of the escape sequence parser
+type parserState int
+
+const (
+	stateGround parserState = iota
+	stateEscape
+	stateCharset // ESC ( or ESC ), the charset follows
+	stateCSI
+	stateOSC
+	stateOSCEscape // ESC within OSC, the string terminator follows
+	stateIgnore    // ESC # and other sequences with one final byte
+)
+
+// maxParams limits the parameters of a control sequence
+const maxParams = 16
+
+// maxOSC limits the length of an operating system command
+const maxOSC = 4096
+
+// parser decodes the output of the remote program into characters and
+// control sequences, which are applied to the terminal.
+type parser struct {
+	state   parserState
+	params  []int
+	cur     int
+	hasCur  bool
+	private byte // private marker of CSI, e.g. ?
+	inter   byte // intermediate byte
+	osc     []byte
+	utf8    [utf8.UTFMax]byte
+	n       int // buffered bytes of a UTF-8 sequence
+}
+
+func (p *parser) feed(t *Terminal, b byte) {
+	switch p.state {
+	case stateGround:
+		p.ground(t, b)
+	case stateEscape:
+		p.escape(t, b)
+	case stateCharset:
+		g := 0
+		if p.inter == ')' {
+			g = 1
+		}
+		t.charset[g] = b == '0'
+		p.state = stateGround
+	case stateCSI:
+		p.csiByte(t, b)
+	case stateOSC:
+		switch b {
+		case 0x07:
+			p.oscEnd(t)
+		case 0x1b:
+			p.state = stateOSCEscape
+		default:
+			if len(p.osc) < maxOSC {
+				p.osc = append(p.osc, b)
+			}
+		}
+	case stateOSCEscape:
+		p.oscEnd(t)
+		if b != '\\' {
+			p.escapeStart()
+			p.escape(t, b)
+		}
+	case stateIgnore:
+		p.state = stateGround
+	}
+}
+
+func (p *parser) ground(t *Terminal, b byte) {
+	if b < 0x20 || b == 0x7f {
+		p.n = 0
+		p.control(t, b)
+		return
+	}
+	if b < 0x80 && p.n == 0 {
+		t.put(rune(b))
+		return
+	}
+
+	// UTF-8 sequence
+	if b&0xC0 != 0x80 {
+		p.n = 0 // a new sequence starts
+	}
+	if p.n == len(p.utf8) {
+		p.n = 0
+	}
+	p.utf8[p.n] = b
+	p.n++
+	if utf8.FullRune(p.utf8[:p.n]) {
+		r, _ := utf8.DecodeRune(p.utf8[:p.n])
+		p.n = 0
+		t.put(r)
+	}
+}
+
+// control applies a C0 control character.
+func (p *parser) control(t *Terminal, b byte) {
+	switch b {
+	case 0x08: // BS
+		if t.x > 0 {
+			t.x--
+		}
+		t.wrapNext = false
+	case 0x09: // HT
+		t.moveTo((t.x/8+1)*8, t.y)
+	case 0x0a, 0x0b, 0x0c: // LF, VT, FF
+		t.lineFeed()
+		t.wrapNext = false
+	case 0x0d: // CR
+		t.x, t.wrapNext = 0, false
+	case 0x0e: // SO
+		t.shift = 1
+	case 0x0f: // SI
+		t.shift = 0
+	case 0x1b:
+		p.escapeStart()
+	}
+}
+
+func (p *parser) escapeStart() {
+	p.state = stateEscape
+	p.inter = 0
+}
+
+func (p *parser) escape(t *Terminal, b byte) {
+	p.state = stateGround
+	switch b {
+	case '[':
+		p.state = stateCSI
+		p.params, p.cur, p.hasCur, p.private, p.inter = p.params[:0], 0, false, 0, 0
+	case ']':
+		p.state = stateOSC
+		p.osc = p.osc[:0]
+	case '(', ')':
+		p.state, p.inter = stateCharset, b
+	case '#', '%', ' ':
+		p.state = stateIgnore
+	case '7':
+		t.saveCursor()
+	case '8':
+		t.restoreCursor()
+	case 'D': // IND
+		t.lineFeed()
+	case 'E': // NEL
+		t.x = 0
+		t.lineFeed()
+	case 'M': // RI
+		t.reverseIndex()
+	case 'c': // RIS
+		t.reset()
+	case 0x18, 0x1a: // CAN, SUB
+	case 0x1b:
+		p.escapeStart()
+	}
+}
+
+func (p *parser) csiByte(t *Terminal, b byte) {
+	switch {
+	case b >= '0' && b <= '9':
+		p.cur = p.cur*10 + int(b-'0')
+		if p.cur > 65535 {
+			p.cur = 65535
+		}
+		p.hasCur = true
+	case b == ';' || b == ':':
+		p.pushParam()
+	case b >= '<' && b <= '?':
+		p.private = b
+	case b >= 0x20 && b <= 0x2f:
+		p.inter = b
+	case b >= 0x40 && b <= 0x7e:
+		p.pushParam()
+		p.state = stateGround
+		if p.inter == 0 {
+			p.csi(t, b)
+		}
+	case b == 0x1b:
+		p.escapeStart()
+	case b < 0x20:
+		p.control(t, b) // controls are executed within sequences
+	}
+}
+
+func (p *parser) pushParam() {
+	if len(p.params) < maxParams {
+		v := p.cur
+		if !p.hasCur {
+			v = -1 // omitted
+		}
+		p.params = append(p.params, v)
+	}
+	p.cur, p.hasCur = 0, false
+}
+
+// param returns the i-th parameter, or def if it is omitted or zero.
+func (p *parser) param(i, def int) int {
+	if i >= len(p.params) || p.params[i] <= 0 {
+		return def
+	}
+	return p.params[i]
+}
+
+// csi applies a control sequence.
+func (p *parser) csi(t *Terminal, final byte) {
+	if p.private == '?' {
+		switch final {
+		case 'h':
+			p.privateModes(t, true)
+		case 'l':
+			p.privateModes(t, false)
+		}
+		return
+	}
+	if p.private != 0 {
+		return // e.g. secondary device attributes
+	}
+
+	n := p.param(0, 1)
+	switch final {
+	case '@': // ICH
+		l := t.lines[t.y]
+		n = clamp(n, 0, t.cols-t.x)
+		copy(l[t.x+n:], l[t.x:])
+		t.erase(t.y, t.x, t.x+n)
+	case 'A': // CUU, stops at the top of the scroll region
+		top := 0
+		if t.y >= t.top {
+			top = t.top
+		}
+		t.moveTo(t.x, clamp(t.y-n, top, t.rows-1))
+	case 'B': // CUD, stops at the bottom of the scroll region
+		bottom := t.rows - 1
+		if t.y <= t.bottom {
+			bottom = t.bottom
+		}
+		t.moveTo(t.x, clamp(t.y+n, 0, bottom))
+	case 'C', 'a': // CUF, HPR
+		t.moveTo(t.x+n, t.y)
+	case 'D': // CUB
+		t.moveTo(t.x-n, t.y)
+	case 'E': // CNL
+		t.moveTo(0, t.y+n)
+	case 'F': // CPL
+		t.moveTo(0, t.y-n)
+	case 'G', '`': // CHA, HPA
+		t.moveTo(n-1, t.y)
+	case 'H', 'f': // CUP, HVP
+		t.moveTo(p.param(1, 1)-1, n-1)
+	case 'd': // VPA
+		t.moveTo(t.x, n-1)
+	case 'e': // VPR
+		t.moveTo(t.x, t.y+n)
+	case 'J': // ED
+		switch p.param(0, 0) {
+		case 0:
+			t.erase(t.y, t.x, t.cols)
+			for y := t.y + 1; y < t.rows; y++ {
+				t.erase(y, 0, t.cols)
+			}
+		case 1:
+			t.erase(t.y, 0, t.x+1)
+			for y := 0; y < t.y; y++ {
+				t.erase(y, 0, t.cols)
+			}
+		case 2, 3:
+			for y := 0; y < t.rows; y++ {
+				t.erase(y, 0, t.cols)
+			}
+		}
+	case 'K': // EL
+		switch p.param(0, 0) {
+		case 0:
+			t.erase(t.y, t.x, t.cols)
+		case 1:
+			t.erase(t.y, 0, t.x+1)
+		case 2:
+			t.erase(t.y, 0, t.cols)
+		}
+	case 'L': // IL
+		if t.y >= t.top && t.y <= t.bottom {
+			t.scrollDown(t.y, t.bottom, n)
+			t.x = 0
+		}
+	case 'M': // DL
+		if t.y >= t.top && t.y <= t.bottom {
+			t.scroll(t.y, t.bottom, n, false)
+			t.x = 0
+		}
+	case 'P': // DCH
+		l := t.lines[t.y]
+		n = clamp(n, 0, t.cols-t.x)
+		copy(l[t.x:], l[t.x+n:])
+		t.erase(t.y, t.cols-n, t.cols)
+	case 'S': // SU
+		t.scroll(t.top, t.bottom, n, false)
+	case 'T': // SD
+		t.scrollDown(t.top, t.bottom, n)
+	case 'X': // ECH
+		t.erase(t.y, t.x, t.x+n)
+	case 'c': // DA
+		if p.param(0, 0) == 0 {
+			t.reply("\x1b[?1;2c")
+		}
+	case 'h':
+		p.modes(t, true)
+	case 'l':
+		p.modes(t, false)
+	case 'm':
+		p.sgr(t)
+	case 'n': // DSR
+		switch p.param(0, 0) {
+		case 5:
+			t.reply("\x1b[0n")
+		case 6:
+			t.reply("\x1b[" + strconv.Itoa(t.y+1) + ";" + strconv.Itoa(t.x+1) + "R")
+		}
+	case 'r': // DECSTBM
+		top, bottom := p.param(0, 1)-1, p.param(1, t.rows)-1
+		if top < bottom && bottom < t.rows {
+			t.top, t.bottom = top, bottom
+			t.moveTo(0, 0)
+		}
+	case 's':
+		t.saveCursor()
+	case 'u':
+		t.restoreCursor()
+	}
+}
+
+func (p *parser) modes(t *Terminal, set bool) {
+	for _, m := range p.params {
+		if m == 4 { // IRM
+			t.insert = set
+		}
+	}
+}
+
+func (p *parser) privateModes(t *Terminal, set bool) {
+	for _, m := range p.params {
+		switch m {
+		case 1: // DECCKM
+			t.appCursor = set
+		case 7: // DECAWM
+			t.autowrap = set
+		case 25: // DECTCEM
+			t.cursorVisible = set
+			t.mark(t.y)
+		case 47, 1047:
+			t.useAlt(set)
+		case 1048:
+			if set {
+				t.saveCursor()
+			} else {
+				t.restoreCursor()
+			}
+		case 1049:
+			if set {
+				t.saveCursor()
+				t.useAlt(true)
+			} else {
+				t.useAlt(false)
+				t.restoreCursor()
+			}
+		}
+	}
+}
+
+// sgr applies select graphic rendition parameters.
+func (p *parser) sgr(t *Terminal) {
+	if len(p.params) == 0 {
+		t.attr = defaultAttr
+		return
+	}
+	for i := 0; i < len(p.params); i++ {
+		v := p.params[i]
+		switch {
+		case v <= 0:
+			t.attr = defaultAttr
+		case v == 1:
+			t.attr.Bold = true
+		case v == 4:
+			t.attr.Underline = true
+		case v == 7:
+			t.attr.Reverse = true
+		case v == 22:
+			t.attr.Bold = false
+		case v == 24:
+			t.attr.Underline = false
+		case v == 27:
+			t.attr.Reverse = false
+		case v >= 30 && v <= 37:
+			t.attr.FG = Color(v - 30)
+		case v == 38:
+			t.attr.FG, i = p.extendedColor(i, t.attr.FG)
+		case v == 39:
+			t.attr.FG = ColorDefault
+		case v >= 40 && v <= 47:
+			t.attr.BG = Color(v - 40)
+		case v == 48:
+			t.attr.BG, i = p.extendedColor(i, t.attr.BG)
+		case v == 49:
+			t.attr.BG = ColorDefault
+		case v >= 90 && v <= 97:
+			t.attr.FG = Color(v - 90 + 8)
+		case v >= 100 && v <= 107:
+			t.attr.BG = Color(v - 100 + 8)
+		}
+	}
+}
+
+// extendedColor parses a 256 color or an RGB color following the
+// parameter i, it returns the color and the index of its last parameter.
+func (p *parser) extendedColor(i int, c Color) (Color, int) {
+	if i+1 >= len(p.params) {
+		return c, i
+	}
+	switch p.params[i+1] {
+	case 5:
+		if i+2 < len(p.params) {
+			return Color(clamp(p.params[i+2], 0, 255)), i + 2
+		}
+	case 2:
+		if i+4 < len(p.params) {
+			ch := func(v int) uint8 { return uint8(clamp(v, 0, 255)) }
+			return RGB(ch(p.params[i+2]), ch(p.params[i+3]), ch(p.params[i+4])), i + 4
+		}
+	}
+	return c, len(p.params)
+}
+
+// oscEnd applies an operating system command, only the window title is
+// supported.
+func (p *parser) oscEnd(t *Terminal) {
+	p.state = stateGround
+	s := string(p.osc)
+	for i := 0; i < len(s); i++ {
+		if s[i] != ';' {
+			continue
+		}
+		switch s[:i] {
+		case "0", "2":
+			t.title = s[i+1:]
+		}
+		return
+	}
+}
+
+// decGraphics maps a character to the DEC special graphics charset,
+// which draws lines and boxes.
+func decGraphics(r rune) rune {
+	if r < 0x5f || r > 0x7e {
+		return r
+	}
+	return []rune(" ◆▒␉␌␍␊°±␤␋┘┐┌└┼⎺⎻─⎼⎽├┤┴┬│≤≥π≠£·")[r-0x5f]
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"image"
+	"image/color"
+)
+
+// lineAt returns the line shown at the given row of the screen, which
+// is a scrollback line if the view is scrolled back.
+func (t *Terminal) lineAt(row int) line {
+	if row < t.view {
+		return t.scrollback[len(t.scrollback)-t.view+row]
+	}
+	return t.lines[row-t.view]
+}
+
+// render draws the changed rows of the screen and flushes the display.
+func (t *Terminal) render() {
+	changed := false
+	if t.pending.n > 0 && t.view == 0 {
+		top, bottom, n := t.pending.top, t.pending.bottom, t.pending.n
+		if n <= bottom-top {
+			t.d.Copy(image.Rect(0, (top+n)*CellHeight, t.cols*CellWidth, (bottom+1)*CellHeight),
+				image.Pt(0, top*CellHeight))
+			changed = true
+		}
+	}
+	if t.view > 0 {
+		// rows of the screen are shown shifted, any change redraws all
+		for _, dirty := range t.dirty {
+			if dirty {
+				t.markAll()
+				break
+			}
+		}
+	}
+
+	// the cursor is drawn as a reversed cell
+	t.mark(t.cursorRow)
+	t.cursorRow = -1
+	if t.cursorVisible && t.y+t.view < t.rows {
+		t.cursorRow = t.y + t.view
+		t.mark(t.cursorRow)
+	}
+
+	for row, dirty := range t.dirty {
+		if !dirty {
+			continue
+		}
+		t.dirty[row] = false
+		cx := -1
+		if row == t.cursorRow {
+			cx = t.x
+		}
+		t.d.Draw(0, row*CellHeight, t.drawLine(t.lineAt(row), cx))
+		changed = true
+	}
+	t.pending = scrollOp{}
+	if changed {
+		t.d.Flush()
+	}
+}
+
+// drawLine draws a line, the cell at column cx is drawn as the cursor.
+func (t *Terminal) drawLine(l line, cx int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, len(l)*CellWidth, CellHeight))
+	for x, c := range l {
+		fg, bg := t.colors(c.a)
+		if x == cx {
+			fg, bg = bg, fg
+		}
+		g := glyph(c.r)
+		for y := 0; y < CellHeight; y++ {
+			bits := g[y]
+			if c.a.Underline && y == CellHeight-2 {
+				bits = 0xFF
+			}
+			off := img.PixOffset(x*CellWidth, y)
+			for i := 0; i < CellWidth; i++ {
+				p := bg
+				if bits&(0x80>>uint(i)) != 0 {
+					p = fg
+				}
+				img.Pix[off+0], img.Pix[off+1], img.Pix[off+2], img.Pix[off+3] = p.R, p.G, p.B, p.A
+				off += 4
+			}
+		}
+	}
+	return img
+}
+
+// colors resolves the foreground and background colors of a cell, bold
+// text uses the bright variant of the basic colors.
+func (t *Terminal) colors(a Attr) (fg, bg color.RGBA) {
+	f, b := a.FG, a.BG
+	if f == ColorDefault {
+		f = t.opts.Scheme.Foreground
+	}
+	if b == ColorDefault {
+		b = t.opts.Scheme.Background
+	}
+	if a.Bold && f >= 0 && f < 8 {
+		f += 8
+	}
+	if a.Reverse {
+		f, b = b, f
+	}
+	return f.rgba(f), b.rgba(b)
+}
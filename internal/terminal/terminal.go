@@ -0,0 +1,492 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package terminal implements an xterm-compatible terminal emulator for
+// the text protocols of Go protocol plugins. The terminal interprets the
+// output of the remote program, keeps the screen and its scrollback,
+// and renders changed rows to the display of the session.
+package terminal
+
+import (
+	"image"
+	"strings"
+	"sync"
+	"unicode"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+)
+
+// DefaultScrollback is the default number of lines of the scrollback
+const DefaultScrollback = 1000
+
+// Options are the options of a terminal
+type Options struct {
+	// Scheme is the color scheme, gray-black by default.
+	Scheme *Scheme
+	// Scrollback is the maximum number of lines kept after they scrolled
+	// off the screen, DefaultScrollback by default.
+	Scrollback int
+	// Backspace is the byte sent by the backspace key, 127 by default.
+	Backspace byte
+	// Typescript records all output of the remote program, if not nil.
+	Typescript *Typescript
+	// Reply sends responses of the terminal to the remote program, e.g.
+	// the cursor position requested by a device status report. Users in
+	// the vt mode respond by their own terminals, hence responses are
+	// not sent while such users are attached.
+	Reply func(p []byte)
+}
+
+// Attr are the display attributes of a character
+type Attr struct {
+	FG, BG    Color
+	Bold      bool
+	Underline bool
+	Reverse   bool
+}
+
+var defaultAttr = Attr{FG: ColorDefault, BG: ColorDefault}
+
+// cell is a character of the screen
+type cell struct {
+	r rune
+	a Attr
+}
+
+// line is a row of characters
+type line []cell
+
+// cursor is a saved cursor position and its attributes
+type cursor struct {
+	x, y    int
+	attr    Attr
+	charset [2]bool
+}
+
+// scrollOp is a pending scroll of the rendered display, rows top to
+// bottom are scrolled up by n rows. A negative n invalidates the scroll
+// of the current frame.
+type scrollOp struct {
+	top, bottom, n int
+}
+
+// Terminal is a terminal emulator that renders to a display.
+type Terminal struct {
+	mu   sync.Mutex
+	d    *plugin.Display
+	opts Options
+
+	width, height int // size of the display in pixels
+	cols, rows    int
+
+	lines      []line // the active screen
+	other      []line // the inactive screen, the main screen if alt is set
+	alt        bool
+	scrollback []line
+	view       int // number of scrollback lines shown above the screen
+
+	x, y     int
+	wrapNext bool // the next character wraps to the next line
+	attr     Attr
+	saved    cursor
+	top      int // scroll region
+	bottom   int
+
+	autowrap      bool
+	cursorVisible bool
+	appCursor     bool
+	insert        bool
+	charset       [2]bool // G0 and G1 are DEC special graphics
+	shift         int     // the active charset, G0 or G1
+	title         string
+
+	p         parser
+	dirty     []bool
+	pending   scrollOp
+	cursorRow int // the row of the rendered cursor, or -1
+	mods      modifiers
+	mask      int                       // the last mouse button mask
+	vt        map[*plugin.User]struct{} // users in the vt mode
+}
+
+// modifiers are the pressed modifier keys
+type modifiers struct {
+	shift, ctrl, alt bool
+}
+
+// New creates a terminal which fills a display of the given size.
+func New(d *plugin.Display, width, height int, opts Options) *Terminal {
+	if opts.Scheme == nil {
+		s := SchemeByName("")
+		opts.Scheme = &s
+	}
+	if opts.Scrollback <= 0 {
+		opts.Scrollback = DefaultScrollback
+	}
+	if opts.Backspace == 0 {
+		opts.Backspace = 0x7f
+	}
+	t := &Terminal{d: d, opts: opts, cursorRow: -1}
+	t.reset()
+	t.resize(width, height)
+	t.render()
+	return t
+}
+
+// reset resets the terminal to its initial state, the size is kept.
+func (t *Terminal) reset() {
+	t.alt = false
+	t.other = nil
+	t.x, t.y, t.wrapNext = 0, 0, false
+	t.attr = defaultAttr
+	t.saved = cursor{attr: defaultAttr}
+	t.autowrap, t.cursorVisible, t.appCursor, t.insert = true, true, false, false
+	t.charset, t.shift = [2]bool{}, 0
+	t.lines = t.blankLines(t.rows)
+	t.top, t.bottom = 0, t.rows-1
+	t.markAll()
+}
+
+// Size returns the number of columns and rows of the terminal.
+func (t *Terminal) Size() (cols, rows int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.cols, t.rows
+}
+
+// Title returns the window title set by the remote program.
+func (t *Terminal) Title() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.title
+}
+
+// Text returns the characters of the given row of the screen, without
+// trailing blanks.
+func (t *Terminal) Text(row int) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if row < 0 || row >= t.rows {
+		return ""
+	}
+	l := t.lines[row]
+	rs := make([]rune, len(l))
+	for i, c := range l {
+		rs[i] = c.r
+	}
+	return strings.TrimRight(string(rs), " ")
+}
+
+// Cursor returns the position of the cursor.
+func (t *Terminal) Cursor() (x, y int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.x, t.y
+}
+
+// Write interprets the output of the remote program and renders the
+// changes to the display.
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.opts.Typescript != nil {
+		t.opts.Typescript.Write(p)
+	}
+	for u := range t.vt {
+		sendVT(u, p)
+	}
+	for _, b := range p {
+		t.p.feed(t, b)
+	}
+	t.render()
+	return len(p), nil
+}
+
+// Resize resizes the terminal to fill a display of the given size, and
+// returns the new number of columns and rows.
+func (t *Terminal) Resize(width, height int) (cols, rows int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resize(width, height)
+	t.render()
+	for u := range t.vt {
+		u.Send(&protocol.LayerSize{Layer: 0, Width: t.cols, Height: t.rows})
+	}
+	return t.cols, t.rows
+}
+
+// GridSize returns the number of columns and rows of a terminal which
+// fills a display of the given size.
+func GridSize(width, height int) (cols, rows int) {
+	cols, rows = width/CellWidth, height/CellHeight
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	return cols, rows
+}
+
+func (t *Terminal) resize(width, height int) {
+	cols, rows := GridSize(width, height)
+	t.width, t.height = width, height
+
+	// keep the cursor on the screen by scrolling lines off the top
+	if off := t.y + 1 - rows; off > 0 {
+		if !t.alt {
+			t.pushScrollback(t.lines[:off])
+		}
+		t.lines = t.lines[off:]
+		t.y -= off
+	}
+	t.cols, t.rows = cols, rows
+	t.lines = t.fit(t.lines)
+	if t.other != nil {
+		t.other = t.fit(t.other)
+	}
+	for i := range t.scrollback {
+		t.scrollback[i] = t.fitLine(t.scrollback[i])
+	}
+	t.x, t.y = clamp(t.x, 0, cols-1), clamp(t.y, 0, rows-1)
+	t.saved.x, t.saved.y = clamp(t.saved.x, 0, cols-1), clamp(t.saved.y, 0, rows-1)
+	t.wrapNext = false
+	t.top, t.bottom = 0, rows-1
+	t.view = clamp(t.view, 0, len(t.scrollback))
+
+	t.d.Resize(width, height)
+	t.d.Fill(image.Rect(0, 0, width, height), t.opts.Scheme.Background.rgba(0))
+	t.dirty = make([]bool, rows)
+	t.markAll()
+}
+
+// fit fits the given lines to the size of the terminal.
+func (t *Terminal) fit(lines []line) []line {
+	if len(lines) > t.rows {
+		lines = lines[:t.rows]
+	}
+	for i := range lines {
+		lines[i] = t.fitLine(lines[i])
+	}
+	return append(lines, t.blankLines(t.rows-len(lines))...)
+}
+
+func (t *Terminal) fitLine(l line) line {
+	if len(l) >= t.cols {
+		return l[:t.cols]
+	}
+	for len(l) < t.cols {
+		l = append(l, cell{' ', defaultAttr})
+	}
+	return l
+}
+
+func (t *Terminal) blankLine() line {
+	l := make(line, t.cols)
+	for i := range l {
+		l[i] = t.blank()
+	}
+	return l
+}
+
+func (t *Terminal) blankLines(n int) []line {
+	lines := make([]line, 0, n)
+	for i := 0; i < n; i++ {
+		lines = append(lines, t.blankLine())
+	}
+	return lines
+}
+
+// blank is an erased cell, which keeps the current background
+func (t *Terminal) blank() cell {
+	return cell{' ', Attr{FG: ColorDefault, BG: t.attr.BG}}
+}
+
+func (t *Terminal) markAll() {
+	for i := range t.dirty {
+		t.dirty[i] = true
+	}
+	t.pending.n = -1
+}
+
+func (t *Terminal) mark(y int) {
+	if y >= 0 && y < len(t.dirty) {
+		t.dirty[y] = true
+	}
+}
+
+func (t *Terminal) pushScrollback(lines []line) {
+	for _, l := range lines {
+		t.scrollback = append(t.scrollback, append(line(nil), l...))
+	}
+	if over := len(t.scrollback) - t.opts.Scrollback; over > 0 {
+		t.scrollback = append(t.scrollback[:0], t.scrollback[over:]...)
+	}
+}
+
+// put prints a character at the cursor.
+func (t *Terminal) put(r rune) {
+	if unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) {
+		return // combining characters are not supported
+	}
+	if t.charset[t.shift] {
+		r = decGraphics(r)
+	}
+	if t.wrapNext {
+		t.wrapNext = false
+		if t.autowrap {
+			t.x = 0
+			t.lineFeed()
+		}
+	}
+	l := t.lines[t.y]
+	if t.insert {
+		copy(l[t.x+1:], l[t.x:])
+	}
+	l[t.x] = cell{r, t.attr}
+	t.mark(t.y)
+	if t.x == t.cols-1 {
+		t.wrapNext = true
+	} else {
+		t.x++
+	}
+}
+
+// lineFeed moves the cursor down, the scroll region is scrolled if the
+// cursor is at its bottom.
+func (t *Terminal) lineFeed() {
+	switch {
+	case t.y == t.bottom:
+		t.scroll(t.top, t.bottom, 1, true)
+	case t.y < t.rows-1:
+		t.y++
+	}
+}
+
+// reverseIndex moves the cursor up, the scroll region is scrolled if
+// the cursor is at its top.
+func (t *Terminal) reverseIndex() {
+	switch {
+	case t.y == t.top:
+		t.scrollDown(t.top, t.bottom, 1)
+	case t.y > 0:
+		t.y--
+	}
+}
+
+// scroll scrolls rows top to bottom up by n rows. If save is set, the
+// lines which scroll off the top of the main screen are kept in the
+// scrollback.
+func (t *Terminal) scroll(top, bottom, n int, save bool) {
+	height := bottom - top + 1
+	if n > height {
+		n = height
+	}
+	if n <= 0 {
+		return
+	}
+	if save && top == 0 && bottom == t.rows-1 && !t.alt {
+		t.pushScrollback(t.lines[:n])
+		if t.view > 0 {
+			t.view = clamp(t.view+n, 0, len(t.scrollback))
+			t.markAll()
+		}
+	}
+	copy(t.lines[top:], t.lines[top+n:bottom+1])
+	for i := bottom - n + 1; i <= bottom; i++ {
+		t.lines[i] = t.blankLine()
+	}
+
+	// the rendered rows move along, only the new rows are drawn, and
+	// the rendered cursor is erased wherever it moved to
+	t.mark(t.cursorRow)
+	t.cursorRow = -1
+	copy(t.dirty[top:], t.dirty[top+n:bottom+1])
+	for i := bottom - n + 1; i <= bottom; i++ {
+		t.dirty[i] = true
+	}
+	switch {
+	case t.pending.n < 0:
+	case t.pending.n == 0:
+		t.pending = scrollOp{top, bottom, n}
+	case t.pending.top == top && t.pending.bottom == bottom:
+		t.pending.n += n
+	default:
+		t.markAll()
+	}
+}
+
+// scrollDown scrolls rows top to bottom down by n rows.
+func (t *Terminal) scrollDown(top, bottom, n int) {
+	height := bottom - top + 1
+	if n > height {
+		n = height
+	}
+	if n <= 0 {
+		return
+	}
+	copy(t.lines[top+n:bottom+1], t.lines[top:bottom+1-n])
+	for i := top; i < top+n; i++ {
+		t.lines[i] = t.blankLine()
+	}
+	for i := top; i <= bottom; i++ {
+		t.dirty[i] = true
+	}
+}
+
+// erase erases the columns from to to of the given row.
+func (t *Terminal) erase(y, from, to int) {
+	from, to = clamp(from, 0, t.cols), clamp(to, 0, t.cols)
+	for x := from; x < to; x++ {
+		t.lines[y][x] = t.blank()
+	}
+	t.mark(y)
+}
+
+// moveTo moves the cursor to the given position within the screen.
+func (t *Terminal) moveTo(x, y int) {
+	t.x, t.y = clamp(x, 0, t.cols-1), clamp(y, 0, t.rows-1)
+	t.wrapNext = false
+}
+
+func (t *Terminal) saveCursor() {
+	t.saved = cursor{x: t.x, y: t.y, attr: t.attr, charset: t.charset}
+}
+
+func (t *Terminal) restoreCursor() {
+	t.moveTo(t.saved.x, t.saved.y)
+	t.attr, t.charset = t.saved.attr, t.saved.charset
+}
+
+// useAlt switches between the main and the alternate screen.
+func (t *Terminal) useAlt(alt bool) {
+	if t.alt == alt {
+		return
+	}
+	t.alt = alt
+	if alt {
+		t.other, t.lines = t.lines, t.blankLines(t.rows)
+	} else {
+		t.lines, t.other = t.other, nil
+	}
+	t.view = 0
+	t.markAll()
+}
+
+func (t *Terminal) reply(s string) {
+	if t.opts.Reply != nil && len(t.vt) == 0 {
+		t.opts.Reply([]byte(s))
+	}
+}
+
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,265 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package terminal_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/internal/terminal"
+	"changkun.de/x/occamy/plugin"
+)
+
+type nopPlugin struct{}
+
+func (nopPlugin) Args() []string { return nil }
+func (nopPlugin) New(s *plugin.Session) (plugin.Connection, error) {
+	return nopConn{}, nil
+}
+
+type nopConn struct{ plugin.Base }
+
+func (nopConn) Join(u *plugin.User, args plugin.Args) error { return nil }
+
+func newTerminal(t *testing.T, cols, rows int, opts terminal.Options) *terminal.Terminal {
+	s, err := plugin.NewSession(nopPlugin{})
+	if err != nil {
+		t.Fatalf("new session error: %v", err)
+	}
+	return terminal.New(s.Display(), cols*terminal.CellWidth, rows*terminal.CellHeight, opts)
+}
+
+func TestTerminal_Write(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		lines []string
+		x, y  int
+	}{
+		{"text", "hello\r\nworld", []string{"hello", "world", ""}, 5, 1},
+		{"wrap", "0123456789ab", []string{"0123456789", "ab", ""}, 2, 1},
+		{"scroll", "1\r\n2\r\n3\r\n4", []string{"2", "3", "4"}, 1, 2},
+		{"cup", "\x1b[2;3Hx", []string{"", "  x", ""}, 3, 1},
+		{"erase line", "hello\x1b[3D\x1b[K", []string{"he", "", ""}, 2, 0},
+		{"erase screen", "a\r\nb\x1b[2J", []string{"", "", ""}, 1, 1},
+		{"delete char", "hello\r\x1b[2P", []string{"llo", "", ""}, 0, 0},
+		{"insert line", "a\r\nb\x1b[1;1H\x1b[L", []string{"", "a", "b"}, 0, 0},
+		{"scroll region", "\x1b[2;3r\x1b[3;1Ha\nb", []string{"", "a", " b"}, 2, 2},
+		{"dec graphics", "\x1b(0qx\x1b(Bq", []string{"─│q", "", ""}, 3, 0},
+		{"utf-8", "ä€", []string{"ä€", "", ""}, 2, 0},
+		{"alt screen", "main\x1b[?1049hAlt\x1b[?1049l", []string{"main", "", ""}, 4, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			term := newTerminal(t, 10, 3, terminal.Options{})
+			term.Write([]byte(tt.input))
+			for i, want := range tt.lines {
+				if got := term.Text(i); got != want {
+					t.Errorf("row %d: got %q, want %q", i, got, want)
+				}
+			}
+			if x, y := term.Cursor(); x != tt.x || y != tt.y {
+				t.Errorf("cursor: got %d,%d, want %d,%d", x, y, tt.x, tt.y)
+			}
+		})
+	}
+}
+
+func TestTerminal_Resize(t *testing.T) {
+	term := newTerminal(t, 10, 3, terminal.Options{})
+	term.Write([]byte("1\r\n2\r\n3"))
+	cols, rows := term.Resize(20*terminal.CellWidth, 2*terminal.CellHeight)
+	if cols != 20 || rows != 2 {
+		t.Fatalf("resize: got %dx%d, want 20x2", cols, rows)
+	}
+	if term.Text(0) != "2" || term.Text(1) != "3" {
+		t.Fatalf("resize: got %q %q, want the last two lines", term.Text(0), term.Text(1))
+	}
+}
+
+func TestTerminal_Reply(t *testing.T) {
+	var reply []byte
+	term := newTerminal(t, 10, 3, terminal.Options{
+		Reply: func(p []byte) { reply = append(reply, p...) },
+	})
+	term.Write([]byte("ab\x1b[6n"))
+	if string(reply) != "\x1b[1;3R" {
+		t.Fatalf("cursor position report: got %q", reply)
+	}
+}
+
+func TestTerminal_Attach(t *testing.T) {
+	s, err := plugin.NewSession(nopPlugin{})
+	if err != nil {
+		t.Fatalf("new session error: %v", err)
+	}
+	var reply []byte
+	term := terminal.New(s.Display(), 10*terminal.CellWidth, 3*terminal.CellHeight, terminal.Options{
+		Reply: func(p []byte) { reply = append(reply, p...) },
+	})
+	term.Write([]byte("0\r\n1\r\n2\r\n\x1b[1;31mred\x1b[m \x1b[48;5;200mx\x1b[2;3H\x1b[7m"))
+
+	rw, peer := net.Pipe()
+	defer peer.Close()
+	u := &plugin.User{ID: "@vt", Terminal: plugin.TerminalVT, Width: 10, Height: 3}
+	if err := s.Join(u, nil, rw); err != nil {
+		t.Fatalf("join error: %v", err)
+	}
+	msgs := make(chan protocol.Message, 16)
+	go func() {
+		defer close(msgs)
+		p := protocol.NewParser()
+		for {
+			elements, err := p.Next(peer)
+			if err != nil {
+				return
+			}
+			strs := make([]string, len(elements))
+			for i := range elements {
+				strs[i] = string(elements[i])
+			}
+			m, err := protocol.Decode(protocol.NewInstruction(strs))
+			if err == nil {
+				msgs <- m
+			}
+		}
+	}()
+	next := func() protocol.Message {
+		t.Helper()
+		select {
+		case m := <-msgs:
+			return m
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no instruction was received")
+		}
+		return nil
+	}
+
+	term.Attach(u)
+	if m, ok := next().(*protocol.Pipe); !ok || m.Name != "STDOUT" {
+		t.Fatalf("attach: got %+v, want the STDOUT pipe", m)
+	}
+	if m, ok := next().(*protocol.LayerSize); !ok || m.Width != 10 || m.Height != 3 {
+		t.Fatalf("attach: got %+v, want the size 10x3", m)
+	}
+	blob, ok := next().(*protocol.Blob)
+	if !ok {
+		t.Fatalf("attach: got %+v, want the screen", blob)
+	}
+	replica := newTerminal(t, 10, 3, terminal.Options{})
+	replica.Write(blob.Data)
+	for i := 0; i < 3; i++ {
+		if got, want := replica.Text(i), term.Text(i); got != want {
+			t.Errorf("replayed screen row %d: got %q, want %q", i, got, want)
+		}
+	}
+	if x, y := replica.Cursor(); x != 2 || y != 1 {
+		t.Errorf("replayed cursor: got %d,%d, want 2,1", x, y)
+	}
+
+	// the output is streamed, and the user answers queries
+	term.Write([]byte("\x1b[6nhi"))
+	if m, ok := next().(*protocol.Blob); !ok || string(m.Data) != "\x1b[6nhi" {
+		t.Fatalf("output: got %+v", m)
+	}
+	if len(reply) != 0 {
+		t.Fatalf("the terminal replied to a vt user: %q", reply)
+	}
+	term.Resize(20*terminal.CellWidth, 2*terminal.CellHeight)
+	if m, ok := next().(*protocol.LayerSize); !ok || m.Width != 20 || m.Height != 2 {
+		t.Fatalf("resize: got %+v, want the size 20x2", m)
+	}
+
+	term.Detach(u)
+	term.Write([]byte("\x1b[6n"))
+	if len(reply) == 0 {
+		t.Fatalf("the terminal did not reply once the vt user left")
+	}
+	select {
+	case m := <-msgs:
+		t.Fatalf("output after detach: %+v", m)
+	default:
+	}
+}
+
+func TestTerminal_Key(t *testing.T) {
+	term := newTerminal(t, 10, 3, terminal.Options{})
+	tests := []struct {
+		keysyms []int
+		want    string
+	}{
+		{[]int{'a'}, "a"},
+		{[]int{0xFF0D}, "\r"},
+		{[]int{0xFF08}, "\x7f"},
+		{[]int{0xFF52}, "\x1b[A"},
+		{[]int{0x010020AC}, "€"},
+		{[]int{0xFFE3, 'c'}, "\x03"},
+		{[]int{0xFFE9, 'x'}, "\x1bx"},
+	}
+	for _, tt := range tests {
+		var got []byte
+		for _, k := range tt.keysyms {
+			got = append(got, term.Key(k, true)...)
+		}
+		for _, k := range tt.keysyms {
+			term.Key(k, false)
+		}
+		if string(got) != tt.want {
+			t.Errorf("keys %x: got %q, want %q", tt.keysyms, got, tt.want)
+		}
+	}
+
+	term.Write([]byte("\x1b[?1h"))
+	if got := term.Key(0xFF52, true); string(got) != "\x1bOA" {
+		t.Errorf("application cursor: got %q", got)
+	}
+}
+
+func TestTypescript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "occamy-typescript")
+	if err != nil {
+		t.Fatalf("create temp dir error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "scripts")
+
+	if _, err := terminal.NewTypescript(path, "ts", false); err == nil {
+		t.Fatalf("typescript without path must fail")
+	}
+	for i, want := range []string{"ts", "ts.1"} {
+		ts, err := terminal.NewTypescript(path, "ts", true)
+		if err != nil {
+			t.Fatalf("new typescript error: %v", err)
+		}
+		term := newTerminal(t, 10, 3, terminal.Options{Typescript: ts})
+		term.Write([]byte("hello"))
+		term.Write([]byte("\r\n"))
+		if err := ts.Close(); err != nil {
+			t.Fatalf("close typescript error: %v", err)
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(path, want))
+		if err != nil {
+			t.Fatalf("typescript %d: %v", i, err)
+		}
+		if string(data) != "[BEGIN TYPESCRIPT]\nhello\r\n\n[END TYPESCRIPT]\n" {
+			t.Fatalf("typescript %d: got %q", i, data)
+		}
+		timing, err := ioutil.ReadFile(filepath.Join(path, want+".timing"))
+		if err != nil {
+			t.Fatalf("typescript timing %d: %v", i, err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(timing)), "\n")
+		if len(lines) != 2 || !strings.HasSuffix(lines[0], " 5") || !strings.HasSuffix(lines[1], " 2") {
+			t.Fatalf("typescript timing %d: got %q", i, timing)
+		}
+	}
+}
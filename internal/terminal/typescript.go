@@ -0,0 +1,114 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Typescript markers, the same as of the libguac terminal
+const (
+	typescriptHeader = "[BEGIN TYPESCRIPT]\n"
+	typescriptFooter = "\n[END TYPESCRIPT]\n"
+)
+
+// maxTypescriptSuffix limits the numeric suffixes tried if a typescript
+// of the same name already exists.
+const maxTypescriptSuffix = 255
+
+// Typescript records the output of a terminal in the format of script(1)
+// and its timing file, which can be replayed by scriptreplay(1).
+type Typescript struct {
+	data, timing *os.File
+	w, tw        *bufio.Writer
+	last         time.Time
+}
+
+// NewTypescript creates a typescript of the given name in the given
+// directory, the timing is written to a file of the same name with a
+// .timing suffix. Existing typescripts are not overwritten, a numeric
+// suffix is appended to the name instead. If createPath is set, the
+// directory is created if it does not exist.
+func NewTypescript(path, name string, createPath bool) (*Typescript, error) {
+	if createPath {
+		err := os.MkdirAll(path, 0700)
+		if err != nil {
+			return nil, fmt.Errorf("terminal: create typescript path: %w", err)
+		}
+	}
+
+	base := filepath.Join(path, name)
+	var (
+		data *os.File
+		err  error
+	)
+	for i := 0; i <= maxTypescriptSuffix; i++ {
+		if i > 0 {
+			base = filepath.Join(path, name+"."+strconv.Itoa(i))
+		}
+		data, err = os.OpenFile(base, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if !os.IsExist(err) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("terminal: create typescript: %w", err)
+	}
+	timing, err := os.OpenFile(base+".timing", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		data.Close()
+		os.Remove(base)
+		return nil, fmt.Errorf("terminal: create typescript timing: %w", err)
+	}
+
+	ts := &Typescript{
+		data:   data,
+		timing: timing,
+		w:      bufio.NewWriter(data),
+		tw:     bufio.NewWriter(timing),
+		last:   time.Now(),
+	}
+	ts.w.WriteString(typescriptHeader)
+	return ts, nil
+}
+
+// Write records the given output of the terminal.
+func (ts *Typescript) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	now := time.Now()
+	fmt.Fprintf(ts.tw, "%0.6f %d\n", now.Sub(ts.last).Seconds(), len(p))
+	ts.last = now
+	n, err := ts.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if err := ts.tw.Flush(); err != nil {
+		return n, err
+	}
+	return n, ts.w.Flush()
+}
+
+// Close ends the typescript and closes its files.
+func (ts *Typescript) Close() error {
+	ts.w.WriteString(typescriptFooter)
+	err := ts.w.Flush()
+	if terr := ts.tw.Flush(); err == nil {
+		err = terr
+	}
+	if cerr := ts.data.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := ts.timing.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package terminal
+
+import (
+	"bytes"
+	"strconv"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+)
+
+// vtStream is the index of the STDOUT pipe of users in the vt mode,
+// which receive no other streams.
+const vtStream = 0
+
+// Attach streams the raw output of the remote program to the given user
+// in the plugin.TerminalVT mode, in place of the display. The user
+// first receives the size of the terminal in columns and rows and the
+// output that draws the current screen and scrollback.
+func (t *Terminal) Attach(u *plugin.User) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.d.Exclude(u)
+	if t.vt == nil {
+		t.vt = make(map[*plugin.User]struct{})
+	}
+	t.vt[u] = struct{}{}
+	u.Send(&protocol.Pipe{Stream: vtStream, Mimetype: "application/octet-stream", Name: "STDOUT"})
+	u.Send(&protocol.LayerSize{Layer: 0, Width: t.cols, Height: t.rows})
+	sendVT(u, t.snapshot())
+}
+
+// Detach stops streaming the output to the given user.
+func (t *Terminal) Detach(u *plugin.User) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.vt, u)
+}
+
+// sendVT sends the given output over the STDOUT pipe of the user.
+func sendVT(u *plugin.User, p []byte) {
+	for len(p) > 0 {
+		n := len(p)
+		if n > plugin.BlobSize {
+			n = plugin.BlobSize
+		}
+		u.Send(&protocol.Blob{Stream: vtStream, Data: p[:n]})
+		p = p[n:]
+	}
+}
+
+// snapshot returns the output which draws the current state of the
+// terminal on a terminal of the same size. The scrollback and the main
+// screen are printed line by line, hence the scrollback of the other
+// terminal is filled as well.
+func (t *Terminal) snapshot() []byte {
+	var b bytes.Buffer
+	b.WriteString("\x1bc")
+
+	main := t.lines
+	if t.alt {
+		main = t.other
+	}
+	attr := defaultAttr
+	for i, l := range append(append([]line(nil), t.scrollback...), main...) {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		attr = writeLine(&b, l, attr)
+	}
+	if t.alt {
+		b.WriteString(cup(t.saved.x, t.saved.y) + "\x1b[?1049h")
+		for y, l := range t.lines {
+			b.WriteString(cup(0, y))
+			attr = writeLine(&b, l, attr)
+		}
+	}
+
+	if t.top != 0 || t.bottom != t.rows-1 {
+		b.WriteString("\x1b[" + strconv.Itoa(t.top+1) + ";" + strconv.Itoa(t.bottom+1) + "r")
+	}
+	if !t.autowrap {
+		b.WriteString("\x1b[?7l")
+	}
+	if !t.cursorVisible {
+		b.WriteString("\x1b[?25l")
+	}
+	if t.appCursor {
+		b.WriteString("\x1b[?1h")
+	}
+	if t.insert {
+		b.WriteString("\x1b[4h")
+	}
+	if t.charset[0] {
+		b.WriteString("\x1b(0")
+	}
+	if t.charset[1] {
+		b.WriteString("\x1b)0")
+	}
+	if t.shift == 1 {
+		b.WriteString("\x0e")
+	}
+	if t.title != "" {
+		b.WriteString("\x1b]2;" + t.title + "\x07")
+	}
+	b.WriteString(sgr(t.attr) + cup(t.x, t.y))
+	return b.Bytes()
+}
+
+// writeLine writes the characters of a line without trailing blanks,
+// attr is the current attributes of the output, the attributes after
+// the line are returned.
+func writeLine(b *bytes.Buffer, l line, attr Attr) Attr {
+	end := len(l)
+	for end > 0 && l[end-1].r == ' ' && l[end-1].a == defaultAttr {
+		end--
+	}
+	for _, c := range l[:end] {
+		if c.a != attr {
+			attr = c.a
+			b.WriteString(sgr(attr))
+		}
+		b.WriteRune(c.r)
+	}
+	return attr
+}
+
+// sgr returns the select graphic rendition sequence of the given
+// attributes.
+func sgr(a Attr) string {
+	s := "\x1b[0"
+	if a.Bold {
+		s += ";1"
+	}
+	if a.Underline {
+		s += ";4"
+	}
+	if a.Reverse {
+		s += ";7"
+	}
+	return s + sgrColor(a.FG, 30) + sgrColor(a.BG, 40) + "m"
+}
+
+// sgrColor returns the parameters of a foreground or background color,
+// base is 30 for foreground and 40 for background colors.
+func sgrColor(c Color, base int) string {
+	switch {
+	case c == ColorDefault:
+		return ""
+	case c&colorRGB != 0:
+		return ";" + strconv.Itoa(base+8) + ";2;" + strconv.Itoa(int(c>>16&0xFF)) + ";" +
+			strconv.Itoa(int(c>>8&0xFF)) + ";" + strconv.Itoa(int(c&0xFF))
+	case c < 8:
+		return ";" + strconv.Itoa(base+int(c))
+	case c < 16:
+		return ";" + strconv.Itoa(base+60+int(c)-8)
+	}
+	return ";" + strconv.Itoa(base+8) + ";5;" + strconv.Itoa(int(c))
+}
+
+// cup returns the sequence which moves the cursor to the given position.
+func cup(x, y int) string {
+	return "\x1b[" + strconv.Itoa(y+1) + ";" + strconv.Itoa(x+1) + "H"
+}
+
+// DisplaySize returns the display size of a terminal that fits the given
+// optimal size of a user, which is in columns and rows if the user is
+// in the vt mode.
+func DisplaySize(u *plugin.User, width, height int) (int, int) {
+	if u.Terminal == plugin.TerminalVT {
+		return width * CellWidth, height * CellHeight
+	}
+	return width, height
+}
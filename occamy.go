@@ -4,6 +4,109 @@
 
 package main
 
-import "changkun.de/x/occamy/server"
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
 
-func main() { server.Run() }
+	"changkun.de/x/occamy/internal/config"
+	"changkun.de/x/occamy/plugin"
+	_ "changkun.de/x/occamy/plugin/shell"  // registers shell
+	_ "changkun.de/x/occamy/plugin/ssh"    // registers ssh-go
+	_ "changkun.de/x/occamy/plugin/telnet" // registers telnet
+	_ "changkun.de/x/occamy/plugin/vnc"    // registers vnc-go
+	"changkun.de/x/occamy/server"
+	"github.com/gin-gonic/gin"
+)
+
+func main() {
+	log.SetPrefix("occamy: ")
+	log.SetFlags(log.LstdFlags | log.Lshortfile | log.Lmsgprefix)
+
+	loc := flag.String("conf", "./conf.yaml", "path to the runtime config file")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `a modern guacamole protocol based remote desktop proxy written in Go.
+Usage:`)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	conf, err := config.Load(*loc)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	gin.SetMode(conf.Mode)
+
+	args := make(map[string]plugin.Args, len(conf.Protocols))
+	for proto, a := range conf.Protocols {
+		args[proto] = a
+	}
+	var hostKeys *server.HostKeys
+	if conf.HostKeys != "" {
+		hostKeys, err = server.OpenHostKeys(conf.HostKeys)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+	var ca *server.CertificateAuthority
+	if conf.SSHCA.Key != "" {
+		ca, err = server.LoadCertificateAuthority(conf.SSHCA.Key, conf.SSHCA.Validity, conf.SSHCA.Principals)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+	var dialer server.Dialer
+	if conf.Dialer != "" {
+		dialer, err = server.ParseDialer(conf.Dialer, hostKeys)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+	var egress *server.EgressPolicy
+	if conf.Egress.Default != "" || len(conf.Egress.Rules) > 0 {
+		egress, err = server.NewEgressPolicy(conf.Egress.Default, conf.Egress.Rules)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+	}
+	s, err := server.New(server.Options{
+		Addr:                 conf.Address,
+		Mode:                 conf.Mode,
+		JWTSecret:            conf.Auth.JWTSecret,
+		JWTAlgorithm:         conf.Auth.JWTAlgorithm,
+		Client:               conf.Client,
+		ProtocolArgs:         args,
+		HostKeys:             hostKeys,
+		AdminToken:           conf.Auth.AdminToken,
+		CertificateAuthority: ca,
+		Dialer:               dialer,
+		Egress:               egress,
+	})
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, os.Kill)
+		sig := <-quit
+		log.Printf("shutting down occammy proxy... %v", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := s.Shutdown(ctx); err != nil {
+			log.Printf("server shutdown with error: %v", err)
+		}
+		cancel()
+		close(done)
+	}()
+	err = s.ListenAndServe()
+	if err != server.ErrServerClosed {
+		log.Printf("close with error: %v", err)
+	}
+	<-done
+	log.Println("occamy proxy is down, good bye!")
+}
@@ -0,0 +1,242 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"sync"
+	"time"
+
+	"changkun.de/x/occamy/internal/protocol"
+)
+
+// BlobSize is the maximum size of the data of a blob instruction
+const BlobSize = 6048
+
+// Display is the display of a session, which is shared by all users.
+// It keeps a copy of the default layer, hence users that join later
+// receive the current display. All methods are safe for concurrent use,
+// drawings are sent to the users once Flush is called.
+type Display struct {
+	mu     sync.Mutex
+	img    *image.RGBA
+	cursor *cursor
+	users  map[*User]struct{}
+	skip   map[*User]struct{} // users excluded until they leave
+	stream int                // index of the next output stream
+	out    []*protocol.Instruction
+}
+
+// cursor is the image of the mouse cursor and its hotspot
+type cursor struct {
+	x, y int
+	img  image.Image
+}
+
+// cursorLayer is the buffer that keeps the image of the cursor
+const cursorLayer = -1
+
+func newDisplay() *Display {
+	return &Display{
+		img:   image.NewRGBA(image.Rect(0, 0, 0, 0)),
+		users: make(map[*User]struct{}),
+		skip:  make(map[*User]struct{}),
+	}
+}
+
+// Size returns the size of the display.
+func (d *Display) Size() (width, height int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	b := d.img.Bounds()
+	return b.Dx(), b.Dy()
+}
+
+// Image returns a copy of the current display.
+func (d *Display) Image() *image.RGBA {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	img := image.NewRGBA(d.img.Bounds())
+	copy(img.Pix, d.img.Pix)
+	return img
+}
+
+// Resize resizes the display, the current contents are kept.
+func (d *Display) Resize(width, height int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), d.img, image.Point{}, draw.Src)
+	d.img = img
+	d.queue(&protocol.LayerSize{Layer: 0, Width: width, Height: height})
+}
+
+// Draw draws the given image at the given position.
+func (d *Display) Draw(x, y int, src image.Image) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	b := src.Bounds()
+	draw.Draw(d.img, image.Rect(x, y, x+b.Dx(), y+b.Dy()), src, b.Min, draw.Over)
+	d.out = append(d.out, d.imageStream(0, protocol.CompositeOver, x, y, src)...)
+}
+
+// Cursor sets the mouse cursor of all users to the given image, the
+// hotspot is the position of the pointer within the image.
+func (d *Display) Cursor(hotX, hotY int, img image.Image) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.cursor = &cursor{x: hotX, y: hotY, img: img}
+	d.out = append(d.out, d.cursor.instructions(d)...)
+}
+
+// instructions draws the cursor to the cursor layer and sets it.
+func (c *cursor) instructions(d *Display) []*protocol.Instruction {
+	b := c.img.Bounds()
+	out := []*protocol.Instruction{
+		(&protocol.LayerSize{Layer: cursorLayer, Width: b.Dx(), Height: b.Dy()}).Encode(),
+	}
+	out = append(out, d.imageStream(cursorLayer, protocol.CompositeSrc, 0, 0, c.img)...)
+	return append(out, (&protocol.Cursor{X: c.x, Y: c.y, SrcLayer: cursorLayer,
+		SrcWidth: b.Dx(), SrcHeight: b.Dy()}).Encode())
+}
+
+// Fill fills the given rectangle with the given color.
+func (d *Display) Fill(r image.Rectangle, c color.Color) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	draw.Draw(d.img, r, image.NewUniform(c), image.Point{}, draw.Src)
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	d.queue(&protocol.Rect{Layer: 0, X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy()})
+	d.queue(&protocol.Cfill{Mode: protocol.CompositeSrc, Layer: 0,
+		R: int(n.R), G: int(n.G), B: int(n.B), A: int(n.A)})
+}
+
+// Copy copies the given rectangle of the display to the given point,
+// e.g. to scroll.
+func (d *Display) Copy(src image.Rectangle, dst image.Point) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	tmp := image.NewRGBA(src)
+	draw.Draw(tmp, src, d.img, src.Min, draw.Src)
+	draw.Draw(d.img, image.Rectangle{dst, dst.Add(src.Size())}, tmp, src.Min, draw.Src)
+	d.queue(&protocol.Copy{
+		SrcLayer: 0, SrcX: src.Min.X, SrcY: src.Min.Y, SrcWidth: src.Dx(), SrcHeight: src.Dy(),
+		Mode: protocol.CompositeSrc, DstLayer: 0, DstX: dst.X, DstY: dst.Y,
+	})
+}
+
+// Send sends the given message to all users, e.g. a clipboard stream
+// or an instruction the display does not draw. The message is sent in
+// order with drawings once Flush is called.
+func (d *Display) Send(m protocol.Message) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queue(m)
+}
+
+// Clipboard sets the clipboard of all users.
+func (d *Display) Clipboard(mimetype string, data []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stream := d.nextStream()
+	d.queue(&protocol.Clipboard{Stream: stream, Mimetype: mimetype})
+	d.out = append(d.out, blobs(stream, data)...)
+}
+
+// Flush ends the current frame and sends all drawings to the users.
+func (d *Display) Flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queue(&protocol.Sync{Timestamp: time.Now().UnixNano() / int64(time.Millisecond)})
+	for u := range d.users {
+		u.write(d.out)
+	}
+	d.out = d.out[:0]
+}
+
+// Exclude stops sending the display to the given user until the user
+// leaves, e.g. a user in the TerminalVT mode which renders the terminal
+// itself.
+func (d *Display) Exclude(u *User) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.users, u)
+	d.skip[u] = struct{}{}
+}
+
+// attach adds a user to the display and sends the current display,
+// unless the user is excluded.
+func (d *Display) attach(u *User) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.skip[u]; ok {
+		return
+	}
+	b := d.img.Bounds()
+	out := []*protocol.Instruction{
+		(&protocol.LayerSize{Layer: 0, Width: b.Dx(), Height: b.Dy()}).Encode(),
+	}
+	if !b.Empty() {
+		out = append(out, d.imageStream(0, protocol.CompositeOver, 0, 0, d.img)...)
+	}
+	if d.cursor != nil {
+		out = append(out, d.cursor.instructions(d)...)
+	}
+	out = append(out, (&protocol.Sync{Timestamp: time.Now().UnixNano() / int64(time.Millisecond)}).Encode())
+	u.write(out)
+	d.users[u] = struct{}{}
+}
+
+// detach removes a user from the display.
+func (d *Display) detach(u *User) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.users, u)
+	delete(d.skip, u)
+}
+
+func (d *Display) queue(m protocol.Message) {
+	d.out = append(d.out, m.Encode())
+}
+
+// imageStream encodes the given image as an img stream of PNG data, which
+// is drawn to the given layer.
+func (d *Display) imageStream(layer int, mode protocol.CompositeMode, x, y int, src image.Image) []*protocol.Instruction {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, src)
+	if err != nil {
+		return nil
+	}
+	stream := d.nextStream()
+	img := &protocol.Img{Stream: stream, Mode: mode, Layer: layer,
+		Mimetype: "image/png", X: x, Y: y}
+	return append([]*protocol.Instruction{img.Encode()}, blobs(stream, buf.Bytes())...)
+}
+
+// nextStream returns the index of a new output stream, the streams of
+// the display are closed immediately, hence indexes are reused.
+func (d *Display) nextStream() int {
+	d.stream = (d.stream + 1) % 64
+	return d.stream
+}
+
+// blobs splits the given data into blob instructions of the given
+// stream, which is ended.
+func blobs(stream int, data []byte) []*protocol.Instruction {
+	var out []*protocol.Instruction
+	for len(data) > 0 {
+		n := len(data)
+		if n > BlobSize {
+			n = BlobSize
+		}
+		out = append(out, (&protocol.Blob{Stream: stream, Data: data[:n]}).Encode())
+		data = data[n:]
+	}
+	return append(out, (&protocol.End{Stream: stream}).Encode())
+}
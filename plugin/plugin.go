@@ -0,0 +1,205 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package plugin implements remote protocols in Go. A ProtocolPlugin is
+// registered by name and is used by the occamy server in place of the
+// libguac C plugin of the same protocol name.
+//
+// A plugin creates a Connection for each Session. The connection
+// receives the users that join and leave the session and their typed
+// input, and draws the remote desktop through the Display of the session,
+// which emits guacamole instructions to all users.
+package plugin
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+
+	"changkun.de/x/occamy/internal/protocol"
+)
+
+// Errors of protocol plugins
+var (
+	ErrNotSupported = errors.New("plugin: operation not supported")
+	ErrClosed       = errors.New("plugin: session closed")
+)
+
+// ProtocolPlugin implements a remote protocol.
+type ProtocolPlugin interface {
+	// Args returns the names of the connection arguments accepted by
+	// the plugin, e.g. hostname, port, username and password.
+	Args() []string
+	// New creates the connection of a new session, which draws to the
+	// display of the session. The remote connection is usually
+	// established once the owner of the session joins, and the
+	// connection stops the session once the remote connection ends.
+	New(s *Session) (Connection, error)
+}
+
+// Connection is a connection to the remote desktop which is shared by
+// all users of a session. Except for File, the methods of a connection
+// are called from the goroutine that reads the input of the user.
+type Connection interface {
+	// Join is called when a user joins the session with the connection
+	// arguments of the user. The owner joins first. A non-nil error
+	// rejects the user.
+	Join(u *User, args Args) error
+	// Leave is called when a joined user leaves the session.
+	Leave(u *User)
+	// Key is called when the user presses or releases a key.
+	Key(u *User, keysym int, pressed bool) error
+	// Mouse is called when the user moves the mouse or presses mouse
+	// buttons, mask is a combination of the mouse button masks.
+	Mouse(u *User, x, y, mask int) error
+	// Size is called when the optimal display size of the user changes.
+	Size(u *User, width, height int) error
+	// Clipboard is called with the complete clipboard contents of the
+	// user.
+	Clipboard(u *User, mimetype string, data []byte) error
+	// File is called on a new goroutine when the user uploads a file,
+	// the upload completes when File returns.
+	File(u *User, f *FileStream) error
+	// Input is called with the raw terminal input of the user, which
+	// is sent by users in the TerminalVT mode.
+	Input(u *User, data []byte) error
+	// Close closes the connection after all users left.
+	Close() error
+}
+
+// Base implements the optional methods of a Connection, which ignore
+// input. A connection embeds Base and overrides the methods it supports.
+type Base struct{}
+
+// Leave implements Connection
+func (Base) Leave(u *User) {}
+
+// Key implements Connection
+func (Base) Key(u *User, keysym int, pressed bool) error { return nil }
+
+// Mouse implements Connection
+func (Base) Mouse(u *User, x, y, mask int) error { return nil }
+
+// Size implements Connection
+func (Base) Size(u *User, width, height int) error { return nil }
+
+// Clipboard implements Connection
+func (Base) Clipboard(u *User, mimetype string, data []byte) error { return nil }
+
+// File implements Connection, uploads are not supported.
+func (Base) File(u *User, f *FileStream) error { return ErrNotSupported }
+
+// Input implements Connection
+func (Base) Input(u *User, data []byte) error { return nil }
+
+// Close implements Connection
+func (Base) Close() error { return nil }
+
+// statusError is an error with a guacamole status
+type statusError struct {
+	status protocol.Status
+	err    error
+}
+
+func (e *statusError) Error() string           { return e.err.Error() }
+func (e *statusError) Unwrap() error           { return e.err }
+func (e *statusError) Status() protocol.Status { return e.status }
+
+// StatusError annotates the given error with the guacamole status that
+// is reported to users.
+func StatusError(status protocol.Status, err error) error {
+	return &statusError{status: status, err: err}
+}
+
+// StatusOf returns the guacamole status of an error of a connection.
+// Errors without status, e.g. from dialing the remote desktop, are
+// mapped to upstream errors.
+func StatusOf(err error) protocol.Status {
+	var (
+		se interface{ Status() protocol.Status }
+		ne net.Error
+	)
+	switch {
+	case err == nil:
+		return protocol.StatusSuccess
+	case errors.As(err, &se):
+		return se.Status()
+	case errors.Is(err, ErrNotSupported):
+		return protocol.StatusUnsupported
+	case errors.Is(err, ErrClosed):
+		return protocol.StatusResourceClosed
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET):
+		return protocol.StatusUpstreamUnavailable
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return protocol.StatusUpstreamNotFound
+	case errors.As(err, &ne) && ne.Timeout():
+		return protocol.StatusUpstreamTimeout
+	}
+	return protocol.StatusUpstreamError
+}
+
+// Args are the connection arguments of a user by name
+type Args map[string]string
+
+// Get returns the named argument, or an empty string if it is absent.
+func (a Args) Get(name string) string {
+	return a[name]
+}
+
+// Int returns the named argument as an integer, or the given fallback
+// if it is absent or invalid.
+func (a Args) Int(name string, fallback int) int {
+	v, err := strconv.Atoi(a[name])
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// Bool reports whether the named argument is "true".
+func (a Args) Bool(name string) bool {
+	return a[name] == "true"
+}
+
+var (
+	mu      sync.RWMutex
+	plugins = make(map[string]ProtocolPlugin)
+)
+
+// Register makes a protocol plugin available by the given protocol
+// name. It panics if the name is registered twice.
+func Register(name string, p ProtocolPlugin) {
+	mu.Lock()
+	defer mu.Unlock()
+	if p == nil {
+		panic("plugin: register nil plugin " + name)
+	}
+	if _, ok := plugins[name]; ok {
+		panic("plugin: register plugin twice " + name)
+	}
+	plugins[name] = p
+}
+
+// Lookup returns the protocol plugin registered by the given name.
+func Lookup(name string) (ProtocolPlugin, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := plugins[name]
+	return p, ok
+}
+
+// Protocols returns the sorted names of all registered plugins.
+func Protocols() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
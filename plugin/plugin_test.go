@@ -0,0 +1,76 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package plugin_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+)
+
+type nopPlugin struct{}
+
+func (nopPlugin) Args() []string { return nil }
+func (nopPlugin) New(s *plugin.Session) (plugin.Connection, error) {
+	return nil, plugin.ErrNotSupported
+}
+
+func TestRegister(t *testing.T) {
+	plugin.Register("occamy-nop", nopPlugin{})
+	if _, ok := plugin.Lookup("occamy-nop"); !ok {
+		t.Fatalf("registered plugin is not found")
+	}
+	if _, ok := plugin.Lookup("occamy-unknown"); ok {
+		t.Fatalf("unknown plugin should not be found")
+	}
+	found := false
+	for _, name := range plugin.Protocols() {
+		found = found || name == "occamy-nop"
+	}
+	if !found {
+		t.Fatalf("registered plugin is not listed: %v", plugin.Protocols())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("register twice should panic")
+		}
+	}()
+	plugin.Register("occamy-nop", nopPlugin{})
+}
+
+func TestStatusOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want protocol.Status
+	}{
+		{nil, protocol.StatusSuccess},
+		{plugin.ErrNotSupported, protocol.StatusUnsupported},
+		{fmt.Errorf("dial: %w", syscall.ECONNREFUSED), protocol.StatusUpstreamUnavailable},
+		{&net.DNSError{IsTimeout: true}, protocol.StatusUpstreamTimeout},
+		{plugin.StatusError(protocol.StatusClientForbidden, errors.New("denied")), protocol.StatusClientForbidden},
+		{errors.New("unknown"), protocol.StatusUpstreamError},
+	}
+	for _, tt := range tests {
+		if got := plugin.StatusOf(tt.err); got != tt.want {
+			t.Errorf("status of %v, want: %v, got: %v", tt.err, tt.want, got)
+		}
+	}
+}
+
+func TestArgs(t *testing.T) {
+	args := plugin.Args{"port": "23", "size": "x", "readonly": "true"}
+	if args.Int("port", 0) != 23 || args.Int("size", 80) != 80 || args.Int("none", 1) != 1 {
+		t.Fatalf("unexpected integer arguments: %v", args)
+	}
+	if !args.Bool("readonly") || args.Bool("none") || args.Get("none") != "" {
+		t.Fatalf("unexpected arguments: %v", args)
+	}
+}
@@ -0,0 +1,462 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/internal/uuid"
+)
+
+// Default display properties of users that do not report them
+const (
+	DefaultWidth  = 1024
+	DefaultHeight = 768
+	DefaultDPI    = 96
+
+	// DefaultColumns and DefaultRows are the default size of users in
+	// the TerminalVT mode.
+	DefaultColumns = 80
+	DefaultRows    = 24
+)
+
+// ClipboardMaxLength is the maximum length of clipboard contents that
+// are received from users, as the clipboard of libguac.
+const ClipboardMaxLength = 262144
+
+// Errors of input streams of users
+var (
+	errClipboardTooLarge = StatusError(protocol.StatusClientTooMany,
+		errors.New("plugin: clipboard contents are too large"))
+	errBlobOverrun = StatusError(protocol.StatusClientOverrun,
+		errors.New("plugin: blob sent before the previous one is acknowledged"))
+)
+
+// TerminalVT is the terminal mode of users which render terminals
+// themselves, e.g. by xterm.js. Terminal protocols send such users the
+// raw VT output of the remote program in place of the display, by
+// blobs of a pipe stream named STDOUT, and read their raw input from
+// pipe streams named STDIN. The optimal size of these users is given
+// in columns and rows rather than pixels.
+const TerminalVT = "vt"
+
+// Event is a lifecycle event of a session
+type Event int
+
+// All lifecycle events of a session
+const (
+	EventJoin Event = iota
+	EventLeave
+	EventStop
+	EventFree
+)
+
+func (e Event) String() string {
+	switch e {
+	case EventJoin:
+		return "join"
+	case EventLeave:
+		return "leave"
+	case EventStop:
+		return "stop"
+	case EventFree:
+		return "free"
+	}
+	return "unknown"
+}
+
+// Hook is called on lifecycle events of a session, the user is nil for
+// stop and free events.
+type Hook func(s *Session, e Event, u *User)
+
+// User is a user of a session. The exported fields are set before the
+// user joins and must not be changed by plugins.
+type User struct {
+	ID            string
+	Owner         bool
+	Width, Height int // optimal display size when joining
+	DPI           int
+	Timezone      string
+	ImageTypes    []string
+	AudioTypes    []string
+	VideoTypes    []string
+	Terminal      string // the terminal mode, e.g. TerminalVT
+
+	mu  sync.Mutex
+	rw  io.ReadWriteCloser
+	err error
+}
+
+// Send sends the given message to the user only.
+func (u *User) Send(m protocol.Message) error {
+	return u.write([]*protocol.Instruction{m.Encode()})
+}
+
+func (u *User) write(ins []*protocol.Instruction) error {
+	var buf bytes.Buffer
+	for _, i := range ins {
+		buf.WriteString(i.String())
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.err != nil {
+		return u.err
+	}
+	_, u.err = u.rw.Write(buf.Bytes())
+	return u.err
+}
+
+// Session is a session of a protocol plugin, it runs one connection to
+// the remote desktop for all its users.
+type Session struct {
+	ID      string
+	args    []string // accepted connection arguments
+	conn    Connection
+	display *Display
+
+	mu      sync.Mutex
+	users   map[*User]struct{}
+	hooks   []Hook
+	stopped bool
+	freed   bool
+}
+
+// NewSession creates a session of the given plugin.
+func NewSession(p ProtocolPlugin) (*Session, error) {
+	s := &Session{
+		ID:      uuid.NewID("$"),
+		args:    p.Args(),
+		display: newDisplay(),
+		users:   make(map[*User]struct{}),
+	}
+	conn, err := p.New(s)
+	if err != nil {
+		return nil, err
+	}
+	s.conn = conn
+	return s, nil
+}
+
+// Display returns the display of the session.
+func (s *Session) Display() *Display {
+	return s.display
+}
+
+// AddHook adds a hook that is called on lifecycle events.
+func (s *Session) AddHook(h Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, h)
+}
+
+// Users returns the joined users.
+func (s *Session) Users() []*User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := make([]*User, 0, len(s.users))
+	for u := range s.users {
+		users = append(users, u)
+	}
+	return users
+}
+
+func (s *Session) dispatch(e Event, u *User) {
+	s.mu.Lock()
+	hooks := append([]Hook(nil), s.hooks...)
+	s.mu.Unlock()
+	for _, h := range hooks {
+		h(s, e, u)
+	}
+}
+
+// Join joins the given user to the session with the given connection
+// arguments, arguments that are not accepted by the plugin are dropped.
+// Instructions of the user are read from rw and instructions to the
+// user are written to rw. If the user joins, Serve must be called.
+func (s *Session) Join(u *User, args Args, rw io.ReadWriteCloser) error {
+	u.rw = rw
+	if u.Width <= 0 || u.Height <= 0 {
+		u.Width, u.Height = DefaultWidth, DefaultHeight
+		if u.Terminal == TerminalVT {
+			u.Width, u.Height = DefaultColumns, DefaultRows
+		}
+	}
+	if u.DPI <= 0 {
+		u.DPI = DefaultDPI
+	}
+
+	s.mu.Lock()
+	stopped := s.stopped
+	s.mu.Unlock()
+	if stopped {
+		return ErrClosed
+	}
+	accepted := make(Args, len(s.args))
+	for _, name := range s.args {
+		accepted[name] = args[name]
+	}
+	err := s.conn.Join(u, accepted)
+	if err != nil {
+		return StatusError(StatusOf(err), err)
+	}
+	s.mu.Lock()
+	s.users[u] = struct{}{}
+	s.mu.Unlock()
+	s.dispatch(EventJoin, u)
+	return nil
+}
+
+// Serve sends the display to the joined user and handles instructions
+// of the user until the user disconnects or the session stops. The user
+// leaves the session and its rw is closed once Serve returns.
+func (s *Session) Serve(u *User) error {
+	defer u.rw.Close()
+	s.display.attach(u)
+	err := s.handle(u, u.rw)
+
+	s.mu.Lock()
+	delete(s.users, u)
+	stopped := s.stopped
+	s.mu.Unlock()
+	s.display.detach(u)
+	s.conn.Leave(u)
+	s.dispatch(EventLeave, u)
+	if stopped || errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// Stop stops the session and disconnects all users. A non-nil error
+// is reported to the users by an error instruction.
+func (s *Session) Stop(err error) {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return
+	}
+	s.stopped = true
+	users := make([]*User, 0, len(s.users))
+	for u := range s.users {
+		users = append(users, u)
+	}
+	s.mu.Unlock()
+
+	s.dispatch(EventStop, nil)
+	var m protocol.Message = &protocol.Disconnect{}
+	if err != nil {
+		m = &protocol.Error{Message: err.Error(), Status: StatusOf(err)}
+	}
+	for _, u := range users {
+		u.Send(m)
+		u.rw.Close()
+	}
+}
+
+// Close stops the session and closes its connection, it is called
+// after all users left.
+func (s *Session) Close() error {
+	s.Stop(nil)
+	s.mu.Lock()
+	if s.freed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.freed = true
+	s.mu.Unlock()
+
+	err := s.conn.Close()
+	s.dispatch(EventFree, nil)
+	return err
+}
+
+// FileStream is a file uploaded by a user, the data of the file is read
+// from the stream.
+type FileStream struct {
+	Mimetype string
+	Filename string
+	r        *io.PipeReader
+	once     sync.Once
+	started  chan struct{}
+}
+
+// Read implements io.Reader
+func (f *FileStream) Read(p []byte) (int, error) {
+	f.once.Do(func() { close(f.started) })
+	return f.r.Read(p)
+}
+
+// inputStream is a stream of the user which is being received
+type inputStream struct {
+	mimetype string
+	data     bytes.Buffer   // clipboard contents
+	w        *io.PipeWriter // file data, if a file stream
+	blobs    chan []byte    // blobs of the file which are not written yet
+	stdin    bool           // terminal input, if a STDIN pipe
+}
+
+// closeFile ends the data of a file stream, a non-nil error aborts the
+// file.
+func (st *inputStream) closeFile(err error) {
+	if err != nil {
+		st.w.CloseWithError(err)
+	}
+	close(st.blobs)
+}
+
+// handle reads and handles instructions of the user.
+func (s *Session) handle(u *User, r io.Reader) error {
+	p := protocol.NewParser()
+	streams := make(map[int]*inputStream)
+	defer func() {
+		for _, st := range streams {
+			if st.w != nil {
+				st.closeFile(ErrClosed)
+			}
+		}
+	}()
+
+	for {
+		elements, err := p.Next(r)
+		if err != nil {
+			return err
+		}
+		strs := make([]string, len(elements))
+		for i := range elements {
+			strs[i] = string(elements[i])
+		}
+		m, err := protocol.Decode(protocol.NewInstruction(strs))
+		if errors.Is(err, protocol.ErrMessageUnknown) {
+			continue // e.g. nop
+		} else if err != nil {
+			return err
+		}
+
+		switch m := m.(type) {
+		case *protocol.Key:
+			err = s.conn.Key(u, m.Keysym, m.Pressed)
+		case *protocol.Mouse:
+			err = s.conn.Mouse(u, m.X, m.Y, m.Mask)
+		case *protocol.Size:
+			err = s.conn.Size(u, m.Width, m.Height)
+		case *protocol.Clipboard:
+			streams[m.Stream] = &inputStream{mimetype: m.Mimetype}
+		case *protocol.File:
+			streams[m.Stream] = s.openFile(u, m)
+		case *protocol.Pipe:
+			if m.Name != "STDIN" {
+				u.Send(ack(m.Stream, ErrNotSupported))
+				break
+			}
+			streams[m.Stream] = &inputStream{mimetype: m.Mimetype, stdin: true}
+			u.Send(ack(m.Stream, nil))
+		case *protocol.Blob:
+			st, ok := streams[m.Stream]
+			if !ok {
+				u.Send(ack(m.Stream, ErrClosed))
+				break
+			}
+			switch {
+			case st.stdin:
+				err = s.conn.Input(u, m.Data)
+				u.Send(ack(m.Stream, err))
+			case st.w != nil:
+				// the file is written and acknowledged by openFile,
+				// users wait for the acknowledgement of each blob
+				select {
+				case st.blobs <- m.Data:
+				default:
+					delete(streams, m.Stream)
+					st.closeFile(errBlobOverrun)
+					u.Send(ack(m.Stream, errBlobOverrun))
+				}
+			case st.data.Len()+len(m.Data) > ClipboardMaxLength:
+				delete(streams, m.Stream)
+				u.Send(ack(m.Stream, errClipboardTooLarge))
+			default:
+				st.data.Write(m.Data)
+			}
+		case *protocol.End:
+			st, ok := streams[m.Stream]
+			if !ok {
+				break
+			}
+			delete(streams, m.Stream)
+			if st.stdin {
+				break
+			}
+			if st.w != nil {
+				st.closeFile(nil)
+				break
+			}
+			err = s.conn.Clipboard(u, st.mimetype, st.data.Bytes())
+		case *protocol.Disconnect:
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// openFile passes an uploaded file to the connection on a new goroutine.
+// The stream is acknowledged once the connection reads the file, and
+// its blobs are written to the file and acknowledged on another
+// goroutine, such that a connection which does not read the file does
+// not block the input of the user.
+func (s *Session) openFile(u *User, m *protocol.File) *inputStream {
+	pr, pw := io.Pipe()
+	f := &FileStream{
+		Mimetype: m.Mimetype,
+		Filename: m.Filename,
+		r:        pr,
+		started:  make(chan struct{}),
+	}
+	st := &inputStream{mimetype: m.Mimetype, w: pw, blobs: make(chan []byte, 1)}
+	done := make(chan error, 1)
+	go func() {
+		err := s.conn.File(u, f)
+		if err != nil {
+			pr.CloseWithError(err)
+		} else {
+			pr.CloseWithError(ErrClosed)
+		}
+		done <- err
+	}()
+
+	go func() {
+		select {
+		case <-f.started:
+			u.Send(ack(m.Stream, nil))
+		case err := <-done:
+			// the file is rejected, or accepted without reading
+			u.Send(ack(m.Stream, err))
+			for range st.blobs {
+				u.Send(ack(m.Stream, ErrClosed))
+			}
+			return
+		}
+		var err error
+		for data := range st.blobs {
+			if err == nil {
+				_, err = pw.Write(data)
+			}
+			u.Send(ack(m.Stream, err))
+		}
+		pw.Close()
+	}()
+	return st
+}
+
+func ack(stream int, err error) *protocol.Ack {
+	if err == nil {
+		return &protocol.Ack{Stream: stream, Message: "OK", Status: protocol.StatusSuccess}
+	}
+	return &protocol.Ack{Stream: stream, Message: err.Error(), Status: StatusOf(err)}
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package plugin_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+)
+
+// inputPlugin records the input of users, its uploads never complete.
+type inputPlugin struct {
+	keys      chan int
+	clipboard chan int
+}
+
+func (p *inputPlugin) Args() []string { return nil }
+
+func (p *inputPlugin) New(s *plugin.Session) (plugin.Connection, error) {
+	return &inputConn{p: p}, nil
+}
+
+type inputConn struct {
+	plugin.Base
+	p *inputPlugin
+}
+
+func (c *inputConn) Join(u *plugin.User, args plugin.Args) error { return nil }
+
+func (c *inputConn) Key(u *plugin.User, keysym int, pressed bool) error {
+	c.p.keys <- keysym
+	return nil
+}
+
+func (c *inputConn) Clipboard(u *plugin.User, mimetype string, data []byte) error {
+	c.p.clipboard <- len(data)
+	return nil
+}
+
+func (c *inputConn) File(u *plugin.User, f *plugin.FileStream) error {
+	select {} // neither reads nor returns
+}
+
+func TestSession_Input(t *testing.T) {
+	p := &inputPlugin{keys: make(chan int, 1), clipboard: make(chan int, 1)}
+	s, err := plugin.NewSession(p)
+	if err != nil {
+		t.Fatalf("new session error: %v", err)
+	}
+	defer s.Close()
+	u := &plugin.User{ID: "@user", Owner: true}
+	nc, peer := net.Pipe()
+	defer peer.Close()
+	if err := s.Join(u, nil, nc); err != nil {
+		t.Fatalf("join error: %v", err)
+	}
+	go s.Serve(u)
+
+	acks := make(chan *protocol.Ack, 100)
+	go func() {
+		parser := protocol.NewParser()
+		for {
+			elements, err := parser.Next(peer)
+			if err != nil {
+				return
+			}
+			strs := make([]string, len(elements))
+			for i := range elements {
+				strs[i] = string(elements[i])
+			}
+			if m, err := protocol.Decode(protocol.NewInstruction(strs)); err == nil {
+				if a, ok := m.(*protocol.Ack); ok {
+					acks <- a
+				}
+			}
+		}
+	}()
+	send := func(m protocol.Message) {
+		t.Helper()
+		peer.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		if _, err := peer.Write([]byte(m.Encode().String())); err != nil {
+			t.Fatalf("send %s error: %v", m.Opcode(), err)
+		}
+	}
+
+	// an upload which the plugin does not read does not block input
+	send(&protocol.File{Stream: 1, Mimetype: "text/plain", Filename: "a.txt"})
+	send(&protocol.Blob{Stream: 1, Data: []byte("a")})
+	send(&protocol.Key{Keysym: 'a', Pressed: true})
+	select {
+	case <-p.keys:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("key is blocked by the upload")
+	}
+
+	// clipboard contents are limited
+	send(&protocol.Clipboard{Stream: 2, Mimetype: "text/plain"})
+	blob := make([]byte, plugin.BlobSize)
+	for n := 0; n <= plugin.ClipboardMaxLength; n += len(blob) {
+		send(&protocol.Blob{Stream: 2, Data: blob})
+	}
+	send(&protocol.End{Stream: 2})
+	select {
+	case a := <-acks:
+		if a.Stream != 2 || a.Status != protocol.StatusClientTooMany {
+			t.Fatalf("ack of a large clipboard: got %+v", a)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("large clipboard is not rejected")
+	}
+	select {
+	case n := <-p.clipboard:
+		t.Fatalf("large clipboard of %d bytes is received", n)
+	default:
+	}
+
+	send(&protocol.Clipboard{Stream: 3, Mimetype: "text/plain"})
+	send(&protocol.Blob{Stream: 3, Data: blob})
+	send(&protocol.End{Stream: 3})
+	select {
+	case n := <-p.clipboard:
+		if n != len(blob) {
+			t.Fatalf("clipboard length: got %d, want %d", n, len(blob))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("clipboard is not received")
+	}
+}
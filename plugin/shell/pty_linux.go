@@ -0,0 +1,95 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// openPTY opens a new pseudo terminal and returns its master and slave.
+func openPTY() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("shell: open pty: %w", err)
+	}
+	var (
+		unlock int32
+		n      uint32
+	)
+	err = ioctl(master, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock)))
+	if err == nil {
+		err = ioctl(master, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n)))
+	}
+	if err == nil {
+		slave, err = os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	}
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("shell: open pty: %w", err)
+	}
+	return master, slave, nil
+}
+
+// setSize sets the window size of the pseudo terminal, which signals
+// SIGWINCH to the command.
+func setSize(master *os.File, cols, rows int) error {
+	ws := struct{ rows, cols, x, y uint16 }{uint16(rows), uint16(cols), 0, 0}
+	return ioctl(master, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+}
+
+// ioctl runs an ioctl on the file, the file is kept in non-blocking
+// mode so that closing it interrupts reads.
+func ioctl(f *os.File, req, arg uintptr) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	err = rc.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+	})
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// procAttr makes the command the session leader of its pseudo terminal,
+// which runs as the given uid and gid. The gid is the primary group of
+// the uid by default.
+func procAttr(uid, gid string) (*syscall.SysProcAttr, error) {
+	attr := &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	u, err := strconv.ParseUint(uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("shell: invalid uid %q", uid)
+	}
+	if gid == "" {
+		usr, err := user.LookupId(uid)
+		if err != nil {
+			return nil, fmt.Errorf("shell: primary group of uid %s: %w", uid, err)
+		}
+		gid = usr.Gid
+	}
+	g, err := strconv.ParseUint(gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("shell: invalid gid %q", gid)
+	}
+	if int(u) == os.Getuid() && int(g) == os.Getgid() {
+		return attr, nil // the user of the proxy, nothing to change
+	}
+	// supplementary groups of the proxy are dropped
+	attr.Credential = &syscall.Credential{Uid: uint32(u), Gid: uint32(g)}
+	return attr, nil
+}
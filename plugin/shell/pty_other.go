@@ -0,0 +1,27 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package shell
+
+import (
+	"os"
+	"syscall"
+
+	"changkun.de/x/occamy/plugin"
+)
+
+// openPTY is only supported on Linux.
+func openPTY() (master, slave *os.File, err error) {
+	return nil, nil, plugin.ErrNotSupported
+}
+
+func setSize(master *os.File, cols, rows int) error {
+	return plugin.ErrNotSupported
+}
+
+func procAttr(uid, gid string) (*syscall.SysProcAttr, error) {
+	return nil, plugin.ErrNotSupported
+}
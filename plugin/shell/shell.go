@@ -0,0 +1,193 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package shell implements a protocol plugin which runs commands of the
+// proxy host in a pseudo terminal. Importing the package registers the
+// plugin as "shell".
+//
+// The commands are allowlisted by the operator with the commands
+// argument, one "name=command arguments..." per line, and the hostname
+// of a connection selects the command by name. Commands are not run by
+// a shell, and run as the uid and gid arguments. The uid is required and
+// must not be root:
+//
+//	protocols:
+//	  shell:
+//	    uid: "1000"
+//	    commands: |
+//	      bash=/bin/bash -l
+//	      pods=/usr/local/bin/kubectl get pods --watch
+//
+// The output of the command is shown by the terminal emulator of the
+// ssh-go and telnet protocols. Pseudo terminals are only supported on
+// Linux.
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+	"changkun.de/x/occamy/plugin/termconn"
+)
+
+// Defaults of the connection arguments
+const (
+	DefaultTerminalType = "linux"
+	DefaultTypescript   = termconn.DefaultTypescript
+)
+
+// defaultPath is the PATH of the commands
+const defaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// killTimeout limits waiting for a command to exit after its pseudo
+// terminal is hung up, the command is killed afterwards.
+const killTimeout = 5 * time.Second
+
+// ErrCommandNotAllowed rejects commands which are not allowlisted
+var ErrCommandNotAllowed = errors.New("shell: command is not allowed")
+
+// ErrRootUser rejects commands without a uid or with the uid of root
+var ErrRootUser = errors.New("shell: commands require a uid other than 0")
+
+func init() {
+	plugin.Register("shell", Plugin{})
+}
+
+// Plugin is the shell protocol plugin.
+type Plugin struct{}
+
+// Args implements plugin.ProtocolPlugin
+func (Plugin) Args() []string {
+	return append([]string{
+		"hostname",
+		"commands",
+		"uid",
+		"gid",
+		"working-directory",
+		"terminal-type",
+	}, termconn.Args...)
+}
+
+// New implements plugin.ProtocolPlugin
+func (Plugin) New(s *plugin.Session) (plugin.Connection, error) {
+	return termconn.New(s, start), nil
+}
+
+// conn is a command running in a pseudo terminal
+type conn struct {
+	cmd  *exec.Cmd
+	pty  *os.File      // the master of the pseudo terminal
+	done chan struct{} // closed once the command exited
+}
+
+// start starts the command in a pseudo terminal of the given size.
+func start(args plugin.Args, cols, rows int) (termconn.Transport, error) {
+	commands, err := parseCommands(args.Get("commands"))
+	if err != nil {
+		return nil, plugin.StatusError(protocol.StatusServerError, err)
+	}
+	name := args.Get("hostname")
+	argv, ok := commands[name]
+	if !ok {
+		return nil, plugin.StatusError(protocol.StatusClientForbidden, fmt.Errorf("%w: %q", ErrCommandNotAllowed, name))
+	}
+	uid := args.Get("uid")
+	if strings.TrimLeft(uid, "0") == "" {
+		return nil, plugin.StatusError(protocol.StatusClientForbidden, ErrRootUser)
+	}
+	attr, err := procAttr(uid, args.Get("gid"))
+	if err != nil {
+		return nil, plugin.StatusError(protocol.StatusServerError, err)
+	}
+
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, plugin.StatusError(protocol.StatusServerError, err)
+	}
+	defer slave.Close()
+	setSize(master, cols, rows)
+
+	ttype := args.Get("terminal-type")
+	if ttype == "" {
+		ttype = DefaultTerminalType
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Env = []string{"TERM=" + ttype, "PATH=" + defaultPath}
+	cmd.Dir = args.Get("working-directory")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = slave, slave, slave
+	cmd.SysProcAttr = attr
+	if err := cmd.Start(); err != nil {
+		master.Close()
+		return nil, plugin.StatusError(protocol.StatusServerError, fmt.Errorf("shell: %w", err))
+	}
+	c := &conn{cmd: cmd, pty: master, done: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		close(c.done)
+	}()
+	return c, nil
+}
+
+// parseCommands parses the allowlist of commands, one name=command per
+// line. Empty lines and lines starting with # are skipped.
+func parseCommands(s string) (map[string][]string, error) {
+	commands := make(map[string][]string)
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		i := strings.IndexByte(l, '=')
+		if i <= 0 {
+			return nil, fmt.Errorf("shell: invalid command %q", l)
+		}
+		argv := strings.Fields(l[i+1:])
+		if len(argv) == 0 {
+			return nil, fmt.Errorf("shell: invalid command %q", l)
+		}
+		commands[strings.TrimSpace(l[:i])] = argv
+	}
+	return commands, nil
+}
+
+// Read reads the output of the command until it exits or the pseudo
+// terminal is closed.
+func (c *conn) Read(p []byte) (int, error) {
+	n, err := c.pty.Read(p)
+	if err != nil {
+		// reading fails with EIO once the command exited
+		<-c.done
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Write writes input to the command.
+func (c *conn) Write(p []byte) (int, error) {
+	return c.pty.Write(p)
+}
+
+// Resize changes the size of the pseudo terminal.
+func (c *conn) Resize(cols, rows int) {
+	setSize(c.pty, cols, rows)
+}
+
+// Close closes the pseudo terminal, which hangs up the command.
+func (c *conn) Close() error {
+	c.pty.Close()
+	select {
+	case <-c.done:
+	case <-time.After(killTimeout):
+		c.cmd.Process.Kill()
+		<-c.done
+	}
+	return nil
+}
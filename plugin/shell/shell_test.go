@@ -0,0 +1,216 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package shell_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+	"changkun.de/x/occamy/plugin/shell"
+)
+
+const commands = `
+# commands of the tests
+sh = /bin/sh
+size=/bin/stty size
+id=/usr/bin/id -u
+`
+
+// uid is a non-root user which the tests can run commands as.
+func uid() string {
+	if os.Getuid() == 0 {
+		return "65534"
+	}
+	return strconv.Itoa(os.Getuid())
+}
+
+func newSession(t *testing.T) *plugin.Session {
+	p, ok := plugin.Lookup("shell")
+	if !ok {
+		t.Fatalf("shell is not registered")
+	}
+	s, err := plugin.NewSession(p)
+	if err != nil {
+		t.Fatalf("new session error: %v", err)
+	}
+	return s
+}
+
+// run runs the command of the given name, sends the given input
+// instructions and returns the typescript once the command exited.
+func run(t *testing.T, args plugin.Args, input ...protocol.Message) string {
+	t.Helper()
+	u := &plugin.User{ID: "@owner", Owner: true, Width: 80 * 8, Height: 24 * 16}
+	ts, _ := runUser(t, u, args, input...)
+	return ts
+}
+
+// runUser runs the command as run does for the given owner, and also
+// returns the instructions received by the owner.
+func runUser(t *testing.T, u *plugin.User, args plugin.Args, input ...protocol.Message) (string, []protocol.Message) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "occamy-shell")
+	if err != nil {
+		t.Fatalf("create temp dir error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := newSession(t)
+	rw, peer := net.Pipe()
+	received := make(chan []protocol.Message, 1)
+	go func() {
+		var msgs []protocol.Message
+		p := protocol.NewParser()
+		for {
+			elements, err := p.Next(peer)
+			if err != nil {
+				break
+			}
+			strs := make([]string, len(elements))
+			for i := range elements {
+				strs[i] = string(elements[i])
+			}
+			if m, err := protocol.Decode(protocol.NewInstruction(strs)); err == nil {
+				msgs = append(msgs, m)
+			}
+		}
+		received <- msgs
+	}()
+	args["commands"] = commands
+	if _, ok := args["uid"]; !ok {
+		args["uid"] = uid()
+	}
+	args["typescript-path"] = dir
+	if err := s.Join(u, args, rw); err != nil {
+		t.Fatalf("join error: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(u) }()
+	for _, m := range input {
+		peer.Write([]byte(m.Encode().String()))
+	}
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("serve error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("session did not stop with the command")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, shell.DefaultTypescript))
+	if err != nil {
+		t.Fatalf("read typescript error: %v", err)
+	}
+	return string(data), <-received
+}
+
+func TestShell(t *testing.T) {
+	out := run(t, plugin.Args{"hostname": "size"})
+	if !strings.Contains(out, "24 80\r\n") {
+		t.Fatalf("initial size: got %q, want 24 80", out)
+	}
+
+	out = run(t, plugin.Args{"hostname": "sh"},
+		&protocol.Size{Width: 100 * 8, Height: 25 * 16},
+		&protocol.Clipboard{Stream: 1, Mimetype: "text/plain"},
+		&protocol.Blob{Stream: 1, Data: []byte("stty size; echo $TERM; exit\r")},
+		&protocol.End{Stream: 1},
+	)
+	if !strings.Contains(out, "25 100\r\n") {
+		t.Fatalf("resized size: got %q, want 25 100", out)
+	}
+	if !strings.Contains(out, "\r\n"+shell.DefaultTerminalType+"\r\n") {
+		t.Fatalf("terminal type: got %q, want %s", out, shell.DefaultTerminalType)
+	}
+}
+
+func TestShell_VT(t *testing.T) {
+	u := &plugin.User{ID: "@owner", Owner: true, Terminal: plugin.TerminalVT, Width: 100, Height: 25}
+	ts, msgs := runUser(t, u, plugin.Args{"hostname": "sh"},
+		&protocol.Pipe{Stream: 1, Mimetype: "application/octet-stream", Name: "STDIN"},
+		&protocol.Blob{Stream: 1, Data: []byte("stty size; exit\r")},
+		&protocol.End{Stream: 1},
+	)
+	if !strings.Contains(ts, "25 100\r\n") {
+		t.Fatalf("size in columns and rows: got %q, want 25 100", ts)
+	}
+
+	var out bytes.Buffer
+	for _, m := range msgs {
+		switch m := m.(type) {
+		case *protocol.Pipe:
+			if m.Name != "STDOUT" {
+				t.Fatalf("unexpected pipe %q", m.Name)
+			}
+		case *protocol.Blob:
+			out.Write(m.Data)
+		case *protocol.Img, *protocol.Rect, *protocol.Copy:
+			t.Fatalf("the display was sent to a vt user: %s", m.Opcode())
+		}
+	}
+	if !strings.Contains(out.String(), "25 100\r\n") {
+		t.Fatalf("vt output: got %q, want the output of the command", out.String())
+	}
+}
+
+func TestShell_Credential(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("changing the uid requires root")
+	}
+	out := run(t, plugin.Args{"hostname": "id", "uid": "65534", "gid": "65534"})
+	if !strings.Contains(out, "65534\r\n") {
+		t.Fatalf("uid: got %q, want 65534", out)
+	}
+}
+
+func TestShell_JoinError(t *testing.T) {
+	tests := []struct {
+		name   string
+		owner  bool
+		args   plugin.Args
+		status protocol.Status
+		err    error
+	}{
+		{"viewer", false, plugin.Args{"hostname": "sh", "commands": commands, "uid": uid()}, protocol.StatusResourceClosed, nil},
+		{"not allowed", true, plugin.Args{"hostname": "rm", "commands": commands, "uid": uid()}, protocol.StatusClientForbidden, shell.ErrCommandNotAllowed},
+		{"no commands", true, plugin.Args{"hostname": "sh", "uid": uid()}, protocol.StatusClientForbidden, shell.ErrCommandNotAllowed},
+		{"invalid commands", true, plugin.Args{"hostname": "sh", "commands": "sh=", "uid": uid()}, protocol.StatusServerError, nil},
+		{"no uid", true, plugin.Args{"hostname": "sh", "commands": commands}, protocol.StatusClientForbidden, shell.ErrRootUser},
+		{"root", true, plugin.Args{"hostname": "sh", "commands": commands, "uid": "0"}, protocol.StatusClientForbidden, shell.ErrRootUser},
+		{"invalid uid", true, plugin.Args{"hostname": "sh", "commands": commands, "uid": "root"}, protocol.StatusServerError, nil},
+		{"not found", true, plugin.Args{"hostname": "x", "commands": "x=/nonexistent", "uid": uid()}, protocol.StatusServerError, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw, _ := net.Pipe()
+			err := newSession(t).Join(&plugin.User{Owner: tt.owner}, tt.args, rw)
+			if err == nil {
+				t.Fatalf("join must fail")
+			}
+			if got := plugin.StatusOf(err); got != tt.status {
+				t.Fatalf("status: got %v, want %v (%v)", got, tt.status, err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("error: got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+
+	"changkun.de/x/occamy/internal/sshutil"
+	"changkun.de/x/occamy/plugin"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Errors of the host key verification
+var (
+	ErrHostKeyUnknown  = errors.New("ssh: host key is not known")
+	ErrHostKeyMismatch = errors.New("ssh: host key does not match the known host key")
+)
+
+// clientConfig returns the configuration of the SSH client by the
+// connection arguments. The private key is preferred over the password,
+// which is also used to answer keyboard-interactive prompts. The key is
+// presented with its certificate if the public-key is a certificate.
+func clientConfig(args plugin.Args) (*ssh.ClientConfig, error) {
+	config := &ssh.ClientConfig{User: args.Get("username")}
+	if config.User == "" {
+		return nil, errors.New("ssh: username is required")
+	}
+
+	var err error
+	config.Auth, err = sshutil.Auth(sshutil.Credentials{
+		Password:    args.Get("password"),
+		PrivateKey:  args.Get("private-key"),
+		Passphrase:  args.Get("passphrase"),
+		Certificate: args.Get("public-key"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	config.HostKeyCallback, config.HostKeyAlgorithms, err = hostKeyCallback(args.Get("host-key"))
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// hostKeyCallback returns the verification of the host key by the given
+// known_hosts lines, or by a public key in the authorized_keys format
+// which is accepted for any host. As the libssh2 based plugin, the host
+// key is not verified if no key is given.
+func hostKeyCallback(hostKey string) (ssh.HostKeyCallback, []string, error) {
+	if strings.TrimSpace(hostKey) == "" {
+		return ssh.InsecureIgnoreHostKey(), nil, nil
+	}
+
+	var algos []string
+	for rest := []byte(hostKey); ; {
+		var (
+			key ssh.PublicKey
+			err error
+		)
+		_, _, key, _, rest, err = ssh.ParseKnownHosts(rest)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			algos = nil
+			break
+		}
+		algos = append(algos, key.Type())
+	}
+	if len(algos) == 0 {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+		if err != nil {
+			return nil, nil, fmt.Errorf("ssh: invalid host-key: %w", err)
+		}
+		return checkHostKey(ssh.FixedHostKey(key)), []string{key.Type()}, nil
+	}
+
+	// the known hosts are only read from files
+	f, err := ioutil.TempFile("", "occamy-known-hosts")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = io.WriteString(f, hostKey)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	cb, err := knownhosts.New(f.Name())
+	if err != nil {
+		return nil, nil, fmt.Errorf("ssh: invalid host-key: %w", err)
+	}
+	return checkHostKey(cb), algos, nil
+}
+
+// checkHostKey maps the errors of a host key verification to
+// ErrHostKeyUnknown and ErrHostKeyMismatch.
+func checkHostKey(cb ssh.HostKeyCallback) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := cb(hostname, remote, key)
+		if err == nil {
+			return nil
+		}
+		var ke *knownhosts.KeyError
+		if errors.As(err, &ke) && len(ke.Want) == 0 {
+			err = ErrHostKeyUnknown
+		} else {
+			err = ErrHostKeyMismatch
+		}
+		return fmt.Errorf("%w: %s %s of %s", err, key.Type(), ssh.FingerprintSHA256(key), hostname)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package ssh implements an SSH terminal as a Go protocol plugin.
+// Importing the package registers the plugin as "ssh-go", which can be
+// used in place of the libssh2 based "ssh" protocol.
+//
+// The owner of a session logs in to the SSH server by password, private
+// key, certificate or keyboard-interactive authentication, and the shell or command
+// runs in a pseudo terminal which is shown by a terminal emulator. The
+// key of the server is verified if the host-key argument is given.
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/internal/sshutil"
+	"changkun.de/x/occamy/plugin"
+	"changkun.de/x/occamy/plugin/termconn"
+	"golang.org/x/crypto/ssh"
+)
+
+// Defaults of the connection arguments
+const (
+	DefaultPort         = "22"
+	DefaultTerminalType = "linux"
+	DefaultTypescript   = termconn.DefaultTypescript
+)
+
+// dialTimeout limits connecting to the SSH server and the handshake
+const dialTimeout = 15 * time.Second
+
+func init() {
+	plugin.Register("ssh-go", Plugin{})
+}
+
+// Plugin is the SSH protocol plugin.
+type Plugin struct{}
+
+// Args implements plugin.ProtocolPlugin
+func (Plugin) Args() []string {
+	return append([]string{
+		"hostname",
+		"host-key",
+		"port",
+		"username",
+		"password",
+		"private-key",
+		"passphrase",
+		"public-key",
+		"command",
+		"server-alive-interval",
+		"terminal-type",
+	}, termconn.Args...)
+}
+
+// New implements plugin.ProtocolPlugin
+func (Plugin) New(s *plugin.Session) (plugin.Connection, error) {
+	return termconn.New(s, dial), nil
+}
+
+// conn is a shell or command of an SSH server
+type conn struct {
+	client  *ssh.Client
+	session *ssh.Session
+	stdin   io.WriteCloser
+	stdout  *io.PipeReader // stdout and stderr of the session
+	stop    chan struct{}  // stops the keepalive
+}
+
+// dial logs in to the SSH server and starts the shell or command in a
+// pseudo terminal of the given size.
+func dial(args plugin.Args, cols, rows int) (termconn.Transport, error) {
+	hostname := args.Get("hostname")
+	if hostname == "" {
+		return nil, plugin.StatusError(protocol.StatusClientBadRequest, errors.New("ssh: hostname is required"))
+	}
+	port := args.Get("port")
+	if port == "" {
+		port = DefaultPort
+	}
+	config, err := clientConfig(args)
+	if err != nil {
+		return nil, plugin.StatusError(protocol.StatusClientBadRequest, err)
+	}
+
+	addr := net.JoinHostPort(hostname, port)
+	nc, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: %w", err)
+	}
+	nc.SetDeadline(time.Now().Add(dialTimeout))
+	client, err := sshutil.NewClient(nc, addr, config)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	nc.SetDeadline(time.Time{})
+
+	session, err := client.NewSession()
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("ssh: %w", err)
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("ssh: %w", err)
+	}
+	pr, pw := io.Pipe()
+	session.Stdout, session.Stderr = pw, pw
+
+	ttype := args.Get("terminal-type")
+	if ttype == "" {
+		ttype = DefaultTerminalType
+	}
+	modes := ssh.TerminalModes{ssh.VERASE: uint32(args.Int("backspace", 127))}
+	err = session.RequestPty(ttype, rows, cols, modes)
+	if err == nil {
+		if command := args.Get("command"); command != "" {
+			err = session.Start(command)
+		} else {
+			err = session.Shell()
+		}
+	}
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("ssh: %w", err)
+	}
+
+	c := &conn{client: client, session: session, stdin: stdin, stdout: pr, stop: make(chan struct{})}
+	go c.wait(pw)
+	if interval := args.Int("server-alive-interval", 0); interval > 0 {
+		go c.keepalive(time.Duration(interval) * time.Second)
+	}
+	return c, nil
+}
+
+// wait waits until the shell or command exits or the connection is
+// closed, which ends the output.
+func (c *conn) wait(stdout *io.PipeWriter) {
+	// the output is copied until the session ends
+	c.session.Wait()
+	close(c.stop)
+	c.client.Close()
+	stdout.Close()
+}
+
+// keepalive sends keepalive requests at the given interval, the
+// connection is closed if the server does not reply.
+func (c *conn) keepalive(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+		}
+		if _, _, err := c.client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+			c.client.Close()
+			return
+		}
+	}
+}
+
+// Read reads the output of the shell.
+func (c *conn) Read(p []byte) (int, error) {
+	return c.stdout.Read(p)
+}
+
+// Write writes input to the shell.
+func (c *conn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+// Resize changes the size of the pseudo terminal.
+func (c *conn) Resize(cols, rows int) {
+	c.session.WindowChange(rows, cols)
+}
+
+// Close closes the connection to the SSH server.
+func (c *conn) Close() error {
+	// the client is already closed if the shell exited
+	c.client.Close()
+	return nil
+}
@@ -0,0 +1,452 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package ssh_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+	occamyssh "changkun.de/x/occamy/plugin/ssh"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// server is an SSH server which accepts the user occamy with the
+// password secret or the given client key. It runs a stub shell which
+// exits after the first line, and reports the requests of the client as
+// events.
+type server struct {
+	ln      net.Listener
+	config  *ssh.ServerConfig
+	hostKey ssh.PublicKey
+	events  chan string
+}
+
+func newServer(t *testing.T, clientKey ssh.PublicKey, keyboardInteractive bool) *server {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate host key error: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("host key error: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+
+	s := &server{ln: ln, hostKey: signer.PublicKey(), events: make(chan string, 100)}
+	s.config = &ssh.ServerConfig{
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if clientKey == nil || !bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, errors.New("unknown key")
+			}
+			s.events <- "auth publickey " + c.User()
+			return nil, nil
+		},
+	}
+	if keyboardInteractive {
+		s.config.KeyboardInteractiveCallback = func(c ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := challenge("", "", []string{"Password: "}, []bool{false})
+			if err != nil || len(answers) != 1 || answers[0] != "secret" {
+				return nil, errors.New("wrong password")
+			}
+			s.events <- "auth keyboard-interactive " + c.User()
+			return nil, nil
+		}
+	} else {
+		s.config.PasswordCallback = func(c ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) != "secret" {
+				return nil, errors.New("wrong password")
+			}
+			s.events <- "auth password " + c.User()
+			return nil, nil
+		}
+	}
+	s.config.AddHostKey(signer)
+	go s.serve()
+	return s
+}
+
+func (s *server) port() string {
+	return strconv.Itoa(s.ln.Addr().(*net.TCPAddr).Port)
+}
+
+// knownHost returns the known_hosts line of the server.
+func (s *server) knownHost() string {
+	return knownhosts.Line([]string{s.ln.Addr().String()}, s.hostKey)
+}
+
+func (s *server) serve() {
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(c)
+	}
+}
+
+func (s *server) handle(c net.Conn) {
+	defer c.Close()
+	sc, chans, reqs, err := ssh.NewServerConn(c, s.config)
+	if err != nil {
+		return
+	}
+	defer sc.Close()
+	go func() {
+		for req := range reqs {
+			s.events <- req.Type
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}()
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			return
+		}
+		go s.session(ch, reqs)
+	}
+}
+
+func (s *server) session(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	for req := range reqs {
+		switch req.Type {
+		case "pty-req":
+			var p struct {
+				Term                      string
+				Cols, Rows, Width, Height uint32
+				Modes                     string
+			}
+			ssh.Unmarshal(req.Payload, &p)
+			erase := uint32(0)
+			for m := []byte(p.Modes); len(m) >= 5 && m[0] != 0; m = m[5:] {
+				if m[0] == ssh.VERASE {
+					erase = binary.BigEndian.Uint32(m[1:])
+				}
+			}
+			s.events <- fmt.Sprintf("pty %s %dx%d erase=%d", p.Term, p.Cols, p.Rows, erase)
+			req.Reply(true, nil)
+		case "window-change":
+			var p struct{ Cols, Rows, Width, Height uint32 }
+			ssh.Unmarshal(req.Payload, &p)
+			s.events <- fmt.Sprintf("window %dx%d", p.Cols, p.Rows)
+		case "exec":
+			var p struct{ Command string }
+			ssh.Unmarshal(req.Payload, &p)
+			s.events <- "exec " + p.Command
+			req.Reply(true, nil)
+			ch.Write([]byte("ran " + p.Command + "\r\n"))
+			exit(ch)
+		case "shell":
+			req.Reply(true, nil)
+			ch.Write([]byte("welcome occamy\r\n$ "))
+			go s.shell(ch)
+		default:
+			req.Reply(false, nil)
+		}
+	}
+}
+
+// shell reports the first line of input and exits.
+func (s *server) shell(ch ssh.Channel) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := ch.Read(b); err != nil {
+			return
+		}
+		if b[0] == '\r' {
+			break
+		}
+		line = append(line, b[0])
+	}
+	s.events <- "line " + string(line)
+	exit(ch)
+}
+
+func exit(ch ssh.Channel) {
+	ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+	ch.Close()
+}
+
+// expect waits for the given event, other events are skipped.
+func (s *server) expect(t *testing.T, want string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-s.events:
+			if e == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("event %q did not happen", want)
+		}
+	}
+}
+
+// clientKey generates a private key of the client in PEM format, which
+// is encrypted if a passphrase is given.
+func clientKey(t *testing.T, passphrase string) (string, ssh.PublicKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key error: %v", err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key error: %v", err)
+	}
+	block := &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	if passphrase != "" {
+		block, err = x509.EncryptPEMBlock(rand.Reader, block.Type, der, []byte(passphrase), x509.PEMCipherAES256)
+		if err != nil {
+			t.Fatalf("encrypt key error: %v", err)
+		}
+	}
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("public key error: %v", err)
+	}
+	return string(pem.EncodeToMemory(block)), pub
+}
+
+// clientCert signs a user certificate of the given key and returns it
+// in the authorized_keys format.
+func clientCert(t *testing.T, pub ssh.PublicKey) (string, *ssh.Certificate) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ca key error: %v", err)
+	}
+	ca, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("ca key error: %v", err)
+	}
+	cert := &ssh.Certificate{
+		Key:             pub,
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"occamy"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatalf("sign certificate error: %v", err)
+	}
+	return string(ssh.MarshalAuthorizedKey(cert)), cert
+}
+
+func newSession(t *testing.T) *plugin.Session {
+	p, ok := plugin.Lookup("ssh-go")
+	if !ok {
+		t.Fatalf("ssh-go is not registered")
+	}
+	s, err := plugin.NewSession(p)
+	if err != nil {
+		t.Fatalf("new session error: %v", err)
+	}
+	return s
+}
+
+// wait waits until the session stops and closes it.
+func wait(t *testing.T, s *plugin.Session, served chan error) {
+	t.Helper()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("serve error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("session did not stop with the SSH connection")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+}
+
+func TestSSH(t *testing.T) {
+	dir, err := ioutil.TempDir("", "occamy-ssh")
+	if err != nil {
+		t.Fatalf("create temp dir error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv := newServer(t, nil, false)
+	defer srv.ln.Close()
+	s := newSession(t)
+
+	rw, peer := net.Pipe()
+	go io.Copy(ioutil.Discard, peer)
+	u := &plugin.User{ID: "@owner", Owner: true, Width: 80 * 8, Height: 24 * 16}
+	err = s.Join(u, plugin.Args{
+		"hostname":               "127.0.0.1",
+		"port":                   srv.port(),
+		"host-key":               srv.knownHost(),
+		"username":               "occamy",
+		"password":               "secret",
+		"server-alive-interval":  "1",
+		"typescript-path":        filepath.Join(dir, "ts"),
+		"create-typescript-path": "true",
+	}, rw)
+	if err != nil {
+		t.Fatalf("join error: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(u) }()
+
+	srv.expect(t, "auth password occamy")
+	srv.expect(t, "pty linux 80x24 erase=127")
+
+	peer.Write([]byte((&protocol.Size{Width: 100 * 8, Height: 25 * 16}).Encode().String()))
+	srv.expect(t, "window 100x25")
+	srv.expect(t, "keepalive@openssh.com")
+
+	for _, k := range []int{'l', 's', 0xFF0D} {
+		peer.Write([]byte((&protocol.Key{Keysym: k, Pressed: true}).Encode().String()))
+		peer.Write([]byte((&protocol.Key{Keysym: k, Pressed: false}).Encode().String()))
+	}
+	srv.expect(t, "line ls")
+	wait(t, s, served)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "ts", occamyssh.DefaultTypescript))
+	if err != nil {
+		t.Fatalf("read typescript error: %v", err)
+	}
+	if !strings.Contains(string(data), "welcome occamy") {
+		t.Fatalf("typescript does not record the output: %q", data)
+	}
+}
+
+func TestSSH_Auth(t *testing.T) {
+	key, pub := clientKey(t, "")
+	encrypted, encryptedPub := clientKey(t, "passphrase")
+	certLine, cert := clientCert(t, pub)
+
+	tests := []struct {
+		name   string
+		pub    ssh.PublicKey
+		kbd    bool
+		args   plugin.Args
+		method string
+	}{
+		{"private key", pub, false, plugin.Args{"private-key": key}, "publickey"},
+		{"encrypted private key", encryptedPub, false, plugin.Args{"private-key": encrypted, "passphrase": "passphrase"}, "publickey"},
+		{"certificate", cert, false, plugin.Args{"private-key": key, "public-key": certLine}, "publickey"},
+		{"keyboard-interactive", nil, true, plugin.Args{"password": "secret"}, "keyboard-interactive"},
+		{"fallback to password", nil, false, plugin.Args{"private-key": key, "password": "secret"}, "password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newServer(t, tt.pub, tt.kbd)
+			defer srv.ln.Close()
+			s := newSession(t)
+
+			rw, peer := net.Pipe()
+			go io.Copy(ioutil.Discard, peer)
+			u := &plugin.User{ID: "@owner", Owner: true, Width: 80 * 8, Height: 24 * 16}
+			tt.args["hostname"] = "127.0.0.1"
+			tt.args["port"] = srv.port()
+			tt.args["username"] = "occamy"
+			tt.args["command"] = "uptime"
+			tt.args["terminal-type"] = "xterm"
+			tt.args["backspace"] = "8"
+			if err := s.Join(u, tt.args, rw); err != nil {
+				t.Fatalf("join error: %v", err)
+			}
+			served := make(chan error, 1)
+			go func() { served <- s.Serve(u) }()
+
+			srv.expect(t, "auth "+tt.method+" occamy")
+			srv.expect(t, "pty xterm 80x24 erase=8")
+			srv.expect(t, "exec uptime")
+			wait(t, s, served)
+		})
+	}
+}
+
+func TestSSH_JoinError(t *testing.T) {
+	srv := newServer(t, nil, false)
+	defer srv.ln.Close()
+	key, pub := clientKey(t, "")
+	other := newServer(t, nil, false)
+	other.ln.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	refused := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+
+	args := func(extra ...string) plugin.Args {
+		a := plugin.Args{"hostname": "127.0.0.1", "port": srv.port(), "username": "occamy", "password": "secret"}
+		for i := 0; i < len(extra); i += 2 {
+			a[extra[i]] = extra[i+1]
+		}
+		return a
+	}
+	tests := []struct {
+		name   string
+		owner  bool
+		args   plugin.Args
+		status protocol.Status
+		err    error
+	}{
+		{"viewer", false, args(), protocol.StatusResourceClosed, nil},
+		{"no hostname", true, args("hostname", ""), protocol.StatusClientBadRequest, nil},
+		{"no username", true, args("username", ""), protocol.StatusClientBadRequest, nil},
+		{"invalid private key", true, args("private-key", "key"), protocol.StatusClientBadRequest, nil},
+		{"public key without certificate", true, args("private-key", key, "public-key", string(ssh.MarshalAuthorizedKey(pub))),
+			protocol.StatusClientBadRequest, nil},
+		{"invalid host key", true, args("host-key", "ssh-ed25519 key"), protocol.StatusClientBadRequest, nil},
+		{"refused", true, args("port", refused), protocol.StatusUpstreamUnavailable, nil},
+		{"wrong password", true, args("password", "wrong"), protocol.StatusClientUnauthorized, nil},
+		{"host key mismatch", true, args("host-key", knownhosts.Line([]string{srv.ln.Addr().String()}, other.hostKey)),
+			protocol.StatusUpstreamError, occamyssh.ErrHostKeyMismatch},
+		{"unknown host", true, args("host-key", knownhosts.Line([]string{"example.com"}, srv.hostKey)),
+			protocol.StatusUpstreamError, occamyssh.ErrHostKeyUnknown},
+		{"fixed host key mismatch", true, args("host-key", string(ssh.MarshalAuthorizedKey(other.hostKey))),
+			protocol.StatusUpstreamError, occamyssh.ErrHostKeyMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw, _ := net.Pipe()
+			err := newSession(t).Join(&plugin.User{Owner: tt.owner}, tt.args, rw)
+			if err == nil {
+				t.Fatalf("join must fail")
+			}
+			if got := plugin.StatusOf(err); got != tt.status {
+				t.Fatalf("status: got %v, want %v (%v)", got, tt.status, err)
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("error: got %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package telnet
+
+import "bytes"
+
+// Telnet commands, see RFC 854
+const (
+	cmdSE   = 240
+	cmdSB   = 250
+	cmdWILL = 251
+	cmdWONT = 252
+	cmdDO   = 253
+	cmdDONT = 254
+	cmdIAC  = 255
+)
+
+// Telnet options
+const (
+	optEcho  = 1  // RFC 857
+	optSGA   = 3  // suppress go ahead, RFC 858
+	optTTYPE = 24 // terminal type, RFC 1091
+	optNAWS  = 31 // negotiate about window size, RFC 1073
+)
+
+// Subnegotiation commands of the terminal type option
+const (
+	ttypeIS   = 0
+	ttypeSEND = 1
+)
+
+// decoderState is the state of the telnet decoder
+type decoderState int
+
+const (
+	stateData decoderState = iota
+	stateIAC
+	stateOption // WILL, WONT, DO or DONT, the option follows
+	stateSB
+	stateSBIAC
+)
+
+// maxSubnegotiation limits the length of a subnegotiation
+const maxSubnegotiation = 1024
+
+// command is a telnet command, either an option negotiation or a
+// subnegotiation with its parameters.
+type command struct {
+	cmd    byte
+	opt    byte
+	params []byte
+}
+
+// decoder splits the telnet stream of the server into data and
+// commands. Commands may be split across reads.
+type decoder struct {
+	state decoderState
+	cmd   byte
+	sb    []byte
+}
+
+// decode decodes the given bytes, data is appended to the buffer and
+// the complete commands are returned.
+func (d *decoder) decode(p []byte, data *bytes.Buffer) []command {
+	var cmds []command
+	for _, b := range p {
+		switch d.state {
+		case stateData:
+			if b == cmdIAC {
+				d.state = stateIAC
+			} else {
+				data.WriteByte(b)
+			}
+		case stateIAC:
+			d.state = stateData
+			switch b {
+			case cmdIAC:
+				data.WriteByte(b)
+			case cmdWILL, cmdWONT, cmdDO, cmdDONT:
+				d.state, d.cmd = stateOption, b
+			case cmdSB:
+				d.state, d.sb = stateSB, d.sb[:0]
+			}
+		case stateOption:
+			d.state = stateData
+			cmds = append(cmds, command{cmd: d.cmd, opt: b})
+		case stateSB:
+			if b == cmdIAC {
+				d.state = stateSBIAC
+			} else if len(d.sb) < maxSubnegotiation {
+				d.sb = append(d.sb, b)
+			}
+		case stateSBIAC:
+			switch b {
+			case cmdSE:
+				d.state = stateData
+				if len(d.sb) > 0 {
+					params := append([]byte(nil), d.sb[1:]...)
+					cmds = append(cmds, command{cmd: cmdSB, opt: d.sb[0], params: params})
+				}
+			case cmdIAC:
+				d.state = stateSB
+				if len(d.sb) < maxSubnegotiation {
+					d.sb = append(d.sb, b)
+				}
+			default:
+				d.state = stateData // malformed, the subnegotiation is dropped
+			}
+		}
+	}
+	return cmds
+}
+
+// escape escapes the IAC bytes of data sent to the server.
+func escape(p []byte) []byte {
+	if bytes.IndexByte(p, cmdIAC) < 0 {
+		return p
+	}
+	return bytes.ReplaceAll(p, []byte{cmdIAC}, []byte{cmdIAC, cmdIAC})
+}
+
+// naws is the subnegotiation of the window size
+func naws(cols, rows int) []byte {
+	b := []byte{cmdIAC, cmdSB, optNAWS}
+	for _, v := range []int{cols, rows} {
+		b = append(b, escape([]byte{byte(v >> 8), byte(v)})...)
+	}
+	return append(b, cmdIAC, cmdSE)
+}
@@ -0,0 +1,275 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package telnet implements the telnet protocol as a Go protocol plugin.
+// Importing the package registers the plugin as "telnet".
+//
+// The owner of a session connects to the telnet server, and the output
+// of the server is shown by a terminal emulator. The terminal size is
+// negotiated by NAWS, and the terminal logs in automatically if the
+// username and password are given and their prompts are recognized.
+package telnet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"sync"
+	"time"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+	"changkun.de/x/occamy/plugin/termconn"
+)
+
+// Defaults of the connection arguments
+const (
+	DefaultPort          = "23"
+	DefaultUsernameRegex = `[Ll]ogin:`
+	DefaultPasswordRegex = `[Pp]assword:`
+	DefaultTerminalType  = "linux"
+	DefaultTypescript    = termconn.DefaultTypescript
+)
+
+// dialTimeout limits connecting to the telnet server
+const dialTimeout = 15 * time.Second
+
+// maxPromptLength limits the output line matched against prompts
+const maxPromptLength = 1024
+
+func init() {
+	plugin.Register("telnet", Plugin{})
+}
+
+// Plugin is the telnet protocol plugin.
+type Plugin struct{}
+
+// Args implements plugin.ProtocolPlugin
+func (Plugin) Args() []string {
+	return append([]string{
+		"hostname",
+		"port",
+		"username",
+		"username-regex",
+		"password",
+		"password-regex",
+		"terminal-type",
+	}, termconn.Args...)
+}
+
+// New implements plugin.ProtocolPlugin
+func (Plugin) New(s *plugin.Session) (plugin.Connection, error) {
+	return termconn.New(s, dial), nil
+}
+
+// conn is a connection to a telnet server
+type conn struct {
+	nc    net.Conn
+	ttype string
+	buf   []byte
+	d     decoder
+
+	mu         sync.Mutex // protects the fields below
+	cols, rows int
+	remote     map[byte]bool // options enabled by the server
+	local      map[byte]bool // options enabled by us
+
+	// auto-login state, the regexps are nil once the prompts appeared
+	username, password string
+	userRe, passRe     *regexp.Regexp
+	line               []byte
+}
+
+// dial connects to the telnet server.
+func dial(args plugin.Args, cols, rows int) (termconn.Transport, error) {
+	hostname := args.Get("hostname")
+	if hostname == "" {
+		return nil, plugin.StatusError(protocol.StatusClientBadRequest, errors.New("telnet: hostname is required"))
+	}
+	port := args.Get("port")
+	if port == "" {
+		port = DefaultPort
+	}
+	c := &conn{
+		ttype:  args.Get("terminal-type"),
+		cols:   cols,
+		rows:   rows,
+		remote: make(map[byte]bool),
+		local:  make(map[byte]bool),
+	}
+	if c.ttype == "" {
+		c.ttype = DefaultTerminalType
+	}
+	if err := c.prompts(args); err != nil {
+		return nil, plugin.StatusError(protocol.StatusClientBadRequest, err)
+	}
+
+	nc, err := net.DialTimeout("tcp", net.JoinHostPort(hostname, port), dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("telnet: %w", err)
+	}
+	c.nc = nc
+	return c, nil
+}
+
+// prompts compiles the prompts of the auto-login.
+func (c *conn) prompts(args plugin.Args) error {
+	c.username, c.password = args.Get("username"), args.Get("password")
+	compile := func(name, def string) (*regexp.Regexp, error) {
+		expr := args.Get(name)
+		if expr == "" {
+			expr = def
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("telnet: invalid %s: %w", name, err)
+		}
+		return re, nil
+	}
+	var err error
+	if c.username != "" {
+		c.userRe, err = compile("username-regex", DefaultUsernameRegex)
+		if err != nil {
+			return err
+		}
+	}
+	if c.password != "" {
+		c.passRe, err = compile("password-regex", DefaultPasswordRegex)
+	}
+	return err
+}
+
+// Read reads the output of the telnet server, the commands in the
+// output are answered.
+func (c *conn) Read(p []byte) (int, error) {
+	if len(c.buf) < len(p) {
+		c.buf = make([]byte, len(p))
+	}
+	var data bytes.Buffer
+	for {
+		n, err := c.nc.Read(c.buf[:len(p)])
+		if n > 0 {
+			for _, cmd := range c.d.decode(c.buf[:n], &data) {
+				c.negotiate(cmd)
+			}
+			if data.Len() > 0 {
+				c.login(data.Bytes())
+				// the output is never longer than its encoding
+				return copy(p, data.Bytes()), err
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// negotiate answers an option negotiation of the server. Options are
+// only acknowledged if their state changes, which avoids negotiation
+// loops.
+func (c *conn) negotiate(cmd command) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch cmd.cmd {
+	case cmdWILL:
+		if cmd.opt != optEcho && cmd.opt != optSGA {
+			c.write(cmdIAC, cmdDONT, cmd.opt)
+		} else if !c.remote[cmd.opt] {
+			c.remote[cmd.opt] = true
+			c.write(cmdIAC, cmdDO, cmd.opt)
+		}
+	case cmdWONT:
+		if c.remote[cmd.opt] {
+			c.remote[cmd.opt] = false
+			c.write(cmdIAC, cmdDONT, cmd.opt)
+		}
+	case cmdDO:
+		if cmd.opt != optNAWS && cmd.opt != optTTYPE && cmd.opt != optSGA {
+			c.write(cmdIAC, cmdWONT, cmd.opt)
+		} else if !c.local[cmd.opt] {
+			c.local[cmd.opt] = true
+			c.write(cmdIAC, cmdWILL, cmd.opt)
+			if cmd.opt == optNAWS {
+				c.write(naws(c.cols, c.rows)...)
+			}
+		}
+	case cmdDONT:
+		if c.local[cmd.opt] {
+			c.local[cmd.opt] = false
+			c.write(cmdIAC, cmdWONT, cmd.opt)
+		}
+	case cmdSB:
+		if cmd.opt == optTTYPE && len(cmd.params) > 0 && cmd.params[0] == ttypeSEND {
+			b := append([]byte{cmdIAC, cmdSB, optTTYPE, ttypeIS}, escape([]byte(c.ttype))...)
+			c.write(append(b, cmdIAC, cmdSE)...)
+		}
+	}
+}
+
+// login answers the username and password prompts in the output of
+// the server.
+func (c *conn) login(p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(p) > 0 && (c.userRe != nil || c.passRe != nil) {
+		i := bytes.IndexByte(p, '\n')
+		end := i >= 0
+		seg := p
+		if end {
+			seg, p = p[:i], p[i+1:]
+		} else {
+			p = nil
+		}
+		c.line = append(c.line, seg...)
+		if over := len(c.line) - maxPromptLength; over > 0 {
+			c.line = append(c.line[:0], c.line[over:]...)
+		}
+
+		switch {
+		case c.userRe != nil && c.userRe.Match(c.line):
+			c.userRe = nil
+			c.write(escape([]byte(c.username + "\r"))...)
+			c.line = c.line[:0]
+		case c.passRe != nil && c.passRe.Match(c.line):
+			// the username prompt is not expected after the password
+			c.userRe, c.passRe = nil, nil
+			c.write(escape([]byte(c.password + "\r"))...)
+			c.line = c.line[:0]
+		case end:
+			c.line = c.line[:0]
+		}
+	}
+}
+
+// Write writes input to the telnet server.
+func (c *conn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.nc.Write(escape(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// write writes to the telnet server, c.mu must be held.
+func (c *conn) write(p ...byte) {
+	c.nc.Write(p)
+}
+
+// Resize sends the size of the terminal if the server enabled NAWS.
+func (c *conn) Resize(cols, rows int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cols, c.rows = cols, rows
+	if c.local[optNAWS] {
+		c.write(naws(cols, rows)...)
+	}
+}
+
+// Close closes the connection to the telnet server.
+func (c *conn) Close() error {
+	return c.nc.Close()
+}
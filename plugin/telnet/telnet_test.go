@@ -0,0 +1,240 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package telnet_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+	"changkun.de/x/occamy/plugin/telnet"
+)
+
+// Telnet commands and options used by the stub server
+const (
+	iac   = 255
+	dont  = 254
+	do    = 253
+	wont  = 252
+	will  = 251
+	sb    = 250
+	se    = 240
+	echo  = 1
+	ttype = 24
+	naws  = 31
+)
+
+// stub is a telnet server which asks for the terminal type and window
+// size, prompts for a username and password, and reports everything
+// the client sends as events.
+type stub struct {
+	ln     net.Listener
+	events chan string
+}
+
+func newStub(t *testing.T) *stub {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	s := &stub{ln: ln, events: make(chan string, 100)}
+	go s.serve()
+	return s
+}
+
+func (s *stub) port() string {
+	return strconv.Itoa(s.ln.Addr().(*net.TCPAddr).Port)
+}
+
+func (s *stub) serve() {
+	c, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer c.Close()
+	c.Write([]byte{iac, do, naws, iac, do, ttype, iac, will, echo, iac, sb, ttype, 1, iac, se})
+	c.Write([]byte("Welcome\r\nlogin: "))
+
+	r := bufio.NewReader(c)
+	var line []byte
+	lines := 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		if b != iac {
+			if b != '\r' {
+				line = append(line, b)
+				continue
+			}
+			s.events <- "line " + string(line)
+			line = line[:0]
+			lines++
+			switch lines {
+			case 1:
+				c.Write([]byte("\r\nPassword: "))
+			case 2:
+				c.Write([]byte("\r\nwelcome occamy\r\n$ "))
+			default:
+				return // the session ends on the first command
+			}
+			continue
+		}
+
+		cmd, _ := r.ReadByte()
+		opt, _ := r.ReadByte()
+		switch cmd {
+		case will, wont, do, dont:
+			s.events <- fmt.Sprintf("%d %d", cmd, opt)
+		case sb:
+			var params []byte
+			for {
+				p, _ := r.ReadByte()
+				if p == iac {
+					r.ReadByte() // SE
+					break
+				}
+				params = append(params, p)
+			}
+			s.events <- fmt.Sprintf("sb %d %v", opt, params)
+		}
+	}
+}
+
+// expect waits for the given event, other events are skipped.
+func (s *stub) expect(t *testing.T, want string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-s.events:
+			if e == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("event %q did not happen", want)
+		}
+	}
+}
+
+func newSession(t *testing.T) *plugin.Session {
+	p, ok := plugin.Lookup("telnet")
+	if !ok {
+		t.Fatalf("telnet is not registered")
+	}
+	s, err := plugin.NewSession(p)
+	if err != nil {
+		t.Fatalf("new session error: %v", err)
+	}
+	return s
+}
+
+func TestTelnet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "occamy-telnet")
+	if err != nil {
+		t.Fatalf("create temp dir error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	srv := newStub(t)
+	defer srv.ln.Close()
+	s := newSession(t)
+
+	rw, peer := net.Pipe()
+	go io.Copy(ioutil.Discard, peer)
+	u := &plugin.User{ID: "@owner", Owner: true, Width: 80 * 8, Height: 24 * 16}
+	err = s.Join(u, plugin.Args{
+		"hostname":               "127.0.0.1",
+		"port":                   srv.port(),
+		"username":               "occamy",
+		"password":               "secret",
+		"typescript-path":        filepath.Join(dir, "ts"),
+		"create-typescript-path": "true",
+	}, rw)
+	if err != nil {
+		t.Fatalf("join error: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(u) }()
+
+	srv.expect(t, fmt.Sprintf("%d %d", will, naws))
+	srv.expect(t, "sb 31 [0 80 0 24]")
+	srv.expect(t, fmt.Sprintf("sb %d %v", ttype, append([]byte{0}, telnet.DefaultTerminalType...)))
+	srv.expect(t, "line occamy")
+	srv.expect(t, "line secret")
+
+	peer.Write([]byte((&protocol.Size{Width: 100 * 8, Height: 25 * 16}).Encode().String()))
+	srv.expect(t, "sb 31 [0 100 0 25]")
+
+	for _, k := range []int{'l', 's', 0xFF0D} {
+		peer.Write([]byte((&protocol.Key{Keysym: k, Pressed: true}).Encode().String()))
+		peer.Write([]byte((&protocol.Key{Keysym: k, Pressed: false}).Encode().String()))
+	}
+	srv.expect(t, "line ls")
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("serve error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("session did not stop with the telnet connection")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "ts", telnet.DefaultTypescript))
+	if err != nil {
+		t.Fatalf("read typescript error: %v", err)
+	}
+	if !strings.Contains(string(data), "welcome occamy") {
+		t.Fatalf("typescript does not record the output: %q", data)
+	}
+}
+
+func TestTelnet_JoinError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+
+	tests := []struct {
+		name   string
+		owner  bool
+		args   plugin.Args
+		status protocol.Status
+	}{
+		{"viewer", false, plugin.Args{"hostname": "127.0.0.1"}, protocol.StatusResourceClosed},
+		{"no hostname", true, plugin.Args{}, protocol.StatusClientBadRequest},
+		{"invalid regex", true, plugin.Args{"hostname": "127.0.0.1", "username": "u", "username-regex": "("}, protocol.StatusClientBadRequest},
+		{"refused", true, plugin.Args{"hostname": "127.0.0.1", "port": port}, protocol.StatusUpstreamUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rw, _ := net.Pipe()
+			err := newSession(t).Join(&plugin.User{Owner: tt.owner}, tt.args, rw)
+			if err == nil {
+				t.Fatalf("join must fail")
+			}
+			if got := plugin.StatusOf(err); got != tt.status {
+				t.Fatalf("status: got %v, want %v", got, tt.status)
+			}
+		})
+	}
+}
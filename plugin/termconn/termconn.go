@@ -0,0 +1,216 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package termconn implements the connection of protocol plugins whose
+// output is shown by the terminal emulator, e.g. telnet, ssh-go and
+// shell. The plugins only provide the transport of the terminal.
+//
+// The owner of a session opens the transport and controls the size of
+// the terminal, other users view the terminal and share its input
+// unless the connection is read-only.
+package termconn
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/internal/terminal"
+	"changkun.de/x/occamy/plugin"
+)
+
+// DefaultTypescript is the default name of typescripts
+const DefaultTypescript = "typescript"
+
+// Args are the connection arguments of the terminal, which are accepted
+// by the plugins in addition to the arguments of their transport.
+var Args = []string{
+	"read-only",
+	"backspace",
+	"color-scheme",
+	"scrollback",
+	"typescript-path",
+	"typescript-name",
+	"create-typescript-path",
+}
+
+// Transport carries the output and the input of a terminal.
+type Transport interface {
+	// Read reads the output, io.EOF ends the session normally.
+	io.Reader
+	// Write writes the input.
+	io.Writer
+	// Resize changes the size of the terminal in columns and rows.
+	Resize(cols, rows int)
+	// Close closes the transport, the output ends afterwards.
+	Close() error
+}
+
+// DialFunc opens the transport of the owner of a session with a
+// terminal of the given size.
+type DialFunc func(args plugin.Args, cols, rows int) (Transport, error)
+
+// Conn is a connection of a terminal.
+type Conn struct {
+	plugin.Base
+	s    *plugin.Session
+	dial DialFunc
+
+	term     *terminal.Terminal
+	ts       *terminal.Typescript
+	readOnly bool
+	done     chan struct{} // closed once the output is read
+
+	mu sync.Mutex // serializes input of the terminal
+	t  Transport
+}
+
+// New returns a connection of the session which opens its transport
+// by dial.
+func New(s *plugin.Session, dial DialFunc) *Conn {
+	return &Conn{s: s, dial: dial}
+}
+
+// Join implements plugin.Connection, the owner opens the transport.
+func (c *Conn) Join(u *plugin.User, args plugin.Args) error {
+	if !u.Owner {
+		if c.term == nil {
+			return plugin.ErrClosed
+		}
+		if u.Terminal == plugin.TerminalVT {
+			c.term.Attach(u)
+		}
+		return nil
+	}
+
+	width, height := terminal.DisplaySize(u, u.Width, u.Height)
+	cols, rows := terminal.GridSize(width, height)
+	t, err := c.dial(args, cols, rows)
+	if err != nil {
+		return err
+	}
+	var ts *terminal.Typescript
+	if path := args.Get("typescript-path"); path != "" {
+		name := args.Get("typescript-name")
+		if name == "" {
+			name = DefaultTypescript
+		}
+		ts, err = terminal.NewTypescript(path, name, args.Bool("create-typescript-path"))
+		if err != nil {
+			t.Close()
+			return plugin.StatusError(protocol.StatusServerError, err)
+		}
+	}
+
+	scheme := terminal.SchemeByName(args.Get("color-scheme"))
+	term := terminal.New(c.s.Display(), width, height, terminal.Options{
+		Scheme:     &scheme,
+		Scrollback: args.Int("scrollback", terminal.DefaultScrollback),
+		Backspace:  byte(args.Int("backspace", 127)),
+		Typescript: ts,
+		Reply:      c.send,
+	})
+	c.term, c.ts, c.t = term, ts, t
+	c.readOnly = args.Bool("read-only")
+	c.done = make(chan struct{})
+	if u.Terminal == plugin.TerminalVT {
+		c.term.Attach(u)
+	}
+	go c.read()
+	return nil
+}
+
+// read reads the output of the transport until it ends, which stops
+// the session.
+func (c *Conn) read() {
+	defer close(c.done)
+	buf := make([]byte, 4096)
+	for {
+		n, err := c.t.Read(buf)
+		if n > 0 {
+			c.term.Write(buf[:n])
+		}
+		if err != nil {
+			// errors after Close are ignored as the session is stopped
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			c.s.Stop(err)
+			return
+		}
+	}
+}
+
+// send sends input to the transport.
+func (c *Conn) send(p []byte) {
+	if len(p) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t.Write(p)
+}
+
+// Key implements plugin.Connection
+func (c *Conn) Key(u *plugin.User, keysym int, pressed bool) error {
+	b := c.term.Key(keysym, pressed)
+	if !c.readOnly {
+		c.send(b)
+	}
+	return nil
+}
+
+// Mouse implements plugin.Connection
+func (c *Conn) Mouse(u *plugin.User, x, y, mask int) error {
+	c.term.Mouse(x, y, mask)
+	return nil
+}
+
+// Size implements plugin.Connection, the terminal follows the display
+// size of the owner.
+func (c *Conn) Size(u *plugin.User, width, height int) error {
+	if !u.Owner {
+		return nil
+	}
+	c.t.Resize(c.term.Resize(terminal.DisplaySize(u, width, height)))
+	return nil
+}
+
+// Input implements plugin.Connection
+func (c *Conn) Input(u *plugin.User, data []byte) error {
+	if !c.readOnly {
+		c.send(data)
+	}
+	return nil
+}
+
+// Leave implements plugin.Connection
+func (c *Conn) Leave(u *plugin.User) {
+	c.term.Detach(u)
+}
+
+// Clipboard implements plugin.Connection, text is pasted.
+func (c *Conn) Clipboard(u *plugin.User, mimetype string, data []byte) error {
+	if !c.readOnly && strings.HasPrefix(mimetype, "text/") {
+		c.send(data)
+	}
+	return nil
+}
+
+// Close implements plugin.Connection
+func (c *Conn) Close() error {
+	var err error
+	if c.t != nil {
+		err = c.t.Close()
+		<-c.done
+	}
+	if c.ts != nil {
+		if terr := c.ts.Close(); err == nil {
+			err = terr
+		}
+	}
+	return err
+}
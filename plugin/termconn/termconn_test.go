@@ -0,0 +1,136 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package termconn_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/internal/terminal"
+	"changkun.de/x/occamy/plugin"
+	"changkun.de/x/occamy/plugin/termconn"
+)
+
+type nopPlugin struct{}
+
+func (nopPlugin) Args() []string { return nil }
+func (nopPlugin) New(s *plugin.Session) (plugin.Connection, error) {
+	return nopConn{}, nil
+}
+
+type nopConn struct{ plugin.Base }
+
+func (nopConn) Join(u *plugin.User, args plugin.Args) error { return nil }
+
+// pipe is a transport whose output is written by the test, its input
+// and sizes are recorded.
+type pipe struct {
+	*io.PipeReader
+	out   *io.PipeWriter
+	input chan string
+	sizes chan string
+}
+
+func (p *pipe) Write(b []byte) (int, error) {
+	p.input <- string(b)
+	return len(b), nil
+}
+
+func (p *pipe) Resize(cols, rows int) { p.sizes <- fmt.Sprintf("%dx%d", cols, rows) }
+
+func (p *pipe) Close() error {
+	p.out.Close()
+	return nil
+}
+
+func newConn(t *testing.T) (*plugin.Session, *termconn.Conn, *pipe) {
+	s, err := plugin.NewSession(nopPlugin{})
+	if err != nil {
+		t.Fatalf("new session error: %v", err)
+	}
+	pr, pw := io.Pipe()
+	p := &pipe{PipeReader: pr, out: pw, input: make(chan string, 10), sizes: make(chan string, 10)}
+	c := termconn.New(s, func(args plugin.Args, cols, rows int) (termconn.Transport, error) {
+		p.Resize(cols, rows)
+		return p, nil
+	})
+	return s, c, p
+}
+
+func expect(t *testing.T, ch chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-ch:
+		if got != want {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("got nothing, want %q", want)
+	}
+}
+
+func TestConn(t *testing.T) {
+	s, c, p := newConn(t)
+	owner := &plugin.User{Owner: true, Width: 80 * terminal.CellWidth, Height: 24 * terminal.CellHeight}
+	viewer := &plugin.User{}
+
+	if err := c.Join(viewer, nil); !errors.Is(err, plugin.ErrClosed) {
+		t.Fatalf("viewer joined before the owner: %v", err)
+	}
+	if err := c.Join(owner, plugin.Args{}); err != nil {
+		t.Fatalf("join error: %v", err)
+	}
+	expect(t, p.sizes, "80x24")
+	if err := c.Join(viewer, nil); err != nil {
+		t.Fatalf("viewer join error: %v", err)
+	}
+
+	c.Key(viewer, 'a', true)
+	expect(t, p.input, "a")
+	c.Clipboard(owner, "text/plain", []byte("b"))
+	expect(t, p.input, "b")
+	c.Size(viewer, 10*terminal.CellWidth, 10*terminal.CellHeight)
+	c.Size(owner, 100*terminal.CellWidth, 25*terminal.CellHeight)
+	expect(t, p.sizes, "100x25")
+
+	// the session stops once the output ends
+	p.out.Close()
+	rw, peer := net.Pipe()
+	go io.Copy(ioutil.Discard, peer)
+	deadline := time.Now().Add(time.Second)
+	for s.Join(&plugin.User{}, nil, rw) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("the session did not stop")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+}
+
+func TestConn_ReadOnly(t *testing.T) {
+	_, c, p := newConn(t)
+	owner := &plugin.User{Owner: true}
+	if err := c.Join(owner, plugin.Args{"read-only": "true"}); err != nil {
+		t.Fatalf("join error: %v", err)
+	}
+	c.Key(owner, 'a', true)
+	c.Input(owner, []byte("b"))
+	c.Clipboard(owner, "text/plain", []byte("c"))
+	select {
+	case in := <-p.input:
+		t.Fatalf("input of a read-only connection: %q", in)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+}
@@ -0,0 +1,643 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package vnc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+
+	"changkun.de/x/occamy/plugin"
+)
+
+// Encodings and pseudo-encodings
+const (
+	encRaw         int32 = 0
+	encCopyRect    int32 = 1
+	encHextile     int32 = 5
+	encTight       int32 = 7
+	encZRLE        int32 = 16
+	encCursor      int32 = -239
+	encDesktopSize int32 = -223
+	encLastRect    int32 = -224
+)
+
+// encodings are the encodings by name, in the default order of
+// preference
+var encodings = []struct {
+	name string
+	enc  int32
+}{
+	{"tight", encTight},
+	{"zrle", encZRLE},
+	{"hextile", encHextile},
+	{"copyrect", encCopyRect},
+	{"raw", encRaw},
+}
+
+// Hextile subencodings
+const (
+	hextileRaw              = 1
+	hextileBackground       = 2
+	hextileForeground       = 4
+	hextileAnySubrects      = 8
+	hextileSubrectsColoured = 16
+)
+
+// Tight compression types and filters
+const (
+	tightFill           = 8
+	tightJPEG           = 9
+	tightFilterCopy     = 0
+	tightFilterPalette  = 1
+	tightFilterGradient = 2
+	tightMinToCompress  = 12
+)
+
+// Sizes and limits of the encodings
+const (
+	bytesPerPixel        = 4
+	bytesPerCompactPixel = 3
+	hextileTileSize      = 16
+	zrleTileSize         = 64
+	zrleMaxCompressed    = 1 << 26
+	tightMaxCompressed   = 1 << 22
+	cursorMaxSize        = 512
+)
+
+// update reads a framebuffer update and draws it to the display.
+func (c *rfbConn) update(d *plugin.Display) error {
+	if err := c.skip(1); err != nil {
+		return err
+	}
+	n, err := c.readU16()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		var rect struct {
+			X, Y, W, H uint16
+			Encoding   int32
+		}
+		if err := binary.Read(c.r, binary.BigEndian, &rect); err != nil {
+			return err
+		}
+		x, y, w, h := int(rect.X), int(rect.Y), int(rect.W), int(rect.H)
+
+		switch rect.Encoding {
+		case encCursor:
+			if err := c.cursor(d, x, y, w, h); err != nil {
+				return err
+			}
+			continue
+		case encDesktopSize:
+			if err := checkSize(w, h); err != nil {
+				return err
+			}
+			c.resize(w, h)
+			d.Resize(w, h)
+			continue
+		case encLastRect:
+			return nil
+		}
+
+		fw, fh := c.size()
+		if x+w > fw || y+h > fh {
+			return fmt.Errorf("vnc: rectangle %dx%d+%d+%d exceeds the framebuffer", w, h, x, y)
+		}
+		var img image.Image
+		switch rect.Encoding {
+		case encRaw:
+			img, err = c.raw(w, h)
+		case encCopyRect:
+			var sx, sy int
+			if sx, err = c.readU16(); err == nil {
+				sy, err = c.readU16()
+			}
+			if err == nil && w > 0 && h > 0 {
+				d.Copy(image.Rect(sx, sy, sx+w, sy+h), image.Pt(x, y))
+			}
+		case encHextile:
+			img, err = c.hextile(w, h)
+		case encZRLE:
+			img, err = c.zrleRect(w, h)
+		case encTight:
+			img, err = c.tightRect(w, h)
+		default:
+			return fmt.Errorf("vnc: unsupported encoding %d", rect.Encoding)
+		}
+		if err != nil {
+			return err
+		}
+		if img != nil && w > 0 && h > 0 {
+			d.Draw(x, y, img)
+		}
+	}
+	return nil
+}
+
+// setRGB sets the pixel at the given offset of the image.
+func setRGB(img *image.RGBA, off int, r, g, b byte) {
+	img.Pix[off], img.Pix[off+1], img.Pix[off+2], img.Pix[off+3] = r, g, b, 0xFF
+}
+
+// fill fills the given rectangle of the image.
+func fill(img *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			setRGB(img, img.PixOffset(x, y), c.R, c.G, c.B)
+		}
+	}
+}
+
+// pixel decodes a pixel of pixelFormat, compact pixels are the first
+// three bytes of a pixel.
+func pixel(p []byte) color.RGBA {
+	return color.RGBA{p[2], p[1], p[0], 0xFF}
+}
+
+// raw reads pixels of the raw encoding.
+func (c *rfbConn) raw(w, h int) (*image.RGBA, error) {
+	buf := make([]byte, w*h*bytesPerPixel)
+	if _, err := io.ReadFull(c.r, buf); err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(buf); i += bytesPerPixel {
+		setRGB(img, i, buf[i+2], buf[i+1], buf[i])
+	}
+	return img, nil
+}
+
+// readPixel reads a pixel of the given size.
+func readPixel(r io.Reader, size int) (color.RGBA, error) {
+	var p [bytesPerPixel]byte
+	_, err := io.ReadFull(r, p[:size])
+	return pixel(p[:]), err
+}
+
+// hextile reads the tiles of the hextile encoding.
+func (c *rfbConn) hextile(w, h int) (*image.RGBA, error) {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	var bg, fg color.RGBA
+	for ty := 0; ty < h; ty += hextileTileSize {
+		for tx := 0; tx < w; tx += hextileTileSize {
+			tile := image.Rect(tx, ty, tx+hextileTileSize, ty+hextileTileSize).Intersect(img.Rect)
+			mask, err := c.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if mask&hextileRaw != 0 {
+				raw, err := c.raw(tile.Dx(), tile.Dy())
+				if err != nil {
+					return nil, err
+				}
+				for y := 0; y < tile.Dy(); y++ {
+					copy(img.Pix[img.PixOffset(tile.Min.X, tile.Min.Y+y):], raw.Pix[y*raw.Stride:(y+1)*raw.Stride])
+				}
+				continue
+			}
+			if mask&hextileBackground != 0 {
+				if bg, err = readPixel(c.r, bytesPerPixel); err != nil {
+					return nil, err
+				}
+			}
+			fill(img, tile, bg)
+			if mask&hextileForeground != 0 {
+				if fg, err = readPixel(c.r, bytesPerPixel); err != nil {
+					return nil, err
+				}
+			}
+			if mask&hextileAnySubrects == 0 {
+				continue
+			}
+			n, err := c.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			for i := 0; i < int(n); i++ {
+				col := fg
+				if mask&hextileSubrectsColoured != 0 {
+					if col, err = readPixel(c.r, bytesPerPixel); err != nil {
+						return nil, err
+					}
+				}
+				var xywh [2]byte
+				if _, err := io.ReadFull(c.r, xywh[:]); err != nil {
+					return nil, err
+				}
+				sx, sy := tile.Min.X+int(xywh[0]>>4), tile.Min.Y+int(xywh[0]&0xF)
+				sub := image.Rect(sx, sy, sx+int(xywh[1]>>4)+1, sy+int(xywh[1]&0xF)+1)
+				fill(img, sub.Intersect(tile), col)
+			}
+		}
+	}
+	return img, nil
+}
+
+// zstream is a zlib stream of the server which spans several
+// rectangles. The server flushes the stream after each rectangle, hence
+// the data of a rectangle can be inflated once it is received.
+type zstream struct {
+	src bytes.Buffer
+	zr  io.ReadCloser
+}
+
+// feed appends compressed data and returns the inflating reader.
+func (z *zstream) feed(p []byte) (io.Reader, error) {
+	z.src.Write(p)
+	if z.zr == nil {
+		zr, err := zlib.NewReader(&z.src)
+		if err != nil {
+			return nil, fmt.Errorf("vnc: invalid zlib stream: %w", err)
+		}
+		z.zr = zr
+	}
+	return z.zr, nil
+}
+
+// reset resets the stream once the server resets its compressor.
+func (z *zstream) reset() {
+	z.src.Reset()
+	z.zr = nil
+}
+
+// byteReader reads single bytes of a zstream without buffering, as
+// reading ahead fails at the end of the data of a rectangle.
+type byteReader struct {
+	r io.Reader
+	b [1]byte
+}
+
+func (r *byteReader) Read(p []byte) (int, error) {
+	return io.ReadFull(r.r, p)
+}
+
+func (r *byteReader) ReadByte() (byte, error) {
+	_, err := io.ReadFull(r.r, r.b[:])
+	return r.b[0], err
+}
+
+// zrleRect reads a rectangle of the ZRLE encoding.
+func (c *rfbConn) zrleRect(w, h int) (*image.RGBA, error) {
+	n, err := c.readU32()
+	if err != nil {
+		return nil, err
+	}
+	if n > zrleMaxCompressed {
+		return nil, fmt.Errorf("vnc: ZRLE data of %d bytes is too long", n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return nil, err
+	}
+	zr, err := c.zrle.feed(data)
+	if err != nil {
+		return nil, err
+	}
+	r := &byteReader{r: zr}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for ty := 0; ty < h; ty += zrleTileSize {
+		for tx := 0; tx < w; tx += zrleTileSize {
+			tile := image.Rect(tx, ty, tx+zrleTileSize, ty+zrleTileSize).Intersect(img.Rect)
+			if err := zrleTile(r, img, tile); err != nil {
+				return nil, fmt.Errorf("vnc: invalid ZRLE tile: %w", err)
+			}
+		}
+	}
+	return img, nil
+}
+
+func zrleTile(r *byteReader, img *image.RGBA, tile image.Rectangle) error {
+	sub, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	tw, total := tile.Dx(), tile.Dx()*tile.Dy()
+	set := func(i int, c color.RGBA) {
+		off := img.PixOffset(tile.Min.X+i%tw, tile.Min.Y+i/tw)
+		setRGB(img, off, c.R, c.G, c.B)
+	}
+
+	switch {
+	case sub == 0: // raw
+		for i := 0; i < total; i++ {
+			c, err := readPixel(r, bytesPerCompactPixel)
+			if err != nil {
+				return err
+			}
+			set(i, c)
+		}
+	case sub == 1: // solid
+		c, err := readPixel(r, bytesPerCompactPixel)
+		if err != nil {
+			return err
+		}
+		fill(img, tile, c)
+	case sub <= 16: // packed palette
+		palette, err := readPalette(r, int(sub), bytesPerCompactPixel)
+		if err != nil {
+			return err
+		}
+		bits := uint(4)
+		if sub == 2 {
+			bits = 1
+		} else if sub <= 4 {
+			bits = 2
+		}
+		for y := 0; y < tile.Dy(); y++ {
+			var b byte
+			left := uint(0)
+			for x := 0; x < tw; x++ {
+				if left == 0 {
+					if b, err = r.ReadByte(); err != nil {
+						return err
+					}
+					left = 8
+				}
+				left -= bits
+				idx := int(b>>left) & (1<<bits - 1)
+				if idx >= len(palette) {
+					return fmt.Errorf("palette index %d out of range", idx)
+				}
+				set(y*tw+x, palette[idx])
+			}
+		}
+	case sub == 128: // plain RLE
+		for i := 0; i < total; {
+			c, err := readPixel(r, bytesPerCompactPixel)
+			if err != nil {
+				return err
+			}
+			run, err := runLength(r)
+			if err != nil {
+				return err
+			}
+			if i+run > total {
+				return fmt.Errorf("run of %d pixels exceeds the tile", run)
+			}
+			for ; run > 0; run-- {
+				set(i, c)
+				i++
+			}
+		}
+	case sub >= 130: // palette RLE
+		palette, err := readPalette(r, int(sub-128), bytesPerCompactPixel)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < total; {
+			idx, err := r.ReadByte()
+			if err != nil {
+				return err
+			}
+			run := 1
+			if idx&128 != 0 {
+				idx &= 127
+				if run, err = runLength(r); err != nil {
+					return err
+				}
+			}
+			if int(idx) >= len(palette) {
+				return fmt.Errorf("palette index %d out of range", idx)
+			}
+			if i+run > total {
+				return fmt.Errorf("run of %d pixels exceeds the tile", run)
+			}
+			for ; run > 0; run-- {
+				set(i, palette[idx])
+				i++
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported subencoding %d", sub)
+	}
+	return nil
+}
+
+func readPalette(r io.Reader, n, size int) ([]color.RGBA, error) {
+	palette := make([]color.RGBA, n)
+	for i := range palette {
+		var err error
+		if palette[i], err = readPixel(r, size); err != nil {
+			return nil, err
+		}
+	}
+	return palette, nil
+}
+
+// runLength reads the run length of ZRLE, which is the sum of its
+// bytes plus one.
+func runLength(r io.ByteReader) (int, error) {
+	n := 1
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		n += int(b)
+		if b != 255 {
+			return n, nil
+		}
+	}
+}
+
+// tightRect reads a rectangle of the tight encoding. The pixels of
+// tight are three bytes in red, green and blue order.
+func (c *rfbConn) tightRect(w, h int) (image.Image, error) {
+	ctl, err := c.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint(0); i < 4; i++ {
+		if ctl&(1<<i) != 0 {
+			c.tight[i].reset()
+		}
+	}
+	typ := ctl >> 4
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	switch {
+	case typ == tightFill:
+		var p [3]byte
+		if _, err := io.ReadFull(c.r, p[:]); err != nil {
+			return nil, err
+		}
+		fill(img, img.Rect, color.RGBA{p[0], p[1], p[2], 0xFF})
+		return img, nil
+	case typ == tightJPEG:
+		data, err := c.tightCompressed()
+		if err != nil {
+			return nil, err
+		}
+		return jpeg.Decode(bytes.NewReader(data))
+	case typ > tightJPEG:
+		return nil, fmt.Errorf("vnc: unsupported tight compression %d", typ)
+	}
+
+	stream := int(typ & 3)
+	filter := byte(tightFilterCopy)
+	if typ&4 != 0 {
+		if filter, err = c.r.ReadByte(); err != nil {
+			return nil, err
+		}
+	}
+	switch filter {
+	case tightFilterCopy:
+		data, err := c.tightData(stream, w*h*3)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < w*h; i++ {
+			setRGB(img, i*4, data[i*3], data[i*3+1], data[i*3+2])
+		}
+	case tightFilterPalette:
+		n, err := c.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		var palette [256 * 3]byte
+		if _, err := io.ReadFull(c.r, palette[:(int(n)+1)*3]); err != nil {
+			return nil, err
+		}
+		if n <= 1 {
+			// two colors, the rows are bitmaps padded to bytes
+			stride := (w + 7) / 8
+			data, err := c.tightData(stream, stride*h)
+			if err != nil {
+				return nil, err
+			}
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					idx := int(data[y*stride+x/8]>>(7-uint(x%8))&1) * 3
+					setRGB(img, img.PixOffset(x, y), palette[idx], palette[idx+1], palette[idx+2])
+				}
+			}
+			break
+		}
+		data, err := c.tightData(stream, w*h)
+		if err != nil {
+			return nil, err
+		}
+		for i, idx := range data {
+			if idx > n {
+				return nil, fmt.Errorf("vnc: tight palette index %d out of range", idx)
+			}
+			p := palette[int(idx)*3:]
+			setRGB(img, i*4, p[0], p[1], p[2])
+		}
+	case tightFilterGradient:
+		data, err := c.tightData(stream, w*h*3)
+		if err != nil {
+			return nil, err
+		}
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				off := img.PixOffset(x, y)
+				for ch := 0; ch < 3; ch++ {
+					var left, up, upLeft int
+					if x > 0 {
+						left = int(img.Pix[off-4+ch])
+					}
+					if y > 0 {
+						up = int(img.Pix[off-img.Stride+ch])
+					}
+					if x > 0 && y > 0 {
+						upLeft = int(img.Pix[off-img.Stride-4+ch])
+					}
+					pred := clamp(left+up-upLeft, 0, 255)
+					img.Pix[off+ch] = byte(pred) + data[(y*w+x)*3+ch]
+				}
+				img.Pix[off+3] = 0xFF
+			}
+		}
+	default:
+		return nil, fmt.Errorf("vnc: unsupported tight filter %d", filter)
+	}
+	return img, nil
+}
+
+// tightData reads filtered data of the given size, data of at least
+// tightMinToCompress bytes is compressed by the given zlib stream.
+func (c *rfbConn) tightData(stream, size int) ([]byte, error) {
+	data := make([]byte, size)
+	if size < tightMinToCompress {
+		_, err := io.ReadFull(c.r, data)
+		return data, err
+	}
+	compressed, err := c.tightCompressed()
+	if err != nil {
+		return nil, err
+	}
+	zr, err := c.tight[stream].feed(compressed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(zr, data); err != nil {
+		return nil, fmt.Errorf("vnc: invalid tight data: %w", err)
+	}
+	return data, nil
+}
+
+// tightCompressed reads data prefixed by its compact length, which is
+// encoded in one to three bytes of seven bits.
+func (c *rfbConn) tightCompressed() ([]byte, error) {
+	n := 0
+	for i := uint(0); i < 3; i++ {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if i == 2 {
+			n |= int(b) << 14
+			break
+		}
+		n |= int(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if n > tightMaxCompressed {
+		return nil, fmt.Errorf("vnc: tight data of %d bytes is too long", n)
+	}
+	data := make([]byte, n)
+	_, err := io.ReadFull(c.r, data)
+	return data, err
+}
+
+// cursor reads the cursor pseudo-encoding, the position of the
+// rectangle is the hotspot of the cursor.
+func (c *rfbConn) cursor(d *plugin.Display, x, y, w, h int) error {
+	if w > cursorMaxSize || h > cursorMaxSize {
+		return fmt.Errorf("vnc: cursor of %dx%d is too large", w, h)
+	}
+	img, err := c.raw(w, h)
+	if err != nil {
+		return err
+	}
+	stride := (w + 7) / 8
+	mask := make([]byte, stride*h)
+	if _, err := io.ReadFull(c.r, mask); err != nil {
+		return err
+	}
+	for py := 0; py < h; py++ {
+		for px := 0; px < w; px++ {
+			if mask[py*stride+px/8]&(0x80>>uint(px%8)) == 0 {
+				off := img.PixOffset(px, py)
+				copy(img.Pix[off:off+4], []byte{0, 0, 0, 0})
+			}
+		}
+	}
+	if w == 0 || h == 0 {
+		img = image.NewRGBA(image.Rect(0, 0, 1, 1)) // hidden cursor
+	}
+	d.Cursor(x, y, img)
+	return nil
+}
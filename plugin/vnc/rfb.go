@@ -0,0 +1,354 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package vnc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/des"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+)
+
+// protocolVersion is the RFB version of the client
+const protocolVersion = "RFB 003.008\n"
+
+// Security types
+const (
+	securityNone    = 1
+	securityVNCAuth = 2
+)
+
+// Client to server messages
+const (
+	msgSetPixelFormat           = 0
+	msgSetEncodings             = 2
+	msgFramebufferUpdateRequest = 3
+	msgKeyEvent                 = 4
+	msgPointerEvent             = 5
+	msgClientCutText            = 6
+)
+
+// Server to client messages
+const (
+	msgFramebufferUpdate   = 0
+	msgSetColourMapEntries = 1
+	msgBell                = 2
+	msgServerCutText       = 3
+)
+
+// maxCutText limits the clipboard text of the server
+const maxCutText = 1 << 24
+
+// maxFramebuffer limits the width and height of the framebuffer of the
+// server, the display of a larger framebuffer would exhaust the memory.
+const maxFramebuffer = 8192
+
+// Errors of RFB connections
+var (
+	ErrUnsupportedVersion  = errors.New("vnc: unsupported RFB version")
+	ErrUnsupportedSecurity = errors.New("vnc: no supported security type")
+	ErrUnsupportedMessage  = errors.New("vnc: unsupported server message")
+	ErrFramebufferTooLarge = errors.New("vnc: framebuffer is too large")
+)
+
+// pixelFormat is the pixel format requested from the server: 32 bits
+// per pixel in little endian, the bytes of a pixel are blue, green, red
+// and padding.
+var pixelFormat = [16]byte{
+	32,     // bits per pixel
+	24,     // depth
+	0,      // big endian
+	1,      // true color
+	0, 255, // red max
+	0, 255, // green max
+	0, 255, // blue max
+	16, 8, 0, // red, green and blue shift
+	0, 0, 0, // padding
+}
+
+// rfbConn is a client connection of the remote framebuffer protocol,
+// see RFC 6143.
+type rfbConn struct {
+	nc net.Conn
+	r  *bufio.Reader
+
+	wmu           sync.Mutex // serializes client messages, protects the size
+	buf           []byte
+	width, height int
+
+	name string
+
+	zrle  zstream
+	tight [4]zstream
+}
+
+// handshake negotiates the protocol version and security with the
+// server, and initializes the connection.
+func handshake(nc net.Conn, password string) (*rfbConn, error) {
+	c := &rfbConn{nc: nc, r: bufio.NewReader(nc)}
+
+	var version [12]byte
+	if _, err := io.ReadFull(c.r, version[:]); err != nil {
+		return nil, err
+	}
+	var major, minor int
+	_, err := fmt.Sscanf(string(version[:]), "RFB %03d.%03d\n", &major, &minor)
+	if err != nil || major < 3 || major == 3 && minor < 8 {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedVersion, version)
+	}
+	if _, err := io.WriteString(nc, protocolVersion); err != nil {
+		return nil, err
+	}
+
+	n, err := c.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, c.reason()
+	}
+	types := make([]byte, n)
+	if _, err := io.ReadFull(c.r, types); err != nil {
+		return nil, err
+	}
+	// VNC authentication is preferred if a password is given
+	var security byte
+	switch {
+	case password != "" && bytes.IndexByte(types, securityVNCAuth) >= 0:
+		security = securityVNCAuth
+	case bytes.IndexByte(types, securityNone) >= 0:
+		security = securityNone
+	case bytes.IndexByte(types, securityVNCAuth) >= 0:
+		security = securityVNCAuth
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedSecurity, types)
+	}
+	if _, err := nc.Write([]byte{security}); err != nil {
+		return nil, err
+	}
+	if security == securityVNCAuth {
+		if err := c.vncAuth(password); err != nil {
+			return nil, err
+		}
+	}
+
+	var result uint32
+	if err := binary.Read(c.r, binary.BigEndian, &result); err != nil {
+		return nil, err
+	}
+	if result != 0 {
+		return nil, plugin.StatusError(protocol.StatusClientUnauthorized, c.reason())
+	}
+
+	// share the desktop with other clients
+	if _, err := nc.Write([]byte{1}); err != nil {
+		return nil, err
+	}
+	var init struct {
+		Width, Height uint16
+		PixelFormat   [16]byte
+		NameLength    uint32
+	}
+	if err := binary.Read(c.r, binary.BigEndian, &init); err != nil {
+		return nil, err
+	}
+	if err := checkSize(int(init.Width), int(init.Height)); err != nil {
+		return nil, err
+	}
+	name, err := c.readString(init.NameLength)
+	if err != nil {
+		return nil, err
+	}
+	c.width, c.height, c.name = int(init.Width), int(init.Height), name
+	return c, nil
+}
+
+// vncAuth answers the DES challenge of the server.
+func (c *rfbConn) vncAuth(password string) error {
+	var challenge [16]byte
+	if _, err := io.ReadFull(c.r, challenge[:]); err != nil {
+		return err
+	}
+	// the key is the password with the bits of each byte reversed
+	var key [8]byte
+	copy(key[:], password)
+	for i, b := range key {
+		b = b>>4 | b<<4
+		b = b&0xCC>>2 | b&0x33<<2
+		key[i] = b&0xAA>>1 | b&0x55<<1
+	}
+	cipher, err := des.NewCipher(key[:])
+	if err != nil {
+		return err
+	}
+	cipher.Encrypt(challenge[:8], challenge[:8])
+	cipher.Encrypt(challenge[8:], challenge[8:])
+	_, err = c.nc.Write(challenge[:])
+	return err
+}
+
+// reason reads the reason of a failure.
+func (c *rfbConn) reason() error {
+	var n uint32
+	if err := binary.Read(c.r, binary.BigEndian, &n); err != nil {
+		return err
+	}
+	s, err := c.readString(n)
+	if err != nil {
+		return err
+	}
+	return errors.New("vnc: " + s)
+}
+
+func (c *rfbConn) readString(n uint32) (string, error) {
+	if n > maxCutText {
+		return "", fmt.Errorf("vnc: string of %d bytes is too long", n)
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(c.r, b)
+	return string(b), err
+}
+
+// write writes a client message.
+func (c *rfbConn) write(fields ...interface{}) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.buf = c.buf[:0]
+	for _, f := range fields {
+		switch v := f.(type) {
+		case uint8:
+			c.buf = append(c.buf, v)
+		case uint16:
+			c.buf = append(c.buf, byte(v>>8), byte(v))
+		case uint32:
+			c.buf = append(c.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+		case int32:
+			c.buf = append(c.buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+		case []byte:
+			c.buf = append(c.buf, v...)
+		default:
+			panic(fmt.Sprintf("vnc: unsupported field %T", f))
+		}
+	}
+	_, err := c.nc.Write(c.buf)
+	return err
+}
+
+// size returns the size of the framebuffer.
+func (c *rfbConn) size() (width, height int) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.width, c.height
+}
+
+// checkSize checks the given size of the framebuffer against
+// maxFramebuffer.
+func checkSize(width, height int) error {
+	if width > maxFramebuffer || height > maxFramebuffer {
+		return fmt.Errorf("%w: %dx%d", ErrFramebufferTooLarge, width, height)
+	}
+	return nil
+}
+
+// resize sets the size of the framebuffer.
+func (c *rfbConn) resize(width, height int) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.width, c.height = width, height
+}
+
+// setPixelFormat requests pixels in the format of pixelFormat.
+func (c *rfbConn) setPixelFormat() error {
+	return c.write(uint8(msgSetPixelFormat), []byte{0, 0, 0}, pixelFormat[:])
+}
+
+// setEncodings announces the supported encodings by preference.
+func (c *rfbConn) setEncodings(encodings []int32) error {
+	fields := []interface{}{uint8(msgSetEncodings), uint8(0), uint16(len(encodings))}
+	for _, e := range encodings {
+		fields = append(fields, e)
+	}
+	return c.write(fields...)
+}
+
+// requestUpdate requests an update of the whole framebuffer.
+func (c *rfbConn) requestUpdate(incremental bool) error {
+	inc := uint8(0)
+	if incremental {
+		inc = 1
+	}
+	w, h := c.size()
+	return c.write(uint8(msgFramebufferUpdateRequest), inc,
+		uint16(0), uint16(0), uint16(w), uint16(h))
+}
+
+// key sends a key event, the keysyms of guacamole are X11 keysyms.
+func (c *rfbConn) key(keysym int, pressed bool) error {
+	down := uint8(0)
+	if pressed {
+		down = 1
+	}
+	return c.write(uint8(msgKeyEvent), down, uint16(0), uint32(keysym))
+}
+
+// pointer sends a pointer event, the button masks of guacamole are
+// the same as of RFB.
+func (c *rfbConn) pointer(x, y, mask int) error {
+	w, h := c.size()
+	return c.write(uint8(msgPointerEvent), uint8(mask),
+		uint16(clamp(x, 0, w-1)), uint16(clamp(y, 0, h-1)))
+}
+
+// cutText sends the clipboard text, which is Latin-1 encoded.
+func (c *rfbConn) cutText(text string) error {
+	latin1 := make([]byte, 0, len(text))
+	for _, r := range text {
+		if r > 0xFF {
+			r = '?'
+		}
+		latin1 = append(latin1, byte(r))
+	}
+	return c.write(uint8(msgClientCutText), []byte{0, 0, 0}, uint32(len(latin1)), latin1)
+}
+
+func (c *rfbConn) readU8() (int, error) {
+	b, err := c.r.ReadByte()
+	return int(b), err
+}
+
+func (c *rfbConn) readU16() (int, error) {
+	var b [2]byte
+	_, err := io.ReadFull(c.r, b[:])
+	return int(binary.BigEndian.Uint16(b[:])), err
+}
+
+func (c *rfbConn) readU32() (uint32, error) {
+	var b [4]byte
+	_, err := io.ReadFull(c.r, b[:])
+	return binary.BigEndian.Uint32(b[:]), err
+}
+
+func (c *rfbConn) skip(n int) error {
+	_, err := c.r.Discard(n)
+	return err
+}
+
+func clamp(v, min, max int) int {
+	if v > max {
+		v = max
+	}
+	if v < min {
+		v = min
+	}
+	return v
+}
@@ -0,0 +1,232 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Package vnc implements an RFB 3.8 client as a Go protocol plugin.
+// Importing the package registers the plugin as "vnc-go", which can be
+// used in place of the libvncclient based "vnc" protocol.
+//
+// The client supports the Raw, CopyRect, Hextile, ZRLE and Tight
+// encodings, the cursor and DesktopSize pseudo-encodings, and the
+// clipboard.
+package vnc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+)
+
+// DefaultPort is the default port of VNC servers
+const DefaultPort = "5900"
+
+// dialTimeout limits connecting to the VNC server and the handshake
+const dialTimeout = 15 * time.Second
+
+func init() {
+	plugin.Register("vnc-go", Plugin{})
+}
+
+// Plugin is the VNC protocol plugin.
+type Plugin struct{}
+
+// Args implements plugin.ProtocolPlugin
+func (Plugin) Args() []string {
+	return []string{"hostname", "port", "password", "read-only", "encodings"}
+}
+
+// New implements plugin.ProtocolPlugin
+func (Plugin) New(s *plugin.Session) (plugin.Connection, error) {
+	return &conn{s: s}, nil
+}
+
+// conn is a connection to a VNC server
+type conn struct {
+	plugin.Base
+	s        *plugin.Session
+	rfb      *rfbConn
+	readOnly bool
+	done     chan struct{} // closed once the server messages are read
+}
+
+// Join implements plugin.Connection, the owner connects to the VNC
+// server.
+func (c *conn) Join(u *plugin.User, args plugin.Args) error {
+	if !u.Owner {
+		if c.rfb == nil {
+			return plugin.ErrClosed
+		}
+		return nil
+	}
+
+	hostname := args.Get("hostname")
+	if hostname == "" {
+		return plugin.StatusError(protocol.StatusClientBadRequest, errors.New("vnc: hostname is required"))
+	}
+	port := args.Get("port")
+	if port == "" {
+		port = DefaultPort
+	}
+	encs, err := parseEncodings(args.Get("encodings"))
+	if err != nil {
+		return plugin.StatusError(protocol.StatusClientBadRequest, err)
+	}
+	c.readOnly = args.Bool("read-only")
+
+	nc, err := net.DialTimeout("tcp", net.JoinHostPort(hostname, port), dialTimeout)
+	if err != nil {
+		return fmt.Errorf("vnc: %w", err)
+	}
+	nc.SetDeadline(time.Now().Add(dialTimeout))
+	rfb, err := handshake(nc, args.Get("password"))
+	if err == nil {
+		nc.SetDeadline(time.Time{})
+		err = rfb.setPixelFormat()
+	}
+	if err == nil {
+		err = rfb.setEncodings(append(encs, encCursor, encDesktopSize, encLastRect))
+	}
+	if err == nil {
+		err = rfb.requestUpdate(false)
+	}
+	if err != nil {
+		nc.Close()
+		return err
+	}
+	c.rfb = rfb
+	c.done = make(chan struct{})
+
+	d := c.s.Display()
+	d.Resize(rfb.width, rfb.height)
+	d.Flush()
+	go c.read()
+	return nil
+}
+
+// parseEncodings parses space separated names of encodings, all
+// encodings are used if none is given.
+func parseEncodings(names string) ([]int32, error) {
+	var encs []int32
+	for _, name := range strings.Fields(names) {
+		found := false
+		for _, e := range encodings {
+			if e.name == name {
+				encs, found = append(encs, e.enc), true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("vnc: unknown encoding %q", name)
+		}
+	}
+	if len(encs) == 0 {
+		for _, e := range encodings {
+			encs = append(encs, e.enc)
+		}
+	}
+	return encs, nil
+}
+
+// read reads server messages until the connection is closed, which
+// stops the session.
+func (c *conn) read() {
+	defer close(c.done)
+	d := c.s.Display()
+	err := c.serve(d)
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	// errors after Close are ignored as the session is stopped
+	c.s.Stop(err)
+}
+
+func (c *conn) serve(d *plugin.Display) error {
+	for {
+		typ, err := c.rfb.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case msgFramebufferUpdate:
+			if err := c.rfb.update(d); err != nil {
+				return err
+			}
+			d.Flush()
+			err = c.rfb.requestUpdate(true)
+		case msgSetColourMapEntries:
+			var n int
+			if err = c.rfb.skip(3); err == nil {
+				n, err = c.rfb.readU16()
+			}
+			if err == nil {
+				err = c.rfb.skip(n * 6)
+			}
+		case msgBell:
+		case msgServerCutText:
+			var n uint32
+			if err = c.rfb.skip(3); err == nil {
+				n, err = c.rfb.readU32()
+			}
+			var text string
+			if err == nil {
+				text, err = c.rfb.readString(n)
+			}
+			if err == nil {
+				d.Clipboard("text/plain", latin1ToUTF8(text))
+				d.Flush()
+			}
+		default:
+			return fmt.Errorf("%w: %d", ErrUnsupportedMessage, typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func latin1ToUTF8(s string) []byte {
+	rs := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		rs[i] = rune(s[i])
+	}
+	return []byte(string(rs))
+}
+
+// Key implements plugin.Connection
+func (c *conn) Key(u *plugin.User, keysym int, pressed bool) error {
+	if c.readOnly {
+		return nil
+	}
+	return c.rfb.key(keysym, pressed)
+}
+
+// Mouse implements plugin.Connection
+func (c *conn) Mouse(u *plugin.User, x, y, mask int) error {
+	if c.readOnly {
+		return nil
+	}
+	return c.rfb.pointer(x, y, mask)
+}
+
+// Clipboard implements plugin.Connection, text is sent to the server.
+func (c *conn) Clipboard(u *plugin.User, mimetype string, data []byte) error {
+	if c.readOnly || !strings.HasPrefix(mimetype, "text/") {
+		return nil
+	}
+	return c.rfb.cutText(string(data))
+}
+
+// Close implements plugin.Connection
+func (c *conn) Close() error {
+	if c.rfb == nil {
+		return nil
+	}
+	err := c.rfb.nc.Close()
+	<-c.done
+	return err
+}
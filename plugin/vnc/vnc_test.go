@@ -0,0 +1,793 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package vnc_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/des"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+	"changkun.de/x/occamy/plugin/vnc"
+)
+
+// server is an in-process RFB 3.8 server with VNC authentication. It
+// reports the messages of the client as events, and sends the messages
+// given to send.
+type server struct {
+	ln       net.Listener
+	password string
+	width    int
+	height   int
+	events   chan string
+	send     chan []byte
+}
+
+func newServer(t *testing.T, password string, width, height int) *server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	s := &server{
+		ln:       ln,
+		password: password,
+		width:    width,
+		height:   height,
+		events:   make(chan string, 100),
+		send:     make(chan []byte),
+	}
+	go s.serve()
+	return s
+}
+
+func (s *server) port() string {
+	return strconv.Itoa(s.ln.Addr().(*net.TCPAddr).Port)
+}
+
+func (s *server) serve() {
+	c, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer c.Close()
+	if !s.handshake(c) {
+		return
+	}
+	go s.read(c)
+	for m := range s.send {
+		if _, err := c.Write(m); err != nil {
+			return
+		}
+	}
+}
+
+func (s *server) handshake(c net.Conn) bool {
+	c.Write([]byte("RFB 003.008\n"))
+	version := make([]byte, 12)
+	io.ReadFull(c, version)
+	c.Write([]byte{1, 2}) // VNC authentication
+
+	security := make([]byte, 1)
+	io.ReadFull(c, security)
+	challenge := make([]byte, 16)
+	rand.Read(challenge)
+	c.Write(challenge)
+	response := make([]byte, 16)
+	io.ReadFull(c, response)
+
+	var key [8]byte
+	copy(key[:], s.password)
+	for i, b := range key {
+		var r byte
+		for j := uint(0); j < 8; j++ {
+			r |= (b >> j & 1) << (7 - j)
+		}
+		key[i] = r
+	}
+	cipher, _ := des.NewCipher(key[:])
+	want := make([]byte, 16)
+	cipher.Encrypt(want[:8], challenge[:8])
+	cipher.Encrypt(want[8:], challenge[8:])
+	if !bytes.Equal(response, want) {
+		reason := "authentication failed"
+		c.Write(append([]byte{0, 0, 0, 1, 0, 0, 0, byte(len(reason))}, reason...))
+		return false
+	}
+	c.Write([]byte{0, 0, 0, 0})
+
+	shared := make([]byte, 1)
+	io.ReadFull(c, shared)
+	var init bytes.Buffer
+	binary.Write(&init, binary.BigEndian, []uint16{uint16(s.width), uint16(s.height)})
+	init.Write([]byte{32, 24, 0, 1, 0, 255, 0, 255, 0, 255, 16, 8, 0, 0, 0, 0})
+	binary.Write(&init, binary.BigEndian, uint32(len("occamy")))
+	init.WriteString("occamy")
+	c.Write(init.Bytes())
+	return true
+}
+
+// read reports the messages of the client.
+func (s *server) read(c net.Conn) {
+	for {
+		typ := make([]byte, 1)
+		if _, err := io.ReadFull(c, typ); err != nil {
+			return
+		}
+		var e string
+		switch typ[0] {
+		case 0: // SetPixelFormat
+			b := make([]byte, 19)
+			io.ReadFull(c, b)
+			e = fmt.Sprintf("pixel format %v", b[3:13])
+		case 2: // SetEncodings
+			b := make([]byte, 3)
+			io.ReadFull(c, b)
+			encs := make([]int32, binary.BigEndian.Uint16(b[1:]))
+			binary.Read(c, binary.BigEndian, encs)
+			e = fmt.Sprintf("encodings %v", encs)
+		case 3: // FramebufferUpdateRequest
+			b := make([]byte, 9)
+			io.ReadFull(c, b)
+			e = fmt.Sprintf("update %d", b[0])
+		case 4: // KeyEvent
+			b := make([]byte, 7)
+			io.ReadFull(c, b)
+			e = fmt.Sprintf("key %x %d", binary.BigEndian.Uint32(b[3:]), b[0])
+		case 5: // PointerEvent
+			b := make([]byte, 5)
+			io.ReadFull(c, b)
+			e = fmt.Sprintf("pointer %d %d %d", binary.BigEndian.Uint16(b[1:]), binary.BigEndian.Uint16(b[3:]), b[0])
+		case 6: // ClientCutText
+			b := make([]byte, 7)
+			io.ReadFull(c, b)
+			text := make([]byte, binary.BigEndian.Uint32(b[3:]))
+			io.ReadFull(c, text)
+			e = fmt.Sprintf("cut %q", text)
+		default:
+			return
+		}
+		s.events <- e
+	}
+}
+
+// expect waits for the given event, other events are skipped.
+func (s *server) expect(t *testing.T, want string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e := <-s.events:
+			if e == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("event %q did not happen", want)
+		}
+	}
+}
+
+// encoder encodes framebuffer updates of an image.
+type encoder struct {
+	img   *image.RGBA
+	buf   bytes.Buffer
+	rects int
+
+	zrle      *zlib.Writer
+	zrleBuf   bytes.Buffer
+	tight     [4]*zlib.Writer
+	tightBufs [4]bytes.Buffer
+}
+
+func newEncoder(img *image.RGBA) *encoder {
+	e := &encoder{img: img}
+	e.zrle = zlib.NewWriter(&e.zrleBuf)
+	for i := range e.tight {
+		e.tight[i] = zlib.NewWriter(&e.tightBufs[i])
+	}
+	return e
+}
+
+func (e *encoder) header(r image.Rectangle, enc int32) {
+	e.rects++
+	binary.Write(&e.buf, binary.BigEndian, []uint16{
+		uint16(r.Min.X), uint16(r.Min.Y), uint16(r.Dx()), uint16(r.Dy())})
+	binary.Write(&e.buf, binary.BigEndian, enc)
+}
+
+// message returns the framebuffer update of all encoded rectangles.
+func (e *encoder) message() []byte {
+	m := append([]byte{0, 0, byte(e.rects >> 8), byte(e.rects)}, e.buf.Bytes()...)
+	e.buf.Reset()
+	e.rects = 0
+	return m
+}
+
+func (e *encoder) at(x, y int) color.RGBA {
+	return e.img.RGBAAt(x, y)
+}
+
+func pixel(c color.RGBA) []byte {
+	return []byte{c.B, c.G, c.R, 0}
+}
+
+func (e *encoder) raw(r image.Rectangle) {
+	e.header(r, 0)
+	e.rawPixels(&e.buf, r, 4)
+}
+
+func (e *encoder) rawPixels(w io.Writer, r image.Rectangle, size int) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			w.Write(pixel(e.at(x, y))[:size])
+		}
+	}
+}
+
+func (e *encoder) copyRect(r image.Rectangle, src image.Point) {
+	e.header(r, 1)
+	binary.Write(&e.buf, binary.BigEndian, []uint16{uint16(src.X), uint16(src.Y)})
+}
+
+// hextile encodes tiles of two colors by subrects, others as raw tiles.
+func (e *encoder) hextile(r image.Rectangle) {
+	e.header(r, 5)
+	for ty := r.Min.Y; ty < r.Max.Y; ty += 16 {
+		for tx := r.Min.X; tx < r.Max.X; tx += 16 {
+			tile := image.Rect(tx, ty, tx+16, ty+16).Intersect(r)
+			colors := map[color.RGBA]int{}
+			for y := tile.Min.Y; y < tile.Max.Y; y++ {
+				for x := tile.Min.X; x < tile.Max.X; x++ {
+					colors[e.at(x, y)]++
+				}
+			}
+			if len(colors) != 2 {
+				e.buf.WriteByte(1) // raw
+				e.rawPixels(&e.buf, tile, 4)
+				continue
+			}
+			var bg, fg color.RGBA
+			for c, n := range colors {
+				if n > colors[bg] {
+					bg, fg = c, bg
+				} else {
+					fg = c
+				}
+			}
+			var subrects [][2]byte
+			for y := tile.Min.Y; y < tile.Max.Y; y++ {
+				for x := tile.Min.X; x < tile.Max.X; x++ {
+					if e.at(x, y) == fg {
+						subrects = append(subrects, [2]byte{byte((x-tx)<<4 | (y - ty)), 0})
+					}
+				}
+			}
+			e.buf.WriteByte(2 | 4 | 8) // background, foreground and subrects
+			e.buf.Write(pixel(bg))
+			e.buf.Write(pixel(fg))
+			e.buf.WriteByte(byte(len(subrects)))
+			for _, s := range subrects {
+				e.buf.Write(s[:])
+			}
+		}
+	}
+}
+
+// zrle encodes a rectangle of a single tile by the given subencoding.
+func (e *encoder) zrleRect(r image.Rectangle, sub byte) {
+	e.header(r, 16)
+	var tile bytes.Buffer
+	tile.WriteByte(sub)
+	palette := func() map[color.RGBA]int {
+		idx := map[color.RGBA]int{}
+		var colors []color.RGBA
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if _, ok := idx[e.at(x, y)]; !ok {
+					idx[e.at(x, y)] = len(colors)
+					colors = append(colors, e.at(x, y))
+				}
+			}
+		}
+		for _, c := range colors {
+			tile.Write(pixel(c)[:3])
+		}
+		return idx
+	}
+	run := func(n int) {
+		for n--; n >= 255; n -= 255 {
+			tile.WriteByte(255)
+		}
+		tile.WriteByte(byte(n))
+	}
+	runs := func(f func(c color.RGBA, n int)) {
+		var cur color.RGBA
+		n := 0
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if n > 0 && e.at(x, y) != cur {
+					f(cur, n)
+					n = 0
+				}
+				cur = e.at(x, y)
+				n++
+			}
+		}
+		f(cur, n)
+	}
+
+	switch {
+	case sub == 0:
+		e.rawPixels(&tile, r, 3)
+	case sub == 1:
+		tile.Write(pixel(e.at(r.Min.X, r.Min.Y))[:3])
+	case sub <= 16:
+		idx := palette()
+		bits := uint(4)
+		if sub == 2 {
+			bits = 1
+		} else if sub <= 4 {
+			bits = 2
+		}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			var b byte
+			used := uint(0)
+			for x := r.Min.X; x < r.Max.X; x++ {
+				b |= byte(idx[e.at(x, y)]) << (8 - bits - used)
+				if used += bits; used == 8 {
+					tile.WriteByte(b)
+					b, used = 0, 0
+				}
+			}
+			if used > 0 {
+				tile.WriteByte(b)
+			}
+		}
+	case sub == 128:
+		runs(func(c color.RGBA, n int) {
+			tile.Write(pixel(c)[:3])
+			run(n)
+		})
+	default:
+		idx := palette()
+		runs(func(c color.RGBA, n int) {
+			if n == 1 {
+				tile.WriteByte(byte(idx[c]))
+				return
+			}
+			tile.WriteByte(byte(idx[c]) | 128)
+			run(n)
+		})
+	}
+
+	e.zrle.Write(tile.Bytes())
+	e.zrle.Flush()
+	binary.Write(&e.buf, binary.BigEndian, uint32(e.zrleBuf.Len()))
+	e.buf.Write(e.zrleBuf.Bytes())
+	e.zrleBuf.Reset()
+}
+
+// Tight filters of the encoder
+const (
+	tightFill = iota
+	tightCopy
+	tightPalette
+	tightGradient
+)
+
+func (e *encoder) tightRect(r image.Rectangle, filter, stream int) {
+	e.header(r, 7)
+	rgb := func(c color.RGBA) []byte { return []byte{c.R, c.G, c.B} }
+	if filter == tightFill {
+		e.buf.WriteByte(0x80)
+		e.buf.Write(rgb(e.at(r.Min.X, r.Min.Y)))
+		return
+	}
+
+	var data bytes.Buffer
+	switch filter {
+	case tightCopy:
+		e.buf.WriteByte(byte(stream) << 4)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				data.Write(rgb(e.at(x, y)))
+			}
+		}
+	case tightPalette:
+		e.buf.WriteByte(byte(stream)<<4 | 0x40)
+		e.buf.WriteByte(1)
+		idx := map[color.RGBA]int{}
+		var colors []color.RGBA
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if _, ok := idx[e.at(x, y)]; !ok {
+					idx[e.at(x, y)] = len(colors)
+					colors = append(colors, e.at(x, y))
+				}
+			}
+		}
+		e.buf.WriteByte(byte(len(colors) - 1))
+		for _, c := range colors {
+			e.buf.Write(rgb(c))
+		}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			var b byte
+			for x := r.Min.X; x < r.Max.X; x++ {
+				if len(colors) > 2 {
+					data.WriteByte(byte(idx[e.at(x, y)]))
+					continue
+				}
+				b |= byte(idx[e.at(x, y)]) << (7 - uint(x-r.Min.X)%8)
+				if (x-r.Min.X)%8 == 7 || x == r.Max.X-1 {
+					data.WriteByte(b)
+					b = 0
+				}
+			}
+		}
+	case tightGradient:
+		e.buf.WriteByte(byte(stream)<<4 | 0x40)
+		e.buf.WriteByte(2)
+		ch := func(x, y, i int) int {
+			if x < r.Min.X || y < r.Min.Y {
+				return 0
+			}
+			return int(rgb(e.at(x, y))[i])
+		}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				for i := 0; i < 3; i++ {
+					pred := ch(x-1, y, i) + ch(x, y-1, i) - ch(x-1, y-1, i)
+					if pred < 0 {
+						pred = 0
+					} else if pred > 255 {
+						pred = 255
+					}
+					data.WriteByte(byte(ch(x, y, i) - pred))
+				}
+			}
+		}
+	}
+
+	if data.Len() < 12 {
+		e.buf.Write(data.Bytes())
+		return
+	}
+	e.tight[stream].Write(data.Bytes())
+	e.tight[stream].Flush()
+	compressed := e.tightBufs[stream].Bytes()
+	n := len(compressed)
+	for n > 0x7F {
+		e.buf.WriteByte(byte(n) | 0x80)
+		n >>= 7
+	}
+	e.buf.WriteByte(byte(n))
+	e.buf.Write(compressed)
+	e.tightBufs[stream].Reset()
+}
+
+func (e *encoder) cursor(hot image.Point, w, h int, c color.RGBA, mask []byte) {
+	e.header(image.Rect(hot.X, hot.Y, hot.X+w, hot.Y+h), -239)
+	for i := 0; i < w*h; i++ {
+		e.buf.Write(pixel(c))
+	}
+	e.buf.Write(mask)
+}
+
+func (e *encoder) desktopSize(w, h int) {
+	e.header(image.Rect(0, 0, w, h), -223)
+}
+
+// testImage draws the test image, each region is encoded differently.
+func testImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	rnd := rand.New(rand.NewSource(1))
+	noise := func(r image.Rectangle) {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.Set(x, y, color.RGBA{byte(rnd.Intn(256)), byte(rnd.Intn(256)), byte(rnd.Intn(256)), 255})
+			}
+		}
+	}
+	set := func(r image.Rectangle, f func(x, y int) color.RGBA) {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.Set(x, y, f(x-r.Min.X, y-r.Min.Y))
+			}
+		}
+	}
+	red := color.RGBA{255, 0, 0, 255}
+	green := color.RGBA{0, 255, 0, 255}
+	blue := color.RGBA{0, 0, 255, 255}
+	gray := color.RGBA{128, 128, 128, 255}
+	palette := []color.RGBA{red, green, blue, gray, {255, 255, 0, 255}}
+
+	noise(image.Rect(0, 0, 16, 16))                            // raw
+	set(image.Rect(16, 0, 32, 16), func(x, y int) color.RGBA { // hextile subrects
+		if x == y {
+			return red
+		}
+		return blue
+	})
+	noise(image.Rect(32, 0, 40, 16)) // hextile raw
+
+	set(image.Rect(40, 0, 48, 8), func(x, y int) color.RGBA { return gray }) // tight fill
+	noise(image.Rect(48, 0, 56, 8))                                          // tight copy
+	set(image.Rect(56, 0, 64, 8), func(x, y int) color.RGBA {                // tight 2 colors
+		return palette[(x+y)%2]
+	})
+	set(image.Rect(40, 8, 48, 16), func(x, y int) color.RGBA { // tight palette
+		return palette[(x*y)%5]
+	})
+	set(image.Rect(48, 8, 56, 16), func(x, y int) color.RGBA { // tight gradient
+		return color.RGBA{byte(x * 30), byte(y * 30), byte(x*y + 7), 255}
+	})
+	noise(image.Rect(56, 8, 64, 16)) // tight copy
+
+	noise(image.Rect(0, 16, 8, 32))                                            // zrle raw
+	set(image.Rect(8, 16, 16, 32), func(x, y int) color.RGBA { return green }) // zrle solid
+	set(image.Rect(16, 16, 24, 32), func(x, y int) color.RGBA {                // zrle packed palette
+		return palette[(x+2*y)%3]
+	})
+	set(image.Rect(24, 16, 32, 32), func(x, y int) color.RGBA { // zrle plain RLE
+		return palette[y/5]
+	})
+	set(image.Rect(32, 16, 40, 32), func(x, y int) color.RGBA { // zrle palette RLE
+		if x == 0 {
+			return red
+		}
+		return palette[y/6+1]
+	})
+
+	draw := image.Rect(40, 16, 56, 32) // copy of the raw region
+	set(draw, func(x, y int) color.RGBA { return img.RGBAAt(x, y) })
+	noise(image.Rect(56, 16, 64, 32)) // raw
+	return img
+}
+
+func TestVNC(t *testing.T) {
+	want := testImage()
+	srv := newServer(t, "secret", 64, 32)
+	defer srv.ln.Close()
+
+	p, ok := plugin.Lookup("vnc-go")
+	if !ok {
+		t.Fatalf("vnc-go is not registered")
+	}
+	s, err := plugin.NewSession(p)
+	if err != nil {
+		t.Fatalf("new session error: %v", err)
+	}
+	rw, peer := net.Pipe()
+	u := &plugin.User{ID: "@owner", Owner: true}
+	err = s.Join(u, plugin.Args{"hostname": "127.0.0.1", "port": srv.port(), "password": "secret"}, rw)
+	if err != nil {
+		t.Fatalf("join error: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(u) }()
+
+	// collect the instructions sent to the user
+	received := make(chan protocol.Message, 1000)
+	go func() {
+		p := protocol.NewParser()
+		for {
+			elements, err := p.Next(peer)
+			if err != nil {
+				close(received)
+				return
+			}
+			strs := make([]string, len(elements))
+			for i := range elements {
+				strs[i] = string(elements[i])
+			}
+			if m, err := protocol.Decode(protocol.NewInstruction(strs)); err == nil {
+				received <- m
+			}
+		}
+	}()
+	wait := func(f func(m protocol.Message) bool) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case m := <-received:
+				if f(m) {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("expected instruction was not received")
+			}
+		}
+	}
+
+	srv.expect(t, "pixel format [32 24 0 1 0 255 0 255 0 255]")
+	srv.expect(t, "encodings [7 16 5 1 0 -239 -223 -224]")
+	srv.expect(t, "update 0")
+
+	e := newEncoder(want)
+	e.raw(image.Rect(0, 0, 16, 16))
+	e.hextile(image.Rect(16, 0, 40, 16))
+	e.tightRect(image.Rect(40, 0, 48, 8), tightFill, 0)
+	e.tightRect(image.Rect(48, 0, 56, 8), tightCopy, 0)
+	e.tightRect(image.Rect(56, 0, 64, 8), tightPalette, 1)
+	e.tightRect(image.Rect(40, 8, 48, 16), tightPalette, 2)
+	e.tightRect(image.Rect(48, 8, 56, 16), tightGradient, 3)
+	e.tightRect(image.Rect(56, 8, 64, 16), tightCopy, 0)
+	e.zrleRect(image.Rect(0, 16, 8, 32), 0)
+	e.zrleRect(image.Rect(8, 16, 16, 32), 1)
+	e.zrleRect(image.Rect(16, 16, 24, 32), 3)
+	e.zrleRect(image.Rect(24, 16, 32, 32), 128)
+	e.zrleRect(image.Rect(32, 16, 40, 32), 128+4)
+	e.copyRect(image.Rect(40, 16, 56, 32), image.Pt(0, 0))
+	e.raw(image.Rect(56, 16, 64, 32))
+	e.cursor(image.Pt(1, 1), 4, 4, color.RGBA{255, 255, 255, 255}, []byte{0xC0, 0xC0, 0, 0})
+	srv.send <- e.message()
+
+	srv.expect(t, "update 1")
+	got := s.Display().Image()
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 64; x++ {
+			if got.RGBAAt(x, y) != want.RGBAAt(x, y) {
+				t.Fatalf("pixel %d,%d: got %v, want %v", x, y, got.RGBAAt(x, y), want.RGBAAt(x, y))
+			}
+		}
+	}
+	wait(func(m protocol.Message) bool {
+		c, ok := m.(*protocol.Cursor)
+		return ok && c.X == 1 && c.Y == 1 && c.SrcWidth == 4 && c.SrcHeight == 4
+	})
+
+	e.desktopSize(80, 40)
+	srv.send <- e.message()
+	srv.expect(t, "update 1")
+	if w, h := s.Display().Size(); w != 80 || h != 40 {
+		t.Fatalf("desktop size: got %dx%d, want 80x40", w, h)
+	}
+
+	srv.send <- append([]byte{3, 0, 0, 0, 0, 0, 0, 5}, "h\xe9llo"...)
+	wait(func(m protocol.Message) bool {
+		b, ok := m.(*protocol.Blob)
+		return ok && string(b.Data) == "héllo"
+	})
+
+	for _, m := range []protocol.Message{
+		&protocol.Key{Keysym: 0xFF0D, Pressed: true},
+		&protocol.Mouse{X: 10, Y: 20, Mask: 1},
+		&protocol.Clipboard{Stream: 1, Mimetype: "text/plain"},
+		&protocol.Blob{Stream: 1, Data: []byte("héllo")},
+		&protocol.End{Stream: 1},
+	} {
+		peer.Write([]byte(m.Encode().String()))
+	}
+	srv.expect(t, "key ff0d 1")
+	srv.expect(t, "pointer 10 20 1")
+	srv.expect(t, `cut "h\xe9llo"`)
+
+	close(srv.send)
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("serve error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("session did not stop with the VNC connection")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+}
+
+func TestVNC_JoinError(t *testing.T) {
+	srv := newServer(t, "secret", 64, 32)
+	defer srv.ln.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	refused := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	ln.Close()
+
+	tests := []struct {
+		name   string
+		args   plugin.Args
+		status protocol.Status
+	}{
+		{"no hostname", plugin.Args{}, protocol.StatusClientBadRequest},
+		{"unknown encoding", plugin.Args{"hostname": "127.0.0.1", "encodings": "h264"}, protocol.StatusClientBadRequest},
+		{"refused", plugin.Args{"hostname": "127.0.0.1", "port": refused}, protocol.StatusUpstreamUnavailable},
+		{"wrong password", plugin.Args{"hostname": "127.0.0.1", "port": srv.port(), "password": "wrong"}, protocol.StatusClientUnauthorized},
+	}
+	p, _ := plugin.Lookup("vnc-go")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := plugin.NewSession(p)
+			if err != nil {
+				t.Fatalf("new session error: %v", err)
+			}
+			rw, _ := net.Pipe()
+			err = s.Join(&plugin.User{Owner: true}, tt.args, rw)
+			if err == nil {
+				t.Fatalf("join must fail")
+			}
+			if got := plugin.StatusOf(err); got != tt.status {
+				t.Fatalf("status: got %v, want %v (%v)", got, tt.status, err)
+			}
+		})
+	}
+}
+
+func TestVNC_FramebufferTooLarge(t *testing.T) {
+	p, _ := plugin.Lookup("vnc-go")
+
+	srv := newServer(t, "secret", 9000, 32)
+	defer srv.ln.Close()
+	s, err := plugin.NewSession(p)
+	if err != nil {
+		t.Fatalf("new session error: %v", err)
+	}
+	rw, _ := net.Pipe()
+	err = s.Join(&plugin.User{Owner: true}, plugin.Args{"hostname": "127.0.0.1", "port": srv.port(), "password": "secret"}, rw)
+	if !errors.Is(err, vnc.ErrFramebufferTooLarge) {
+		t.Fatalf("join with a 9000x32 framebuffer: got %v, want %v", err, vnc.ErrFramebufferTooLarge)
+	}
+
+	srv = newServer(t, "secret", 64, 32)
+	defer srv.ln.Close()
+	s, err = plugin.NewSession(p)
+	if err != nil {
+		t.Fatalf("new session error: %v", err)
+	}
+	rw, peer := net.Pipe()
+	stopped := make(chan string, 1)
+	go func() {
+		p := protocol.NewParser()
+		for {
+			elements, err := p.Next(peer)
+			if err != nil {
+				return
+			}
+			if string(elements[0]) == "error" {
+				stopped <- string(elements[1])
+			}
+		}
+	}()
+	u := &plugin.User{ID: "@owner", Owner: true}
+	err = s.Join(u, plugin.Args{"hostname": "127.0.0.1", "port": srv.port(), "password": "secret"}, rw)
+	if err != nil {
+		t.Fatalf("join error: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(u) }()
+	srv.expect(t, "update 0")
+
+	e := newEncoder(testImage())
+	e.desktopSize(64, 9000)
+	srv.send <- e.message()
+	select {
+	case msg := <-stopped:
+		if want := vnc.ErrFramebufferTooLarge.Error() + ": 64x9000"; msg != want {
+			t.Fatalf("resize to 64x9000: got %q, want %q", msg, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("session did not stop with the oversized desktop size")
+	}
+	<-served
+	if w, h := s.Display().Size(); w != 64 || h != 32 {
+		t.Fatalf("desktop size: got %dx%d, want 64x32", w, h)
+	}
+	close(srv.send)
+	s.Close()
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// admin registers the admin API to the given group. All requests are
+// authorized by Options.AdminToken as bearer token.
+//
+//	GET    /hostkeys        lists the known host keys
+//	GET    /hostkeys/:host  returns the host key of host:port
+//	PUT    /hostkeys/:host  pins {"key": "ssh-ed25519 AAAA..."} as host key
+//	DELETE /hostkeys/:host  revokes the host key of host:port
+func (s *Server) admin(r *gin.RouterGroup) {
+	r.Use(s.authorizeAdmin)
+	if s.opts.HostKeys != nil {
+		r.GET("/hostkeys", s.listHostKeys)
+		r.GET("/hostkeys/:host", s.getHostKey)
+		r.PUT("/hostkeys/:host", s.pinHostKey)
+		r.DELETE("/hostkeys/:host", s.revokeHostKey)
+	}
+}
+
+// authorizeAdmin rejects requests without the admin token.
+func (s *Server) authorizeAdmin(c *gin.Context) {
+	want := "Bearer " + s.opts.AdminToken
+	got := c.GetHeader("Authorization")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+		return
+	}
+	c.Next()
+}
+
+func (s *Server) listHostKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, s.opts.HostKeys.List())
+}
+
+func (s *Server) getHostKey(c *gin.Context) {
+	key, ok := s.opts.HostKeys.Get(c.Param("host"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": ErrHostKeyUnknown.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+func (s *Server) pinHostKey(c *gin.Context) {
+	var req struct {
+		Key string `json:"key" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key, err := s.opts.HostKeys.Pin(c.Param("host"), req.Key)
+	switch {
+	case errors.Is(err, ErrHostKeyInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+func (s *Server) revokeHostKey(c *gin.Context) {
+	ok, err := s.opts.HostKeys.Revoke(c.Param("host"))
+	switch {
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	case !ok:
+		c.JSON(http.StatusNotFound, gin.H{"error": ErrHostKeyUnknown.Error()})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultCertValidity is the validity of the certificates issued by a
+// CertificateAuthority without a validity.
+const DefaultCertValidity = 5 * time.Minute
+
+// certSkew is the time before the issuing that certificates are valid
+// from, it tolerates clocks of SSH servers which are behind.
+const certSkew = time.Minute
+
+// ErrNoPrincipals is returned if a certificate is requested for a
+// username without principals.
+var ErrNoPrincipals = errors.New("occamy: no certificate principals for the user")
+
+// certKeys generate the ephemeral keys of the certificates by protocol,
+// the private key of the libssh2 based ssh plugin has to be an RSA key
+// in the PKCS#1 format.
+var certKeys = map[string]func() (interface{}, *pem.Block, error){
+	"ssh":    rsaCertKey,
+	"ssh-go": ed25519CertKey,
+}
+
+// certExtensions are the permissions of the issued certificates, port
+// forwarding and the agent are permitted for onward hops.
+var certExtensions = map[string]string{
+	"permit-pty":              "",
+	"permit-agent-forwarding": "",
+	"permit-port-forwarding":  "",
+}
+
+// CertificateAuthority is an SSH certificate authority which issues
+// short-lived user certificates of ephemeral keys, so that no long-lived
+// password or key of SSH servers has to be part of a JWT. The servers
+// trust the public key of the authority by TrustedUserCAKeys.
+type CertificateAuthority struct {
+	// Signer signs the certificates.
+	Signer ssh.Signer
+	// Validity is the validity of the certificates, DefaultCertValidity
+	// is used if zero.
+	Validity time.Duration
+	// Principals are the principals of the certificates by username.
+	// They are set by the operator, certificates are not issued for
+	// usernames without principals.
+	Principals map[string][]string
+}
+
+// LoadCertificateAuthority loads the certificate authority of the given
+// unencrypted private key file.
+func LoadCertificateAuthority(path string, validity time.Duration, principals map[string][]string) (*CertificateAuthority, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("occamy: read ssh ca key error: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("occamy: parse ssh ca key error: %w", err)
+	}
+	return &CertificateAuthority{Signer: signer, Validity: validity, Principals: principals}, nil
+}
+
+// IssueUser issues a certificate of the given username for the given
+// protocol, which is valid for the principals of the username. It
+// returns ErrNoPrincipals if the username has no principals.
+func (ca *CertificateAuthority) IssueUser(proto, username string) (key, cert string, err error) {
+	principals := ca.Principals[username]
+	if len(principals) == 0 {
+		return "", "", fmt.Errorf("%w %s", ErrNoPrincipals, username)
+	}
+	return ca.Issue(proto, username, principals)
+}
+
+// certError returns the guacamole status error of a failed issuing.
+func certError(err error) error {
+	if errors.Is(err, ErrNoPrincipals) {
+		return plugin.StatusError(protocol.StatusClientForbidden, err)
+	}
+	return plugin.StatusError(protocol.StatusServerError, err)
+}
+
+// Issue generates an ephemeral key for the given protocol, i.e. ssh or
+// ssh-go, and signs a user certificate of the key for the given
+// principals. It returns the private key in the PEM format and the
+// certificate in the authorized_keys format, as the private-key and
+// public-key arguments of the protocol.
+func (ca *CertificateAuthority) Issue(proto, keyID string, principals []string) (key, cert string, err error) {
+	gen, ok := certKeys[proto]
+	if !ok {
+		return "", "", fmt.Errorf("occamy: protocol %s does not support certificates", proto)
+	}
+	if len(principals) == 0 {
+		return "", "", errors.New("occamy: certificate without principals")
+	}
+	priv, block, err := gen()
+	if err != nil {
+		return "", "", fmt.Errorf("occamy: generate key error: %w", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return "", "", fmt.Errorf("occamy: generate key error: %w", err)
+	}
+
+	var serial [8]byte
+	if _, err := rand.Read(serial[:]); err != nil {
+		return "", "", fmt.Errorf("occamy: generate serial error: %w", err)
+	}
+	validity := ca.Validity
+	if validity <= 0 {
+		validity = DefaultCertValidity
+	}
+	now := time.Now()
+	c := &ssh.Certificate{
+		Key:             signer.PublicKey(),
+		Serial:          binary.BigEndian.Uint64(serial[:]),
+		CertType:        ssh.UserCert,
+		KeyId:           keyID,
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(now.Add(-certSkew).Unix()),
+		ValidBefore:     uint64(now.Add(validity).Unix()),
+		Permissions:     ssh.Permissions{Extensions: certExtensions},
+	}
+	if err := c.SignCert(rand.Reader, ca.Signer); err != nil {
+		return "", "", fmt.Errorf("occamy: sign certificate error: %w", err)
+	}
+	return string(pem.EncodeToMemory(block)),
+		strings.TrimSpace(string(ssh.MarshalAuthorizedKey(c))), nil
+}
+
+func rsaCertKey() (interface{}, *pem.Block, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}, nil
+}
+
+func ed25519CertKey() (interface{}, *pem.Block, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, &pem.Block{Type: "PRIVATE KEY", Bytes: der}, nil
+}
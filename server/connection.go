@@ -6,102 +6,245 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"net/http/pprof"
-	"os"
-	"os/signal"
+	"runtime/debug"
 	"sync"
 	"time"
 
 	"changkun.de/x/occamy/internal/config"
 	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
-func init() {
-	config.Init()
+// ErrServerClosed is returned by the Server's ListenAndServe method
+// after a call to Shutdown.
+var ErrServerClosed = errors.New("occamy: server closed")
+
+// Options are the runtime options of an occamy server
+type Options struct {
+	// Addr is the TCP address to listen on, it is only used by
+	// ListenAndServe.
+	Addr string
+	// Mode is the running mode of the server, options: debug/release/test.
+	// The debug mode additionally serves /debug/pprof.
+	Mode string
+	// JWTSecret is the key for signing JWT tokens.
+	JWTSecret string
+	// JWTAlgorithm is the JWT signing algorithm, HS256 by default.
+	JWTAlgorithm string
+	// Client enables the /api/v1/login endpoint and serves the web
+	// client demo from ClientDir at /static.
+	Client bool
+	// ClientDir is the directory of the web client demo,
+	// ./client/occamy-web/dist by default.
+	ClientDir string
+	// Interceptors are the global interceptors of all connections,
+	// they are called in order.
+	Interceptors []Interceptor
+	// ConnInterceptors returns the interceptors of a new connection,
+	// which are called after the global interceptors. It can also
+	// adjust the permissions of the connected user.
+	ConnInterceptors func(u *UserContext) []Interceptor
+	// SessionHook is called on lifecycle events of all sessions, e.g. for
+	// webhooks or auditing. It is called synchronously and must not block.
+	SessionHook func(e SessionEvent)
+	// ProtocolArgs are the connection arguments of protocols by protocol
+	// name, e.g. the typescript-path of telnet or enable-sftp of ssh.
+	// Arguments of the JWT take precedence.
+	ProtocolArgs map[string]plugin.Args
+	// HostKeys verifies the host keys of the SSH servers of the ssh and
+	// ssh-go protocols if set. The mode of the verification is set by
+	// the host-key-mode argument of the protocol or by the JWT.
+	HostKeys *HostKeys
+	// CertificateAuthority issues a short-lived certificate of an
+	// ephemeral key to log in to the SSH servers of the ssh and ssh-go
+	// protocols if set and the JWT has no password. It cannot be used
+	// with Client, whose login endpoint issues JWTs to any caller.
+	CertificateAuthority *CertificateAuthority
+	// Dialer connects to the remote hosts of the ssh, ssh-go, telnet, rdp,
+	// vnc and vnc-go protocols if set, the sessions then connect to a
+	// loopback endpoint which relays to the remote host by the dialer.
+	// It also dials the first jump host of a JWT.
+	Dialer Dialer
+	// Egress permits the remote hosts of the ssh, ssh-go, telnet, rdp,
+	// vnc and vnc-go protocols if set. Connections to other hosts are
+	// rejected before their session is created, and the permitted
+	// sessions connect through a loopback endpoint as with a Dialer.
+	Egress *EgressPolicy
+	// AdminToken enables the admin API at /api/v1/admin if set, which
+	// is authorized by the token as bearer token.
+	AdminToken string
+}
+
+// Server is an occamy proxy that serves all sessions
+// connects to occamy
+type Server struct {
+	opts     Options
+	jwtm     *jwt.GinJWTMiddleware
+	upgrader *websocket.Upgrader
+	engine   *gin.Engine
+	http     *http.Server
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+
+	cmu    sync.Mutex // protects http, conns and closed
+	conns  map[*websocket.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup // counts active connections
 }
 
-// Run is an export method that serves occamy proxy
-func Run() {
-	proxy := &proxy{
+// New creates an occamy server using the given options.
+func New(opts Options) (*Server, error) {
+	if opts.JWTAlgorithm == "" {
+		opts.JWTAlgorithm = "HS256"
+	}
+	if opts.ClientDir == "" {
+		opts.ClientDir = "./client/occamy-web/dist"
+	}
+	if opts.Client && opts.CertificateAuthority != nil {
+		return nil, errors.New("occamy: certificates cannot be issued to the unauthenticated logins of the web client")
+	}
+	s := &Server{
+		opts:     opts,
 		sessions: make(map[string]*Session),
+		conns:    make(map[*websocket.Conn]struct{}),
 		upgrader: &websocket.Upgrader{
 			ReadBufferSize:  protocol.MaxInstructionLength,
 			WriteBufferSize: protocol.MaxInstructionLength,
 			Subprotocols:    []string{"guacamole"}, // fixed by guacamole-client
 		},
 	}
-	proxy.serve()
+	err := s.initJWT()
+	if err != nil {
+		return nil, fmt.Errorf("initialize router error: %w", err)
+	}
+	s.routers()
+	return s, nil
 }
 
-// proxy is an occamy proxy that serves all sessions
-// connects to occamy
-type proxy struct {
-	jwtm     *jwt.GinJWTMiddleware
-	upgrader *websocket.Upgrader
-	engine   *gin.Engine
+// Handler returns the http.Handler that serves the occamy APIs.
+// It can be mounted into any other http server.
+func (s *Server) Handler() http.Handler {
+	return s.engine
+}
 
-	mu       sync.Mutex
-	sessions map[string]*Session
+// ListenAndServe listens on Options.Addr and serves the occamy APIs.
+// It always returns a non-nil error. After Shutdown, the returned
+// error is ErrServerClosed.
+func (s *Server) ListenAndServe() error {
+	s.cmu.Lock()
+	if s.closed {
+		s.cmu.Unlock()
+		return ErrServerClosed
+	}
+	s.http = &http.Server{Handler: s.engine, Addr: s.opts.Addr}
+	s.cmu.Unlock()
+
+	log.Printf("starting at http://%s...", s.opts.Addr)
+	err := s.http.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return ErrServerClosed
+	}
+	return err
 }
 
-func (p *proxy) serve() {
-	s := &http.Server{
-		Handler: p.routers(),
-		Addr:    config.Runtime.Address,
+// Shutdown gracefully shuts down the server. It stops accepting new
+// connections, closes all connected users, and waits until all
+// sessions are terminated or the given context is done.
+func (s *Server) Shutdown(ctx context.Context) (err error) {
+	s.cmu.Lock()
+	s.closed = true
+	hs := s.http
+	for ws := range s.conns {
+		ws.Close()
 	}
+	s.cmu.Unlock()
+
+	if hs != nil {
+		err = hs.Shutdown(ctx)
+	}
+
 	done := make(chan struct{})
 	go func() {
-		quit := make(chan os.Signal, 1)
-		signal.Notify(quit, os.Interrupt, os.Kill)
-		sig := <-quit
-		log.Printf("shutting down occammy proxy... %v", sig)
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		if err := s.Shutdown(ctx); err != nil {
-			log.Printf("server shutdown with error: %v", err)
-		}
-		cancel()
-		done <- struct{}{}
+		s.wg.Wait()
+		close(done)
 	}()
-	log.Printf("starting at http://%s...", config.Runtime.Address)
-	err := s.ListenAndServe()
-	if err != http.ErrServerClosed {
-		log.Printf("close with error: %v", err)
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
 	}
-	<-done
-	log.Println("occamy proxy is down, good bye!")
 	return
 }
 
-func (p *proxy) routers() *gin.Engine {
-	p.engine = gin.Default()
-	p.initJWT()
-	if config.Runtime.Client {
-		p.engine.StaticFS("/static", http.Dir("./client/occamy-web/dist"))
+// interceptors returns all interceptors of a new connection
+func (s *Server) interceptors(u *UserContext) []Interceptor {
+	if s.opts.ConnInterceptors == nil {
+		return s.opts.Interceptors
 	}
-	v1 := p.engine.Group("/api/v1")
-	if config.Runtime.Client {
-		v1.POST("/login", p.jwtm.LoginHandler)
+	conn := s.opts.ConnInterceptors(u)
+	all := make([]Interceptor, 0, len(s.opts.Interceptors)+len(conn))
+	all = append(all, s.opts.Interceptors...)
+	return append(all, conn...)
+}
+
+func (s *Server) routers() {
+	s.engine = gin.New()
+	s.engine.Use(logRequests, recoverPanics)
+	if s.opts.Client {
+		s.engine.StaticFS("/static", http.Dir(s.opts.ClientDir))
+	}
+	v1 := s.engine.Group("/api/v1")
+	if s.opts.Client {
+		v1.POST("/login", s.jwtm.LoginHandler)
 	}
 	auth := v1.Group("/connect")
-	auth.Use(p.jwtm.MiddlewareFunc())
-	auth.GET("", p.serveWS)
-	if gin.Mode() == gin.DebugMode {
-		p.profile()
+	auth.Use(s.jwtm.MiddlewareFunc())
+	auth.GET("", s.serveWS)
+	if s.opts.AdminToken != "" {
+		s.admin(v1.Group("/admin"))
 	}
-	return p.engine
+	if s.opts.Mode == gin.DebugMode {
+		s.profile()
+	}
+}
+
+// logRequests logs the requests once they are served. The query is not
+// logged as it carries the token of websocket connections.
+func logRequests(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+	log.Printf("%s %s %d %v", c.Request.Method, c.Request.URL.Path, c.Writer.Status(), time.Since(start))
+}
+
+// recoverPanics answers requests whose handler panicked with an internal
+// server error, the panic is logged.
+func recoverPanics(c *gin.Context) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("panic serving %s %s: %v\n%s", c.Request.Method, c.Request.URL.Path, err, debug.Stack())
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+	}()
+	c.Next()
 }
 
-func (p *proxy) initJWT() {
+func (s *Server) initJWT() error {
 	jwtm, err := jwt.New(&jwt.GinJWTMiddleware{
 		Realm:            "occamy-proxy",
-		Key:              []byte(config.Runtime.Auth.JWTSecret),
-		SigningAlgorithm: config.Runtime.Auth.JWTAlgorithm,
-		TimeFunc:         time.Now().UTC,
+		Key:              []byte(s.opts.JWTSecret),
+		SigningAlgorithm: s.opts.JWTAlgorithm,
+		TimeFunc:         func() time.Time { return time.Now().UTC() },
 		Authenticator: func(c *gin.Context) (interface{}, error) {
 			var conf config.JWT
 			err := c.ShouldBind(&conf)
@@ -114,10 +257,12 @@ func (p *proxy) initJWT() {
 		PayloadFunc: func(data interface{}) jwt.MapClaims {
 			if v, ok := data.(*config.JWT); ok {
 				return jwt.MapClaims{
-					"protocol": v.Protocol,
-					"host":     v.Host,
-					"username": v.Username,
-					"password": v.Password,
+					"protocol":      v.Protocol,
+					"host":          v.Host,
+					"username":      v.Username,
+					"password":      v.Password,
+					"host_key_mode": v.HostKeyMode,
+					"jump_hosts":    v.JumpHosts,
 				}
 			}
 			return jwt.MapClaims{}
@@ -125,9 +270,10 @@ func (p *proxy) initJWT() {
 		TokenLookup: "header: Authorization, query: token, cookie: jwt",
 	})
 	if err != nil {
-		log.Fatalf("initialize router error: %v", err)
+		return err
 	}
-	p.jwtm = jwtm
+	s.jwtm = jwtm
+	return nil
 }
 
 // profile the standard HandlerFuncs from the net/http/pprof package with
@@ -145,14 +291,14 @@ func (p *proxy) initJWT() {
 // - collect a 5-second execution trace:
 //   wget http://0.0.0.0:5636/debug/pprof/trace?seconds=5
 //
-func (p *proxy) profile() {
+func (s *Server) profile() {
 	pprofHandler := func(h http.HandlerFunc) gin.HandlerFunc {
 		handler := http.HandlerFunc(h)
 		return func(c *gin.Context) {
 			handler.ServeHTTP(c.Writer, c.Request)
 		}
 	}
-	r := p.engine.Group("/debug/pprof")
+	r := s.engine.Group("/debug/pprof")
 	{
 		r.GET("/", pprofHandler(pprof.Index))
 		r.GET("/cmdline", pprofHandler(pprof.Cmdline))
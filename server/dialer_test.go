@@ -0,0 +1,221 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package server_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/client"
+	"changkun.de/x/occamy/server"
+)
+
+// socks5Proxy is a SOCKS5 proxy without authentication which reports the
+// addresses it connects to.
+func socks5Proxy(t *testing.T, connects chan<- string) string {
+	return proxy(t, func(nc net.Conn) (net.Conn, error) {
+		var head [3]byte
+		if _, err := io.ReadFull(nc, head[:2]); err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(ioutil.Discard, nc, int64(head[1])); err != nil {
+			return nil, err
+		}
+		nc.Write([]byte{5, 0})
+
+		if _, err := io.ReadFull(nc, head[:]); err != nil {
+			return nil, err
+		}
+		var atyp [1]byte
+		if _, err := io.ReadFull(nc, atyp[:]); err != nil {
+			return nil, err
+		}
+		var host string
+		switch atyp[0] {
+		case 1:
+			ip := make(net.IP, net.IPv4len)
+			io.ReadFull(nc, ip)
+			host = ip.String()
+		case 3:
+			var n [1]byte
+			io.ReadFull(nc, n[:])
+			name := make([]byte, n[0])
+			io.ReadFull(nc, name)
+			host = string(name)
+		}
+		var port [2]byte
+		if _, err := io.ReadFull(nc, port[:]); err != nil {
+			return nil, err
+		}
+		addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:]))))
+		rc, err := net.Dial("tcp", addr)
+		if err != nil {
+			nc.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+			return nil, err
+		}
+		connects <- addr
+		nc.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+		return rc, nil
+	})
+}
+
+// httpProxy is an HTTP proxy which only supports CONNECT and reports the
+// addresses it connects to.
+func httpProxy(t *testing.T, connects chan<- string) string {
+	return proxy(t, func(nc net.Conn) (net.Conn, error) {
+		req, err := http.ReadRequest(bufio.NewReader(nc))
+		if err != nil {
+			return nil, err
+		}
+		if req.Method != http.MethodConnect {
+			io.WriteString(nc, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+			return nil, io.EOF
+		}
+		rc, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			io.WriteString(nc, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+			return nil, err
+		}
+		connects <- req.Host
+		io.WriteString(nc, "HTTP/1.1 200 Connection established\r\n\r\n")
+		return rc, nil
+	})
+}
+
+// proxy serves a proxy whose handshake returns the connection to the
+// requested address.
+func proxy(t *testing.T, handshake func(nc net.Conn) (net.Conn, error)) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer nc.Close()
+				rc, err := handshake(nc)
+				if err != nil {
+					return
+				}
+				defer rc.Close()
+				go io.Copy(rc, nc)
+				io.Copy(nc, rc)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestParseDialer(t *testing.T) {
+	if d, err := server.ParseDialer("direct", nil); err != nil || d != server.Direct {
+		t.Fatalf("parse direct dialer: got %v, %v", d, err)
+	}
+	for _, rawurl := range []string{
+		"socks5://",
+		"ftp://proxy:21",
+		"ssh://gateway",
+		"ssh://occamy@gateway",
+		"ssh://occamy@gateway?key=/nonexistent",
+	} {
+		if _, err := server.ParseDialer(rawurl, nil); err == nil {
+			t.Fatalf("parse %s: want an error", rawurl)
+		}
+	}
+}
+
+func TestDialer(t *testing.T) {
+	connects := make(chan string, 10)
+	jump := newJumpHost(t, "gateway")
+	dialers := []struct {
+		name   string
+		rawurl string
+		report <-chan string
+	}{
+		{"socks5", "socks5://" + socks5Proxy(t, connects), connects},
+		{"http", "http://" + httpProxy(t, connects), connects},
+		{"ssh", "ssh://occamy:gateway@" + jump.addr(), jump.forwards},
+	}
+
+	for _, tt := range dialers {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer, err := server.ParseDialer(tt.rawurl, nil)
+			if err != nil {
+				t.Fatalf("parse dialer error: %v", err)
+			}
+			s, err := server.New(server.Options{
+				Mode:      "test",
+				JWTSecret: "secret",
+				Client:    true,
+				ClientDir: t.TempDir(),
+				Dialer:    dialer,
+			})
+			if err != nil {
+				t.Fatalf("create server error: %v", err)
+			}
+			ts := httptest.NewServer(s.Handler())
+			defer ts.Close()
+
+			target, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("listen error: %v", err)
+			}
+			defer target.Close()
+			accepted := make(chan net.Conn, 1)
+			go func() {
+				nc, err := target.Accept()
+				if err == nil {
+					accepted <- nc
+				}
+			}()
+
+			body := `{"protocol":"telnet","host":"` + target.Addr().String() + `"}`
+			resp, err := http.Post(ts.URL+"/api/v1/login", "application/json", strings.NewReader(body))
+			if err != nil {
+				t.Fatalf("login error: %v", err)
+			}
+			var out struct {
+				Token string `json:"token"`
+			}
+			err = json.NewDecoder(resp.Body).Decode(&out)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatalf("decode token error: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			c, err := client.Dial(ctx, ts.URL, out.Token, nil)
+			if err != nil {
+				t.Fatalf("connect error: %v", err)
+			}
+			defer c.Close()
+			select {
+			case nc := <-accepted:
+				nc.Close()
+			case <-ctx.Done():
+				t.Fatalf("the target was not connected")
+			}
+			if got := <-tt.report; got != target.Addr().String() {
+				t.Fatalf("dialer connected to %s, want the target %s", got, target.Addr())
+			}
+		})
+	}
+}
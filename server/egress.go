@@ -0,0 +1,219 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"changkun.de/x/occamy/internal/config"
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+)
+
+// ErrEgressDenied reports a remote host which is not permitted by the
+// egress policy.
+var ErrEgressDenied = errors.New("occamy: remote host is not permitted")
+
+// EgressPolicy decides which remote hosts occamy connects to, so that
+// the connect API cannot reach arbitrary hosts of the internal network,
+// e.g. cloud metadata endpoints. The host of a connection is resolved
+// and every address of it has to be permitted, the connection is then
+// made to a permitted address, hence a DNS record which changes in the
+// meantime is not followed.
+type EgressPolicy struct {
+	rules []egressRule
+	allow bool // if no rule matches
+}
+
+// egressRule is a parsed config.EgressRule.
+type egressRule struct {
+	allow     bool
+	protocols map[string]bool
+	names     []string // host names, *.suffix matches all subdomains
+	nets      []*net.IPNet
+	ports     [][2]int // inclusive ranges
+}
+
+// NewEgressPolicy returns the egress policy of the given rules, the
+// first rule which matches a connection decides. Connections which no
+// rule matches are permitted if def is allow, and denied if it is deny
+// or empty.
+func NewEgressPolicy(def string, rules []config.EgressRule) (*EgressPolicy, error) {
+	p := &EgressPolicy{}
+	switch def {
+	case "allow":
+		p.allow = true
+	case "deny", "":
+	default:
+		return nil, fmt.Errorf("occamy: invalid egress default %q", def)
+	}
+	for i, r := range rules {
+		rule, err := parseEgressRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("occamy: egress rule %d: %w", i+1, err)
+		}
+		p.rules = append(p.rules, rule)
+	}
+	return p, nil
+}
+
+func parseEgressRule(r config.EgressRule) (egressRule, error) {
+	rule := egressRule{}
+	switch r.Action {
+	case "allow":
+		rule.allow = true
+	case "deny":
+	default:
+		return rule, fmt.Errorf("invalid action %q", r.Action)
+	}
+	if len(r.Protocols) > 0 {
+		rule.protocols = make(map[string]bool, len(r.Protocols))
+		for _, proto := range r.Protocols {
+			rule.protocols[proto] = true
+		}
+	}
+	for _, host := range r.Hosts {
+		if _, ipnet, err := net.ParseCIDR(host); err == nil {
+			rule.nets = append(rule.nets, ipnet)
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			bits := 8 * len(ip.To16())
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			rule.nets = append(rule.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		if host == "" || strings.ContainsAny(host, "/:") {
+			return rule, fmt.Errorf("invalid host %q", host)
+		}
+		rule.names = append(rule.names, strings.ToLower(strings.TrimSuffix(host, ".")))
+	}
+	for _, port := range r.Ports {
+		lo, hi := port, port
+		if i := strings.IndexByte(port, '-'); i >= 0 {
+			lo, hi = port[:i], port[i+1:]
+		}
+		from, err1 := strconv.Atoi(lo)
+		to, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || from < 1 || to > 0xffff || from > to {
+			return rule, fmt.Errorf("invalid port %q", port)
+		}
+		rule.ports = append(rule.ports, [2]int{from, to})
+	}
+	return rule, nil
+}
+
+// matches reports whether the rule matches a connection of the given
+// protocol to the given address and port of the given host name, which
+// is empty if the host is an IP address.
+func (r *egressRule) matches(proto, name string, ip net.IP, port int) bool {
+	if r.protocols != nil && !r.protocols[proto] {
+		return false
+	}
+	if r.ports != nil {
+		ok := false
+		for _, ports := range r.ports {
+			ok = ok || ports[0] <= port && port <= ports[1]
+		}
+		if !ok {
+			return false
+		}
+	}
+	if r.names == nil && r.nets == nil {
+		return true
+	}
+	for _, ipnet := range r.nets {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	for _, pattern := range r.names {
+		if name == pattern ||
+			strings.HasPrefix(pattern, "*.") && strings.HasSuffix(name, pattern[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// permits reports whether the policy permits a connection of the given
+// protocol to the given address and port of the given host name.
+func (p *EgressPolicy) permits(proto, name string, ip net.IP, port int) bool {
+	for i := range p.rules {
+		if p.rules[i].matches(proto, name, ip, port) {
+			return p.rules[i].allow
+		}
+	}
+	return p.allow
+}
+
+// Check resolves the host of the given host:port and returns its
+// addresses if the policy permits connections of the given protocol to
+// all of them, otherwise an error of ErrEgressDenied.
+func (p *EgressPolicy) Check(proto, addr string) ([]net.IP, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, plugin.StatusError(protocol.StatusClientBadRequest, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 0xffff {
+		return nil, plugin.StatusError(protocol.StatusClientBadRequest,
+			fmt.Errorf("occamy: invalid port of %s", addr))
+	}
+
+	var (
+		name string
+		ips  []net.IP
+	)
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		name = strings.ToLower(strings.TrimSuffix(host, "."))
+		ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+		defer cancel()
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, plugin.StatusError(protocol.StatusUpstreamNotFound, err)
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+	for _, ip := range ips {
+		if !p.permits(proto, name, ip, port) {
+			return nil, plugin.StatusError(protocol.StatusClientForbidden,
+				fmt.Errorf("%w: %s connection to %s (%s)", ErrEgressDenied, proto, addr, ip))
+		}
+	}
+	return ips, nil
+}
+
+// dialer returns a dial function which checks the connections of the
+// given protocol by the policy and dials a permitted address of the
+// host by the given dial function.
+func (p *EgressPolicy) dialer(proto string, dial dialFunc) dialFunc {
+	return func(network, addr string) (net.Conn, error) {
+		ips, err := p.Check(proto, addr)
+		if err != nil {
+			return nil, err
+		}
+		_, port, _ := net.SplitHostPort(addr)
+		for _, ip := range ips {
+			var nc net.Conn
+			nc, err = dial(network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return nc, nil
+			}
+		}
+		return nil, err
+	}
+}
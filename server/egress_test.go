@@ -0,0 +1,263 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/client"
+	"changkun.de/x/occamy/internal/config"
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+	"changkun.de/x/occamy/server"
+)
+
+func TestEgressPolicy_Check(t *testing.T) {
+	policy, err := server.NewEgressPolicy("deny", []config.EgressRule{
+		{Action: "deny", Hosts: []string{"169.254.0.0/16", "10.0.0.1"}},
+		{Action: "allow", Protocols: []string{"ssh"}, Hosts: []string{"10.0.0.0/8"}, Ports: []string{"22", "2200-2299"}},
+		{Action: "allow", Protocols: []string{"telnet"}, Hosts: []string{"localhost", "*.localhost"}},
+		{Action: "allow", Protocols: []string{"vnc"}, Hosts: []string{"::1"}},
+	})
+	if err != nil {
+		t.Fatalf("new egress policy error: %v", err)
+	}
+
+	tests := []struct {
+		proto, addr string
+		permitted   bool
+	}{
+		{"ssh", "10.1.2.3:22", true},
+		{"ssh", "10.1.2.3:2222", true},
+		{"ssh", "10.1.2.3:2300", false},
+		{"ssh", "10.0.0.1:22", false},
+		{"ssh-go", "10.1.2.3:22", false},
+		{"ssh", "169.254.169.254:22", false},
+		{"ssh", "192.168.0.1:22", false},
+		{"telnet", "localhost:23", true},
+		{"telnet", "127.0.0.1:23", false},
+		{"vnc", "[::1]:5900", true},
+		{"vnc", "127.0.0.1:5900", false},
+	}
+	for _, tt := range tests {
+		_, err := policy.Check(tt.proto, tt.addr)
+		if tt.permitted && err != nil {
+			t.Fatalf("%s %s: unexpected error: %v", tt.proto, tt.addr, err)
+		}
+		if !tt.permitted && !errors.Is(err, server.ErrEgressDenied) {
+			t.Fatalf("%s %s: got %v, want %v", tt.proto, tt.addr, err, server.ErrEgressDenied)
+		}
+		if !tt.permitted && plugin.StatusOf(err) != protocol.StatusClientForbidden {
+			t.Fatalf("%s %s: status %v", tt.proto, tt.addr, plugin.StatusOf(err))
+		}
+	}
+
+	allow, err := server.NewEgressPolicy("allow", nil)
+	if err != nil {
+		t.Fatalf("new egress policy error: %v", err)
+	}
+	if _, err := allow.Check("rdp", "192.168.0.1:3389"); err != nil {
+		t.Fatalf("default allow: got %v", err)
+	}
+
+	for _, rule := range []config.EgressRule{
+		{Action: "permit"},
+		{Action: "allow", Hosts: []string{"10.0.0.0/33"}},
+		{Action: "allow", Hosts: []string{""}},
+		{Action: "allow", Ports: []string{"0"}},
+		{Action: "allow", Ports: []string{"30-20"}},
+		{Action: "allow", Ports: []string{"ssh"}},
+	} {
+		if _, err := server.NewEgressPolicy("", []config.EgressRule{rule}); err == nil {
+			t.Fatalf("rule %+v: want an error", rule)
+		}
+	}
+	if _, err := server.NewEgressPolicy("maybe", nil); err == nil {
+		t.Fatalf("invalid default: want an error")
+	}
+}
+
+func TestEgressPolicy_Connect(t *testing.T) {
+	policy, err := server.NewEgressPolicy("deny", []config.EgressRule{
+		{Action: "allow", Protocols: []string{"telnet"}, Hosts: []string{"localhost"}},
+	})
+	if err != nil {
+		t.Fatalf("new egress policy error: %v", err)
+	}
+	s, err := server.New(server.Options{
+		Mode:      "test",
+		JWTSecret: "secret",
+		Client:    true,
+		ClientDir: t.TempDir(),
+		Egress:    policy,
+	})
+	if err != nil {
+		t.Fatalf("create server error: %v", err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	defer target.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		nc, err := target.Accept()
+		if err == nil {
+			accepted <- nc
+		}
+	}()
+	_, port, _ := net.SplitHostPort(target.Addr().String())
+
+	login := func(host string) string {
+		t.Helper()
+		body := `{"protocol":"telnet","host":"` + host + `"}`
+		resp, err := http.Post(ts.URL+"/api/v1/login", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("login error: %v", err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode token error: %v", err)
+		}
+		return out.Token
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the address is not permitted although localhost is
+	c, err := client.Dial(ctx, ts.URL, login(target.Addr().String()), nil)
+	if err == nil {
+		<-c.Done()
+		err = c.Err()
+	}
+	var serr *client.ServerError
+	if !errors.As(err, &serr) || serr.Status != protocol.StatusClientForbidden {
+		t.Fatalf("connect to a forbidden host: got %v", err)
+	}
+	select {
+	case <-accepted:
+		t.Fatalf("a forbidden host was connected")
+	default:
+	}
+
+	c, err = client.Dial(ctx, ts.URL, login(net.JoinHostPort("localhost", port)), nil)
+	if err != nil {
+		t.Fatalf("connect to a permitted host error: %v", err)
+	}
+	defer c.Close()
+	select {
+	case nc := <-accepted:
+		nc.Close()
+	case <-ctx.Done():
+		t.Fatalf("the permitted host was not connected")
+	}
+}
+
+func TestEgressPolicy_JumpHosts(t *testing.T) {
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	defer target.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		nc, err := target.Accept()
+		if err == nil {
+			accepted <- nc
+		}
+	}()
+	bastion, inner := newJumpHost(t, "bastion"), newJumpHost(t, "inner")
+	_, bastionPort, _ := net.SplitHostPort(bastion.addr())
+	_, targetPort, _ := net.SplitHostPort(target.Addr().String())
+
+	// only the bastion and the target are permitted
+	policy, err := server.NewEgressPolicy("deny", []config.EgressRule{
+		{Action: "allow", Protocols: []string{"ssh"}, Hosts: []string{"127.0.0.1"}, Ports: []string{bastionPort}},
+		{Action: "allow", Protocols: []string{"telnet"}, Hosts: []string{"127.0.0.1"}, Ports: []string{targetPort}},
+	})
+	if err != nil {
+		t.Fatalf("new egress policy error: %v", err)
+	}
+	s, err := server.New(server.Options{
+		Mode:      "test",
+		JWTSecret: "secret",
+		Client:    true,
+		ClientDir: t.TempDir(),
+		Egress:    policy,
+	})
+	if err != nil {
+		t.Fatalf("create server error: %v", err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	login := func(host, hops string) string {
+		t.Helper()
+		body := `{"protocol":"telnet","host":"` + host + `","jump_hosts":` + hops + `}`
+		resp, err := http.Post(ts.URL+"/api/v1/login", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("login error: %v", err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode token error: %v", err)
+		}
+		return out.Token
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	hop := func(j *jumpHost, password string) string {
+		return `{"host":"` + j.addr() + `","username":"occamy","password":"` + password + `"}`
+	}
+	for _, tt := range []struct{ name, host, hops string }{
+		{"second jump host", target.Addr().String(), `[` + hop(bastion, "bastion") + `,` + hop(inner, "inner") + `]`},
+		{"target", inner.addr(), `[` + hop(bastion, "bastion") + `]`},
+	} {
+		c, err := client.Dial(ctx, ts.URL, login(tt.host, tt.hops), nil)
+		if err == nil {
+			<-c.Done()
+			err = c.Err()
+		}
+		var serr *client.ServerError
+		if !errors.As(err, &serr) || serr.Status != protocol.StatusClientForbidden {
+			t.Fatalf("connect to a forbidden %s through a permitted jump host: got %v", tt.name, err)
+		}
+	}
+	select {
+	case addr := <-bastion.forwards:
+		t.Fatalf("the bastion forwarded to %s", addr)
+	default:
+	}
+
+	c, err := client.Dial(ctx, ts.URL, login(target.Addr().String(), `[`+hop(bastion, "bastion")+`]`), nil)
+	if err != nil {
+		t.Fatalf("connect to a permitted target error: %v", err)
+	}
+	defer c.Close()
+	select {
+	case nc := <-accepted:
+		nc.Close()
+	case <-ctx.Done():
+		t.Fatalf("the permitted target was not connected")
+	}
+}
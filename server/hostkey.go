@@ -0,0 +1,385 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"changkun.de/x/occamy/internal/config"
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyMode decides how connections to SSH servers without a known
+// host key are handled.
+type HostKeyMode string
+
+// Modes of the host key verification
+const (
+	// HostKeyTOFU trusts the host key of an unknown server on first use
+	// and stores it for later connections.
+	HostKeyTOFU HostKeyMode = "tofu"
+	// HostKeyStrict rejects connections to unknown servers, their host
+	// keys have to be pinned by the admin API beforehand.
+	HostKeyStrict HostKeyMode = "strict"
+)
+
+// Errors of the host key verification
+var (
+	ErrHostKeyUnknown = errors.New("occamy: host key is not known")
+	ErrHostKeyChanged = errors.New("occamy: host key has changed")
+	ErrHostKeyInvalid = errors.New("occamy: invalid host key")
+)
+
+// hostKeyProtocols are the protocols whose host keys are verified by
+// the host key store, all of them accept the host-key argument.
+var hostKeyProtocols = map[string]bool{"ssh": true, "ssh-go": true}
+
+// hostKeyTimeout limits fetching the host key of a server.
+const hostKeyTimeout = 10 * time.Second
+
+// HostKey is a known host key of an SSH server.
+type HostKey struct {
+	// Host is the address of the server, i.e. host:port.
+	Host string `json:"host"`
+	// Type is the algorithm of the key, e.g. ssh-ed25519.
+	Type string `json:"type"`
+	// Fingerprint is the SHA256 fingerprint of the key.
+	Fingerprint string `json:"fingerprint"`
+	// Key is the key in the authorized_keys format.
+	Key string `json:"key"`
+}
+
+// HostKeys is a store of the known host keys of SSH servers, which is
+// persisted as an OpenSSH known_hosts file. The stored key of a server
+// is passed to the ssh and ssh-go protocols as the host-key argument.
+type HostKeys struct {
+	path string
+
+	mu   sync.Mutex
+	keys map[string]ssh.PublicKey // by host:port
+}
+
+// OpenHostKeys opens the host key store of the given known_hosts file.
+// The file is created once a host key is stored.
+func OpenHostKeys(path string) (*HostKeys, error) {
+	h := &HostKeys{path: path, keys: make(map[string]ssh.PublicKey)}
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("occamy: read host keys error: %w", err)
+	}
+	for rest := data; ; {
+		var (
+			marker string
+			hosts  []string
+			key    ssh.PublicKey
+		)
+		marker, hosts, key, _, rest, err = ssh.ParseKnownHosts(rest)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("occamy: parse host keys error: %w", err)
+		}
+		if marker != "" {
+			return nil, fmt.Errorf("occamy: parse host keys error: unsupported marker @%s", marker)
+		}
+		for _, host := range hosts {
+			h.keys[hostAddr(host)] = key
+		}
+	}
+	return h, nil
+}
+
+// hostAddr converts a host of a known_hosts file to host:port.
+func hostAddr(host string) string {
+	if strings.HasPrefix(host, "[") {
+		if h, p, err := net.SplitHostPort(host); err == nil {
+			return net.JoinHostPort(h, p)
+		}
+	}
+	return net.JoinHostPort(host, "22")
+}
+
+// newHostKey describes the given key of the given host.
+func newHostKey(host string, key ssh.PublicKey) HostKey {
+	return HostKey{
+		Host:        host,
+		Type:        key.Type(),
+		Fingerprint: ssh.FingerprintSHA256(key),
+		Key:         strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))),
+	}
+}
+
+// List returns all known host keys, sorted by host.
+func (h *HostKeys) List() []HostKey {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	keys := make([]HostKey, 0, len(h.keys))
+	for host, key := range h.keys {
+		keys = append(keys, newHostKey(host, key))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Host < keys[j].Host })
+	return keys
+}
+
+// Get returns the known host key of the given host:port.
+func (h *HostKeys) Get(host string) (HostKey, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key, ok := h.keys[host]
+	if !ok {
+		return HostKey{}, false
+	}
+	return newHostKey(host, key), true
+}
+
+// Pin stores the given key in the authorized_keys format as the host
+// key of the given host:port, it replaces a known key of the host.
+func (h *HostKeys) Pin(host, authorizedKey string) (HostKey, error) {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		return HostKey{}, fmt.Errorf("%w: %v", ErrHostKeyInvalid, err)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+	if err != nil {
+		return HostKey{}, fmt.Errorf("%w: %v", ErrHostKeyInvalid, err)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	old, ok := h.keys[host]
+	h.keys[host] = key
+	if err := h.save(); err != nil {
+		if ok {
+			h.keys[host] = old
+		} else {
+			delete(h.keys, host)
+		}
+		return HostKey{}, err
+	}
+	return newHostKey(host, key), nil
+}
+
+// Revoke removes the host key of the given host:port, it reports false
+// if the host is not known.
+func (h *HostKeys) Revoke(host string) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key, ok := h.keys[host]
+	if !ok {
+		return false, nil
+	}
+	delete(h.keys, host)
+	if err := h.save(); err != nil {
+		h.keys[host] = key
+		return false, err
+	}
+	return true, nil
+}
+
+// save writes all host keys to the known_hosts file, it is called with
+// h.mu held.
+func (h *HostKeys) save() error {
+	hosts := make([]string, 0, len(h.keys))
+	for host := range h.keys {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	var buf bytes.Buffer
+	for _, host := range hosts {
+		buf.WriteString(knownhosts.Line([]string{host}, h.keys[host]))
+		buf.WriteByte('\n')
+	}
+
+	// the file is replaced at once, a crash cannot truncate it
+	f, err := ioutil.TempFile(filepath.Dir(h.path), ".known_hosts")
+	if err != nil {
+		return fmt.Errorf("occamy: save host keys error: %w", err)
+	}
+	_, err = f.Write(buf.Bytes())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), h.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("occamy: save host keys error: %w", err)
+	}
+	return nil
+}
+
+// Verify fetches the host key of the SSH server of the given host:port
+// and compares it with the known host key. A server without a known
+// key is trusted and stored in the HostKeyTOFU mode, and rejected in
+// the HostKeyStrict mode. It returns the verified key in the
+// known_hosts format, as the host-key argument of the ssh protocols.
+func (h *HostKeys) Verify(host string, mode HostKeyMode) (string, error) {
+	key, err := h.verify(host, mode, nil)
+	if err != nil {
+		return "", err
+	}
+	return knownhosts.Line([]string{host}, key), nil
+}
+
+// verify fetches the host key of the given host:port through the given
+// dial function, or directly if nil, and checks it as Verify.
+func (h *HostKeys) verify(host string, mode HostKeyMode, dial dialFunc) (ssh.PublicKey, error) {
+	algos := h.algorithms(host)
+	if algos == nil && mode != HostKeyTOFU {
+		return nil, unknownHostKey(host, mode)
+	}
+	key, err := fetchHostKey(host, algos, dial)
+	if err != nil {
+		return nil, plugin.StatusError(protocol.StatusUpstreamError,
+			fmt.Errorf("occamy: fetch host key of %s error: %w", host, err))
+	}
+	if err := h.check(host, key, mode); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// callback returns the verification of the host keys of SSH connections
+// in the given mode, the connections have to be established to host:port
+// addresses and restricted to the algorithms of the host.
+func (h *HostKeys) callback(mode HostKeyMode) ssh.HostKeyCallback {
+	return func(host string, _ net.Addr, key ssh.PublicKey) error {
+		return h.check(host, key, mode)
+	}
+}
+
+// algorithms returns the key algorithm of the known host key of the
+// given host:port, or nil if the host is not known. Servers may have
+// keys of other algorithms.
+func (h *HostKeys) algorithms(host string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if known, ok := h.keys[host]; ok {
+		return []string{known.Type()}
+	}
+	return nil
+}
+
+// check compares the presented key of the given host:port with the
+// known host key, a key of an unknown host is stored in the HostKeyTOFU
+// mode and rejected otherwise.
+func (h *HostKeys) check(host string, key ssh.PublicKey, mode HostKeyMode) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if known, ok := h.keys[host]; ok {
+		return compareHostKey(host, key, known)
+	}
+	if mode != HostKeyTOFU {
+		return unknownHostKey(host, mode)
+	}
+	h.keys[host] = key
+	if err := h.save(); err != nil {
+		delete(h.keys, host)
+		return plugin.StatusError(protocol.StatusServerError, err)
+	}
+	log.Printf("trusted host key of %s on first use: %s %s", host, key.Type(), ssh.FingerprintSHA256(key))
+	return nil
+}
+
+// unknownHostKey is the error of an unknown host in the given mode.
+func unknownHostKey(host string, mode HostKeyMode) error {
+	return plugin.StatusError(protocol.StatusClientForbidden,
+		fmt.Errorf("%w: %s is not trusted in the %s mode", ErrHostKeyUnknown, host, mode))
+}
+
+// compareHostKey compares the presented key of the given host with the
+// known key.
+func compareHostKey(host string, key, known ssh.PublicKey) error {
+	if !bytes.Equal(key.Marshal(), known.Marshal()) {
+		return plugin.StatusError(protocol.StatusUpstreamError,
+			fmt.Errorf("%w: %s presents %s %s, but %s is known", ErrHostKeyChanged,
+				host, key.Type(), ssh.FingerprintSHA256(key), ssh.FingerprintSHA256(known)))
+	}
+	return nil
+}
+
+// errHostKeyFetched aborts the handshake of fetchHostKey.
+var errHostKeyFetched = errors.New("host key fetched")
+
+// fetchHostKey returns the host key that the SSH server of the given
+// host:port presents for the given key algorithms, or for any algorithm
+// if none is given. The server is connected by the given dial function,
+// or directly if nil, and the handshake is aborted before authentication.
+func fetchHostKey(host string, algos []string, dial dialFunc) (ssh.PublicKey, error) {
+	if dial == nil {
+		dial = Direct.Dial
+	}
+	nc, err := dial("tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	defer nc.Close()
+	stop := time.AfterFunc(hostKeyTimeout, func() { nc.Close() })
+	defer stop.Stop()
+
+	var key ssh.PublicKey
+	_, _, _, err = ssh.NewClientConn(nc, host, &ssh.ClientConfig{
+		User:              "occamy",
+		HostKeyAlgorithms: algos,
+		HostKeyCallback: func(_ string, _ net.Addr, k ssh.PublicKey) error {
+			key = k
+			return errHostKeyFetched
+		},
+	})
+	if key == nil {
+		if err == nil {
+			err = errors.New("no host key")
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// hostKeyMode returns the mode of the host key verification of the
+// given connection. The host-key-mode argument of the operator is the
+// least strict mode, the JWT can only request a stricter mode.
+func hostKeyMode(jwt *config.JWT, args plugin.Args) (HostKeyMode, error) {
+	floor, err := parseHostKeyMode(args.Get("host-key-mode"))
+	if err != nil {
+		return "", plugin.StatusError(protocol.StatusServerError, err)
+	}
+	mode, err := parseHostKeyMode(jwt.HostKeyMode)
+	if err != nil {
+		return "", plugin.StatusError(protocol.StatusClientBadRequest, err)
+	}
+	if floor == HostKeyStrict || jwt.HostKeyMode == "" {
+		return floor, nil
+	}
+	return mode, nil
+}
+
+// parseHostKeyMode parses the given mode, it is HostKeyTOFU if empty.
+func parseHostKeyMode(mode string) (HostKeyMode, error) {
+	switch HostKeyMode(mode) {
+	case "":
+		return HostKeyTOFU, nil
+	case HostKeyTOFU, HostKeyStrict:
+		return HostKeyMode(mode), nil
+	}
+	return "", fmt.Errorf("occamy: invalid host key mode %q", mode)
+}
@@ -0,0 +1,291 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package server_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"changkun.de/x/occamy/client"
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+	"changkun.de/x/occamy/server"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshServer is an SSH server which only performs handshakes
+type sshServer struct {
+	ln  net.Listener
+	key ssh.PublicKey
+}
+
+func newSSHServer(t *testing.T) *sshServer {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate host key error: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("create signer error: %v", err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				ssh.NewServerConn(nc, config)
+				nc.Close()
+			}()
+		}
+	}()
+	return &sshServer{ln: ln, key: signer.PublicKey()}
+}
+
+func (s *sshServer) addr() string { return s.ln.Addr().String() }
+
+func (s *sshServer) authorizedKey() string {
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(s.key)))
+}
+
+func TestHostKeys_Verify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	keys, err := server.OpenHostKeys(path)
+	if err != nil {
+		t.Fatalf("open host keys error: %v", err)
+	}
+	srv := newSSHServer(t)
+
+	_, err = keys.Verify(srv.addr(), server.HostKeyStrict)
+	if !errors.Is(err, server.ErrHostKeyUnknown) || plugin.StatusOf(err) != protocol.StatusClientForbidden {
+		t.Fatalf("unknown host in strict mode: got %v", err)
+	}
+	if _, ok := keys.Get(srv.addr()); ok {
+		t.Fatalf("unknown host must not be stored in strict mode")
+	}
+
+	line, err := keys.Verify(srv.addr(), server.HostKeyTOFU)
+	if err != nil {
+		t.Fatalf("trust on first use error: %v", err)
+	}
+	if !strings.HasSuffix(line, srv.authorizedKey()) {
+		t.Fatalf("host-key: got %q, want the key of the server", line)
+	}
+
+	// the trusted key is persisted and verified in the strict mode
+	keys, err = server.OpenHostKeys(path)
+	if err != nil {
+		t.Fatalf("reopen host keys error: %v", err)
+	}
+	key, ok := keys.Get(srv.addr())
+	if !ok || key.Fingerprint != ssh.FingerprintSHA256(srv.key) {
+		t.Fatalf("stored host key: got %+v", key)
+	}
+	if _, err := keys.Verify(srv.addr(), server.HostKeyStrict); err != nil {
+		t.Fatalf("known host in strict mode: %v", err)
+	}
+
+	// a changed key is rejected in both modes
+	other := newSSHServer(t)
+	if _, err := keys.Pin(srv.addr(), other.authorizedKey()); err != nil {
+		t.Fatalf("pin error: %v", err)
+	}
+	for _, mode := range []server.HostKeyMode{server.HostKeyTOFU, server.HostKeyStrict} {
+		_, err = keys.Verify(srv.addr(), mode)
+		if !errors.Is(err, server.ErrHostKeyChanged) || plugin.StatusOf(err) != protocol.StatusUpstreamError {
+			t.Fatalf("changed host key in %s mode: got %v", mode, err)
+		}
+	}
+
+	ok, err = keys.Revoke(srv.addr())
+	if !ok || err != nil {
+		t.Fatalf("revoke: got %v, %v", ok, err)
+	}
+	if _, err := keys.Verify(srv.addr(), server.HostKeyTOFU); err != nil {
+		t.Fatalf("trust on first use after revoke error: %v", err)
+	}
+}
+
+func TestAdmin_HostKeys(t *testing.T) {
+	keys, err := server.OpenHostKeys(filepath.Join(t.TempDir(), "known_hosts"))
+	if err != nil {
+		t.Fatalf("open host keys error: %v", err)
+	}
+	s, err := server.New(server.Options{
+		Mode:       "test",
+		JWTSecret:  "secret",
+		HostKeys:   keys,
+		AdminToken: "admin",
+	})
+	if err != nil {
+		t.Fatalf("create server error: %v", err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	srv := newSSHServer(t)
+
+	do := func(method, path, token, body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+"/api/v1/admin"+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("new request error: %v", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s error: %v", method, path, err)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		method, path, token, body string
+		status                    int
+	}{
+		{"GET", "/hostkeys", "", "", http.StatusUnauthorized},
+		{"GET", "/hostkeys", "wrong", "", http.StatusUnauthorized},
+		{"GET", "/hostkeys/" + srv.addr(), "admin", "", http.StatusNotFound},
+		{"PUT", "/hostkeys/" + srv.addr(), "admin", `{"key":"ssh-ed25519 key"}`, http.StatusBadRequest},
+		{"PUT", "/hostkeys/" + srv.addr(), "admin", `{"key":"` + srv.authorizedKey() + `"}`, http.StatusOK},
+		{"GET", "/hostkeys/" + srv.addr(), "admin", "", http.StatusOK},
+		{"DELETE", "/hostkeys/" + srv.addr(), "admin", "", http.StatusNoContent},
+		{"DELETE", "/hostkeys/" + srv.addr(), "admin", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp := do(tt.method, tt.path, tt.token, tt.body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Fatalf("%s %s: got %v, want %v", tt.method, tt.path, resp.StatusCode, tt.status)
+		}
+	}
+
+	do("PUT", "/hostkeys/"+srv.addr(), "admin", `{"key":"`+srv.authorizedKey()+`"}`).Body.Close()
+	resp := do("GET", "/hostkeys", "admin", "")
+	defer resp.Body.Close()
+	var list []server.HostKey
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("decode host keys error: %v", err)
+	}
+	if len(list) != 1 || list[0].Host != srv.addr() || list[0].Fingerprint != ssh.FingerprintSHA256(srv.key) {
+		t.Fatalf("host keys: got %+v", list)
+	}
+}
+
+func TestHostKeys_Connect(t *testing.T) {
+	keys, err := server.OpenHostKeys(filepath.Join(t.TempDir(), "known_hosts"))
+	if err != nil {
+		t.Fatalf("open host keys error: %v", err)
+	}
+	s, err := server.New(server.Options{
+		Mode:      "test",
+		JWTSecret: "secret",
+		Client:    true,
+		ClientDir: t.TempDir(),
+		HostKeys:  keys,
+	})
+	if err != nil {
+		t.Fatalf("create server error: %v", err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	srv := newSSHServer(t)
+
+	// unknown hosts are rejected before the session is created
+	body := `{"protocol":"ssh","host":"` + srv.addr() + `","host_key_mode":"strict"}`
+	resp, err := http.Post(ts.URL+"/api/v1/login", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("login error: %v", err)
+	}
+	var out struct {
+		Token string `json:"token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode token error: %v", err)
+	}
+
+	c, err := client.Dial(context.Background(), ts.URL, out.Token, nil)
+	if err == nil {
+		<-c.Done()
+		err = c.Err()
+	}
+	var serr *client.ServerError
+	if !errors.As(err, &serr) || serr.Status != protocol.StatusClientForbidden {
+		t.Fatalf("connect to unknown host in strict mode: got %v", err)
+	}
+	if !strings.Contains(serr.Message, server.ErrHostKeyUnknown.Error()) {
+		t.Fatalf("error message: got %q", serr.Message)
+	}
+}
+
+func TestHostKeys_ModeFloor(t *testing.T) {
+	keys, err := server.OpenHostKeys(filepath.Join(t.TempDir(), "known_hosts"))
+	if err != nil {
+		t.Fatalf("open host keys error: %v", err)
+	}
+	s, err := server.New(server.Options{
+		Mode:         "test",
+		JWTSecret:    "secret",
+		Client:       true,
+		ClientDir:    t.TempDir(),
+		HostKeys:     keys,
+		ProtocolArgs: map[string]plugin.Args{"ssh-go": {"host-key-mode": "strict"}},
+	})
+	if err != nil {
+		t.Fatalf("create server error: %v", err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	srv := newSSHServer(t)
+
+	// the JWT cannot downgrade the strict mode of the operator
+	body := `{"protocol":"ssh-go","host":"` + srv.addr() + `","username":"occamy","password":"secret","host_key_mode":"tofu"}`
+	resp, err := http.Post(ts.URL+"/api/v1/login", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("login error: %v", err)
+	}
+	var out struct {
+		Token string `json:"token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode token error: %v", err)
+	}
+
+	c, err := client.Dial(context.Background(), ts.URL, out.Token, nil)
+	if err == nil {
+		<-c.Done()
+		err = c.Err()
+	}
+	var serr *client.ServerError
+	if !errors.As(err, &serr) || !strings.Contains(serr.Message, server.ErrHostKeyUnknown.Error()) {
+		t.Fatalf("connect to unknown host with tofu in strict mode: got %v", err)
+	}
+	if _, ok := keys.Get(srv.addr()); ok {
+		t.Fatalf("host key of %s must not be trusted", srv.addr())
+	}
+}
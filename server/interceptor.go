@@ -0,0 +1,175 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"changkun.de/x/occamy/internal/config"
+	"changkun.de/x/occamy/internal/protocol"
+	"github.com/gorilla/websocket"
+)
+
+// Direction is the direction of an instruction relayed by a session
+type Direction int
+
+const (
+	// ToClient instructions are sent from the remote desktop to the
+	// browser client.
+	ToClient Direction = iota
+	// ToServer instructions are sent from the browser client to the
+	// remote desktop.
+	ToServer
+)
+
+func (d Direction) String() string {
+	if d == ToClient {
+		return "client"
+	}
+	return "server"
+}
+
+// Permission is a set of actions a user is allowed to perform
+type Permission uint
+
+// All permissions of a user, they are enforced by the
+// EnforcePermissions interceptor.
+const (
+	PermissionInput     Permission = 1 << iota // mouse, key, size and terminal input
+	PermissionClipboard                        // clipboard
+	PermissionFile                             // file and pipe
+	PermissionAll       = PermissionInput | PermissionClipboard | PermissionFile
+)
+
+// UserContext is the context of a connected user which is given to
+// interceptors.
+type UserContext struct {
+	SessionID   string
+	UserID      string
+	Owner       bool       // the user created the session
+	Permissions Permission // PermissionAll by default
+	JWT         *config.JWT
+
+	relay *relay
+}
+
+// Inject sends an instruction in the given direction. Injected
+// instructions bypass all interceptors. It is safe to call Inject
+// from any goroutine while the connection is alive.
+func (u *UserContext) Inject(dir Direction, ins *protocol.Instruction) error {
+	if dir == ToClient {
+		return u.relay.writeClient([]byte(ins.String()))
+	}
+	return u.relay.writeServer([]byte(ins.String()))
+}
+
+// Next passes an instruction to the next interceptor of a chain
+type Next func(ins *protocol.Instruction) error
+
+// Interceptor intercepts an instruction relayed in the given direction.
+// An interceptor passes the instruction by calling next with it,
+// modifies it by calling next with another instruction, drops it by
+// returning without calling next, or injects instructions by calling
+// next more than once or by UserContext.Inject. A non-nil error
+// terminates the connection.
+type Interceptor func(u *UserContext, dir Direction, ins *protocol.Instruction, next Next) error
+
+// EnforcePermissions is an interceptor that drops the instructions
+// sent by a user without the corresponding permission.
+func EnforcePermissions(u *UserContext, dir Direction, ins *protocol.Instruction, next Next) error {
+	if dir != ToServer {
+		return next(ins)
+	}
+	var required Permission
+	switch ins.Opcode() {
+	case "mouse", "key", "size":
+		required = PermissionInput
+	case "clipboard":
+		required = PermissionClipboard
+	case "file":
+		required = PermissionFile
+	case "pipe":
+		required = PermissionFile
+		if args := ins.Args(); len(args) > 2 && args[2] == "STDIN" {
+			required = PermissionInput
+		}
+	}
+	if u.Permissions&required != required {
+		return nil
+	}
+	return next(ins)
+}
+
+// RecordInput returns an interceptor that records the instructions
+// sent by a user to w, one instruction per line prefixed by its offset
+// in milliseconds since the connection began. The instruction is encoded
+// as a JSON string, hence line breaks in its elements are escaped.
+// Recordings can be replayed by occamy-bench. The interceptor records a
+// single connection, hence it should be created by
+// Options.ConnInterceptors. Write errors are ignored.
+func RecordInput(w io.Writer) Interceptor {
+	var (
+		mu  sync.Mutex
+		buf bytes.Buffer
+	)
+	start := time.Now()
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	return func(u *UserContext, dir Direction, ins *protocol.Instruction, next Next) error {
+		switch {
+		case dir != ToServer:
+		case ins.Expect("sync"), ins.Expect("nop"), ins.Expect("disconnect"):
+		default:
+			mu.Lock()
+			// the encoder terminates the line
+			buf.Reset()
+			fmt.Fprintf(&buf, "%d ", time.Since(start).Milliseconds())
+			enc.Encode(ins.String())
+			w.Write(buf.Bytes())
+			mu.Unlock()
+		}
+		return next(ins)
+	}
+}
+
+// chain builds the interceptor chain for the given direction, the
+// last handler writes the instruction to its destination.
+func chain(u *UserContext, dir Direction, interceptors []Interceptor, last Next) Next {
+	next := last
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		intercept, n := interceptors[i], next
+		next = func(ins *protocol.Instruction) error {
+			return intercept(u, dir, ins, n)
+		}
+	}
+	return next
+}
+
+// relay serializes writes to both sides of a connection, since
+// instructions can be injected concurrently.
+type relay struct {
+	cmu  sync.Mutex
+	ws   *websocket.Conn
+	smu  sync.Mutex
+	conn *protocol.InstructionIO
+}
+
+func (r *relay) writeClient(buf []byte) error {
+	r.cmu.Lock()
+	defer r.cmu.Unlock()
+	return r.ws.WriteMessage(websocket.TextMessage, buf)
+}
+
+func (r *relay) writeServer(buf []byte) error {
+	r.smu.Lock()
+	defer r.smu.Unlock()
+	_, err := r.conn.WriteRaw(buf)
+	return err
+}
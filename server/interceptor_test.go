@@ -0,0 +1,83 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package server_test
+
+import (
+	"strings"
+	"testing"
+
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/server"
+)
+
+func TestEnforcePermissions(t *testing.T) {
+	tests := []struct {
+		perm server.Permission
+		dir  server.Direction
+		ins  []string
+		pass bool
+	}{
+		{server.PermissionAll, server.ToServer, []string{"mouse", "1", "2", "0"}, true},
+		{server.PermissionClipboard, server.ToServer, []string{"mouse", "1", "2", "0"}, false},
+		{server.PermissionClipboard, server.ToServer, []string{"key", "65", "1"}, false},
+		{server.PermissionInput, server.ToServer, []string{"key", "65", "1"}, true},
+		{server.PermissionInput, server.ToServer, []string{"clipboard", "1", "text/plain"}, false},
+		{server.PermissionInput, server.ToServer, []string{"file", "1", "text/plain", "a"}, false},
+		{server.PermissionInput, server.ToServer, []string{"pipe", "1", "application/octet-stream", "STDIN"}, true},
+		{server.PermissionFile, server.ToServer, []string{"pipe", "1", "application/octet-stream", "STDIN"}, false},
+		{server.PermissionFile, server.ToServer, []string{"pipe", "1", "text/plain", "a"}, true},
+		{0, server.ToServer, []string{"sync", "1000"}, true},
+		{0, server.ToClient, []string{"clipboard", "1", "text/plain"}, true},
+	}
+	for _, tt := range tests {
+		u := &server.UserContext{Permissions: tt.perm}
+		passed := false
+		err := server.EnforcePermissions(u, tt.dir, protocol.NewInstruction(tt.ins), func(*protocol.Instruction) error {
+			passed = true
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("enforce permissions error: %v", err)
+		}
+		if passed != tt.pass {
+			t.Errorf("enforce permissions %v to %v with %b, want pass: %v", tt.ins, tt.dir, tt.perm, tt.pass)
+		}
+	}
+}
+
+func TestRecordInput(t *testing.T) {
+	var buf strings.Builder
+	record := server.RecordInput(&buf)
+	u := &server.UserContext{}
+	for _, tt := range []struct {
+		dir server.Direction
+		ins []string
+	}{
+		{server.ToServer, []string{"key", "65", "1"}},
+		{server.ToServer, []string{"sync", "1000"}},
+		{server.ToClient, []string{"sync", "1000"}},
+		{server.ToServer, []string{"mouse", "1", "2", "0"}},
+		{server.ToServer, []string{"clipboard", "1", "a\nb</p>"}},
+	} {
+		passed := false
+		record(u, tt.dir, protocol.NewInstruction(tt.ins), func(*protocol.Instruction) error {
+			passed = true
+			return nil
+		})
+		if !passed {
+			t.Fatalf("record input should pass %v", tt.ins)
+		}
+	}
+	want := []string{`"3.key,2.65,1.1;"`, `"5.mouse,1.1,1.2,1.0;"`, `"9.clipboard,1.1,7.a\nb</p>;"`}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("recorded lines, want: %d, got: %q", len(want), buf.String())
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "0 ") || line[2:] != want[i] {
+			t.Errorf("recorded line, want: 0 %s, got: %s", want[i], line)
+		}
+	}
+}
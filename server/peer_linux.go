@@ -0,0 +1,100 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// +build linux
+
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"unsafe"
+)
+
+// ownConn reports whether the peer of the given loopback connection is
+// a socket of this process, e.g. of the plugin of a session. The socket
+// of the peer is looked up in /proc/net/tcp by its address, and among
+// the open files of the process by its inode.
+func ownConn(nc net.Conn) bool {
+	peer, ok := nc.RemoteAddr().(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	local, ok := nc.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	inode, err := socketInode(peer, local)
+	if err != nil {
+		return false
+	}
+
+	d, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return false
+	}
+	fds, err := d.Readdirnames(-1)
+	d.Close()
+	if err != nil {
+		return false
+	}
+	want := "socket:[" + inode + "]"
+	for _, fd := range fds {
+		if link, err := os.Readlink("/proc/self/fd/" + fd); err == nil && link == want {
+			return true
+		}
+	}
+	return false
+}
+
+// socketInode returns the inode of the IPv4 TCP socket from local to
+// remote.
+func socketInode(local, remote *net.TCPAddr) (string, error) {
+	l, r := procAddr(local), procAddr(remote)
+	if l == "" || r == "" {
+		return "", fmt.Errorf("occamy: %s is not an IPv4 address", local)
+	}
+	f, err := os.Open("/proc/net/tcp")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when
+		// retrnsmt uid timeout inode ...
+		fields := strings.Fields(s.Text())
+		if len(fields) > 9 && fields[1] == l && fields[2] == r {
+			return fields[9], nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("occamy: socket %s of %s not found", local, remote)
+}
+
+// procAddr formats an IPv4 address as /proc/net/tcp, which prints the
+// address in the byte order of the host, e.g. 0100007F:1F90 for
+// 127.0.0.1:8080 on little-endian hosts.
+func procAddr(a *net.TCPAddr) string {
+	ip := a.IP.To4()
+	if ip == nil {
+		return ""
+	}
+	return fmt.Sprintf("%08X:%04X", nativeEndian.Uint32(ip), a.Port)
+}
+
+// nativeEndian is the byte order of the host.
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
@@ -5,23 +5,47 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 
 	"changkun.de/x/occamy/internal/config"
+	"changkun.de/x/occamy/internal/lib"
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
 	jwt "github.com/appleboy/gin-jwt/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/ssh/knownhosts"
 )
 
 // serveWS implements /api/v1/connect
-func (p *proxy) serveWS(c *gin.Context) {
-	ws, err := p.upgrader.Upgrade(c.Writer, c.Request, nil)
+func (s *Server) serveWS(c *gin.Context) {
+	info := &config.UserInfo{}
+	err := c.ShouldBindQuery(info)
+	if err != nil {
+		log.Printf("bind user information failed: %v", err)
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("upgrade websocket failed: %v", err)
 		c.Writer.Write([]byte(http.StatusText(http.StatusBadRequest)))
 		return
 	}
+	if !s.track(ws) {
+		closeWithError(ws, ErrServerClosed)
+		ws.Close()
+		return
+	}
+	defer s.untrack(ws)
 
 	claims := jwt.ExtractClaims(c)
 	jwt := &config.JWT{
@@ -30,34 +54,230 @@ func (p *proxy) serveWS(c *gin.Context) {
 		Username: claims["username"].(string),
 		Password: claims["password"].(string),
 	}
-	err = p.routeConn(ws, jwt)
+	if hops, ok := claims["jump_hosts"].([]interface{}); ok && len(hops) > 0 {
+		// the claims are decoded as generic JSON values
+		b, err := json.Marshal(hops)
+		if err == nil {
+			err = json.Unmarshal(b, &jwt.JumpHosts)
+		}
+		if err != nil {
+			closeWithError(ws, plugin.StatusError(protocol.StatusClientBadRequest,
+				fmt.Errorf("occamy: invalid jump hosts: %w", err)))
+			ws.Close()
+			return
+		}
+	}
+	if mode, ok := claims["host_key_mode"].(string); ok {
+		jwt.HostKeyMode = mode
+	}
+	err = s.routeConn(ws, jwt, info)
 	if err != nil {
 		log.Printf("route connection failed: %v", err)
-		ws.WriteMessage(websocket.CloseMessage, []byte(err.Error()))
+		closeWithError(ws, err)
 	}
 	ws.Close()
 }
 
-func (p *proxy) routeConn(ws *websocket.Conn, jwt *config.JWT) (err error) {
-	p.mu.Lock()
-	s, ok := p.sessions[jwt.GenerateID()]
+// closeWithError reports the error that terminated a connection to the
+// browser by an error instruction, and closes the websocket with the
+// status code as close reason, as guacamole-common-js expects.
+func closeWithError(ws *websocket.Conn, err error) {
+	status, ok := statusOf(err)
+	if ok {
+		ins := (&protocol.Error{Message: err.Error(), Status: status}).Encode()
+		ws.WriteMessage(websocket.TextMessage, []byte(ins.String()))
+	}
+	reason := strconv.Itoa(int(status))
+	ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason))
+}
+
+// statusOf returns the guacamole status of an error that terminated a
+// connection, it reports false if the connection terminated normally.
+func statusOf(err error) (protocol.Status, bool) {
+	var (
+		perr *protocol.ParseError
+		serr interface{ Status() protocol.Status }
+	)
+	switch {
+	case err == nil, errors.Is(err, io.EOF),
+		websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway):
+		return protocol.StatusSuccess, false
+	case errors.Is(err, ErrServerClosed):
+		return protocol.StatusServerBusy, true
+	case errors.As(err, &perr):
+		return protocol.StatusClientBadRequest, true
+	case errors.As(err, &serr):
+		return serr.Status(), true // libguac and plugin errors
+	}
+	return lib.ProtocolStatus(err), true
+}
+
+func (s *Server) routeConn(ws *websocket.Conn, jwt *config.JWT, info *config.UserInfo) (err error) {
+	// the libguac plugins draw terminals as images only, the users
+	// which render the terminal themselves would receive no output.
+	if _, ok := plugin.Lookup(jwt.Protocol); !ok && info.Terminal == plugin.TerminalVT {
+		return plugin.StatusError(protocol.StatusClientBadRequest,
+			fmt.Errorf("occamy: protocol %s does not support the %s terminal mode", jwt.Protocol, info.Terminal))
+	}
+
+	s.mu.Lock()
+	sess, ok := s.sessions[jwt.GenerateID()]
+	if ok {
+		err = sess.Join(ws, jwt, info, false, func() { s.mu.Unlock() })
+		return
+	}
+	s.mu.Unlock()
+
+	// the arguments of a new session may require to contact the remote
+	// host, which must not block other connections.
+	args, tun, err := s.sessionArgs(jwt)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	sess, ok = s.sessions[jwt.GenerateID()]
 	if ok {
-		err = s.Join(ws, jwt, false, func() { p.mu.Unlock() })
+		if tun != nil {
+			tun.Close()
+		}
+		err = sess.Join(ws, jwt, info, false, func() { s.mu.Unlock() })
 		return
 	}
 
-	s, err = NewSession(jwt.Protocol)
+	sess, err = NewSession(jwt.Protocol, s.opts.Mode)
 	if err != nil {
-		p.mu.Unlock()
+		s.mu.Unlock()
+		if tun != nil {
+			tun.Close()
+		}
 		return
 	}
 
-	p.sessions[jwt.GenerateID()] = s
-	log.Printf("new session was created: %s", s.ID)
-	err = s.Join(ws, jwt, true, func() { p.mu.Unlock() }) // block here
+	sess.intercept = s.interceptors
+	sess.args = args
+	if tun != nil {
+		// the tunnel serves all users of the session, it is closed
+		// with the session rather than when the owner leaves
+		sess.endpoint, sess.tunnel = tun.Addr(), tun
+	}
+	if s.opts.SessionHook != nil {
+		sess.observe(s.opts.SessionHook)
+	}
+	s.sessions[jwt.GenerateID()] = sess
+	log.Printf("new session was created: %s", sess.ID)
+	err = sess.Join(ws, jwt, info, true, func() { s.mu.Unlock() }) // block here
 
-	p.mu.Lock()
-	delete(p.sessions, jwt.GenerateID())
-	p.mu.Unlock()
+	s.mu.Lock()
+	delete(s.sessions, jwt.GenerateID())
+	s.mu.Unlock()
 	return
 }
+
+// sessionArgs returns the connection arguments of a new session of the
+// given connection, and the tunnel through Options.Dialer or the jump
+// hosts of the JWT if any, which is closed with the session. The remote
+// host is rejected unless Options.Egress permits it. The host-key
+// of SSH servers is set by verifying the server with Options.HostKeys,
+// and the private-key and public-key by a certificate of
+// Options.CertificateAuthority.
+func (s *Server) sessionArgs(jwt *config.JWT) (sargs plugin.Args, tun *tunnel, err error) {
+	args := s.opts.ProtocolArgs[jwt.Protocol]
+	sargs = make(plugin.Args, len(args)+3)
+	for name, v := range args {
+		sargs[name] = v
+	}
+
+	var dial dialFunc
+	tunneled := !localProtocols[jwt.Protocol]
+	if len(jwt.JumpHosts) > 0 || (s.opts.Dialer != nil || s.opts.Egress != nil) && tunneled {
+		target, err := targetAddr(jwt)
+		if err != nil {
+			return nil, nil, plugin.StatusError(protocol.StatusClientBadRequest, err)
+		}
+		if s.opts.Egress != nil {
+			// the policy is checked again when the tunnel dials, it
+			// rejects forbidden hosts here before any is connected
+			if err := s.checkEgress(jwt, target); err != nil {
+				return nil, nil, err
+			}
+		}
+		tun, err = s.newTunnel(jwt, target)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer func() {
+			if err != nil {
+				tun.Close()
+				tun = nil
+			}
+		}()
+		dial = tun.dial
+	}
+
+	if s.opts.HostKeys != nil && hostKeyProtocols[jwt.Protocol] {
+		mode, err := hostKeyMode(jwt, args)
+		if err != nil {
+			return nil, nil, err
+		}
+		host := jwt.Host
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, "22")
+		}
+		key, err := s.opts.HostKeys.verify(host, mode, dial)
+		if err != nil {
+			return nil, nil, err
+		}
+		// the session connects to the endpoint of the tunnel
+		if tun != nil {
+			host = tun.Addr()
+		}
+		sargs["host-key"] = knownhosts.Line([]string{host}, key)
+	}
+
+	if s.opts.CertificateAuthority != nil && certKeys[jwt.Protocol] != nil &&
+		jwt.Password == "" && jwt.Username != "" {
+		key, cert, err := s.opts.CertificateAuthority.IssueUser(jwt.Protocol, jwt.Username)
+		if err != nil {
+			return nil, nil, certError(err)
+		}
+		sargs["private-key"] = key
+		sargs["passphrase"] = ""
+		sargs["public-key"] = cert
+	}
+	return sargs, tun, nil
+}
+
+// checkEgress checks the connections of a session to the given target
+// by Options.Egress: the ssh connections to every jump host and the
+// connection of the protocol to the target.
+func (s *Server) checkEgress(jwt *config.JWT, target string) error {
+	for _, hop := range jwt.JumpHosts {
+		if _, err := s.opts.Egress.Check("ssh", jumpAddr(hop)); err != nil {
+			return err
+		}
+	}
+	_, err := s.opts.Egress.Check(jwt.Protocol, target)
+	return err
+}
+
+// track registers an active websocket connection, it reports false if
+// the server is already shut down.
+func (s *Server) track(ws *websocket.Conn) bool {
+	s.cmu.Lock()
+	defer s.cmu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[ws] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+// untrack removes a terminated websocket connection.
+func (s *Server) untrack(ws *websocket.Conn) {
+	s.cmu.Lock()
+	delete(s.conns, ws)
+	s.cmu.Unlock()
+	s.wg.Done()
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/client"
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/server"
+)
+
+func TestNew(t *testing.T) {
+	// two servers can live in the same process
+	var servers []*server.Server
+	for _, secret := range []string{"secret-a", "secret-b"} {
+		s, err := server.New(server.Options{
+			Mode:      "test",
+			JWTSecret: secret,
+			Client:    true,
+			ClientDir: t.TempDir(),
+		})
+		if err != nil {
+			t.Fatalf("create server error: %v", err)
+		}
+		servers = append(servers, s)
+	}
+
+	tokens := make([]string, len(servers))
+	for i, s := range servers {
+		ts := httptest.NewServer(s.Handler())
+		defer ts.Close()
+
+		body := `{"protocol":"vnc","host":"0.0.0.0:5901","password":"occamy"}`
+		resp, err := http.Post(ts.URL+"/api/v1/login", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("login error: %v", err)
+		}
+		var out struct {
+			Token string `json:"token"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&out)
+		resp.Body.Close()
+		if err != nil || out.Token == "" {
+			t.Fatalf("login response without token, err: %v", err)
+		}
+		tokens[i] = out.Token
+
+		resp, err = http.Get(ts.URL + "/api/v1/connect")
+		if err != nil {
+			t.Fatalf("connect error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("connect without token should be rejected, got: %v", resp.StatusCode)
+		}
+	}
+
+	// tokens are signed by different secrets
+	ts := httptest.NewServer(servers[0].Handler())
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/api/v1/connect?token=" + tokens[1])
+	if err != nil {
+		t.Fatalf("connect error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("token of another server should be rejected, got: %v", resp.StatusCode)
+	}
+
+	for _, s := range servers {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := s.Shutdown(ctx)
+		cancel()
+		if err != nil {
+			t.Fatalf("shutdown error: %v", err)
+		}
+		if err := s.ListenAndServe(); err != server.ErrServerClosed {
+			t.Fatalf("serve after shutdown should fail, got: %v", err)
+		}
+	}
+
+	// connections after shutdown are rejected by an error instruction
+	c, err := client.Dial(context.Background(), ts.URL, tokens[0], nil)
+	if err == nil {
+		<-c.Done()
+		err = c.Err()
+	}
+	var serr *client.ServerError
+	if !errors.As(err, &serr) || serr.Status != protocol.StatusServerBusy {
+		t.Fatalf("connect after shutdown should fail with server busy, got: %v", err)
+	}
+}
+
+func TestServer_TerminalMode(t *testing.T) {
+	s, err := server.New(server.Options{
+		Mode:      "test",
+		JWTSecret: "secret",
+		Client:    true,
+		ClientDir: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("create server error: %v", err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	body := `{"protocol":"ssh","host":"127.0.0.1:22","username":"occamy","password":"secret"}`
+	resp, err := http.Post(ts.URL+"/api/v1/login", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("login error: %v", err)
+	}
+	var out struct {
+		Token string `json:"token"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode token error: %v", err)
+	}
+
+	// the libguac ssh plugin cannot serve the vt mode
+	c, err := client.Dial(context.Background(), ts.URL, out.Token, &client.Options{Terminal: "vt"})
+	if err == nil {
+		<-c.Done()
+		err = c.Err()
+	}
+	var serr *client.ServerError
+	if !errors.As(err, &serr) || serr.Status != protocol.StatusClientBadRequest ||
+		!strings.Contains(serr.Message, "vt terminal mode") {
+		t.Fatalf("connect to ssh in vt mode: got %v", err)
+	}
+}
+
+func TestServer_LogRequests(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	s, err := server.New(server.Options{Mode: "test", JWTSecret: "secret"})
+	if err != nil {
+		t.Fatalf("create server error: %v", err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/api/v1/connect?token=secret-token")
+	if err != nil {
+		t.Fatalf("connect error: %v", err)
+	}
+	resp.Body.Close()
+	ts.Close() // waits until the request is logged
+
+	out := buf.String()
+	if !strings.Contains(out, "GET /api/v1/connect 401") {
+		t.Fatalf("request is not logged: %q", out)
+	}
+	if strings.Contains(out, "secret-token") {
+		t.Fatalf("token is logged: %q", out)
+	}
+}
@@ -6,8 +6,10 @@ package server
 
 import (
 	"fmt"
+	"io"
 	"log"
-	"runtime"
+	"net"
+	"os"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -15,6 +17,8 @@ import (
 	"changkun.de/x/occamy/internal/config"
 	"changkun.de/x/occamy/internal/lib"
 	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/internal/uuid"
+	"changkun.de/x/occamy/plugin"
 	"github.com/gorilla/websocket"
 )
 
@@ -24,21 +28,51 @@ type Session struct {
 	ID             string
 	connectedUsers uint64
 	once           sync.Once
-	client         *lib.Client // shared client in a session
+	client         *lib.Client     // shared client in a session
+	exec           *lib.Executor   // runs all libguac calls of the session
+	plugin         *plugin.Session // replaces client if the protocol is a Go plugin
+	args           plugin.Args     // connection arguments set by the operator
+	endpoint       string          // host:port connected in place of the JWT host, if any
+	tunnel         io.Closer       // serves the endpoint until the session is closed
+
+	// intercept returns the interceptors of a joined user
+	intercept func(u *UserContext) []Interceptor
+}
+
+// SessionEvent is a lifecycle event of a session
+type SessionEvent struct {
+	Type      string // join, leave, stop or free
+	SessionID string
+	UserID    string // the joining or leaving user
+	Owner     bool   // the user is the owner of the session
+	Users     int    // number of connected users after the event
 }
 
-// NewSession creates a new occamy proxy session
-func NewSession(proto string) (*Session, error) {
-	runtime.LockOSThread() // without unlock to exit the Go thread
+// NewSession creates a new occamy proxy session, the libguac log level
+// is derived from the given running mode.
+func NewSession(proto, mode string) (*Session, error) {
+	if p, ok := plugin.Lookup(proto); ok {
+		ps, err := plugin.NewSession(p)
+		if err != nil {
+			return nil, fmt.Errorf("occamy-plugin: new session error: %w", err)
+		}
+		return &Session{ID: ps.ID, plugin: ps}, nil
+	}
 
-	cli, err := lib.NewClient()
+	s := &Session{exec: lib.NewExecutor()}
+	err := s.exec.Do(func() (err error) {
+		s.client, err = lib.NewClient()
+		return
+	})
 	if err != nil {
+		s.exec.Close()
 		return nil, fmt.Errorf("occamy-lib: new client error: %w", err)
 	}
 
-	s := &Session{client: cli}
-	s.client.InitLogLevel(config.Runtime.Mode)
-	err = s.client.LoadProtocolPlugin(proto)
+	err = s.exec.Do(func() error {
+		s.client.InitLogLevel(mode)
+		return s.client.LoadProtocolPlugin(proto)
+	})
 	if err != nil {
 		s.close()
 		return nil, fmt.Errorf("occamy-lib: load protocol plugin failed: %w", err)
@@ -51,9 +85,8 @@ func NewSession(proto string) (*Session, error) {
 // reading/writing from the socket via read/write threads. The given socket,
 // parser, and any associated resources will be freed unless the user is not
 // added successfully.
-func (s *Session) Join(ws *websocket.Conn, jwt *config.JWT, owner bool, unlock func()) error {
+func (s *Session) Join(ws *websocket.Conn, jwt *config.JWT, info *config.UserInfo, owner bool, unlock func()) error {
 	defer s.close()
-	lib.ResetErrors()
 
 	// 1. prepare socket pair
 	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
@@ -61,93 +94,293 @@ func (s *Session) Join(ws *websocket.Conn, jwt *config.JWT, owner bool, unlock f
 		unlock()
 		return fmt.Errorf("new socket pair error: %w", err)
 	}
+	if s.plugin != nil {
+		return s.joinPlugin(ws, fds, jwt, info, owner, unlock)
+	}
 
 	// 2. create guac socket using fds[0]
-	sock, err := lib.NewSocket(fds[0])
+	var sock *lib.Socket
+	err = s.exec.Do(func() (err error) {
+		lib.ResetErrors()
+		sock, err = lib.NewSocket(fds[0])
+		return
+	})
 	if err != nil {
+		unlock()
 		return fmt.Errorf("occamy-lib: create guac socket error: %w", err)
 	}
-	defer sock.Close()
+	defer s.exec.Do(func() error { sock.Close(); return nil })
 
 	// 3. create guac user using created guac socket
-	u, err := lib.NewUser(sock, s.client, owner, jwt)
+	var u *lib.User
+	err = s.exec.Do(func() (err error) {
+		u, err = lib.NewUser(sock, s.client, owner, s.remote(jwt), info)
+		return
+	})
 	if err != nil {
+		unlock()
 		return fmt.Errorf("occamy-lib: create guac user error: %w", err)
 	}
-	defer u.Close()
+	defer s.exec.Do(func() error { u.Close(); return nil })
 
 	// 4. count new user
 	atomic.AddUint64(&s.connectedUsers, 1)
 	defer atomic.AddUint64(&s.connectedUsers, ^uint64(0))
 
 	// 5. preparing connection
-	err = u.Prepare()
+	err = s.exec.Do(func() error { return u.Prepare(s.args) })
 	if err != nil {
 		unlock()
 		return fmt.Errorf("occamy-lib: handle user connection error: %w", err)
 	}
 	unlock()
 
-	// 6. handle connection
+	// 6. handle connection, the input is read outside of the executor
+	// until the user disconnects, and handled on the executor.
 	done := make(chan struct{}, 1)
-	go u.HandleConnection(done) // block until disconnect/completion
+	go u.HandleConnection(s.exec, done) // block until disconnect/completion
 
 	// 7. proxy io
-	conn := protocol.NewInstructionIO(fds[1])
-	defer conn.Close()
-
-	err = s.serveIO(conn, ws)
+	err = s.proxy(ws, fds[1], u.ID, owner, jwt)
 	<-done
 	return err
 }
 
-// Close closes a session.
+// joinPlugin joins a user to the session of a Go protocol plugin, which
+// serves the user through fds[0] of the given socket pair.
+func (s *Session) joinPlugin(ws *websocket.Conn, fds [2]int, jwt *config.JWT, info *config.UserInfo, owner bool, unlock func()) error {
+	f := os.NewFile(uintptr(fds[0]), "occamy-plugin")
+	rw, err := net.FileConn(f)
+	f.Close()
+	if err != nil {
+		unlock()
+		syscall.Close(fds[1])
+		return fmt.Errorf("occamy-plugin: open socket error: %w", err)
+	}
+
+	u := &plugin.User{
+		ID:         uuid.NewID("@"),
+		Owner:      owner,
+		Width:      info.Width,
+		Height:     info.Height,
+		DPI:        info.DPI,
+		Timezone:   info.Timezone,
+		ImageTypes: info.Image,
+		AudioTypes: info.Audio,
+		VideoTypes: info.Video,
+		Terminal:   info.Terminal,
+	}
+	atomic.AddUint64(&s.connectedUsers, 1)
+	defer atomic.AddUint64(&s.connectedUsers, ^uint64(0))
+
+	err = s.plugin.Join(u, pluginArgs(s.remote(jwt), s.args), rw)
+	if err != nil {
+		unlock()
+		rw.Close()
+		syscall.Close(fds[1])
+		return fmt.Errorf("occamy-plugin: join error: %w", err)
+	}
+	unlock()
+
+	done := make(chan error, 1)
+	go func() { done <- s.plugin.Serve(u) }()
+	err = s.proxy(ws, fds[1], u.ID, owner, jwt)
+	if perr := <-done; perr != nil {
+		err = perr
+	}
+	return err
+}
+
+// remote returns the JWT of the remote host that the session connects
+// to, which is the endpoint of a tunnel in place of the host if any.
+func (s *Session) remote(jwt *config.JWT) *config.JWT {
+	if s.endpoint == "" {
+		return jwt
+	}
+	r := *jwt
+	r.Host = s.endpoint
+	return &r
+}
+
+// pluginArgs maps the connection arguments of a JWT to the arguments of
+// Go protocol plugins, named like the arguments of libguac plugins. The
+// given arguments of the operator are used unless the JWT sets them.
+func pluginArgs(jwt *config.JWT, base plugin.Args) plugin.Args {
+	host, port, err := net.SplitHostPort(jwt.Host)
+	if err != nil {
+		host, port = jwt.Host, ""
+	}
+	args := make(plugin.Args, len(base)+4)
+	for name, v := range base {
+		args[name] = v
+	}
+	for name, v := range map[string]string{
+		"hostname": host,
+		"port":     port,
+		"username": jwt.Username,
+		"password": jwt.Password,
+	} {
+		if v != "" {
+			args[name] = v
+		}
+	}
+	return args
+}
+
+// proxy relays instructions between the websocket and the given end of
+// the socket pair of a user, until either side is closed.
+func (s *Session) proxy(ws *websocket.Conn, fd int, uid string, owner bool, jwt *config.JWT) error {
+	conn := protocol.NewInstructionIO(fd)
+	defer conn.Close()
+
+	uc := &UserContext{
+		SessionID:   s.ID,
+		UserID:      uid,
+		Owner:       owner,
+		Permissions: PermissionAll,
+		JWT:         jwt,
+		relay:       &relay{ws: ws, conn: conn},
+	}
+	var interceptors []Interceptor
+	if s.intercept != nil {
+		interceptors = s.intercept(uc)
+	}
+	return s.serveIO(uc, interceptors)
+}
+
+// observe calls the given hook on lifecycle events of the session.
+func (s *Session) observe(hook func(e SessionEvent)) {
+	if s.plugin != nil {
+		s.plugin.AddHook(func(ps *plugin.Session, e plugin.Event, u *plugin.User) {
+			ev := SessionEvent{Type: e.String(), SessionID: s.ID, Users: len(ps.Users())}
+			if u != nil {
+				ev.UserID, ev.Owner = u.ID, u.Owner
+			}
+			hook(ev)
+		})
+		return
+	}
+	s.client.AddHook(func(c *lib.Client, e lib.Event, u *lib.User) {
+		ev := SessionEvent{Type: e.String(), SessionID: s.ID, Users: len(c.Users())}
+		if u != nil {
+			ev.UserID, ev.Owner = u.ID, u.Owner()
+		}
+		hook(ev)
+	})
+}
+
+// Close closes a session once all users left, stops its executor and
+// closes its tunnel.
 func (s *Session) close() {
 	if atomic.LoadUint64(&s.connectedUsers) > 0 {
 		return
 	}
-	s.client.Close()
+	s.once.Do(func() {
+		if s.plugin != nil {
+			s.plugin.Close()
+		} else {
+			s.exec.Do(func() error { s.client.Close(); return nil })
+			s.exec.Close()
+		}
+		if s.tunnel != nil {
+			s.tunnel.Close()
+		}
+	})
 }
 
-func (s *Session) serveIO(conn *protocol.InstructionIO, ws *websocket.Conn) (err error) {
+// serveIO relays instructions between the remote desktop and the browser
+// client through the given interceptors. Without interceptors, raw
+// instructions are relayed without parsing.
+func (s *Session) serveIO(u *UserContext, interceptors []Interceptor) (err error) {
+	r := u.relay
+	toClient := chain(u, ToClient, interceptors, func(ins *protocol.Instruction) error {
+		return r.writeClient([]byte(ins.String()))
+	})
+	toServer := chain(u, ToServer, interceptors, func(ins *protocol.Instruction) error {
+		return r.writeServer([]byte(ins.String()))
+	})
+
 	wg := sync.WaitGroup{}
 	exit := make(chan error, 2)
 	wg.Add(2)
-	go func(conn *protocol.InstructionIO, ws *websocket.Conn) {
+	go func() {
+		defer wg.Done()
 		var err error
 		for {
-			raw, err := conn.ReadRaw()
-			if err != nil {
-				break
+			if len(interceptors) == 0 {
+				var raw []byte
+				raw, err = r.conn.ReadRaw()
+				if err == nil {
+					err = r.writeClient(raw)
+				}
+			} else {
+				var ins *protocol.Instruction
+				ins, err = r.conn.Read()
+				if err == nil {
+					err = toClient(ins)
+				}
 			}
-			err = ws.WriteMessage(websocket.TextMessage, raw)
 			if err != nil {
 				break
 			}
 		}
 		exit <- err
 		log.Println("reading from desktop terminated.")
-		wg.Done()
-	}(conn, ws)
-	go func(conn *protocol.InstructionIO, ws *websocket.Conn) {
-		var err error
+	}()
+	go func() {
+		defer wg.Done()
+		var (
+			err error
+			buf []byte
+			p   = protocol.NewParser()
+		)
 		for {
-			_, buf, err := ws.ReadMessage()
+			_, buf, err = r.ws.ReadMessage()
 			if err != nil {
 				break
 			}
-			_, err = conn.WriteRaw(buf)
+			if len(interceptors) == 0 {
+				err = r.writeServer(buf)
+			} else {
+				err = parseEach(p, buf, toServer)
+			}
 			if err != nil {
 				break
 			}
 		}
 		exit <- err
 		log.Println("reading from client terminated.")
-		wg.Done()
-	}(conn, ws)
+	}()
 	err = <-exit
-	conn.Close()
+	r.conn.Close()
 	wg.Wait()
 	log.Println("IO goroutines are terminated.")
 	return
 }
+
+// parseEach parses all instructions of a websocket message and passes
+// them to the given handler one by one.
+func parseEach(p *protocol.Parser, buf []byte, handle Next) error {
+	for len(buf) > 0 {
+		n, err := p.Append(buf)
+		if err != nil {
+			p.Reset()
+			return err
+		}
+		buf = buf[n:]
+		if p.State() != protocol.ParserStateComplete {
+			p.Reset()
+			return protocol.ErrInstructionMissSemi
+		}
+		elements := p.Elements()
+		strs := make([]string, len(elements))
+		for i := range elements {
+			strs[i] = string(elements[i])
+		}
+		err = handle(protocol.NewInstruction(strs))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package server_test
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/client"
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/plugin"
+	"changkun.de/x/occamy/server"
+)
+
+// testPlugin draws a white display, fills it red on key presses and
+// stops the session on q.
+type testPlugin struct {
+	files chan string
+}
+
+func (p *testPlugin) Args() []string { return []string{"username"} }
+
+func (p *testPlugin) New(s *plugin.Session) (plugin.Connection, error) {
+	return &testConn{s: s, files: p.files}, nil
+}
+
+type testConn struct {
+	plugin.Base
+	s     *plugin.Session
+	files chan string
+}
+
+func (c *testConn) Join(u *plugin.User, args plugin.Args) error {
+	if args.Get("username") != "occamy" || args.Get("password") != "" {
+		return plugin.StatusError(protocol.StatusClientUnauthorized, errors.New("unknown user"))
+	}
+	if u.Owner {
+		d := c.s.Display()
+		d.Resize(u.Width, u.Height)
+		d.Fill(image.Rect(0, 0, u.Width, u.Height), color.White)
+		d.Flush()
+	}
+	return nil
+}
+
+func (c *testConn) Key(u *plugin.User, keysym int, pressed bool) error {
+	if !pressed {
+		return nil
+	}
+	if keysym == 'q' {
+		c.s.Stop(nil)
+		return nil
+	}
+	d := c.s.Display()
+	w, h := d.Size()
+	d.Fill(image.Rect(0, 0, w, h), color.RGBA{255, 0, 0, 255})
+	d.Flush()
+	return nil
+}
+
+func (c *testConn) File(u *plugin.User, f *plugin.FileStream) error {
+	data, err := ioutil.ReadAll(f)
+	c.files <- f.Filename + ":" + string(data)
+	return err
+}
+
+// nextFrame waits for the next sync instruction.
+func nextFrame(t *testing.T, c *client.Client) {
+	for m := range c.Messages() {
+		if m.Opcode() == "sync" {
+			return
+		}
+	}
+	t.Fatalf("connection terminated before a frame: %v", c.Err())
+}
+
+func TestSession_Plugin(t *testing.T) {
+	p := &testPlugin{files: make(chan string, 1)}
+	plugin.Register("occamy-test", p)
+
+	s, err := server.New(server.Options{Mode: "test", JWTSecret: "secret", Client: true, ClientDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("create server error: %v", err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cred := client.Credentials{Protocol: "occamy-test", Host: "localhost:0", Username: "guest", Password: "occamy"}
+	c, err := client.Connect(ctx, ts.URL, cred, nil)
+	if err == nil {
+		<-c.Done()
+		err = c.Err()
+	}
+	var serr *client.ServerError
+	if !errors.As(err, &serr) || serr.Status != protocol.StatusClientUnauthorized {
+		t.Fatalf("rejected user should receive the status of the plugin, got: %v", err)
+	}
+
+	cred.Username = "occamy"
+	c, err = client.Connect(ctx, ts.URL, cred, &client.Options{Width: 4, Height: 4, Framebuffer: true})
+	if err != nil {
+		t.Fatalf("connect error: %v", err)
+	}
+	defer c.Close()
+	fb := c.Framebuffer()
+
+	nextFrame(t, c)
+	if w, h := fb.Size(); w != 4 || h != 4 || fb.Image().RGBAAt(3, 3) != (color.RGBA{255, 255, 255, 255}) {
+		t.Fatalf("joined user should receive the display, got %dx%d: %v", w, h, fb.Image().Pix)
+	}
+	c.SendKey('a', true)
+	nextFrame(t, c)
+	if got := fb.Image().RGBAAt(0, 0); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Fatalf("key should fill the display, got: %v", got)
+	}
+
+	err = c.UploadFile(ctx, "text/plain", "hello.txt", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("upload file error: %v", err)
+	}
+	if got := <-p.files; got != "hello.txt:hello" {
+		t.Fatalf("plugin should receive the file, got: %s", got)
+	}
+
+	c.SendKey('q', true)
+	select {
+	case <-c.Done():
+	case <-ctx.Done():
+		t.Fatalf("stopped session should disconnect the user")
+	}
+	if c.Err() != nil {
+		t.Fatalf("stopped session should disconnect without error, got: %v", c.Err())
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"changkun.de/x/occamy/internal/config"
+	"changkun.de/x/occamy/internal/protocol"
+	"changkun.de/x/occamy/internal/sshutil"
+	"changkun.de/x/occamy/plugin"
+	"golang.org/x/crypto/ssh"
+)
+
+// dialFunc connects to the given address of the network.
+type dialFunc func(network, addr string) (net.Conn, error)
+
+// dialTimeout limits connecting to remote hosts and SSH handshakes.
+const dialTimeout = 10 * time.Second
+
+// defaultPorts are the default ports of the protocols whose connections
+// can be tunneled, by protocol name.
+var defaultPorts = map[string]string{
+	"ssh":    "22",
+	"ssh-go": "22",
+	"telnet": "23",
+	"rdp":    "3389",
+	"vnc":    "5900",
+	"vnc-go": "5900",
+}
+
+// localProtocols are the protocols which connect to no remote host.
+var localProtocols = map[string]bool{
+	"shell": true,
+}
+
+// targetAddr returns the host:port of the remote host of the given
+// connection, the port defaults to the port of the protocol.
+func targetAddr(jwt *config.JWT) (string, error) {
+	if _, _, err := net.SplitHostPort(jwt.Host); err == nil {
+		return jwt.Host, nil
+	}
+	port, ok := defaultPorts[jwt.Protocol]
+	if !ok {
+		return "", fmt.Errorf("occamy: protocol %s cannot be tunneled", jwt.Protocol)
+	}
+	return net.JoinHostPort(jwt.Host, port), nil
+}
+
+// tunnel relays the connections to a loopback endpoint to a remote
+// target through a chain of SSH jump hosts, as the ProxyJump of OpenSSH,
+// and the dialer of the server. A session connects to the endpoint in
+// place of the target, e.g. again to reconnect or to open a second
+// connection for SFTP, hence the endpoint accepts connections until the
+// session is closed. Only connections of this process are relayed, such
+// that no other local process can use the tunnel of a session.
+type tunnel struct {
+	target  string
+	proto   string   // of the connection to the target
+	base    dialFunc // dials the first jump host or the target
+	egress  *EgressPolicy
+	ln      net.Listener
+	clients []*ssh.Client // from the first to the last jump host
+
+	sent, received uint64 // bytes relayed to and from the target
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// newTunnel connects to the jump hosts of the given connection in order,
+// each through the previous one, and listens on the loopback endpoint to
+// the given target.
+func (s *Server) newTunnel(jwt *config.JWT, target string) (*tunnel, error) {
+	mode, err := hostKeyMode(jwt, s.opts.ProtocolArgs[jwt.Protocol])
+	if err != nil {
+		return nil, err
+	}
+	base := Direct
+	if s.opts.Dialer != nil {
+		base = s.opts.Dialer
+	}
+	t := &tunnel{
+		target: target,
+		proto:  jwt.Protocol,
+		base:   base.Dial,
+		egress: s.opts.Egress,
+		conns:  make(map[net.Conn]struct{}),
+	}
+	for i, hop := range jwt.JumpHosts {
+		addr := jumpAddr(hop)
+		cfg, err := s.jumpConfig(hop, addr, mode)
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		c, err := dialJumpHost(t.dialer("ssh"), addr, cfg)
+		if err != nil {
+			t.Close()
+			return nil, fmt.Errorf("occamy: jump host %d (%s): %w", i+1, addr, err)
+		}
+		t.clients = append(t.clients, c)
+	}
+
+	t.ln, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Close()
+		return nil, plugin.StatusError(protocol.StatusServerError,
+			fmt.Errorf("occamy: listen tunnel endpoint error: %w", err))
+	}
+	t.wg.Add(1)
+	go t.serve()
+	return t, nil
+}
+
+// Addr returns the loopback endpoint of the tunnel.
+func (t *tunnel) Addr() string { return t.ln.Addr().String() }
+
+// dial connects to the given address of the protocol of the target.
+func (t *tunnel) dial(network, addr string) (net.Conn, error) {
+	return t.dialer(t.proto)(network, addr)
+}
+
+// dialer returns the dial function of connections of the given protocol
+// through the last jump host, or by the base dialer if no jump host is
+// connected yet. Every connection is checked by the egress policy, the
+// host is therefore resolved by occamy, not by the jump host.
+func (t *tunnel) dialer(proto string) dialFunc {
+	dial := func(network, addr string) (net.Conn, error) {
+		if len(t.clients) == 0 {
+			return t.base(network, addr)
+		}
+		return t.clients[len(t.clients)-1].Dial(network, addr)
+	}
+	if t.egress == nil {
+		return dial
+	}
+	return t.egress.dialer(proto, dial)
+}
+
+// serve relays the connections to the endpoint until it is closed.
+func (t *tunnel) serve() {
+	defer t.wg.Done()
+	for {
+		nc, err := t.ln.Accept()
+		if err != nil {
+			return
+		}
+		if !ownConn(nc) {
+			log.Printf("tunnel to %s: rejected connection of another process from %s", t.target, nc.RemoteAddr())
+			nc.Close()
+			continue
+		}
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			t.relay(nc)
+		}()
+	}
+}
+
+// relay copies the given connection to the target and back.
+func (t *tunnel) relay(nc net.Conn) {
+	defer nc.Close()
+	rc, err := t.dial("tcp", t.target)
+	if err != nil {
+		log.Printf("tunnel to %s failed: %v", t.target, err)
+		return
+	}
+	defer rc.Close()
+	if !t.track(nc) {
+		return
+	}
+	defer t.untrack(nc)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		n, _ := io.Copy(rc, nc)
+		atomic.AddUint64(&t.sent, uint64(n))
+		done <- struct{}{}
+	}()
+	go func() {
+		n, _ := io.Copy(nc, rc)
+		atomic.AddUint64(&t.received, uint64(n))
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// track registers a relayed connection, it reports false if the tunnel
+// is closed.
+func (t *tunnel) track(nc net.Conn) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conns == nil {
+		return false
+	}
+	t.conns[nc] = struct{}{}
+	return true
+}
+
+func (t *tunnel) untrack(nc net.Conn) {
+	t.mu.Lock()
+	delete(t.conns, nc)
+	t.mu.Unlock()
+}
+
+// Close closes the endpoint, the relayed connections and the jump host
+// connections, and waits until all relays return.
+func (t *tunnel) Close() error {
+	if t.ln != nil {
+		t.ln.Close()
+		defer func() {
+			log.Printf("tunnel to %s closed: %d bytes sent, %d bytes received",
+				t.target, atomic.LoadUint64(&t.sent), atomic.LoadUint64(&t.received))
+		}()
+	}
+	t.mu.Lock()
+	for nc := range t.conns {
+		nc.Close()
+	}
+	t.conns = nil
+	t.mu.Unlock()
+	for i := len(t.clients) - 1; i >= 0; i-- {
+		t.clients[i].Close()
+	}
+	t.wg.Wait()
+	return nil
+}
+
+// jumpAddr returns the host:port of the given jump host.
+func jumpAddr(hop config.JumpHost) string {
+	if _, _, err := net.SplitHostPort(hop.Host); err == nil {
+		return hop.Host
+	}
+	return net.JoinHostPort(hop.Host, "22")
+}
+
+// jumpConfig returns the configuration of the SSH client of the given
+// jump host. A certificate of Options.CertificateAuthority is used if
+// the jump host has neither a password nor a private key, and its host
+// key is verified by Options.HostKeys if set.
+func (s *Server) jumpConfig(hop config.JumpHost, addr string, mode HostKeyMode) (*ssh.ClientConfig, error) {
+	cfg := &ssh.ClientConfig{
+		User:            hop.Username,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         dialTimeout,
+	}
+	if cfg.User == "" {
+		return nil, plugin.StatusError(protocol.StatusClientBadRequest,
+			fmt.Errorf("occamy: jump host %s requires a username", addr))
+	}
+	if s.opts.HostKeys != nil {
+		cfg.HostKeyCallback = s.opts.HostKeys.callback(mode)
+		cfg.HostKeyAlgorithms = s.opts.HostKeys.algorithms(addr)
+	}
+
+	creds := sshutil.Credentials{
+		Password:   hop.Password,
+		PrivateKey: hop.PrivateKey,
+		Passphrase: hop.Passphrase,
+	}
+	var err error
+	if creds.PrivateKey == "" && creds.Password == "" && s.opts.CertificateAuthority != nil {
+		creds.PrivateKey, creds.Certificate, err = s.opts.CertificateAuthority.IssueUser("ssh-go", hop.Username)
+		if err != nil {
+			return nil, certError(err)
+		}
+	}
+	cfg.Auth, err = sshutil.Auth(creds)
+	if err != nil {
+		return nil, plugin.StatusError(protocol.StatusClientBadRequest,
+			fmt.Errorf("occamy: jump host %s: %w", addr, err))
+	}
+	if len(cfg.Auth) == 0 {
+		return nil, plugin.StatusError(protocol.StatusClientUnauthorized,
+			fmt.Errorf("occamy: jump host %s requires a password or a private key", addr))
+	}
+	return cfg, nil
+}
+
+// dialJumpHost connects to the SSH server of the given host:port by the
+// given dial function.
+func dialJumpHost(dial dialFunc, addr string, cfg *ssh.ClientConfig) (*ssh.Client, error) {
+	nc, err := dial("tcp", addr)
+	if err != nil {
+		return nil, plugin.StatusError(protocol.StatusUpstreamUnavailable, err)
+	}
+	// the connections through jump hosts do not support deadlines
+	stop := time.AfterFunc(dialTimeout, func() { nc.Close() })
+	defer stop.Stop()
+
+	c, err := sshutil.NewClient(nc, addr, cfg)
+	if err == nil {
+		return c, nil
+	}
+	nc.Close()
+	var serr interface{ Status() protocol.Status }
+	switch {
+	case errors.As(err, &serr): // host key and authentication errors
+		return nil, err
+	case !stop.Stop():
+		return nil, plugin.StatusError(protocol.StatusUpstreamTimeout,
+			errors.New("ssh: handshake timed out"))
+	}
+	return nil, plugin.StatusError(protocol.StatusUpstreamError, err)
+}
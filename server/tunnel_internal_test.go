@@ -0,0 +1,110 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/internal/config"
+)
+
+func TestTunnel_Connections(t *testing.T) {
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	defer target.Close()
+	go func() {
+		for {
+			nc, err := target.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(nc, nc)
+				nc.Close()
+			}()
+		}
+	}()
+
+	s := &Server{}
+	tun, err := s.newTunnel(&config.JWT{Protocol: "telnet", Host: target.Addr().String()}, target.Addr().String())
+	if err != nil {
+		t.Fatalf("new tunnel error: %v", err)
+	}
+	defer tun.Close()
+
+	// connections of this process are relayed, e.g. reconnects
+	for i := 0; i < 2; i++ {
+		nc, err := net.DialTimeout("tcp", tun.Addr(), time.Second)
+		if err != nil {
+			t.Fatalf("connect tunnel error: %v", err)
+		}
+		defer nc.Close()
+		nc.SetDeadline(time.Now().Add(5 * time.Second))
+		if _, err := nc.Write([]byte("occamy")); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+		buf := make([]byte, 6)
+		if _, err := io.ReadFull(nc, buf); err != nil || string(buf) != "occamy" {
+			t.Fatalf("relay of connection %d: got %q, %v", i+1, buf, err)
+		}
+	}
+
+	// other local processes cannot use the tunnel
+	cmd := exec.Command(os.Args[0], "-test.run=^TestTunnel_OtherProcess$")
+	cmd.Env = append(os.Environ(), "OCCAMY_TUNNEL_ADDR="+tun.Addr())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("connection of another process: %v\n%s", err, out)
+	}
+}
+
+// TestTunnel_OtherProcess connects to the tunnel of OCCAMY_TUNNEL_ADDR,
+// it is run in another process by TestTunnel_Connections.
+func TestTunnel_OtherProcess(t *testing.T) {
+	addr := os.Getenv("OCCAMY_TUNNEL_ADDR")
+	if addr == "" {
+		t.Skip("run by TestTunnel_Connections")
+	}
+	nc, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		return // the endpoint is closed
+	}
+	defer nc.Close()
+	nc.SetDeadline(time.Now().Add(5 * time.Second))
+	nc.Write([]byte("occamy"))
+	if _, err := io.ReadFull(nc, make([]byte, 6)); err == nil {
+		t.Fatalf("the tunnel relays connections of other processes")
+	}
+}
+
+func TestTunnel_EgressAtDial(t *testing.T) {
+	policy, err := NewEgressPolicy("deny", []config.EgressRule{
+		{Action: "allow", Protocols: []string{"telnet"}, Hosts: []string{"127.0.0.1"}, Ports: []string{"2323"}},
+	})
+	if err != nil {
+		t.Fatalf("new egress policy error: %v", err)
+	}
+	s := &Server{opts: Options{Egress: policy}}
+	tun, err := s.newTunnel(&config.JWT{Protocol: "telnet", Host: "127.0.0.1:2323"}, "127.0.0.1:2323")
+	if err != nil {
+		t.Fatalf("new tunnel error: %v", err)
+	}
+	defer tun.Close()
+
+	// every connection of the tunnel is checked, not only the target
+	for _, dial := range []dialFunc{tun.dial, tun.dialer("ssh")} {
+		_, err := dial("tcp", "127.0.0.1:22")
+		if !errors.Is(err, ErrEgressDenied) {
+			t.Fatalf("dial a forbidden address: got %v", err)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright 2019 Changkun Ou. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package server_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"changkun.de/x/occamy/client"
+	"changkun.de/x/occamy/internal/protocol"
+	_ "changkun.de/x/occamy/plugin/telnet" // registers telnet
+	"changkun.de/x/occamy/server"
+	"golang.org/x/crypto/ssh"
+)
+
+// jumpHost is an SSH server which forwards direct-tcpip channels
+type jumpHost struct {
+	ln       net.Listener
+	forwards chan string
+}
+
+func newJumpHost(t *testing.T, password string) *jumpHost {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate host key error: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("create signer error: %v", err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, p []byte) (*ssh.Permissions, error) {
+			if string(p) != password {
+				return nil, errors.New("wrong password")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	j := &jumpHost{ln: ln, forwards: make(chan string, 10)}
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go j.serve(nc, config)
+		}
+	}()
+	return j
+}
+
+func (j *jumpHost) serve(nc net.Conn, config *ssh.ServerConfig) {
+	defer nc.Close()
+	_, chans, reqs, err := ssh.NewServerConn(nc, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nch := range chans {
+		var req struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if nch.ChannelType() != "direct-tcpip" || ssh.Unmarshal(nch.ExtraData(), &req) != nil {
+			nch.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+		addr := net.JoinHostPort(req.Host, strconv.Itoa(int(req.Port)))
+		rc, err := net.Dial("tcp", addr)
+		if err != nil {
+			nch.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		ch, creqs, err := nch.Accept()
+		if err != nil {
+			rc.Close()
+			continue
+		}
+		j.forwards <- addr
+		go ssh.DiscardRequests(creqs)
+		go func() {
+			defer ch.Close()
+			defer rc.Close()
+			go io.Copy(rc, ch)
+			io.Copy(ch, rc)
+		}()
+	}
+}
+
+func (j *jumpHost) addr() string { return j.ln.Addr().String() }
+
+func TestTunnel_JumpHosts(t *testing.T) {
+	keys, err := server.OpenHostKeys(filepath.Join(t.TempDir(), "known_hosts"))
+	if err != nil {
+		t.Fatalf("open host keys error: %v", err)
+	}
+	s, err := server.New(server.Options{
+		Mode:      "test",
+		JWTSecret: "secret",
+		Client:    true,
+		ClientDir: t.TempDir(),
+		HostKeys:  keys,
+	})
+	if err != nil {
+		t.Fatalf("create server error: %v", err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	// the target is only reachable through the second jump host in
+	// production, here it only accepts a connection
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	defer target.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		nc, err := target.Accept()
+		if err == nil {
+			accepted <- nc
+		}
+	}()
+	bastion, inner := newJumpHost(t, "bastion"), newJumpHost(t, "inner")
+
+	login := func(hops string) string {
+		t.Helper()
+		body := `{"protocol":"telnet","host":"` + target.Addr().String() + `","jump_hosts":` + hops + `}`
+		resp, err := http.Post(ts.URL+"/api/v1/login", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("login error: %v", err)
+		}
+		defer resp.Body.Close()
+		var out struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode token error: %v", err)
+		}
+		return out.Token
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// a jump host that rejects the credentials aborts the connection
+	token := login(`[{"host":"` + bastion.addr() + `","username":"occamy","password":"bastion"},` +
+		`{"host":"` + inner.addr() + `","username":"occamy","password":"wrong"}]`)
+	c, err := client.Dial(ctx, ts.URL, token, nil)
+	if err == nil {
+		<-c.Done()
+		err = c.Err()
+	}
+	var serr *client.ServerError
+	if !errors.As(err, &serr) || serr.Status != protocol.StatusClientUnauthorized {
+		t.Fatalf("connect with wrong jump host password: got %v", err)
+	}
+	if !strings.Contains(serr.Message, "jump host 2") {
+		t.Fatalf("error message: got %q", serr.Message)
+	}
+	<-bastion.forwards // to the inner jump host
+
+	token = login(`[{"host":"` + bastion.addr() + `","username":"occamy","password":"bastion"},` +
+		`{"host":"` + inner.addr() + `","username":"occamy","password":"inner"}]`)
+	c, err = client.Dial(ctx, ts.URL, token, nil)
+	if err != nil {
+		t.Fatalf("connect through jump hosts error: %v", err)
+	}
+	defer c.Close()
+	select {
+	case nc := <-accepted:
+		nc.Close()
+	case <-ctx.Done():
+		t.Fatalf("the target was not connected")
+	}
+	if got := <-bastion.forwards; got != inner.addr() {
+		t.Fatalf("bastion forwarded to %s, want the inner jump host %s", got, inner.addr())
+	}
+	if got := <-inner.forwards; got != target.Addr().String() {
+		t.Fatalf("inner jump host forwarded to %s, want the target %s", got, target.Addr())
+	}
+
+	// the host keys of the jump hosts are trusted on first use
+	if len(keys.List()) != 2 {
+		t.Fatalf("host keys of jump hosts: got %+v", keys.List())
+	}
+}
+
+func TestTunnel_OwnerLeaves(t *testing.T) {
+	s, err := server.New(server.Options{
+		Mode:      "test",
+		JWTSecret: "secret",
+		Client:    true,
+		ClientDir: t.TempDir(),
+		Dialer:    server.Direct,
+	})
+	if err != nil {
+		t.Fatalf("create server error: %v", err)
+	}
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error: %v", err)
+	}
+	defer target.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		nc, err := target.Accept()
+		if err == nil {
+			accepted <- nc
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cred := client.Credentials{Protocol: "telnet", Host: target.Addr().String()}
+	owner, err := client.Connect(ctx, ts.URL, cred, nil)
+	if err != nil {
+		t.Fatalf("connect owner error: %v", err)
+	}
+	var nc net.Conn
+	select {
+	case nc = <-accepted:
+		defer nc.Close()
+	case <-ctx.Done():
+		t.Fatalf("the target was not connected")
+	}
+	guest, err := client.Connect(ctx, ts.URL, cred, nil)
+	if err != nil {
+		t.Fatalf("connect guest error: %v", err)
+	}
+
+	// the tunnel is closed with the session, not when the owner leaves
+	owner.Close()
+	nc.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, err = ioutil.ReadAll(nc)
+	var nerr net.Error
+	if !errors.As(err, &nerr) || !nerr.Timeout() {
+		t.Fatalf("the tunnel was closed while a user remains: %v", err)
+	}
+
+	guest.Close()
+	nc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := ioutil.ReadAll(nc); err != nil {
+		t.Fatalf("the tunnel was not closed with the session: %v", err)
+	}
+}